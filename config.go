@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// applyConfigFile reads a JSON object mapping flag names to values, such as
+// {"filedir": "sprites", "trim": true, "padding": "2,2"}, and sets each flag
+// that was not given on the command line, so command-line flags override the
+// file. Values are strings, numbers or booleans, parsed as they would be on
+// the command line. Unknown flag names are an error.
+func applyConfigFile(flags *flag.FlagSet, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var config map[string]json.RawMessage
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&config); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", filename, err)
+	}
+
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "config" || flags.Lookup(name) == nil {
+			return fmt.Errorf("config %s: unknown option %q", filename, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		value, err := configValue(config[name])
+		if err != nil {
+			return fmt.Errorf("config %s: option %q: %w", filename, name, err)
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("config %s: option %q: %w", filename, name, err)
+		}
+	}
+	return nil
+}
+
+// configValue converts a JSON value from the config file to the string the
+// corresponding flag would be given on the command line.
+func configValue(raw json.RawMessage) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	default:
+		return "", fmt.Errorf("value %s must be a string, number or boolean", strings.TrimSpace(string(raw)))
+	}
+}
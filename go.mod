@@ -1,3 +1,7 @@
 module texturepacker
 
-go 1.22.4
+go 1.26.0
+
+require golang.org/x/image v0.46.0
+
+require golang.org/x/sys v0.48.0 // indirect
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"texturepacker/packer"
+)
+
+// exitInterrupted is the exit status of a run stopped by SIGINT or SIGTERM,
+// 128 plus the number of SIGINT, as shells report an interrupted command.
+const exitInterrupted = 130
+
+// interruptContext returns a context that is cancelled on the first SIGINT
+// or SIGTERM, with the signal as its cause, so in-flight loading, packing
+// and saving stop at their next check. The signal also removes the pending
+// temporary files straight away, and no output is committed after it. A
+// second signal, for work that does not stop promptly, exits at once.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		packer.RemovePendingOutputs()
+		cancel(fmt.Errorf("%w by %s signal", packer.ErrInterrupted, sig))
+		fmt.Fprintln(os.Stderr, "Interrupted; stopping. Interrupt again to quit at once.")
+		<-signals
+		os.Exit(exitInterrupted)
+	}()
+	return ctx
+}
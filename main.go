@@ -1,216 +1,923 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"image"
-	"image/draw"
+	"go/token"
+	"image/color"
 	"image/png"
 	"os"
 	"path/filepath"
-	"sort"
-	"sync"
-)
-
-// Rectangle represents an image with an ID, width, height, and the image data itself.
-type Rectangle struct {
-	ID     int
-	Width  int
-	Height int
-	Image  image.Image
-}
+	"regexp"
+	"strings"
 
-// Shelf represents a horizontal shelf for packing rectangles in the texture atlas.
-type Shelf struct {
-	Y      int
-	Height int
-	Width  int
-}
+	"texturepacker/packer"
+)
 
 // main is the entry point of the program. It parses command-line flags,
 // collects image files from a directory, loads and processes them,
-// generates a texture atlas, saves it as 'atlas.png', and prints atlas information.
+// generates a texture atlas, saves it as 'atlas.png' along with its
+// 'atlas.json' manifest, and prints atlas information. With -groupby dir,
+// one atlas and manifest is produced per immediate parent directory.
+// With -timeout, loading, packing and saving are abandoned once the
+// timeout elapses. With -watch the atlases are built again whenever the
+// images or the files the options are read from change, until the program
+// is interrupted. SIGINT or SIGTERM stops the run, discarding the outputs
+// not yet complete, with exit status 130; any other error that ends the run
+// exits with status 1.
 func main() {
-	maxHeight, filedir := parseFlags()
-	files, err := collectImageFiles(filedir)
+	packer.RegisterSVGFormat()
+	args := os.Args[1:]
+	cmd := parseFlags(args)
+	opts := cmd.Options
+	stopProfiles, err := startProfiles(cmd.CPUProfile, cmd.MemProfile)
 	if err != nil {
-		fmt.Println("Error collecting image files:", err)
-		return
+		packer.LogError("starting CPU profile", err)
+		os.Exit(1)
+	}
+	ctx := interruptContext()
+	switch {
+	case cmd.Watch:
+		builds := 0
+		packer.Watch(ctx, opts, cmd.Inputs, func(ctx context.Context) {
+			// Every rebuild parses the flags again, so edits to -config,
+			// -crops and the other inputs apply as well as to the images.
+			opts := opts
+			if builds++; builds > 1 {
+				opts = parseFlags(args).Options
+			}
+			// A failed build is reported, and the next change rebuilds.
+			if _, err := packer.Run(ctx, opts); err != nil {
+				packer.ReportError(err)
+			}
+		})
+	case cmd.CompareTrim:
+		err = packer.CompareTrim(ctx, opts)
+	default:
+		_, err = packer.Run(ctx, opts)
+	}
+	if err != nil {
+		packer.ReportError(err)
+	}
+	if err := stopProfiles(); err != nil {
+		packer.LogError("", err)
+	}
+	if ctx.Err() != nil {
+		os.Exit(exitInterrupted)
 	}
-
-	rectangles, err := loadImages(files)
 	if err != nil {
-		fmt.Println("Error loading images:", err)
-		return
+		os.Exit(1)
 	}
+}
 
-	atlas, packedRectangles := generateAtlas(rectangles, maxHeight)
+// command is what the command line asks for: the Options used to build the
+// atlas, the files other than images they are read from, which -watch
+// watches, and how the program runs the build.
+type command struct {
+	Options packer.Options
+	Inputs  []string
+	// CPUProfile and MemProfile are the files profiles are written to.
+	CPUProfile string
+	MemProfile string
+	// Watch rebuilds the atlases whenever an input changes, and CompareTrim
+	// builds every atlas both untrimmed and trimmed.
+	Watch       bool
+	CompareTrim bool
+}
+
+// parseFlags parses the command-line arguments into the command to run.
+// With -config, options missing from the command line are read from a file.
+func parseFlags(args []string) command {
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	configFile := flags.String("config", "", "JSON file of option values keyed by flag name; flags on the command line take precedence")
+	tpsFile := flags.String("tps", "", "TexturePacker .tps settings file whose padding, max size and trim mode apply where no flag or -config sets them")
+	maxWidth := flags.Int("maxwidth", 1080, "Maximum width of the texture atlas")
+	maxHeight := flags.Int("maxheight", 1080, "Maximum height of the texture atlas")
+	filedir := flags.String("filedir", "", "Directory containing image files, or a comma-separated list of directories")
+	twoPass := flags.Bool("twopass", false, "Run a trial pack and reorder sprites by wasted space before the final pack")
+	groupBy := flags.String("groupby", "", "Produce one atlas per group; \"dir\" groups by immediate parent directory")
+	minify := flags.Bool("minify", false, "Write the smallest lossless PNG encoding of the atlas and report the saving")
+	var padding packer.Padding
+	flags.Var(&padding, "padding", "Gap in pixels between packed sprites, either N or X,Y for different horizontal and vertical gaps")
+	borderPadding := flags.Int("borderpadding", 0, "Transparent margin in pixels between the sprites and every edge of the atlas")
+	extrudeBy := flags.Int("extrude", 0, "Repeat the edge pixels of every sprite this many pixels outward into the padding around it, so filtering at its edges samples its own colors (0 disables)")
+	out := flags.String("out", packer.DefaultAtlasName+".png", "Filename of the atlas image, whose extension, .png, .gif, .jpg or .jpeg, chooses the format; groups and pages get suffixes before it")
+	nameTemplate := flags.String("nametemplate", "", "Output filename template using {name}, {group}, {page} and {type} tokens, e.g. \"{name}_{page}.png\"")
+	manifestTemplate := flags.String("manifest", "", "Manifest filename template using the -nametemplate tokens, e.g. \"data/{name}.json\" (default: each atlas image's name with the -format extension)")
+	var outputOpts packer.OutputOptions
+	flags.Var(&outputOpts, "outputopts", "Encoder options for the output format as comma-separated key=value pairs: compression=best|default|fast|none for PNG, colors=N and dither=true|false for GIF, quality=N for JPEG")
+	gifColors := flags.Int("gifcolors", 256, "Most colors of each GIF atlas, written when the atlas filename ends in .gif, from 2 to 256 including the transparent one")
+	gifDither := flags.Bool("gifdither", false, "Dither GIF atlases that have more colors than -gifcolors with Floyd-Steinberg error diffusion")
+	quality := flags.Int("quality", packer.DefaultJPEGQuality, "Quality of JPEG atlases, from 1 to 100")
+	strips := flags.Bool("strips", false, "Lay out each animation as a horizontal strip on its own row")
+	animRegex := flags.String("animregex", packer.DefaultAnimRegex, "Regexp matched against each file's base name; its first capture group names the animation")
+	bitDepth := flags.Int("bitdepth", 8, "Bits per channel of the atlas: 8 or 16")
+	targetDepth := flags.Int("targetdepth", 0, "Convert every sprite to this many bits per channel, 8 or 16, warning when precision is lost, and write the atlas at that depth (0 keeps decoded pixels as they are)")
+	channels := flags.String("channels", packer.ChannelsRGBA, "Channels of the atlas: rgba, or a single channel holding the sprites' \"alpha\" or \"gray\" luminance")
+	expectCount := flags.Int("expectcount", 0, "Fail unless exactly this many sprites are packed (0 disables the check)")
+	var expectSize packer.Size
+	flags.Var(&expectSize, "expectsize", "Fail unless every image loaded is exactly WxH pixels, listing those that are not")
+	plan := flags.Bool("plan", false, "Read only image headers, print the planned atlas sizes, and exit without writing anything")
+	trim := flags.Bool("trim", false, "Trim fully transparent borders from each image before packing, leaving out images with nothing left")
+	trimSolid := flags.Bool("trimsolid", false, "With -trim, also trim borders of the single color found in each image's top-left pixel")
+	trimTolerance := flags.Int("trimtolerance", 0, "Maximum per-channel difference (0-255) for a pixel to match the -trimsolid border color")
+	trimAlpha := flags.Int("trimalpha", 0, "Highest alpha (0-255) a pixel may have and still be trimmed as transparent; any more opaque pixel keeps its row and column")
+	compareTrimFlag := flags.Bool("comparetrim", false, "Build every atlas both untrimmed and trimmed, as \"atlas_untrimmed\" and \"atlas_trimmed\" outputs, and report the difference in size")
+	cpuProfile := flags.String("cpuprofile", "", "Write a pprof CPU profile of the run to this file, with samples labeled by phase: load, pack, draw or save")
+	memProfile := flags.String("memprofile", "", "Write a pprof heap profile to this file when the run ends")
+	statsFile := flags.String("stats", "", "Write atlas generation statistics as JSON to this file, e.g. stats.json")
+	sidecarFile := flags.String("sidecar", "", "JSON file mapping sprite names to metadata, such as pivots, copied into the manifest")
+	overwrite := flags.Bool("overwrite", true, "Replace existing output files; when false, fail instead of overwriting them")
+	var cell packer.Size
+	flags.Var(&cell, "cell", "Pack into a uniform grid of WxH cells, scaling oversized sprites down to fit and centering smaller ones")
+	var resize packer.ResizeRules
+	flags.Var(&resize, "resize", "Scale sprites whose name matches a glob to fit within a size, as PATTERN=WxH, e.g. \"icons/*=64x64\"; repeat for more rules")
+	deterministic := flags.Bool("deterministic", false, "Load images one at a time instead of concurrently, so warnings and progress come in file order, and record pack times in -stats as zero so it is reproducible too")
+	alphaBleed := flags.Int("alphableed", 0, "Spread sprite colors this many pixels into neighboring transparent pixels before drawing (0 disables)")
+	timeout := flags.Duration("timeout", 0, "Give up if loading, packing and saving take longer than this, e.g. 30s (0 disables)")
+	alpha := flags.String("alpha", packer.AlphaStraight, "Alpha of the written atlas: straight, premultiplied, or both to also write a premultiplied copy with a \""+packer.PremultipliedSuffix+"\" suffix")
+	reportLargest := flags.Int("reportlargest", 0, "List this many of each atlas's largest sprites by area and by footprint including padding (0 disables)")
+	jsonPretty := flags.Bool("jsonpretty", true, "Write indented manifests; when false, write compact JSON")
+	sortMode := flags.String("sort", packer.SortHeight, "Order sprites are packed in, largest first: by \"height\", \"maxside\" (the longer of width and height) or \"area\"")
+	packerName := flags.String("packer", packer.PackerMaxRects, "Packer placing sprites: \"maxrects\" puts each sprite in the free space where it ends highest, filling gaps above short sprites, \"shelf\" fills rows of fixed-height shelves; -twopass, -compact, -shelffit and -shelfbucket choose shelf unless it is set")
+	shelfFit := flags.String("shelffit", packer.ShelfFitFirst, "Shelf chosen for each sprite: \"first\" with room, or \"best\" leaving the least unused height")
+	maxPerPage := flags.Int("maxperpage", 0, "Start a new atlas page once a page holds this many sprites (0 disables)")
+	textureArray := flags.Bool("texturearray", false, "Write the pages of each atlas as texture array layers of identical power-of-two size, with one manifest")
+	polygon := flags.Bool("polygon", false, "Trace each sprite's visible silhouette and write it to the manifest as polygons")
+	polygonTolerance := flags.Float64("polygontolerance", packer.DefaultPolygonTolerance, "Maximum distance in pixels a simplified -polygon outline may stray from the silhouette")
+	spriteAlign := flags.Int("spritealign", 0, "Place every sprite's top-left corner on a multiple of this many pixels, e.g. 4 or 8 (0 disables)")
+	compact := flags.Bool("compact", false, "After shelf packing, move whole bottom shelves into gaps on the shelves above when they fit, shortening the atlas")
+	tieBreak := flags.String("tiebreak", packer.TieBreakSmallestY, "How -shelffit best chooses among equally good shelves: \"smallest-y\", \"smallest-x\", \"topleft\" or \"bottomleft\"")
+	allowRotation := flags.Bool("allowrotation", false, "Let the packer turn a sprite a quarter turn clockwise: the shelf packer when it fits a shelf that way but not as it is, maxrects when that places it better")
+	shelfBucket := flags.Int("shelfbucket", 0, "Round the height of each new shelf up to a multiple of this many pixels so similar heights share shelves (0 to disable)")
+	growth := flags.String("growth", packer.GrowthWidth, "Direction the packer grows the atlas: \"width\" fills rows then grows down, \"height\" fills columns then grows right, \"square\" keeps it near square")
+	verify := flags.Bool("verify", false, "Check every trim against its source image, then read back each saved atlas and manifest, and fail if they are inconsistent")
+	metadata := flags.Bool("metadata", false, "Copy each PNG's DPI (pHYs) and text chunks into its manifest entry")
+	recordOptions := flags.Bool("recordoptions", false, "Record the tool version, packing algorithm and the value of every flag in each manifest's meta section")
+	autoSizeFlag := flags.Bool("autosize", false, "Use the smallest of the page sizes 256, 512, 1024, 2048 and 4096 that holds every sprite on one page as the -maxwidth and -maxheight bounds")
+	requirePOT := flags.Bool("requirepot", false, "Fail instead of writing an atlas whose width or height is not a power of two")
+	var svgSize packer.Size
+	flags.Var(&svgSize, "svgsize", "Rasterize SVG sources to fit WxH pixels, e.g. 64x64, keeping their aspect ratio (default: their own width and height)")
+	var minSize packer.Size
+	flags.Var(&minSize, "minsize", "Smallest atlas size as WxH, e.g. 256x256; smaller atlases are padded with transparent pixels")
+	var reserve packer.Size
+	flags.Var(&reserve, "reserve", "Keep a blank WxH region, e.g. 64x64, in the top-left corner of every atlas and record it in the manifest")
+	manifestFormat := flags.String("format", packer.FormatJSON, "Manifest format: \"json\", \"ndjson\" for a header line and one line per sprite, \"go\" for a Go source file declaring the sprite rectangles, \"xml\" for generic XML of sprite placements, \"minimal\" for JSON mapping sprite names to [x,y,w,h] alone, or \"plist\" for a TexturePacker cocos2d property list")
+	goPackage := flags.String("gopackage", packer.DefaultGoPackage, "Package declared by manifests written with -format go")
+	includeEmpty := flags.Bool("includeempty", false, "Keep images with no visible pixels as placeholder sprites; with -trim they are trimmed to a single pixel")
+	skipEmpty := flags.Bool("skipempty", false, "Leave out images with no visible pixels, even without -trim, and list them on stderr")
+	compareAtlas := flags.String("compareatlas", "", "Reference atlas image, or directory of them, to compare the pixels of the written atlases with; exits with status 1 if more than -comparetolerance pixels differ")
+	compareTolerance := flags.Int("comparetolerance", 0, "Most pixels an atlas may differ from its -compareatlas reference in")
+	compareDiff := flags.Bool("comparediff", false, "With -compareatlas, write an image marking the differing pixels beside each atlas that differs, with a \"_diff\" suffix")
+	compareManifest := flags.String("comparemanifest", "", "Reference manifest, or directory of them, to compare the written manifests with; exits with status 1 if sprites moved or changed")
+	sdf := flags.Bool("sdf", false, "Replace each sprite with a single-channel signed distance field of its alpha silhouette, grown by -sdfspread on every side")
+	sdfSpread := flags.Int("sdfspread", packer.DefaultSDFSpread, "Distance in pixels over which an -sdf field falls off on either side of the edge")
+	maskFile := flags.String("maskshape", "", "Image whose fully opaque pixels are the only place sprites are packed, e.g. a circle for a round atlas; every atlas takes its size")
+	backgroundFlag := flags.String("background", "", "Color to fill each atlas with before drawing the sprites, as \"#rrggbbaa\" with straight alpha or \"#rrggbb\" (default: transparent)")
+	canvasFile := flags.String("canvas", "", "Image to draw each atlas onto instead of a blank one; its full size is the space sprites are packed into")
+	listFormats := flags.Bool("listformats", false, "Print the image formats that can be read and written, then exit")
+	reportSchema := flags.Bool("reportschema", false, "Print the JSON Schema of the JSON manifest format written by this build, then exit")
+	skipBad := flags.Bool("skipbad", false, "Leave out image files that cannot be read or decoded, listing them on stderr, instead of failing the run")
+	scale := flags.Int("scale", 1, "Enlarge every sprite by this integer factor with nearest-neighbor sampling before packing, for pixel art")
+	mirrorHalves := flags.Bool("mirrorhalves", false, "Pack only the left or top half of sprites the sidecar marks as mirrorable, recording the mirror in the manifest")
+	mipLevelCount := flags.Int("miplevels", 0, "Also write each atlas halved this many times, as \"_mip1\" to \"_mipN\" images with the same layout, listed in the manifest (0 disables)")
+	tileSize := flags.Int("tilesize", 0, "Also slice each atlas into tiles of this many pixels per side, written as \"_tile_COLUMN_ROW\" images and listed in the manifest (0 disables)")
+	preview := flags.Int("preview", 0, "Also write each atlas downscaled to at most this many pixels per side, e.g. 512, with a \""+packer.PreviewSuffix+"\" suffix (0 disables)")
+	debug := flags.Bool("debug", false, "Also write each atlas with sprite borders colored by category, with a \""+packer.DebugSuffix+"\" suffix, and print the color legend")
+	debugCategory := flags.String("debugcategory", "", "Regexp matched against each sprite name; its first capture group is the -debug category (default: the sprite's directory)")
+	retries := flags.Int("retries", 0, "Retry opening or reading an image this many times, with doubling delays from 100ms, before giving up; missing and undecodable files are not retried")
+	dumpTrimmed := flags.String("dumptrimmed", "", "Directory to also write each sprite to as its own PNG, exactly as packed after trimming")
+	glyphsFile := flags.String("glyphs", "", "JSON file of glyph metadata (character code, offsets and advance per sprite, plus kerning) to write a BMFont .fnt beside each atlas")
+	maxPages := flags.Int("maxpages", 0, "Fail, listing the sprites left over, if an atlas needs more than this many pages (0 disables)")
+	dedup := flags.Bool("dedup", false, "Pack sprites with identical decoded pixels once, pointing every one's manifest entry at the same region")
+	uniqueNames := flags.Bool("uniquenames", false, "Fail before packing if sprites in different directories share a base name, listing every collision")
+	origin := flags.String("origin", packer.OriginTopLeft, "Point manifest positions are measured from: the \"topleft\" or \"bottomleft\" corner of the atlas, with y up from the bottom, or its \"center\"")
+	pathMode := flags.String("pathmode", packer.PathModeRelative, "How sprites are named in the manifest: \"base\" file name, path \"relative\" to -filedir, or \"absolute\" path")
+	nameRegex := flags.String("nameregex", "", "Regexp replaced in each sprite's name, after -pathmode, before it is written to the manifest, e.g. \"^sprites/\"")
+	nameReplace := flags.String("namereplace", "", "Replacement for -nameregex matches, which may refer to capture groups as $1 or ${name}")
+	nameConventionsFlag := flags.String("nameconventions", "", "Comma-separated filename suffix conventions to read from sprite names and strip: scale (@2x), priority (@p3), nineslice (.9)")
+	var since packer.Since
+	flags.Var(&since, "modifiedsince", "Pack only images modified since a time, given as a duration back from now such as \"2h\" or a time such as \"2024-05-01\" or RFC 3339")
+	recursive := flags.Bool("recursive", true, "Collect images from the subdirectories of -filedir as well as its top level")
+	includeFlag := flags.String("include", "", "Comma-separated glob patterns, such as \"ui_*.png\", of which an image's base name must match one to be packed (default: all images)")
+	excludeFlag := flags.String("exclude", "", "Comma-separated glob patterns, such as \"*_mask.png\", of which an image's base name must match none to be packed")
+	skipFile := flags.String("skipfile", "", "File listing paths under -filedir, one per line, to leave out of the atlas")
+	cropsFile := flags.String("crops", "", "JSON file mapping source sheets to lists of named crop rectangles, each packed as its own sprite")
+	placementDumpFlag := flags.Bool("placementdump", false, "Write a sorted, line-per-sprite text file of placements beside each manifest, for diffing atlas changes in code review")
+	verbose := flags.Bool("verbose", false, "Print a packing summary of each atlas and of the run: sprite area against atlas area, shelves and the largest free gap")
+	minUtilization := flags.Float64("minutilization", 0, "Exit with an error if the sprites cover less than this fraction, from 0 to 1, of the total atlas area (0 disables)")
+	dumpFree := flags.Bool("dumpfree", false, "List the unused regions of each atlas, largest first, with their share of its area")
+	freeRegionsFlag := flags.Bool("freeregions", false, "Record the unused regions of each atlas in its manifest as \"free\", for allocating sprites into them at runtime")
+	logJSON := flags.Bool("logjson", false, "Log warnings and errors to stderr as JSON lines instead of plain text")
+	frames := flags.Bool("frames", false, "Split animated PNG (APNG) and WebP sources into one sprite per frame, recording frame order and delays in the manifest")
+	merge := flags.String("merge", "", "Comma-separated manifests of existing atlases whose sprites are repacked into new atlases, instead of reading -filedir")
+	spatialIndex := flags.Int("spatialindex", 0, "Add a grid index to each manifest listing the sprites touching every cell of this many pixels square (0 disables)")
+	emitQuads := flags.Bool("emitquads", false, "Add each sprite's four vertex positions, relative to its pivot, and UVs to its manifest entry, ready for a vertex buffer")
+	averageColorFlag := flags.Bool("averagecolor", false, "Add each sprite's mean color over its visible pixels, as \"#rrggbbaa\", to its manifest entry")
+	rle := flags.Bool("rle", false, "Add each sprite's run-length analysis (runs of a single color per row, longest run, single-color rows) to its manifest entry")
+	bundle := flags.Bool("bundle", false, "Also write each atlas image and its JSON manifest together as a single .tpb bundle file")
+	var maxMemory packer.ByteSize
+	flags.Var(&maxMemory, "maxmemory", "Estimated memory for decoded images, e.g. 512M, above which each image is reloaded as it is drawn instead of kept in memory (0 disables)")
+	watchFlag := flags.Bool("watch", false, "Keep running and rebuild the atlases whenever an image under -filedir, or an input such as -config, -sidecar or -crops, is added, changed or removed")
+	progress := flags.Bool("progress", false, "Print a line to stderr as each image is loaded and placed")
+	flags.Parse(args)
 
-	if err := saveAtlas("atlas.png", atlas); err != nil {
-		fmt.Println("Error saving atlas:", err)
-		return
+	if *configFile != "" {
+		if err := applyConfigFile(flags, *configFile); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+	}
+	if *logJSON {
+		packer.EnableJSONLogging(os.Stderr)
 	}
 
-	printAtlasInfo(atlas.Bounds().Max.X, atlas.Bounds().Max.Y, packedRectangles)
-}
+	if *listFormats {
+		packer.PrintFormats()
+		os.Exit(0)
+	}
+	if *reportSchema {
+		if err := packer.PrintManifestSchema(); err != nil {
+			packer.LogError("", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
-// parseFlags parses command-line flags to retrieve the maximum height
-// of the texture atlas and the directory containing image files.
-func parseFlags() (int, string) {
-	maxHeight := flag.Int("maxheight", 1080, "Maximum height of the texture atlas")
-	filedir := flag.String("filedir", "", "Directory containing image files")
-	flag.Parse()
+	if *tpsFile != "" {
+		if err := applyTPSFile(flags, *tpsFile); err != nil {
+			packer.LogError("", err)
+			os.Exit(1)
+		}
+	}
 
-	if *filedir == "" {
+	fileDirs := packer.SplitList(*filedir)
+	if len(fileDirs) == 0 && *merge == "" {
 		fmt.Println("Please provide a valid directory using -filedir flag.")
 		os.Exit(1)
 	}
+	if *merge != "" && (*filedir != "" || *cropsFile != "" || *skipFile != "" || *groupBy != "" || !since.IsZero() || !*recursive || *includeFlag != "" || *excludeFlag != "") {
+		fmt.Println("-merge reads sprites from existing atlases and cannot be combined with -filedir, -crops, -skipfile, -modifiedsince, -recursive, -include, -exclude or -groupby.")
+		os.Exit(1)
+	}
 
-	return *maxHeight, *filedir
-}
+	if *groupBy != "" && *groupBy != "dir" {
+		fmt.Printf("Unsupported -groupby value %q; supported values: dir.\n", *groupBy)
+		os.Exit(1)
+	}
+
+	outputExt := filepath.Ext(*out)
+	atlasName := strings.TrimSuffix(*out, outputExt)
+	if outputExt == "" {
+		outputExt = ".png"
+	}
+	if atlasName == "" || strings.HasSuffix(atlasName, "/") || strings.HasSuffix(atlasName, string(filepath.Separator)) {
+		fmt.Printf("Invalid -out %q: must name a file.\n", *out)
+		os.Exit(1)
+	}
+	outSet := false
+	flags.Visit(func(f *flag.Flag) { outSet = outSet || f.Name == "out" })
+	if outSet && *nameTemplate != "" {
+		fmt.Println("-out cannot be combined with -nametemplate, which names the atlas images itself.")
+		os.Exit(1)
+	}
+	if err := packer.ValidateNameTemplate(*nameTemplate, *groupBy != "", *maxPerPage > 0 || *textureArray); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	// A texture array has one manifest for all its layers.
+	if err := packer.ValidateNameTemplate(*manifestTemplate, *groupBy != "", *maxPerPage > 0 && !*textureArray); err != nil {
+		fmt.Println("Error in -manifest:", err)
+		os.Exit(1)
+	}
+
+	if *alpha != packer.AlphaStraight && *alpha != packer.AlphaPremultiplied && *alpha != packer.AlphaBoth {
+		fmt.Printf("Unsupported -alpha value %q; supported values: straight, premultiplied, both.\n", *alpha)
+		os.Exit(1)
+	}
+
+	if *growth != packer.GrowthWidth && *growth != packer.GrowthHeight && *growth != packer.GrowthSquare {
+		fmt.Printf("Unsupported -growth value %q; supported values: width, height, square.\n", *growth)
+		os.Exit(1)
+	}
+
+	switch *sortMode {
+	case packer.SortHeight, packer.SortMaxSide, packer.SortArea:
+	default:
+		fmt.Printf("Unsupported -sort value %q; supported values: height, maxside, area.\n", *sortMode)
+		os.Exit(1)
+	}
+
+	if *shelfFit != packer.ShelfFitFirst && *shelfFit != packer.ShelfFitBest {
+		fmt.Printf("Unsupported -shelffit value %q; supported values: first, best.\n", *shelfFit)
+		os.Exit(1)
+	}
+	switch *tieBreak {
+	case packer.TieBreakSmallestY, packer.TieBreakSmallestX, packer.TieBreakTopLeft, packer.TieBreakBottomLeft:
+	default:
+		fmt.Printf("Unsupported -tiebreak value %q; supported values: smallest-y, smallest-x, topleft, bottomleft.\n", *tieBreak)
+		os.Exit(1)
+	}
+	if *compact && (*strips || !cell.IsZero()) {
+		fmt.Println("-compact only applies to shelf packing and cannot be combined with -strips or -cell.")
+		os.Exit(1)
+	}
+	switch *packerName {
+	case packer.PackerShelf, packer.PackerMaxRects:
+	default:
+		fmt.Printf("Unsupported -packer value %q; supported values: shelf, maxrects.\n", *packerName)
+		os.Exit(1)
+	}
+	packerSet := false
+	flags.Visit(func(f *flag.Flag) { packerSet = packerSet || f.Name == "packer" })
+	shelfOnly := *twoPass || *compact || *shelfFit != packer.ShelfFitFirst || *shelfBucket > 0
+	if !packerSet && shelfOnly {
+		// Options tuning shelves ask for the shelf packer.
+		*packerName = packer.PackerShelf
+	}
+	if *packerName == packer.PackerMaxRects && ((packerSet && (*strips || !cell.IsZero() || *maskFile != "")) || shelfOnly) {
+		fmt.Println("-packer maxrects cannot be combined with -strips, -cell or -maskshape, which have packers of their own, nor with -twopass, -compact, -shelffit or -shelfbucket, which only apply to shelves.")
+		os.Exit(1)
+	}
+	if *allowRotation && (*strips || !cell.IsZero() || *maskFile != "" || *glyphsFile != "" || *manifestFormat == packer.FormatMinimal) {
+		fmt.Println("-allowrotation only applies to the shelf and maxrects packers and cannot be combined with -strips, -cell or -maskshape, nor with -glyphs or -format minimal, which cannot record a turned sprite.")
+		os.Exit(1)
+	}
+	if *tieBreak != packer.TieBreakSmallestY && *shelfFit != packer.ShelfFitBest {
+		fmt.Println("-tiebreak only applies to -shelffit best, where shelves can fit a sprite equally well.")
+		os.Exit(1)
+	}
+
+	switch *origin {
+	case packer.OriginTopLeft, packer.OriginBottomLeft, packer.OriginCenter:
+	default:
+		fmt.Printf("Unsupported -origin value %q; supported values: topleft, bottomleft, center.\n", *origin)
+		os.Exit(1)
+	}
+	switch *pathMode {
+	case packer.PathModeBase, packer.PathModeRelative, packer.PathModeAbsolute:
+	default:
+		fmt.Printf("Unsupported -pathmode value %q; supported values: base, relative, absolute.\n", *pathMode)
+		os.Exit(1)
+	}
+	if *pathMode == packer.PathModeAbsolute && *merge != "" {
+		fmt.Println("-pathmode absolute needs the -filedir directories of the sprites and cannot be combined with -merge.")
+		os.Exit(1)
+	}
+
+	if *manifestFormat != packer.FormatJSON && *manifestFormat != packer.FormatNDJSON && *manifestFormat != packer.FormatGo && *manifestFormat != packer.FormatXML && *manifestFormat != packer.FormatMinimal && *manifestFormat != packer.FormatPlist {
+		fmt.Printf("Unsupported -format value %q; supported values: json, ndjson, go, xml, minimal, plist.\n", *manifestFormat)
+		os.Exit(1)
+	}
+
+	if *manifestFormat == packer.FormatGo && !token.IsIdentifier(*goPackage) {
+		fmt.Printf("Invalid -gopackage %q; must be a Go identifier.\n", *goPackage)
+		os.Exit(1)
+	}
+
+	if (*manifestFormat == packer.FormatGo || *manifestFormat == packer.FormatMinimal) && *verify {
+		fmt.Println("-verify reads back JSON manifests and cannot be combined with -format go or minimal.")
+		os.Exit(1)
+	}
+
+	if (*manifestFormat == packer.FormatGo || *manifestFormat == packer.FormatMinimal) && *compareManifest != "" {
+		fmt.Println("-comparemanifest reads back JSON manifests and cannot be combined with -format go or minimal.")
+		os.Exit(1)
+	}
+	if *manifestFormat == packer.FormatMinimal && *textureArray {
+		fmt.Println("-format minimal has no room for texture array layers and cannot be combined with -texturearray.")
+		os.Exit(1)
+	}
+	if *manifestFormat == packer.FormatPlist && (*textureArray || *origin != packer.OriginTopLeft) {
+		fmt.Println("-format plist has no room for texture array layers and measures from the top-left corner, so it cannot be combined with -texturearray or -origin.")
+		os.Exit(1)
+	}
+	if *autoSizeFlag {
+		maxSizeSet := false
+		flags.Visit(func(f *flag.Flag) { maxSizeSet = maxSizeSet || f.Name == "maxwidth" || f.Name == "maxheight" })
+		if maxSizeSet || *maxPerPage > 0 || *textureArray || *canvasFile != "" {
+			fmt.Println("-autosize picks the -maxwidth and -maxheight bounds for a single page and cannot be combined with -maxwidth, -maxheight, -maxperpage, -texturearray or -canvas.")
+			os.Exit(1)
+		}
+	}
+	if *includeEmpty && *skipEmpty {
+		fmt.Println("-includeempty keeps the images -skipempty leaves out; the two cannot be combined.")
+		os.Exit(1)
+	}
+	if *compareTolerance < 0 {
+		fmt.Printf("Invalid -comparetolerance %d; must be non-negative.\n", *compareTolerance)
+		os.Exit(1)
+	}
+	if *compareAtlas == "" && (*compareTolerance > 0 || *compareDiff) {
+		fmt.Println("-comparetolerance and -comparediff only apply with -compareatlas.")
+		os.Exit(1)
+	}
+	if *compareTrimFlag && (*watchFlag || *plan || *compareManifest != "" || *compareAtlas != "" || *dumpTrimmed != "") {
+		fmt.Println("-comparetrim builds every atlas twice and cannot be combined with -watch, -plan, -comparemanifest, -compareatlas or -dumptrimmed.")
+		os.Exit(1)
+	}
+	if *watchFlag && (*merge != "" || *plan || *expectCount > 0 || *compareManifest != "" || *compareAtlas != "" || *minUtilization > 0) {
+		fmt.Println("-watch rebuilds from -filedir until interrupted and cannot be combined with -merge, -plan, -expectcount, -comparemanifest, -compareatlas or -minutilization.")
+		os.Exit(1)
+	}
+	if *plan && (*trim || *skipEmpty || *mirrorHalves || *dedup) {
+		fmt.Println("-plan reads only image headers and cannot be combined with -trim, -skipempty, -mirrorhalves or -dedup, which need the pixels.")
+		os.Exit(1)
+	}
+	if *minUtilization < 0 || *minUtilization > 1 {
+		fmt.Printf("Invalid -minutilization %v; must be between 0 and 1.\n", *minUtilization)
+		os.Exit(1)
+	}
+
+	if *trimTolerance < 0 || *trimTolerance > 255 {
+		fmt.Printf("Invalid -trimtolerance %d; must be between 0 and 255.\n", *trimTolerance)
+		os.Exit(1)
+	}
+
+	if *trimAlpha < 0 || *trimAlpha > 255 {
+		fmt.Printf("Invalid -trimalpha %d; must be between 0 and 255.\n", *trimAlpha)
+		os.Exit(1)
+	}
+
+	if *polygonTolerance < 0 {
+		fmt.Printf("Invalid -polygontolerance %g; must not be negative.\n", *polygonTolerance)
+		os.Exit(1)
+	}
+
+	if *borderPadding < 0 {
+		fmt.Printf("Invalid -borderpadding %d; must not be negative.\n", *borderPadding)
+		os.Exit(1)
+	}
+	if *borderPadding > 0 && (*canvasFile != "" || *maskFile != "") {
+		fmt.Println("-borderpadding grows the atlas and cannot be combined with -canvas or -maskshape, which fix its size.")
+		os.Exit(1)
+	}
+
+	if *extrudeBy < 0 {
+		fmt.Printf("Invalid -extrude %d; must not be negative.\n", *extrudeBy)
+		os.Exit(1)
+	}
+	if *extrudeBy > 0 && (padding.X < 2**extrudeBy || padding.Y < 2**extrudeBy) {
+		fmt.Printf("-extrude %d needs a -padding of at least %d both ways, since neighbouring sprites extrude into the same gap.\n", *extrudeBy, 2**extrudeBy)
+		os.Exit(1)
+	}
+	if *extrudeBy > 0 && *maskFile != "" {
+		fmt.Println("-extrude cannot be combined with -maskshape, whose excluded pixels it would draw into.")
+		os.Exit(1)
+	}
+
+	if *sdf && *sdfSpread < 1 {
+		fmt.Printf("Invalid -sdfspread %d; must be at least 1.\n", *sdfSpread)
+		os.Exit(1)
+	}
 
-// collectImageFiles retrieves a list of image files from the specified directory.
-func collectImageFiles(filedir string) ([]string, error) {
-	var files []string
-	err := filepath.Walk(filedir, func(path string, info os.FileInfo, err error) error {
+	if *sdf && !cell.IsZero() {
+		fmt.Println("-sdf grows sprites beyond their cells and cannot be combined with -cell.")
+		os.Exit(1)
+	}
+
+	var background color.NRGBA
+	if *backgroundFlag != "" {
+		c, err := packer.ParseBackground(*backgroundFlag)
 		if err != nil {
-			return err
+			fmt.Printf("Invalid -background %q: %v.\n", *backgroundFlag, err)
+			os.Exit(1)
 		}
-		if !info.IsDir() && isImageFile(path) {
-			files = append(files, path)
+		background = c
+		if *canvasFile != "" || *sdf {
+			fmt.Println("-background cannot be combined with -canvas, which gives the atlas its own pixels, or with -sdf, whose atlas is a distance field.")
+			os.Exit(1)
 		}
-		return nil
-	})
-	return files, err
-}
+	}
 
-// isImageFile checks if the given filename has a supported image file extension.
-func isImageFile(filename string) bool {
-	switch filepath.Ext(filename) {
-	case ".png", ".jpg", ".jpeg", ".gif", ".bmp":
-		return true
-	default:
-		return false
+	if *canvasFile != "" && (*textureArray || !minSize.IsZero()) {
+		fmt.Println("-canvas fixes the atlas size and cannot be combined with -texturearray or -minsize.")
+		os.Exit(1)
 	}
-}
 
-// loadImages loads image files concurrently, sorts them by height,
-// and returns a slice of rectangles representing each loaded image.
-func loadImages(files []string) ([]Rectangle, error) {
-	rectangles := make([]Rectangle, len(files))
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(files))
-
-	for i, file := range files {
-		wg.Add(1)
-		go func(i int, file string) {
-			defer wg.Done()
-			img, err := loadImage(file)
-			if err != nil {
-				errChan <- fmt.Errorf("failed to load image %s: %w", file, err)
-				return
-			}
-			rectangles[i] = Rectangle{
-				ID:     i + 1,
-				Image:  img,
-				Width:  img.Bounds().Dx(),
-				Height: img.Bounds().Dy(),
-			}
-		}(i, file)
+	if *maskFile != "" && (*canvasFile != "" || *textureArray || !minSize.IsZero() || *autoSizeFlag ||
+		*strips || !cell.IsZero() || *twoPass || *compact || *shelfFit != packer.ShelfFitFirst || *growth != packer.GrowthWidth) {
+		fmt.Println("-maskshape packs with its own packer into the mask's size and cannot be combined with -canvas, -texturearray, -minsize, -autosize, -strips, -cell, -twopass, -compact, -shelffit best or -growth.")
+		os.Exit(1)
 	}
 
-	wg.Wait()
-	close(errChan)
+	if len(resize) > 0 && !cell.IsZero() {
+		fmt.Println("-resize cannot be combined with -cell, which already gives every sprite a uniform size.")
+		os.Exit(1)
+	}
 
-	if err := <-errChan; err != nil {
-		return nil, err
+	if !reserve.IsZero() && (*strips || !cell.IsZero()) {
+		fmt.Println("-reserve cannot be combined with -strips or -cell, which lay sprites out in fixed rows or cells.")
+		os.Exit(1)
 	}
 
-	sort.Slice(rectangles, func(i, j int) bool {
-		return rectangles[i].Height > rectangles[j].Height
-	})
+	if *spriteAlign < 0 {
+		fmt.Printf("Invalid -spritealign %d; must not be negative.\n", *spriteAlign)
+		os.Exit(1)
+	}
+	if *spriteAlign > 1 && !cell.IsZero() {
+		fmt.Println("-spritealign cannot be combined with -cell; choose a cell size and padding that add up to a multiple of the alignment instead.")
+		os.Exit(1)
+	}
 
-	return rectangles, nil
-}
+	if *spatialIndex < 0 {
+		fmt.Printf("Invalid -spatialindex %d; must not be negative.\n", *spatialIndex)
+		os.Exit(1)
+	}
+	if *freeRegionsFlag && *textureArray {
+		fmt.Println("-freeregions records the free space of a single atlas image and cannot be combined with -texturearray.")
+		os.Exit(1)
+	}
+	if *bundle && *textureArray {
+		fmt.Println("-bundle holds a single atlas image and cannot be combined with -texturearray.")
+		os.Exit(1)
+	}
+	if *spatialIndex > 0 && *textureArray {
+		fmt.Println("-spatialindex cannot be combined with -texturearray, whose layers share one manifest.")
+		os.Exit(1)
+	}
 
-// loadImages loads image files concurrently, sorts them by height,
-// and returns a slice of rectangles representing each loaded image.
-func loadImage(file string) (image.Image, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
+	if *glyphsFile != "" && (*textureArray || !cell.IsZero()) {
+		fmt.Println("-glyphs cannot be combined with -texturearray or -cell.")
+		os.Exit(1)
 	}
-	defer f.Close()
 
-	img, _, err := image.Decode(f)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+	if *mirrorHalves && *polygon {
+		fmt.Println("-mirrorhalves cannot be combined with -polygon, whose outlines cover the whole sprite.")
+		os.Exit(1)
 	}
 
-	return img, nil
-}
+	if *dedup && *strips {
+		fmt.Println("-dedup cannot be combined with -strips, whose rows need every frame of an animation.")
+		os.Exit(1)
+	}
 
-// generateAtlas packs the provided rectangles into a texture atlas image
-// using a shelf packing algorithm and returns the texture atlas image
-// along with the mapping of rectangle IDs to their positions in the atlas.
-func generateAtlas(rectangles []Rectangle, maxHeight int) (*image.RGBA, map[int]image.Rectangle) {
-	packedRectangles := make(map[int]image.Rectangle)
-	shelves := []Shelf{{Y: 0, Height: 0, Width: 0}}
-	maxWidth := 0
-
-	for _, rect := range rectangles {
-		packed := false
-		for i, shelf := range shelves {
-			if rect.Height <= shelf.Height && shelf.Width+rect.Width <= maxHeight {
-				packedRectangles[rect.ID] = image.Rect(shelf.Width, shelf.Y, shelf.Width+rect.Width, shelf.Y+rect.Height)
-				shelves[i].Width += rect.Width
-				if shelves[i].Width > maxWidth {
-					maxWidth = shelves[i].Width
-				}
-				packed = true
-				break
-			}
-		}
+	if *maxPages < 0 {
+		fmt.Printf("Invalid -maxpages %d; must not be negative.\n", *maxPages)
+		os.Exit(1)
+	}
 
-		if !packed {
-			newShelf := Shelf{Y: shelves[len(shelves)-1].Y + shelves[len(shelves)-1].Height, Height: rect.Height, Width: rect.Width}
-			shelves = append(shelves, newShelf)
-			packedRectangles[rect.ID] = image.Rect(0, newShelf.Y, rect.Width, newShelf.Y+rect.Height)
-			if rect.Width > maxWidth {
-				maxWidth = rect.Width
-			}
+	if *shelfBucket < 0 {
+		fmt.Printf("Invalid -shelfbucket %d; must not be negative.\n", *shelfBucket)
+		os.Exit(1)
+	}
+
+	if *retries < 0 {
+		fmt.Printf("Invalid -retries %d; must not be negative.\n", *retries)
+		os.Exit(1)
+	}
+
+	if *mipLevelCount < 0 {
+		fmt.Printf("Invalid -miplevels %d; must not be negative.\n", *mipLevelCount)
+		os.Exit(1)
+	}
+	if *mipLevelCount > 0 && (*textureArray || *alpha == packer.AlphaBoth) {
+		fmt.Println("-miplevels writes one chain of levels per atlas image and cannot be combined with -texturearray or -alpha both.")
+		os.Exit(1)
+	}
+	if *tileSize < 0 {
+		fmt.Printf("Invalid -tilesize %d; must not be negative.\n", *tileSize)
+		os.Exit(1)
+	}
+	if *tileSize > 0 && (*textureArray || *alpha == packer.AlphaBoth) {
+		fmt.Println("-tilesize slices one image per atlas and cannot be combined with -texturearray or -alpha both.")
+		os.Exit(1)
+	}
+	if *preview < 0 {
+		fmt.Printf("Invalid -preview %d; must not be negative.\n", *preview)
+		os.Exit(1)
+	}
+
+	if *maxWidth < 1 {
+		fmt.Printf("Invalid -maxwidth %d; must be at least 1.\n", *maxWidth)
+		os.Exit(1)
+	}
+	if *maxHeight < 1 {
+		fmt.Printf("Invalid -maxheight %d; must be at least 1.\n", *maxHeight)
+		os.Exit(1)
+	}
+
+	if *scale < 1 {
+		fmt.Printf("Invalid -scale %d; must be at least 1.\n", *scale)
+		os.Exit(1)
+	}
+
+	if *alphaBleed < 0 {
+		fmt.Printf("Invalid -alphableed %d; must not be negative.\n", *alphaBleed)
+		os.Exit(1)
+	}
+
+	if *channels != packer.ChannelsRGBA && *channels != packer.ChannelsAlpha && *channels != packer.ChannelsGray {
+		fmt.Printf("Unsupported -channels value %q; supported values: rgba, alpha, gray.\n", *channels)
+		os.Exit(1)
+	}
+	if *channels != packer.ChannelsRGBA && *sdf {
+		fmt.Println("-sdf atlases already have a single channel and cannot be combined with -channels.")
+		os.Exit(1)
+	}
+
+	if *bitDepth != 8 && *bitDepth != 16 {
+		fmt.Printf("Unsupported -bitdepth %d; supported values: 8, 16.\n", *bitDepth)
+		os.Exit(1)
+	}
+	if *targetDepth != 0 {
+		if *targetDepth != 8 && *targetDepth != 16 {
+			fmt.Printf("Unsupported -targetdepth %d; supported values: 8, 16.\n", *targetDepth)
+			os.Exit(1)
+		}
+		bitDepthSet := false
+		flags.Visit(func(f *flag.Flag) { bitDepthSet = bitDepthSet || f.Name == "bitdepth" })
+		if bitDepthSet && *bitDepth != *targetDepth {
+			fmt.Printf("-targetdepth %d conflicts with -bitdepth %d.\n", *targetDepth, *bitDepth)
+			os.Exit(1)
 		}
+		*bitDepth = *targetDepth
 	}
 
-	totalHeight := shelves[len(shelves)-1].Y + shelves[len(shelves)-1].Height
-	atlas := image.NewRGBA(image.Rect(0, 0, maxWidth, totalHeight))
+	// The first atlas image stands for them all: their names differ only in
+	// the tokens before the extension.
+	firstAtlas := packer.AtlasFilename(atlasName, outputExt, *nameTemplate, "", 0, packer.AtlasKindDiffuse)
+	format := packer.OutputFormat(firstAtlas)
+	if format == "" {
+		fmt.Printf("Unsupported atlas image extension %q; supported extensions: .png, .gif, .jpg, .jpeg.\n", filepath.Ext(firstAtlas))
+		os.Exit(1)
+	}
+	for _, pair := range [][2]string{{"gifcolors", "colors"}, {"gifdither", "dither"}, {"quality", "quality"}} {
+		set := false
+		flags.Visit(func(f *flag.Flag) { set = set || f.Name == pair[0] })
+		if _, ok := outputOpts[pair[1]]; ok && set {
+			fmt.Printf("-%s conflicts with -outputopts %s.\n", pair[0], pair[1])
+			os.Exit(1)
+		}
+	}
+	encoding := packer.Options{PNGCompression: png.BestCompression, GIFColors: *gifColors, GIFDither: *gifDither, JPEGQuality: *quality}
+	if err := outputOpts.Apply(format, &encoding); err != nil {
+		fmt.Printf("Invalid -outputopts %q: %v.\n", outputOpts.String(), err)
+		os.Exit(1)
+	}
+	*gifColors, *gifDither, *quality = encoding.GIFColors, encoding.GIFDither, encoding.JPEGQuality
+	if _, ok := outputOpts["compression"]; ok && *minify {
+		fmt.Println("-minify always uses the best PNG compression and cannot be combined with -outputopts compression.")
+		os.Exit(1)
+	}
+	if *gifColors < 2 || *gifColors > 256 {
+		fmt.Printf("Invalid -gifcolors %d; must be between 2 and 256.\n", *gifColors)
+		os.Exit(1)
+	}
+	if *quality < 1 || *quality > 100 {
+		fmt.Printf("Invalid -quality %d; must be between 1 and 100.\n", *quality)
+		os.Exit(1)
+	}
+	if format == "gif" {
+		if *bitDepth == 16 || *minify || *bundle {
+			fmt.Println("GIF atlases have 8-bit palette colors and cannot be combined with -bitdepth 16, -minify or -bundle.")
+			os.Exit(1)
+		}
+	} else {
+		gifSet := false
+		flags.Visit(func(f *flag.Flag) { gifSet = gifSet || f.Name == "gifcolors" || f.Name == "gifdither" })
+		if gifSet {
+			fmt.Println("-gifcolors and -gifdither only apply to GIF atlases, written when the atlas filename ends in .gif.")
+			os.Exit(1)
+		}
+	}
+	if format == "jpeg" {
+		if *bitDepth == 16 || *minify || *bundle || *alpha != packer.AlphaStraight {
+			fmt.Println("JPEG atlases have 8-bit colors and no alpha, and cannot be combined with -bitdepth 16, -minify, -bundle or -alpha premultiplied or both.")
+			os.Exit(1)
+		}
+	} else {
+		qualitySet := false
+		flags.Visit(func(f *flag.Flag) { qualitySet = qualitySet || f.Name == "quality" })
+		if qualitySet {
+			fmt.Println("-quality only applies to JPEG atlases, written when the atlas filename ends in .jpg or .jpeg.")
+			os.Exit(1)
+		}
+	}
 
-	for _, rect := range rectangles {
-		draw.Draw(atlas, packedRectangles[rect.ID], rect.Image, image.Point{}, draw.Src)
+	animPattern, err := regexp.Compile(*animRegex)
+	if err != nil || animPattern.NumSubexp() < 1 {
+		fmt.Printf("Invalid -animregex %q: must be a valid regexp with a capture group.\n", *animRegex)
+		os.Exit(1)
 	}
 
-	return atlas, packedRectangles
-}
+	fsys, err := packer.OpenDirs(fileDirs)
+	if err != nil {
+		fmt.Printf("Invalid -filedir %q: %v.\n", *filedir, err)
+		os.Exit(1)
+	}
 
-// saveAtlas saves the texture atlas image as a PNG file with the specified filename.
-func saveAtlas(filename string, atlas *image.RGBA) error {
-	f, err := os.Create(filename)
+	include, err := packer.ParseGlobs(*includeFlag)
 	if err != nil {
-		return err
+		fmt.Printf("Invalid -include %q: %v.\n", *includeFlag, err)
+		os.Exit(1)
+	}
+	exclude, err := packer.ParseGlobs(*excludeFlag)
+	if err != nil {
+		fmt.Printf("Invalid -exclude %q: %v.\n", *excludeFlag, err)
+		os.Exit(1)
 	}
-	defer f.Close()
 
-	encoder := png.Encoder{CompressionLevel: png.BestCompression}
-	return encoder.Encode(f, atlas)
-}
+	var debugPattern *regexp.Regexp
+	if *debugCategory != "" {
+		debugPattern, err = regexp.Compile(*debugCategory)
+		if err != nil || debugPattern.NumSubexp() < 1 {
+			fmt.Printf("Invalid -debugcategory %q: must be a valid regexp with a capture group.\n", *debugCategory)
+			os.Exit(1)
+		}
+	}
 
-// printAtlasInfo prints information about the generated texture atlas,
-// including its dimensions and the positions of packed rectangles.
-func printAtlasInfo(width, height int, packedRectangles map[int]image.Rectangle) {
-	fmt.Printf("Atlas size: %d x %d\n", width, height)
-	fmt.Println("Packed rectangles:")
-	for id, rect := range packedRectangles {
-		fmt.Printf("ID: %d, Rect: %v\n", id, rect)
+	var namePattern *regexp.Regexp
+	if *nameRegex != "" {
+		if namePattern, err = regexp.Compile(*nameRegex); err != nil {
+			fmt.Printf("Invalid -nameregex %q: %v.\n", *nameRegex, err)
+			os.Exit(1)
+		}
+	} else if *nameReplace != "" {
+		fmt.Println("-namereplace only applies to matches of -nameregex.")
+		os.Exit(1)
+	}
+	conventions, err := packer.ParseNameConventions(*nameConventionsFlag)
+	if err != nil {
+		fmt.Printf("Unsupported -nameconventions value %q: %v; supported values: %s.\n", *nameConventionsFlag, err, strings.Join(packer.ConventionNames(), ", "))
+		os.Exit(1)
+	}
+
+	opts := packer.Options{
+		MaxWidth:         *maxWidth,
+		MaxHeight:        *maxHeight,
+		FileDir:          *filedir,
+		FS:               fsys,
+		TwoPass:          *twoPass,
+		GroupBy:          *groupBy,
+		Minify:           *minify,
+		PNGCompression:   encoding.PNGCompression,
+		GIFColors:        *gifColors,
+		GIFDither:        *gifDither,
+		JPEGQuality:      *quality,
+		Padding:          padding,
+		BorderPadding:    *borderPadding,
+		Extrude:          *extrudeBy,
+		NameTemplate:     *nameTemplate,
+		ManifestTemplate: *manifestTemplate,
+		AtlasName:        atlasName,
+		AtlasExtension:   outputExt,
+		Strips:           *strips,
+		AnimRegex:        animPattern,
+		BitDepth:         *bitDepth,
+		TargetDepth:      *targetDepth,
+		Channels:         *channels,
+		ExpectCount:      *expectCount,
+		ExpectSize:       expectSize,
+		ModifiedSince:    since,
+		Recursive:        *recursive,
+		Include:          include,
+		Exclude:          exclude,
+		Plan:             *plan,
+		Trim:             *trim,
+		TrimSolid:        *trimSolid,
+		TrimTolerance:    *trimTolerance,
+		TrimAlpha:        *trimAlpha,
+		StatsFile:        *statsFile,
+		SidecarFile:      *sidecarFile,
+		Overwrite:        *overwrite,
+		Cell:             cell,
+		Resize:           resize,
+		SVGSize:          svgSize,
+		Deterministic:    *deterministic,
+		AlphaBleed:       *alphaBleed,
+		Timeout:          *timeout,
+		Alpha:            *alpha,
+		ReportLargest:    *reportLargest,
+		JSONPretty:       *jsonPretty,
+		Packer:           *packerName,
+		ShelfFit:         *shelfFit,
+		AllowRotation:    *allowRotation,
+		Sort:             *sortMode,
+		ShelfBucket:      *shelfBucket,
+		SpriteAlign:      *spriteAlign,
+		TieBreak:         *tieBreak,
+		PathMode:         *pathMode,
+		Origin:           *origin,
+		NameRegex:        namePattern,
+		NameReplace:      *nameReplace,
+		NameConventions:  conventions,
+		MaxPerPage:       *maxPerPage,
+		MaxPages:         *maxPages,
+		TextureArray:     *textureArray,
+		Polygon:          *polygon,
+		PolygonTolerance: *polygonTolerance,
+		Growth:           *growth,
+		Verify:           *verify,
+		Metadata:         *metadata,
+		MinSize:          minSize,
+		Reserve:          reserve,
+		RequirePOT:       *requirePOT,
+		Compact:          *compact,
+		UniqueNames:      *uniqueNames,
+		Dedup:            *dedup,
+		AutoSize:         *autoSizeFlag,
+		Format:           *manifestFormat,
+		GoPackage:        *goPackage,
+		SkipEmpty:        *skipEmpty,
+		IncludeEmpty:     *includeEmpty,
+		SkipBad:          *skipBad,
+		Scale:            *scale,
+		MirrorHalves:     *mirrorHalves,
+		Preview:          *preview,
+		MipLevels:        *mipLevelCount,
+		TileSize:         *tileSize,
+		Retries:          *retries,
+		DumpTrimmed:      *dumpTrimmed,
+		DumpFree:         *dumpFree,
+		Verbose:          *verbose,
+		MinUtilization:   *minUtilization,
+		PlacementDump:    *placementDumpFlag,
+		FreeRegions:      *freeRegionsFlag,
+		Frames:           *frames,
+		MaxMemory:        maxMemory,
+		SpatialIndex:     *spatialIndex,
+		RLE:              *rle,
+		AverageColor:     *averageColorFlag,
+		EmitQuads:        *emitQuads,
+		Bundle:           *bundle,
+		Debug:            *debug,
+		DebugCategory:    debugPattern,
+		CompareManifest:  *compareManifest,
+		CompareAtlas:     *compareAtlas,
+		CompareTolerance: *compareTolerance,
+		CompareDiff:      *compareDiff,
+		SDF:              *sdf,
+		SDFSpread:        *sdfSpread,
+		Background:       background,
+	}
+	if *canvasFile != "" {
+		canvas, err := packer.DecodeImageFile(*canvasFile)
+		if err != nil {
+			packer.LogError("loading canvas", err)
+			os.Exit(1)
+		}
+		opts.Canvas = canvas
+	}
+	if *maskFile != "" {
+		mask, err := packer.DecodeImageFile(*maskFile)
+		if err != nil {
+			packer.LogError("loading mask", err)
+			os.Exit(1)
+		}
+		opts.Mask = packer.NewMask(mask)
+	}
+	if *merge != "" {
+		set, err := packer.LoadMergeSet(packer.SplitList(*merge))
+		if err != nil {
+			packer.LogError("loading atlases to merge", err)
+			os.Exit(1)
+		}
+		opts.FS, opts.Crops, opts.Merge = set.FS, set.Crops, set
+	}
+	if *skipFile != "" {
+		skip, err := packer.LoadSkipFile(*skipFile)
+		if err != nil {
+			packer.LogError("loading skip file", err)
+			os.Exit(1)
+		}
+		opts.Skip = skip
+	}
+	if *cropsFile != "" {
+		crops, err := packer.LoadCrops(*cropsFile)
+		if err != nil {
+			packer.LogError("loading crops", err)
+			os.Exit(1)
+		}
+		opts.Crops = crops
+	}
+	if *glyphsFile != "" {
+		font, err := packer.LoadGlyphs(*glyphsFile)
+		if err != nil {
+			packer.LogError("loading glyphs", err)
+			os.Exit(1)
+		}
+		opts.Glyphs = font
+	}
+	if *progress {
+		opts.Progress = packer.PrintProgress
+	}
+	if *recordOptions {
+		opts.Recorded = flagValues(flags)
+	}
+	var inputs []string
+	for _, file := range []string{*configFile, *tpsFile, *sidecarFile, *cropsFile, *skipFile, *glyphsFile, *canvasFile, *maskFile} {
+		if file != "" {
+			inputs = append(inputs, file)
+		}
+	}
+	return command{
+		Options:     opts,
+		Inputs:      inputs,
+		CPUProfile:  *cpuProfile,
+		MemProfile:  *memProfile,
+		Watch:       *watchFlag,
+		CompareTrim: *compareTrimFlag,
 	}
-	fmt.Println("Atlas saved as atlas.png successfully.")
 }
@@ -0,0 +1,13 @@
+package main
+
+import "flag"
+
+// flagValues returns the current value of every flag in the set, including
+// those left at their defaults, as it would be given on the command line.
+func flagValues(flags *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	flags.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
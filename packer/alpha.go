@@ -0,0 +1,128 @@
+package packer
+
+import (
+	"image"
+	"image/draw"
+	"path/filepath"
+	"strings"
+)
+
+// Values of the -alpha flag, selecting which alpha representations of the
+// atlas are written.
+const (
+	AlphaStraight      = "straight"
+	AlphaPremultiplied = "premultiplied"
+	AlphaBoth          = "both"
+)
+
+// PremultipliedSuffix is inserted before the extension of the premultiplied
+// atlas when both variants are written.
+const PremultipliedSuffix = "_premultiplied"
+
+// premultipliedFilename returns the filename of the premultiplied variant of
+// an atlas written alongside the straight one.
+func premultipliedFilename(atlasFile string) string {
+	ext := filepath.Ext(atlasFile)
+	return strings.TrimSuffix(atlasFile, ext) + PremultipliedSuffix + ext
+}
+
+// premultiplyAtlas returns a copy of the atlas with every pixel's color
+// multiplied by its alpha, rounded to the nearest value. The atlas is copied
+// with draw rather than by its Pix slice, which for a sub-image has a longer
+// stride and an offset origin. The copy has the
+// same type as the atlas, so it is still encoded with straight alpha and the
+// PNG holds the premultiplied values as they are.
+func premultiplyAtlas(atlas draw.Image) image.Image {
+	switch src := atlas.(type) {
+	case *image.NRGBA:
+		dst := image.NewNRGBA(src.Bounds())
+		draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+		for i := 0; i < len(dst.Pix); i += 4 {
+			a := uint32(dst.Pix[i+3])
+			for k := 0; k < 3; k++ {
+				dst.Pix[i+k] = uint8((uint32(dst.Pix[i+k])*a + 127) / 255)
+			}
+		}
+		return dst
+	case *image.NRGBA64:
+		dst := image.NewNRGBA64(src.Bounds())
+		draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+		for i := 0; i < len(dst.Pix); i += 8 {
+			a := uint64(dst.Pix[i+6])<<8 | uint64(dst.Pix[i+7])
+			for k := 0; k < 6; k += 2 {
+				c := uint64(dst.Pix[i+k])<<8 | uint64(dst.Pix[i+k+1])
+				c = (c*a + 32767) / 65535
+				dst.Pix[i+k], dst.Pix[i+k+1] = uint8(c>>8), uint8(c)
+			}
+		}
+		return dst
+	default:
+		return atlas
+	}
+}
+
+// atlasOutput is an atlas image together with the file it is saved to.
+type atlasOutput struct {
+	File  string
+	Image image.Image
+}
+
+// setManifestAlpha records in the manifest how the atlas written as outputs,
+// by alphaOutputs for opts.Alpha, represents alpha.
+func setManifestAlpha(manifest *Manifest, outputs []atlasOutput, opts Options) {
+	switch opts.Alpha {
+	case AlphaPremultiplied:
+		manifest.PremultipliedAlpha = true
+	case AlphaBoth:
+		manifest.PremultipliedImage = outputs[1].File
+	}
+}
+
+// alphaOutputs returns the images to save for an atlas under the given -alpha
+// mode. The straight atlas, when written, comes first and keeps atlasFile;
+// with AlphaBoth the premultiplied copy is saved under premultipliedFilename.
+func alphaOutputs(atlasFile string, atlas draw.Image, mode string) []atlasOutput {
+	switch mode {
+	case AlphaPremultiplied:
+		return []atlasOutput{{atlasFile, premultiplyAtlas(atlas)}}
+	case AlphaBoth:
+		return []atlasOutput{{atlasFile, atlas}, {premultipliedFilename(atlasFile), premultiplyAtlas(atlas)}}
+	default:
+		return []atlasOutput{{atlasFile, atlas}}
+	}
+}
+
+// premultipliedEvidence is how many translucent, not black pixels a source
+// must have, all with no color channel above their alpha, before it is taken
+// to be premultiplied already; fewer are too little to go on.
+const premultipliedEvidence = 16
+
+// looksPremultiplied reports whether img appears to hold premultiplied
+// colors, which premultiplying for -alpha would darken a second time. A
+// pixel with a color channel above its alpha, or a fully transparent pixel
+// with any color, cannot be premultiplied and settles that the image is
+// not. Otherwise the image is suspicious once enough translucent pixels
+// have been seen; black ones prove nothing either way, so images whose only
+// translucency is black, such as plain drop shadows, are not reported.
+// Opaque images are skipped without reading their pixels.
+func looksPremultiplied(img image.Image) bool {
+	if !hasAlpha(img) {
+		return false
+	}
+	at := nrgbaReader(img)
+	b := img.Bounds()
+	evidence := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := at(x, y)
+			if c.A == 0xff || c.R|c.G|c.B == 0 {
+				continue
+			}
+			if c.A == 0 || c.R > c.A || c.G > c.A || c.B > c.A {
+				return false
+			}
+			evidence++
+		}
+	}
+	return evidence >= premultipliedEvidence
+}
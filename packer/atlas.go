@@ -0,0 +1,1615 @@
+package packer
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rectangle is one sprite to pack: its image and size as packed, and what
+// was learned about it while loading. The manifest entry of the sprite is
+// built from it.
+type Rectangle struct {
+	ID int
+	// Name is the slash-separated path the image was loaded from within
+	// Options.FS or, for a crop of a sheet or a frame of an animation, the
+	// crop's or frame's name.
+	Name   string
+	Width  int
+	Height int
+	// Image is nil once the rectangle is loaded when the images are
+	// streamed under Options.MaxMemory; Reload then decodes and processes
+	// its source again.
+	Image image.Image
+
+	// Trimmed is set when the image was trimmed: SourceWidth and
+	// SourceHeight are the size of the original image and TrimOffset the
+	// position of the kept pixels within it.
+	Trimmed      bool
+	SourceWidth  int
+	SourceHeight int
+	TrimOffset   image.Point
+
+	// Resized is set when the image was scaled to fit a cell, from
+	// OriginalWidth by OriginalHeight.
+	Resized        bool
+	OriginalWidth  int
+	OriginalHeight int
+
+	// Meta is what the sidecar file gives for the sprite.
+	Meta SpriteMeta
+
+	// Outlines is the traced silhouette of the packed image with
+	// Options.Polygon, and Metadata what was read from the source file with
+	// Options.Metadata.
+	Outlines [][][2]int
+	Metadata ImageMetadata
+	// Mirror is set when only the canonical half of a symmetric sprite is
+	// packed, and Frame for a frame of an animated source.
+	Mirror *MirrorEntry
+	Frame  *FrameEntry
+	// Runs is the run-length analysis of the packed image with Options.RLE.
+	Runs   *RunStats
+	Reload func() (image.Image, error)
+
+	// AverageColor is the mean color of the packed image with
+	// Options.AverageColor.
+	AverageColor string
+
+	// SourceScale and NineSlice are what Options.NameConventions read from
+	// the sprite's file name.
+	SourceScale int
+	NineSlice   *NineSlice
+
+	// PixelHash is the pixelHash of the packed image with Options.Dedup,
+	// and Duplicates the sprites with the same pixels that share this one's
+	// region.
+	PixelHash  [sha256.Size]byte
+	Duplicates []Rectangle
+}
+
+// Options holds the settings that control how the texture atlases are
+// generated. The command line sets one field per flag, named after it; the
+// zero value of most fields turns its feature off, and the string fields
+// take the values of the constants declared for them.
+type Options struct {
+	// MaxWidth and MaxHeight bound every atlas page.
+	MaxWidth  int
+	MaxHeight int
+	// FileDir names the group of images at the root of FS.
+	FileDir string
+	// FS holds the images: the -filedir directory, or a dirsFS when several
+	// are listed.
+	FS fs.FS
+	// TwoPass runs a trial pack and reorders sprites by the space they waste
+	// before the final pack.
+	TwoPass bool
+	// GroupBy, when "dir", builds one atlas per parent directory.
+	GroupBy string
+	// Minify writes the smallest lossless PNG encoding of each atlas.
+	Minify         bool
+	PNGCompression png.CompressionLevel
+	// GIFColors and GIFDither set how GIF atlases are quantized, and
+	// JPEGQuality how JPEG ones are compressed.
+	GIFColors   int
+	GIFDither   bool
+	JPEGQuality int
+	// Padding is left between neighbouring sprites and BorderPadding between
+	// the sprites and every edge of the atlas. Extrude repeats each sprite's
+	// edge pixels that far out into its padding.
+	Padding       Padding
+	BorderPadding int
+	Extrude       int
+	// NameTemplate and ManifestTemplate name the output files. AtlasName is
+	// their base name and the {name} token, and AtlasExtension the extension
+	// atlas images get when no template gives them one.
+	NameTemplate     string
+	ManifestTemplate string
+	AtlasName        string
+	AtlasExtension   string
+	// Strips lays out each animation, named by the first capture group of
+	// AnimRegex, as a strip on its own row.
+	Strips    bool
+	AnimRegex *regexp.Regexp
+	// BitDepth is the bits per channel of the atlas, 8 or 16, and
+	// TargetDepth, when set, the depth every sprite is converted to.
+	BitDepth    int
+	TargetDepth int
+	// Channels is ChannelsRGBA, or a single channel the atlas holds.
+	Channels string
+	// ExpectCount and ExpectSize, when set, fail the run unless that many
+	// sprites are packed and every image is that size as loaded.
+	ExpectCount int
+	ExpectSize  Size
+	// Plan reads only image headers and prints the planned atlas sizes.
+	Plan bool
+	// Trim removes transparent borders, and with TrimSolid borders of the
+	// top-left pixel's color within TrimTolerance. Pixels with an alpha up to
+	// TrimAlpha count as transparent.
+	Trim          bool
+	TrimSolid     bool
+	TrimTolerance int
+	TrimAlpha     int
+	// StatsFile is where run statistics are written as JSON.
+	StatsFile string
+	// SidecarFile maps sprite names to metadata copied into the manifest.
+	SidecarFile string
+	// Overwrite replaces existing output files instead of failing.
+	Overwrite bool
+	// Cell scales every sprite to fit a cell of that size, and Resize scales
+	// the sprites its rules match.
+	Cell   Size
+	Resize ResizeRules
+	// SVGSize is the box SVG sources are rasterized to fit, keeping their
+	// aspect ratio; when zero they are rasterized at their own size.
+	SVGSize Size
+	// Deterministic loads images one at a time in file order and records
+	// pack times in the statistics file as zero.
+	Deterministic bool
+	// AlphaBleed spreads sprite colors that many pixels into the transparent
+	// pixels around them.
+	AlphaBleed int
+	// Timeout, when set, ends the run with an error once it has taken that
+	// long.
+	Timeout time.Duration
+	// Alpha selects the straight atlas, the premultiplied one or both.
+	Alpha string
+	// ReportLargest lists that many of each atlas's largest sprites.
+	ReportLargest int
+	// JSONPretty indents JSON manifests.
+	JSONPretty bool
+	// Packer, ShelfFit, Sort, ShelfBucket, SpriteAlign, TieBreak and Growth
+	// choose how sprites are placed, with the shelf packer when Packer is
+	// empty; AllowRotation lets the packer turn them a quarter turn.
+	Packer        string
+	ShelfFit      string
+	AllowRotation bool
+	Sort          string
+	ShelfBucket   int
+	SpriteAlign   int
+	TieBreak      string
+	Growth        string
+	// PathMode and Origin set how sprites are named and positioned in the
+	// manifest. Every match of NameRegex in a name is then replaced by
+	// NameReplace, and the NameConventions, named by the Convention
+	// constants, read from it.
+	PathMode        string
+	Origin          string
+	NameRegex       *regexp.Regexp
+	NameReplace     string
+	NameConventions []string
+	// MaxPerPage starts a new page once a page holds that many sprites, and
+	// MaxPages fails the run when more pages are needed.
+	MaxPerPage int
+	MaxPages   int
+	// TextureArray writes the pages as layers of identical size with one
+	// manifest.
+	TextureArray bool
+	// Polygon traces each sprite's silhouette, simplified to within
+	// PolygonTolerance pixels, into the manifest.
+	Polygon          bool
+	PolygonTolerance float64
+	// Verify checks every trim and reads back each saved atlas and manifest.
+	Verify bool
+	// Metadata copies each PNG's DPI and text chunks into the manifest.
+	Metadata bool
+	// MinSize is the smallest an atlas is padded to, and Reserve a blank
+	// region kept in the top-left corner of every atlas.
+	MinSize Size
+	Reserve Size
+	// RequirePOT fails instead of writing an atlas whose sides are not
+	// powers of two.
+	RequirePOT bool
+	// Compact moves bottom shelves into gaps on the shelves above.
+	Compact bool
+	// UniqueNames fails if sprites in different directories share a name.
+	UniqueNames bool
+	// Dedup packs sprites with identical pixels once.
+	Dedup bool
+	// AutoSize uses the smallest standard page size holding every sprite as
+	// the bounds.
+	AutoSize bool
+	// Recorded holds the flag values recorded in each manifest's meta
+	// section.
+	Recorded map[string]string
+	// Format is the manifest format, and GoPackage the package declared by
+	// FormatGo manifests.
+	Format    string
+	GoPackage string
+	// SkipEmpty leaves out images with no visible pixels, and IncludeEmpty
+	// keeps those trimmed to nothing. SkipBad leaves out files that cannot
+	// be read or decoded.
+	SkipEmpty    bool
+	IncludeEmpty bool
+	SkipBad      bool
+	// Scale enlarges every sprite by that integer factor.
+	Scale int
+	// MirrorHalves packs only half of sprites the sidecar marks as mirrored.
+	MirrorHalves bool
+	// Preview, MipLevels and TileSize write a downscaled preview, halved
+	// mip levels and tiles of each atlas beside it.
+	Preview   int
+	MipLevels int
+	TileSize  int
+	// Retries is how many times opening or reading an image is retried.
+	Retries int
+	// DumpTrimmed is a directory each sprite is also written to as packed.
+	DumpTrimmed string
+	// DumpFree, Verbose, PlacementDump and FreeRegions report the layout:
+	// the unused regions, a packing summary, a placement listing beside
+	// each manifest and the unused regions in the manifest.
+	DumpFree      bool
+	Verbose       bool
+	PlacementDump bool
+	FreeRegions   bool
+	// MinUtilization fails the run when the sprites cover less than that
+	// fraction of the total atlas area.
+	MinUtilization float64
+	// Debug writes an overlay of each atlas coloring sprites by the
+	// category DebugCategory captures from their names.
+	Debug         bool
+	DebugCategory *regexp.Regexp
+	// CompareManifest and CompareAtlas are references the written files
+	// must match; CompareTolerance pixels may differ, and CompareDiff
+	// writes an image of those that do.
+	CompareManifest  string
+	CompareAtlas     string
+	CompareTolerance int
+	CompareDiff      bool
+	// SDF replaces each sprite with a signed distance field of its alpha,
+	// falling off over SDFSpread pixels.
+	SDF       bool
+	SDFSpread int
+	// Canvas is drawn into each atlas in place of a blank one, and bounds
+	// it. Background is the color the atlas is otherwise filled with.
+	Canvas     image.Image
+	Background color.NRGBA
+	// Mask limits sprites to its opaque pixels.
+	Mask *Mask
+	// Crops lists named rectangles of source sheets packed as sprites.
+	Crops map[string][]Crop
+	// Skip, Recursive, Include, Exclude and ModifiedSince choose which files
+	// under FS are packed.
+	Skip          map[string]bool
+	Recursive     bool
+	Include       []string
+	Exclude       []string
+	ModifiedSince Since
+	// Frames splits animated sources into a sprite per frame.
+	Frames bool
+	// Merge holds the sprites of existing atlases to repack instead of FS.
+	Merge *MergeSet
+	// Glyphs holds the glyph metadata of a BMFont written beside each atlas.
+	Glyphs *GlyphFont
+	// MaxMemory is the most memory the decoded images may take before they
+	// are streamed: each image is then released once loaded and reloaded
+	// when drawn.
+	MaxMemory ByteSize
+	// SpatialIndex, RLE, AverageColor and EmitQuads add a grid index and
+	// per-sprite run-lengths, mean colors and quads to the manifest.
+	SpatialIndex int
+	RLE          bool
+	AverageColor bool
+	EmitQuads    bool
+	// Bundle also writes each atlas and its manifest as one file.
+	Bundle bool
+	// Progress, when set, is called as each image loads and each sprite is
+	// placed.
+	Progress ProgressFunc
+
+	// animations holds the animated sources read with Frames, and stream
+	// is set once MaxMemory has been found to be too little to keep the
+	// decoded images; both are filled in as the sprites are prepared.
+	animations map[string]*animation
+	stream     bool
+}
+
+// Layout describes where each rectangle was placed in the atlas and the
+// resulting atlas dimensions. Rows is only set by the strip packer, and
+// Reserved only with -reserve.
+type Layout struct {
+	Placements map[int]image.Rectangle
+	Width      int
+	Height     int
+	Rows       []StripRow
+	Reserved   image.Rectangle
+	Border     int
+}
+
+// Run builds every atlas for opts once, until ctx is done, and returns the
+// statistics of the atlases built, or the error that ended the run,
+// including a failed check such as -verify or -expectcount.
+func Run(ctx context.Context, opts Options) ([]AtlasStats, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, opts.Timeout, fmt.Errorf("timed out after %s", opts.Timeout))
+		defer cancel()
+	}
+
+	files, err := sourceFiles(ctx, &opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 && !opts.ModifiedSince.IsZero() {
+		fmt.Printf("No images under %s were modified since %s; nothing to pack.\n", opts.FileDir, opts.ModifiedSince.Format(time.RFC3339))
+		return nil, nil
+	}
+	if len(files) == 0 {
+		fmt.Printf("No images under %s matched; nothing to pack.\n", opts.FileDir)
+		return nil, nil
+	}
+	if opts.Plan {
+		return nil, planAtlases(ctx, files, opts)
+	}
+	names, groups, err := prepareSprites(ctx, files, &opts)
+	if err != nil {
+		return nil, err
+	}
+	// Skipping and trimming can leave out every image, which would make
+	// an empty atlas.
+	if !slices.ContainsFunc(names, func(name string) bool { return len(groups[name]) > 0 }) {
+		fmt.Printf("No images under %s are left after skipping; nothing to pack.\n", opts.FileDir)
+		return nil, nil
+	}
+
+	var atlasStats []AtlasStats
+	for _, name := range names {
+		opts := opts
+		if opts.AutoSize {
+			size, err := autoSize(groups[name], opts)
+			if err != nil {
+				return nil, taskFailed("choosing atlas size", err)
+			}
+			opts.MaxWidth, opts.MaxHeight = size, size
+			fmt.Printf("Chose a %dx%d page for %s.\n", size, size, AtlasFilename(opts.AtlasName, opts.AtlasExtension, opts.NameTemplate, name, 0, AtlasKindDiffuse))
+		}
+		pages, err := paginate(groups[name], opts)
+		if err != nil {
+			return nil, taskFailed("paging sprites", err)
+		}
+		if opts.TextureArray {
+			stats, err := buildTextureArray(ctx, name, pages, opts)
+			if err != nil {
+				return nil, taskFailed("", err)
+			}
+			atlasStats = append(atlasStats, stats...)
+			continue
+		}
+		for page, pageRectangles := range pages {
+			stats, err := buildAtlas(ctx, name, page, pageRectangles, opts)
+			if err != nil {
+				return nil, taskFailed("", err)
+			}
+			atlasStats = append(atlasStats, stats)
+		}
+	}
+
+	if opts.StatsFile != "" {
+		if err := saveStats(opts.StatsFile, newStats(opts, atlasStats), opts.Overwrite); err != nil {
+			return nil, taskFailed("saving stats", err)
+		}
+	}
+
+	utilization := totalUtilization(atlasStats)
+	if opts.Verbose {
+		stats := newStats(opts, atlasStats)
+		fmt.Printf("Packing summary: %d sprites on %d pages, %.1f%% utilization\n", stats.Sprites, stats.Pages, utilization*100)
+	}
+	if utilization < opts.MinUtilization {
+		return nil, fmt.Errorf("atlas utilization %.1f%% is below -minutilization %.1f%%", utilization*100, opts.MinUtilization*100)
+	}
+
+	if opts.CompareAtlas != "" {
+		atlasFiles := make([]string, len(atlasStats))
+		for i, stats := range atlasStats {
+			atlasFiles[i] = stats.Image
+		}
+		failed, err := compareAtlases(atlasFiles, opts.CompareAtlas, opts)
+		if err != nil {
+			return nil, taskFailed("comparing atlases", err)
+		}
+		if failed > 0 {
+			return nil, fmt.Errorf("%d of %d atlas images differ from the reference in more than %d pixels", failed, len(atlasFiles), opts.CompareTolerance)
+		}
+	}
+	if opts.CompareManifest != "" {
+		var manifestFiles []string
+		for _, stats := range atlasStats {
+			if len(manifestFiles) == 0 || manifestFiles[len(manifestFiles)-1] != stats.Manifest {
+				manifestFiles = append(manifestFiles, stats.Manifest)
+			}
+		}
+		changed, err := compareManifests(manifestFiles, opts.CompareManifest)
+		if err != nil {
+			return nil, taskFailed("comparing manifests", err)
+		}
+		if changed > 0 {
+			return nil, fmt.Errorf("%d of %d manifests differ from the reference", changed, len(manifestFiles))
+		}
+	}
+	return atlasStats, nil
+}
+
+// buildAtlas packs the rectangles of one page of a group into a single
+// atlas, saves it under the name given by the name template with a manifest
+// beside it, prints atlas information and, with opts.ReportLargest, its
+// largest sprites, and returns statistics about the atlas. With opts.Verify
+// the saved files are read back and checked.
+// The group is empty when not grouping. Nothing is saved once ctx is done.
+func buildAtlas(ctx context.Context, group string, page int, rectangles []Rectangle, opts Options) (AtlasStats, error) {
+	start := time.Now()
+	atlas, layout, err := generateAtlas(ctx, rectangles, opts)
+	if err != nil {
+		return AtlasStats{}, fmt.Errorf("generating atlas: %w", err)
+	}
+	packTime := time.Since(start)
+
+	atlasFile := AtlasFilename(opts.AtlasName, opts.AtlasExtension, opts.NameTemplate, group, page, AtlasKindDiffuse)
+	manifestFile := manifestPath(atlasFile, group, page, opts)
+	otherFiles := []string{manifestFile}
+	if opts.Debug {
+		otherFiles = append(otherFiles, debugFilename(atlasFile))
+	}
+	if opts.Glyphs != nil {
+		otherFiles = append(otherFiles, fontFilename(atlasFile))
+	}
+	if opts.Bundle {
+		otherFiles = append(otherFiles, bundleFilename(atlasFile))
+	}
+	if opts.PlacementDump {
+		otherFiles = append(otherFiles, placementsFilename(manifestFile))
+	}
+	var set outputSet
+	defer set.Abort()
+	outputs, err := writeAtlasImages(ctx, &set, atlasFile, atlas, opts, otherFiles...)
+	if err != nil {
+		return AtlasStats{}, err
+	}
+
+	if ctx.Err() != nil {
+		return AtlasStats{}, fmt.Errorf("saving manifest: %w", context.Cause(ctx))
+	}
+	manifest := buildManifest(atlasFile, rectangles, layout)
+	manifest.Page = page
+	if opts.SDF {
+		manifest.SDFSpread = opts.SDFSpread
+	}
+	if opts.Scale > 1 {
+		manifest.Scale = opts.Scale
+	}
+	if opts.SpatialIndex > 0 {
+		manifest.SpatialIndex = buildSpatialIndex(manifest, opts.SpatialIndex)
+	}
+	if opts.MipLevels > 0 {
+		manifest.Mips = mipLevels(manifest, opts.MipLevels)
+	}
+	if opts.TileSize > 0 {
+		manifest.TileSize = opts.TileSize
+		manifest.Tiles = atlasTiles(atlasFile, manifest.Width, manifest.Height, opts.TileSize)
+	}
+	if opts.FreeRegions {
+		manifest.Free = freeEntries(layout, opts.Padding)
+	}
+	if opts.EmitQuads {
+		addQuads(&manifest)
+	}
+	if opts.Recorded != nil {
+		manifest.Meta = manifestMeta(opts)
+	}
+	setManifestAlpha(&manifest, outputs, opts)
+	written := withOrigin(manifest, opts.Origin, false)
+	if err := saveManifest(&set, manifestFile, written, opts); err != nil {
+		return AtlasStats{}, fmt.Errorf("saving manifest: %w", err)
+	}
+	// The atlas and its manifest are moved into place together.
+	if err := set.Commit(); err != nil {
+		return AtlasStats{}, fmt.Errorf("saving atlas: %w", err)
+	}
+	if opts.PlacementDump {
+		if err := savePlacementDump(placementsFilename(manifestFile), written, opts.Overwrite); err != nil {
+			return AtlasStats{}, fmt.Errorf("saving placement dump: %w", err)
+		}
+	}
+	if opts.Glyphs != nil {
+		if err := saveFont(fontFilename(atlasFile), manifest, opts); err != nil {
+			return AtlasStats{}, fmt.Errorf("saving font: %w", err)
+		}
+	}
+	if opts.Bundle {
+		if err := saveBundle(bundleFilename(atlasFile), atlasFile, written, opts.Overwrite); err != nil {
+			return AtlasStats{}, fmt.Errorf("saving bundle: %w", err)
+		}
+	}
+	if opts.Verify {
+		if err := verifyOutputs(manifestFile, spriteCount(rectangles), opts.Padding); err != nil {
+			return AtlasStats{}, fmt.Errorf("verifying %s: %w", manifestFile, err)
+		}
+		if opts.Bundle {
+			if err := verifyBundle(bundleFilename(atlasFile), spriteCount(rectangles)); err != nil {
+				return AtlasStats{}, fmt.Errorf("verifying %s: %w", bundleFilename(atlasFile), err)
+			}
+		}
+	}
+
+	printAtlasInfo(atlasFile, atlas.Bounds().Max.X, atlas.Bounds().Max.Y, layout.Placements)
+	if opts.ReportLargest > 0 {
+		printLargest(rectangles, opts.ReportLargest, opts.Padding)
+	}
+	if opts.DumpFree {
+		printFreeRegions(atlasFile, layout, opts.Padding)
+	}
+	if opts.Verbose {
+		printPackingSummary(atlasFile, rectangles, layout, opts)
+	}
+	if opts.Debug {
+		if err := saveDebugOverlay(debugFilename(atlasFile), atlas, rectangles, layout, opts); err != nil {
+			return AtlasStats{}, fmt.Errorf("saving debug overlay: %w", err)
+		}
+	}
+	stats := newAtlasStats(atlasFile, rectangles, layout, packTime)
+	stats.Manifest = manifestFile
+	return stats, nil
+}
+
+// writeAtlasImages writes the atlas images opts asks for into set, for the
+// caller to commit with the manifest, and returns the variants of the atlas
+// itself. Previews, mip levels and tiles are written too but not returned.
+// Unless opts.Overwrite is set, nothing is written if any of these files or
+// of otherFiles already exists, and nothing is written once ctx is done.
+func writeAtlasImages(ctx context.Context, set *outputSet, atlasFile string, atlas draw.Image, opts Options, otherFiles ...string) ([]atlasOutput, error) {
+	defer profilePhase(ctx, "save")()
+	outputs := alphaOutputs(atlasFile, atlas, opts.Alpha)
+	if opts.Minify {
+		for i, output := range outputs {
+			minified, size, standardSize, err := minifyAtlas(output.Image)
+			if err != nil {
+				return nil, fmt.Errorf("minifying atlas: %w", err)
+			}
+			fmt.Printf("Minified PNG %s: %d bytes (standard encoding: %d bytes, saved %d bytes)\n", output.File, size, standardSize, standardSize-size)
+			outputs[i].Image = minified
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("saving atlas: %w", context.Cause(ctx))
+	}
+
+	previewFile := previewFilename(atlasFile)
+	var tiles []TileEntry
+	if opts.TileSize > 0 {
+		tiles = atlasTiles(atlasFile, atlas.Bounds().Dx(), atlas.Bounds().Dy(), opts.TileSize)
+	}
+	if !opts.Overwrite {
+		filenames := otherFiles
+		for _, output := range outputs {
+			filenames = append(filenames, output.File)
+		}
+		if opts.Preview > 0 {
+			filenames = append(filenames, previewFile)
+		}
+		for level := 1; level <= opts.MipLevels; level++ {
+			filenames = append(filenames, mipFilename(atlasFile, level))
+		}
+		for _, tile := range tiles {
+			filenames = append(filenames, tile.Image)
+		}
+		if err := checkOutputsAbsent(filenames...); err != nil {
+			return nil, fmt.Errorf("saving atlas: %w", err)
+		}
+	}
+	for _, output := range outputs {
+		if err := encodeAtlas(set, output.File, output.Image, opts); err != nil {
+			return nil, fmt.Errorf("saving atlas: %w", err)
+		}
+	}
+	if opts.Preview > 0 {
+		if err := encodeAtlas(set, previewFile, previewImage(atlas, opts.Preview), opts); err != nil {
+			return nil, fmt.Errorf("saving preview: %w", err)
+		}
+	}
+	for level := 1; level <= opts.MipLevels; level++ {
+		mip := alphaOutputs(mipFilename(atlasFile, level), mipImage(atlas, level), opts.Alpha)[0]
+		if err := encodeAtlas(set, mip.File, mip.Image, opts); err != nil {
+			return nil, fmt.Errorf("saving mip level %d: %w", level, err)
+		}
+	}
+	if len(tiles) > 0 {
+		if err := saveTiles(set, outputs[0].Image, tiles, opts); err != nil {
+			return nil, fmt.Errorf("saving tiles: %w", err)
+		}
+	}
+	return outputs, nil
+}
+
+// groupRectangles partitions the rectangles into the atlases to build,
+// preserving their order, and returns the sorted group names alongside.
+// With -groupby dir a group is the path of the directory that contains each
+// source file relative to opts.FS, with underscores for slashes, such as
+// "ui_icons", and for files at its root the base name of opts.FileDir;
+// otherwise everything is in the single group "". Two directories making
+// the same group name, whose atlases would overwrite each other, are an
+// error.
+func groupRectangles(rectangles []Rectangle, opts Options) ([]string, map[string][]Rectangle, error) {
+	if opts.GroupBy == "" {
+		return []string{""}, map[string][]Rectangle{"": rectangles}, nil
+	}
+
+	groups := make(map[string][]Rectangle)
+	dirs := make(map[string]string)
+	for _, rect := range rectangles {
+		dir := path.Dir(rect.Name)
+		group := strings.ReplaceAll(dir, "/", "_")
+		if dir == "." {
+			group = filepath.Base(filepath.Clean(opts.FileDir))
+		}
+		if other, ok := dirs[group]; ok && other != dir {
+			describe := func(dir string) string {
+				if dir == "." {
+					return "the root of " + opts.FileDir
+				}
+				return "directory " + dir
+			}
+			return nil, nil, fmt.Errorf("%s and %s both make the group %q", describe(other), describe(dir), group)
+		}
+		dirs[group] = dir
+		groups[group] = append(groups[group], rect)
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, groups, nil
+}
+
+// LoadFS loads the image files of fsys that opts selects, such as an
+// embed.FS or an fstest.MapFS, and processes them with prepareSprites, as
+// Run does before packing, returning rectangles ready for Pack. Rectangles
+// are named by their slash-separated paths within fsys, as opts.PathMode
+// and opts.NameRegex rename them, and opts.FS is ignored. The rectangles
+// are those of a single atlas kept in memory, so it fails with
+// opts.GroupBy, opts.Merge, opts.Plan or opts.MaxMemory, which only Run
+// supports.
+func LoadFS(ctx context.Context, fsys fs.FS, opts Options) ([]Rectangle, error) {
+	if opts.GroupBy != "" || opts.Merge != nil || opts.Plan || opts.MaxMemory > 0 {
+		return nil, errors.New("LoadFS does not support GroupBy, Merge, Plan or MaxMemory")
+	}
+	opts.FS = fsys
+	files, err := sourceFiles(ctx, &opts)
+	if err != nil {
+		return nil, err
+	}
+	_, groups, err := prepareSprites(ctx, files, &opts)
+	if err != nil {
+		return nil, err
+	}
+	return groups[""], nil
+}
+
+// sourceFiles returns the files the sprites are read from: those of the
+// atlases to merge, or those collectImageFiles selects from opts.FS, with
+// the crops of the files left out dropped. With opts.Frames it reads the
+// animations among them into opts. It fails if opts.NameConventions names
+// a convention that does not exist.
+func sourceFiles(ctx context.Context, opts *Options) ([]string, error) {
+	if err := checkConventions(opts.NameConventions); err != nil {
+		return nil, err
+	}
+	var files []string
+	if opts.Merge != nil {
+		files = opts.Merge.Files
+	} else {
+		var err error
+		if files, err = collectImageFiles(opts.FS, *opts); err != nil {
+			return nil, taskFailed("collecting image files from "+opts.FileDir, err)
+		}
+		if !opts.ModifiedSince.IsZero() || filtersImages(*opts) {
+			opts.Crops = onlyModified(opts.Crops, files)
+		}
+	}
+	if opts.Frames {
+		var err error
+		if opts.animations, err = scanAnimations(ctx, opts.FS, files, opts.SkipBad); err != nil {
+			return nil, taskFailed("reading animations", err)
+		}
+	}
+	return files, nil
+}
+
+// prepareSprites loads the sprites of files and processes them as every
+// atlas needs before packing: it decides with opts.MaxMemory whether to
+// stream them, recording that in opts, restores merged sprites, applies the
+// sidecar with its variants and mirroring, checks opts.UniqueNames and
+// opts.ExpectCount, and then groups, renames, dumps and deduplicates the
+// sprites. It returns the sorted group names and the sprites of each group.
+func prepareSprites(ctx context.Context, files []string, opts *Options) ([]string, map[string][]Rectangle, error) {
+	if opts.MaxMemory > 0 {
+		estimate, err := estimateMemory(ctx, files, *opts)
+		if err != nil {
+			return nil, nil, taskFailed("estimating memory", err)
+		}
+		if opts.stream = estimate > int64(opts.MaxMemory); opts.stream {
+			fmt.Printf("Decoded images need an estimated %.1f MiB, more than -maxmemory %s; reloading each image as it is drawn.\n", float64(estimate)/(1<<20), &opts.MaxMemory)
+		}
+	}
+
+	rectangles, err := loadImages(ctx, files, *opts)
+	if err != nil {
+		return nil, nil, taskFailed("loading images", err)
+	}
+	if opts.Merge != nil {
+		restoreMerged(rectangles, opts.Merge.Entries)
+	}
+
+	if opts.SidecarFile != "" {
+		meta, err := loadSidecar(opts.SidecarFile)
+		if err != nil {
+			return nil, nil, taskFailed("loading sidecar", err)
+		}
+		if !opts.ModifiedSince.IsZero() || filtersImages(*opts) {
+			names := make([]string, len(rectangles))
+			for i, rect := range rectangles {
+				names[i] = rect.Name
+			}
+			meta = onlyModified(meta, names)
+		}
+		applySidecar(rectangles, meta)
+		if rectangles, err = addVariants(rectangles, opts.BitDepth == 16); err != nil {
+			return nil, nil, taskFailed("adding variants", err)
+		}
+		if opts.MirrorHalves {
+			mirrorRectangles(rectangles)
+		}
+		sortRectangles(rectangles, opts.Sort)
+	}
+	if opts.UniqueNames {
+		if err := checkUniqueNames(rectangles); err != nil {
+			return nil, nil, taskFailed("checking sprite names", err)
+		}
+	}
+
+	if opts.ExpectCount > 0 && len(rectangles) != opts.ExpectCount {
+		return nil, nil, fmt.Errorf("expected %d sprites but found %d", opts.ExpectCount, len(rectangles))
+	}
+
+	names, groups, err := groupRectangles(rectangles, *opts)
+	if err != nil {
+		return nil, nil, taskFailed("grouping sprites", err)
+	}
+	for _, name := range names {
+		if err := renameSprites(groups[name], *opts); err != nil {
+			return nil, nil, taskFailed("naming sprites", err)
+		}
+	}
+	if opts.DumpTrimmed != "" {
+		// Dumped once named, so each file is found under its manifest name.
+		for _, name := range names {
+			if err := dumpSprites(ctx, opts.DumpTrimmed, groups[name], *opts); err != nil {
+				return nil, nil, taskFailed("dumping sprites", err)
+			}
+		}
+	}
+	if opts.Glyphs != nil {
+		var all []Rectangle
+		for _, name := range names {
+			all = append(all, groups[name]...)
+		}
+		checkGlyphs(all, opts.Glyphs)
+	}
+	if opts.Dedup {
+		// Within each group only, since a region is shared on one atlas.
+		duplicates := 0
+		for _, name := range names {
+			groups[name] = dedupRectangles(groups[name])
+			duplicates += spriteCount(groups[name]) - len(groups[name])
+		}
+		if duplicates > 0 {
+			fmt.Printf("%d sprites have the same pixels as another and share its region.\n", duplicates)
+		}
+	}
+	return names, groups, nil
+}
+
+// collectImageFiles retrieves a list of the image files walkImageFiles
+// selects from fsys, returning their slash-separated paths in lexical
+// order, without the files in opts.Skip and, unless opts.ModifiedSince is
+// the zero time, those last modified before it.
+func collectImageFiles(fsys fs.FS, opts Options) ([]string, error) {
+	skip, since := opts.Skip, opts.ModifiedSince.Time
+	var files []string
+	err := walkImageFiles(fsys, opts, func(name string, d fs.DirEntry) error {
+		files = append(files, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(skip) > 0 {
+		files = skipFiles(files, skip)
+	}
+	if since.IsZero() {
+		return files, nil
+	}
+	kept := files[:0]
+	for _, file := range files {
+		info, err := fs.Stat(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		if !info.ModTime().Before(since) {
+			kept = append(kept, file)
+		}
+	}
+	return kept, nil
+}
+
+// isImageFile checks if the given filename has the extension of one of the
+// inputFormats. The comparison is case-insensitive so that files such as
+// "SPRITE.PNG" are included.
+func isImageFile(filename string) bool {
+	ext := strings.ToLower(path.Ext(filename))
+	for _, format := range inputFormats {
+		if slices.Contains(format.Extensions, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadImages decodes the image files on a pool of workers, processes each
+// as opts asks, and returns their rectangles sorted for packing. Images
+// opts leaves out are reported as warnings. Every file that fails is
+// reported, not only the first, and once ctx is done decoding stops and the
+// cause is returned.
+func loadImages(ctx context.Context, files []string, opts Options) ([]Rectangle, error) {
+	defer profilePhase(ctx, "load")()
+	sources, err := spriteSources(files, opts.Crops, opts.animations)
+	if err != nil {
+		return nil, err
+	}
+	// A sheet with several crops is decoded once, by whichever of its
+	// sprites gets to it first.
+	decoded := make(map[string]func() (image.Image, error), len(files))
+	for _, file := range files {
+		decoded[file] = sync.OnceValues(func() (image.Image, error) {
+			return loadImage(ctx, file, opts)
+		})
+	}
+	// Likewise, all frames of an animation are rendered together.
+	rendered := make(map[string]func() ([]image.Image, error), len(opts.animations))
+	for file, anim := range opts.animations {
+		rendered[file] = sync.OnceValues(anim.render)
+	}
+
+	rectangles := make([]Rectangle, len(sources))
+	skipped := make([]string, len(sources))
+	sizes := make([]image.Point, len(sources))
+	reporter := newProgressReporter(opts.Progress, StageLoad, len(sources))
+	errs := make([]error, len(sources))
+
+	// decode returns the source's image. When streaming, nothing is cached,
+	// so each sprite's pixels can be released once it is loaded.
+	decode := func(source spriteSource) (image.Image, error) {
+		if source.Frame != nil {
+			var frames []image.Image
+			var err error
+			if opts.stream {
+				frames, err = opts.animations[source.File].render()
+			} else {
+				frames, err = rendered[source.File]()
+			}
+			if err != nil {
+				return nil, err
+			}
+			return frames[source.Frame.Index], nil
+		}
+		if opts.stream {
+			return loadImage(ctx, source.File, opts)
+		}
+		return decoded[source.File]()
+	}
+
+	// sprite loads and processes the i-th sprite, returning a reason to skip
+	// it instead if it is to be left out. Reloading a streamed sprite runs it
+	// again without reporting progress or warnings a second time.
+	sprite := func(i int, reload bool) (Rectangle, string, error) {
+		source := sources[i]
+		file := source.File
+		reporter := reporter
+		if reload {
+			reporter = nil
+		}
+		img, err := decode(source)
+		if err == nil && source.Crop != nil {
+			if img, err = cropImage(img, *source.Crop); err != nil {
+				return Rectangle{}, "", fmt.Errorf("failed to crop image %s: %w", file, err)
+			}
+		}
+		if err != nil && opts.SkipBad && ctx.Err() == nil {
+			reporter.report(source.Name, image.Rectangle{})
+			return Rectangle{}, err.Error(), nil
+		}
+		if err != nil {
+			return Rectangle{}, "", fmt.Errorf("failed to load image %s: %w", file, err)
+		}
+		sizes[i] = img.Bounds().Size()
+		name, info := parseSpriteName(source.Name, opts.NameConventions)
+		var nineSlice *NineSlice
+		if info.nineSlice {
+			inner, insets, err := stripNinePatch(img)
+			if err != nil && opts.SkipBad {
+				reporter.report(source.Name, image.Rectangle{})
+				return Rectangle{}, err.Error(), nil
+			}
+			if err != nil {
+				return Rectangle{}, "", fmt.Errorf("%s: %w", source.Name, err)
+			}
+			img, nineSlice = inner, &insets
+		}
+		if opts.TargetDepth != 0 {
+			var lossy bool
+			if img, lossy = normalizeDepth(img, opts.TargetDepth); lossy && !reload {
+				Warnf("%s: converting to %d bits per channel loses precision", source.Name, opts.TargetDepth)
+			}
+		}
+		if opts.Alpha != AlphaStraight && !reload && looksPremultiplied(img) {
+			Warnf("%s looks premultiplied already, as none of its translucent pixels has a color channel above its alpha; -alpha %s will darken it by premultiplying again", source.Name, opts.Alpha)
+		}
+		rect := Rectangle{
+			ID:        i + 1,
+			Name:      name,
+			Image:     img,
+			Width:     img.Bounds().Dx(),
+			Height:    img.Bounds().Dy(),
+			Frame:     source.Frame,
+			NineSlice: nineSlice,
+		}
+		applyNameInfo(&rect, info)
+		if opts.Scale > 1 {
+			img = scaleNearest(img, opts.Scale, opts.BitDepth == 16)
+			rect.Image = img
+			rect.Width, rect.Height = img.Bounds().Dx(), img.Bounds().Dy()
+		}
+		if box, ok := opts.Resize.match(source.Name); ok {
+			resizeRectangle(&rect, box)
+			img = rect.Image
+		}
+		if opts.SkipEmpty && trimBounds(img, false, 0, 0).Empty() {
+			reporter.report(source.Name, img.Bounds())
+			return Rectangle{}, "image is fully transparent", nil
+		}
+		if opts.Metadata {
+			meta, err := readPNGMetadata(opts.FS, file)
+			if err != nil && opts.SkipBad {
+				reporter.report(source.Name, img.Bounds())
+				return Rectangle{}, "invalid metadata: " + err.Error(), nil
+			}
+			if err != nil {
+				return Rectangle{}, "", fmt.Errorf("failed to read metadata of %s: %w", file, err)
+			}
+			rect.Metadata = meta
+		}
+		if opts.Trim {
+			if trimRectangle(&rect, opts) {
+				reporter.report(source.Name, img.Bounds())
+				return Rectangle{}, "nothing is left after trimming", nil
+			}
+			if opts.Verify && rect.Trimmed && !reload {
+				if err := verifyTrim(rect, img, opts); err != nil {
+					return Rectangle{}, "", fmt.Errorf("verifying trim of %s: %w", source.Name, err)
+				}
+			}
+		}
+		if !opts.Cell.IsZero() {
+			fitRectangle(&rect, opts.Cell)
+		}
+		if opts.Polygon {
+			rect.Outlines = traceOutlines(rect.Image, opts.PolygonTolerance)
+		}
+		if opts.AverageColor {
+			rect.AverageColor = averageColor(rect.Image)
+		}
+		if opts.SDF {
+			sdfRectangle(&rect, opts.SDFSpread, opts.BitDepth == 16)
+		}
+		if opts.AlphaBleed > 0 {
+			rect.Image = bleedAlpha(rect.Image, opts.AlphaBleed, opts.BitDepth == 16)
+		}
+		if opts.RLE {
+			rect.Runs = analyzeRuns(rect.Image)
+		}
+		if opts.Dedup && !reload {
+			rect.PixelHash = pixelHash(rect.Image)
+		}
+		reporter.report(source.Name, img.Bounds())
+		return rect, "", nil
+	}
+
+	load := func(i int) {
+		rect, skip, err := sprite(i, false)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		if skip != "" {
+			skipped[i] = skip
+			return
+		}
+		if opts.stream {
+			rect.Image = nil
+			rect.Reload = func() (image.Image, error) {
+				rect, skip, err := sprite(i, true)
+				if err == nil && skip != "" {
+					err = fmt.Errorf("reloading %s: %s", sources[i].Name, skip)
+				}
+				return rect.Image, err
+			}
+		}
+		rectangles[i] = rect
+	}
+
+	workers := runtime.NumCPU()
+	if opts.Deterministic {
+		workers = 1
+	}
+	if err := runPool(ctx, len(sources), workers, load); err != nil {
+		return nil, err
+	}
+	// Report every file that failed, in order, rather than the first.
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if !opts.ExpectSize.IsZero() {
+		if err := checkSizes(spriteNames(sources), sizes, skipped, opts.ExpectSize); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.NameConventions != nil {
+		if err := checkConventionNames(rectangles, spriteNames(sources), skipped); err != nil {
+			return nil, err
+		}
+	}
+
+	rectangles = dropSkipped(rectangles, spriteNames(sources), skipped)
+	sortRectangles(rectangles, opts.Sort)
+	return rectangles, nil
+}
+
+// dropSkipped removes the rectangles of sprites with a reason to skip them,
+// warning about each in file order.
+func dropSkipped(rectangles []Rectangle, names, skipped []string) []Rectangle {
+	kept := rectangles[:0]
+	for i, rect := range rectangles {
+		if skipped[i] != "" {
+			warnSkipped(names[i], skipped[i])
+			continue
+		}
+		kept = append(kept, rect)
+	}
+	return kept
+}
+
+// sortRectangles orders rectangles by descending sidecar priority, then by
+// the descending sortKey of the -sort mode, then by descending height, the
+// order the shelf packer expects, and then by name, descending width and
+// ID. That is a total order, so sprites of equal priority and size always
+// keep a predictable order that does not depend on how they were loaded or
+// on the sort algorithm, even for two sprites with the same name.
+func sortRectangles(rectangles []Rectangle, mode string) {
+	sort.SliceStable(rectangles, func(i, j int) bool {
+		if rectangles[i].Meta.Priority != rectangles[j].Meta.Priority {
+			return rectangles[i].Meta.Priority > rectangles[j].Meta.Priority
+		}
+		if a, b := sortKey(rectangles[i], mode), sortKey(rectangles[j], mode); a != b {
+			return a > b
+		}
+		if rectangles[i].Height != rectangles[j].Height {
+			return rectangles[i].Height > rectangles[j].Height
+		}
+		if rectangles[i].Name != rectangles[j].Name {
+			return rectangles[i].Name < rectangles[j].Name
+		}
+		if rectangles[i].Width != rectangles[j].Width {
+			return rectangles[i].Width > rectangles[j].Width
+		}
+		return rectangles[i].ID < rectangles[j].ID
+	})
+}
+
+// loadImage opens and decodes one image file from opts.FS. A transient
+// failure to open or read the file is retried up to opts.Retries times,
+// waiting retryDelay before the first retry and twice as long before each
+// one after; a missing file or one that fails to decode is not retried.
+// Waiting stops once ctx is done.
+func loadImage(ctx context.Context, file string, opts Options) (image.Image, error) {
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		img, err := decodeImage(ctx, file, opts)
+		if err == nil || attempt >= opts.Retries || !isTransient(err) || ctx.Err() != nil {
+			return img, err
+		}
+		Warnf("loading %s failed: %v; retrying in %s", file, err, delay)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+		delay *= 2
+	}
+}
+
+// decodeImage makes a single attempt at opening and decoding an image file,
+// marking failures to open or read it as transient. Files with the .svg
+// extension are rasterized to fit opts.SVGSize, with a warning naming any
+// content the rasterizer left out.
+func decodeImage(ctx context.Context, file string, opts Options) (image.Image, error) {
+	f, err := openTransient(opts.FS, file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &errorRecorder{r: f}
+	var img image.Image
+	if isSVGFile(file) {
+		var unsupported []string
+		img, unsupported, err = decodeSVG(contextReader{ctx, r}, opts.SVGSize)
+		if err == nil && len(unsupported) > 0 {
+			Warnf("%s: SVG content not drawn: %s", file, strings.Join(unsupported, ", "))
+		}
+	} else {
+		img, _, err = image.Decode(contextReader{ctx, r})
+	}
+	if err != nil && r.err != nil {
+		return nil, transientError{fmt.Errorf("failed to read image: %w", r.err)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return img, nil
+}
+
+// generateAtlas packs the provided rectangles into a texture atlas image
+// using a shelf packing algorithm and returns the texture atlas image
+// along with the layout holding each rectangle's position in the atlas.
+func generateAtlas(ctx context.Context, rectangles []Rectangle, opts Options) (draw.Image, Layout, error) {
+	endPack := profilePhase(ctx, "pack")
+	layout, err := planLayout(rectangles, opts)
+	endPack()
+	if err != nil {
+		return nil, Layout{}, err
+	}
+	reportPlacements(rectangles, layout, opts.Progress)
+	atlas, err := drawAtlas(ctx, rectangles, layout, opts)
+	if err != nil {
+		return nil, Layout{}, err
+	}
+	return atlas, layout, nil
+}
+
+// planLayout computes the validated layout for the rectangles with the
+// packer opts selects. It only uses each rectangle's size, so it works on
+// rectangles whose pixels have not been decoded. Sprites and layouts that do
+// not fit the bounds, which are the canvas or mask when opts has one, are
+// an error.
+func planLayout(rectangles []Rectangle, opts Options) (Layout, error) {
+	if opts.Canvas != nil {
+		opts = canvasBound(opts)
+	}
+	if !opts.Reserve.IsZero() {
+		rectangles = withReserved(rectangles, opts.Reserve)
+	}
+	if opts.Canvas == nil && opts.Mask == nil {
+		if err := checkBound(rectangles, opts); err != nil {
+			return Layout{}, err
+		}
+	}
+	limit := image.Pt(opts.MaxWidth, opts.MaxHeight)
+	// The packers fill the space inside the border.
+	opts.MaxWidth -= 2 * opts.BorderPadding
+	opts.MaxHeight -= 2 * opts.BorderPadding
+	var layout Layout
+	var err error
+	switch {
+	case opts.Mask != nil:
+		layout, err = packMask(rectangles, opts)
+	case !opts.Cell.IsZero():
+		layout, err = packCells(rectangles, opts)
+	case opts.Strips:
+		layout, err = packStrips(rectangles, opts)
+	case opts.Packer == PackerMaxRects:
+		layout = packGrowth(rectangles, opts, packMaxRects)
+	case opts.TwoPass:
+		layout = packGrowth(rectangles, opts, packTwoPass)
+	case opts.ShelfFit == ShelfFitBest:
+		layout = packGrowth(rectangles, opts, packBestFit)
+	default:
+		layout = packGrowth(rectangles, opts, packRectangles)
+	}
+	if err != nil {
+		return Layout{}, err
+	}
+	if opts.Canvas != nil {
+		if err := checkCanvasFits(rectangles, layout, opts.Canvas); err != nil {
+			return Layout{}, err
+		}
+		layout.Width, layout.Height = opts.Canvas.Bounds().Dx(), opts.Canvas.Bounds().Dy()
+	}
+
+	if err := validatePlacements(rectangles, layout); err != nil {
+		return Layout{}, err
+	}
+	if !opts.Reserve.IsZero() {
+		layout = takeReserved(layout)
+	}
+	if opts.BorderPadding > 0 {
+		layout = withBorder(layout, opts.BorderPadding)
+	}
+	layout.Width = max(layout.Width, opts.MinSize.W)
+	layout.Height = max(layout.Height, opts.MinSize.H)
+	if opts.Mask == nil && (layout.Width > limit.X || layout.Height > limit.Y) {
+		return Layout{}, &boundsError{Size: image.Pt(layout.Width, layout.Height), Limit: limit}
+	}
+	if opts.RequirePOT && !opts.TextureArray {
+		if err := checkPowerOfTwo(layout); err != nil {
+			return Layout{}, err
+		}
+	}
+	return layout, nil
+}
+
+// packTwoPass packs the rectangles once as a trial, then packs again
+// with the sprites that caused the most waste moved to the front of the order.
+// A sprite that had to open a new shelf below the first one grows the atlas
+// by a whole shelf, so those sprites are packed first, largest area first,
+// giving them a chance to claim space on the upper shelves. Sidecar
+// priorities still take precedence over this reordering.
+// The layout with the better occupancy is returned, and the occupancy of both
+// passes is reported so the extra packing time can be judged.
+func packTwoPass(rectangles []Rectangle, opts Options) Layout {
+	trial := packRectangles(rectangles, opts)
+
+	cost := make(map[int]int, len(rectangles))
+	for _, rect := range rectangles {
+		placed := trial.Placements[rect.ID]
+		if placed.Min.X == 0 && placed.Min.Y > 0 {
+			cost[rect.ID] = rect.Width * rect.Height
+		}
+	}
+
+	reordered := make([]Rectangle, len(rectangles))
+	copy(reordered, rectangles)
+	sort.SliceStable(reordered, func(i, j int) bool {
+		if reordered[i].Meta.Priority != reordered[j].Meta.Priority {
+			return reordered[i].Meta.Priority > reordered[j].Meta.Priority
+		}
+		return cost[reordered[i].ID] > cost[reordered[j].ID]
+	})
+	final := packRectangles(reordered, opts)
+
+	before := occupancy(rectangles, trial)
+	after := occupancy(rectangles, final)
+	if after <= before {
+		fmt.Printf("Two-pass packing: occupancy %.1f%% -> %.1f%%, keeping single-pass layout\n", before*100, after*100)
+		return trial
+	}
+	fmt.Printf("Two-pass packing: occupancy %.1f%% -> %.1f%% (+%.1f points)\n", before*100, after*100, (after-before)*100)
+	return final
+}
+
+// packRectangles computes shelf placements for the rectangles in the order
+// given, without drawing anything, and returns the resulting layout, using
+// the shelf packer of the packer package.
+// opts.Padding.X pixels are left between neighbours on a shelf and
+// opts.Padding.Y pixels between consecutive shelves. Of the shelves with
+// room for a rectangle, opts.ShelfFit picks the first one or, with
+// ShelfFitBest, the one leaving the least unused height above it. With
+// opts.ShelfBucket, new shelves are opened at the rectangle's height rounded
+// up to a multiple of it, so sprites of similar height can share a shelf.
+// With opts.SpriteAlign, sprites and shelves start at x and y coordinates
+// rounded up to a multiple of it. With opts.AllowRotation, a sprite that
+// fits no shelf as it is but does turned is placed turned.
+func packRectangles(rectangles []Rectangle, opts Options) Layout {
+	sizes := make([]image.Point, len(rectangles))
+	for i, rect := range rectangles {
+		sizes[i] = image.Pt(rect.Width, rect.Height)
+	}
+	placements, size := Place(sizes, ShelfOptions{
+		MaxWidth:  opts.MaxWidth,
+		MaxHeight: opts.MaxHeight,
+		Padding:   image.Pt(opts.Padding.X, opts.Padding.Y),
+		BestFit:   opts.ShelfFit == ShelfFitBest,
+		Prefer:    func(a, b image.Point) bool { return preferPosition(opts.TieBreak, a, b) },
+		Bucket:    opts.ShelfBucket,
+		Align:     opts.SpriteAlign,
+		Rotate:    opts.AllowRotation,
+	})
+	packedRectangles := make(map[int]image.Rectangle, len(rectangles))
+	for i, rect := range rectangles {
+		packedRectangles[rect.ID] = placements[i]
+	}
+	return Layout{Placements: packedRectangles, Width: size.X, Height: size.Y}
+}
+
+// checkBound fails if a rectangle is wider than opts.MaxWidth or taller
+// than opts.MaxHeight, less the -borderpadding margin on either side, since
+// no atlas within the bounds could hold it. With opts.AllowRotation, a
+// rectangle that fits turned passes. The error names the largest such
+// rectangle and the bound needed, checking widths first.
+func checkBound(rectangles []Rectangle, opts Options) error {
+	axes := []struct {
+		flag      string
+		bound     int
+		side      func(Rectangle) int
+		dimension string
+	}{
+		{"-maxwidth", opts.MaxWidth, func(r Rectangle) int { return r.Width }, "wide"},
+		{"-maxheight", opts.MaxHeight, func(r Rectangle) int { return r.Height }, "tall"},
+	}
+	border := 2 * opts.BorderPadding
+	fitsTurned := func(r Rectangle) bool {
+		return opts.AllowRotation && r.Height+border <= opts.MaxWidth && r.Width+border <= opts.MaxHeight
+	}
+	for _, axis := range axes {
+		largest := -1
+		for i, rect := range rectangles {
+			if axis.side(rect)+border > axis.bound && !fitsTurned(rect) && (largest < 0 || axis.side(rect) > axis.side(rectangles[largest])) {
+				largest = i
+			}
+		}
+		if largest < 0 {
+			continue
+		}
+		rect := rectangles[largest]
+		side := axis.side(rect)
+		if border > 0 {
+			return fmt.Errorf("sprite %s is %dpx %s, more than the %dpx the %s bound of %dpx leaves inside -borderpadding; raise %s to at least %d", rect.Name, side, axis.dimension, axis.bound-border, axis.flag, axis.bound, axis.flag, side+border)
+		}
+		return fmt.Errorf("sprite %s is %dpx %s, more than the %s bound of %dpx; raise %s to at least %d", rect.Name, side, axis.dimension, axis.flag, axis.bound, axis.flag, side)
+	}
+	return nil
+}
+
+// checkPowerOfTwo fails if either side of the layout is not a power of two,
+// for pipelines that must only ship such atlases. Texture array layers are
+// rounded up to powers of two anyway and need no check.
+func checkPowerOfTwo(layout Layout) error {
+	if isPowerOfTwo(layout.Width) && isPowerOfTwo(layout.Height) {
+		return nil
+	}
+	return fmt.Errorf("atlas is %dx%d, which -requirepot rejects as not a power of two on each side; -minsize %dx%d would round it up", layout.Width, layout.Height, nextPowerOfTwo(layout.Width), nextPowerOfTwo(layout.Height))
+}
+
+// validatePlacements checks that the layout holds a placement for every
+// rectangle and for nothing else, so a packer that fails to place a sprite
+// cannot silently drop it from the atlas. planLayout checks what the packer
+// returned, and drawAtlas the layout it is given. The error names every
+// sprite that was not placed.
+func validatePlacements(rectangles []Rectangle, layout Layout) error {
+	var missing []string
+	for _, rect := range rectangles {
+		if _, ok := layout.Placements[rect.ID]; !ok {
+			missing = append(missing, rect.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%d of %d sprites were not placed: %s", len(missing), len(rectangles), strings.Join(missing, ", "))
+	}
+	if len(layout.Placements) != len(rectangles) {
+		return fmt.Errorf("layout has %d placements for %d sprites", len(layout.Placements), len(rectangles))
+	}
+	return nil
+}
+
+// reportPlacements calls progress, if set, for each rectangle in packing order
+// with the position it was given in the layout.
+func reportPlacements(rectangles []Rectangle, layout Layout, progress ProgressFunc) {
+	reporter := newProgressReporter(progress, StagePlace, len(rectangles))
+	for _, rect := range rectangles {
+		reporter.report(rect.Name, layout.Placements[rect.ID])
+	}
+}
+
+// drawAtlas draws every rectangle's image at its placement in an atlas of
+// the layout's size, over opts.Canvas or opts.Background, and returns it.
+// The atlas holds straight alpha, as an *image.NRGBA or at 16 bits an
+// *image.NRGBA64, so the colors opts.AlphaBleed spreads into transparent
+// pixels survive; single-channel and SDF atlases are gray or alpha images
+// instead. Sprites are drawn concurrently, yet the atlas is the same byte
+// for byte as if they were drawn in order, as any that overlap are drawn
+// last, one at a time. A rectangle without a placement is an error, and
+// drawing stops once ctx is done, returning the cause.
+func drawAtlas(ctx context.Context, rectangles []Rectangle, layout Layout, opts Options) (draw.Image, error) {
+	defer profilePhase(ctx, "draw")()
+	if err := validatePlacements(rectangles, layout); err != nil {
+		return nil, err
+	}
+	bounds := image.Rect(0, 0, layout.Width, layout.Height)
+	var atlas draw.Image
+	switch {
+	case opts.SDF && opts.BitDepth == 16:
+		atlas = image.NewGray16(bounds)
+	case opts.SDF:
+		atlas = image.NewGray(bounds)
+	case opts.Channels == ChannelsGray && opts.BitDepth == 16:
+		atlas = image.NewGray16(bounds)
+	case opts.Channels == ChannelsGray:
+		atlas = image.NewGray(bounds)
+	case opts.Channels == ChannelsAlpha && opts.BitDepth == 16:
+		atlas = image.NewAlpha16(bounds)
+	case opts.Channels == ChannelsAlpha:
+		atlas = image.NewAlpha(bounds)
+	case opts.BitDepth == 16:
+		atlas = image.NewNRGBA64(bounds)
+	default:
+		atlas = image.NewNRGBA(bounds)
+	}
+	if opts.Canvas != nil {
+		draw.Draw(atlas, bounds, opts.Canvas, opts.Canvas.Bounds().Min, draw.Src)
+	}
+	if opts.Background != (color.NRGBA{}) {
+		fillBackground(atlas, opts.Background)
+	}
+
+	overlapping := overlappingSprites(rectangles, layout)
+	errs := make([]error, len(rectangles))
+	drawSprite := func(i int) {
+		rect := rectangles[i]
+		img, err := rect.pixels()
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		placed := layout.Placements[rect.ID]
+		if rotated(rect, placed) {
+			draw.Draw(atlas, placed, Rotate(img, false), image.Point{}, draw.Src)
+			return
+		}
+		// Trimmed sub-images and some decoders' images do not start at
+		// the origin.
+		draw.Draw(atlas, placed, img, img.Bounds().Min, draw.Src)
+	}
+	err := runPool(ctx, len(rectangles), runtime.NumCPU(), func(i int) {
+		if !overlapping[i] {
+			drawSprite(i)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range rectangles {
+		if overlapping[i] {
+			if ctx.Err() != nil {
+				return nil, context.Cause(ctx)
+			}
+			drawSprite(i)
+		}
+	}
+	// The first failure in order is reported, not whichever came first.
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Extrude > 0 {
+		for _, rect := range rectangles {
+			extrude(atlas, layout.Placements[rect.ID], opts.Extrude)
+		}
+	}
+	return alphaAsGray(atlas), nil
+}
+
+// overlappingSprites reports, for each rectangle, whether its placement
+// overlaps that of a rectangle before it. The rest are pairwise disjoint,
+// so drawing them in any order gives the same pixels.
+func overlappingSprites(rectangles []Rectangle, layout Layout) []bool {
+	order := make([]int, len(rectangles))
+	for i := range order {
+		order[i] = i
+	}
+	placed := func(i int) image.Rectangle { return layout.Placements[rectangles[i].ID] }
+	sort.Slice(order, func(a, b int) bool { return placed(order[a]).Min.X < placed(order[b]).Min.X })
+
+	overlapping := make([]bool, len(rectangles))
+	for a, i := range order {
+		r := placed(i)
+		// Sorted by left edge, so no rectangle from the first one starting
+		// beyond r's right edge on can overlap it.
+		for _, j := range order[a+1:] {
+			if placed(j).Min.X >= r.Max.X {
+				break
+			}
+			if r.Overlaps(placed(j)) {
+				overlapping[max(i, j)] = true
+			}
+		}
+	}
+	return overlapping
+}
+
+// occupancy returns the fraction of the layout's atlas area covered by the
+// rectangles, in the range [0, 1].
+func occupancy(rectangles []Rectangle, layout Layout) float64 {
+	atlasArea := layout.Width * layout.Height
+	if atlasArea == 0 {
+		return 0
+	}
+	used := 0
+	for _, rect := range rectangles {
+		used += rect.Width * rect.Height
+	}
+	return float64(used) / float64(atlasArea)
+}
+
+// saveAtlas saves the texture atlas image as a PNG file with the specified filename,
+// compressed at opts.PNGCompression, as a GIF reduced to opts.GIFColors
+// colors when the filename ends in .gif, or as a JPEG at opts.JPEGQuality
+// when it ends in .jpg or .jpeg.
+// The file is replaced atomically once the image is fully encoded. Unless
+// opts.Overwrite is set, it fails if the file already exists.
+func saveAtlas(filename string, atlas image.Image, opts Options) error {
+	var set outputSet
+	defer set.Abort()
+	if err := encodeAtlas(&set, filename, atlas, opts); err != nil {
+		return err
+	}
+	return set.Commit()
+}
+
+// encodeAtlas writes the atlas image to filename as saveAtlas does, but as
+// part of set, which moves it into place when committed.
+func encodeAtlas(set *outputSet, filename string, atlas image.Image, opts Options) error {
+	f, err := set.Create(filename, opts.Overwrite)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	switch OutputFormat(filename) {
+	case "gif":
+		err = encodeGIF(w, filename, atlas, opts)
+	case "jpeg":
+		err = encodeJPEG(w, atlas, opts)
+	default:
+		encoder := png.Encoder{CompressionLevel: opts.PNGCompression}
+		err = encoder.Encode(w, atlas)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// printAtlasInfo prints information about the generated texture atlas,
+// including its dimensions and the positions of packed rectangles, listed in
+// ID order so the output is the same from run to run.
+func printAtlasInfo(filename string, width, height int, packedRectangles map[int]image.Rectangle) {
+	fmt.Printf("Atlas size: %d x %d\n", width, height)
+	fmt.Println("Packed rectangles:")
+	ids := make([]int, 0, len(packedRectangles))
+	for id := range packedRectangles {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		fmt.Printf("ID: %d, Rect: %v\n", id, packedRectangles[id])
+	}
+	fmt.Printf("Atlas saved as %s successfully.\n", filename)
+}
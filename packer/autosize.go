@@ -0,0 +1,24 @@
+package packer
+
+import "fmt"
+
+// autoSizes are the standard power-of-two page sizes -autosize chooses
+// from, smallest first.
+var autoSizes = []int{256, 512, 1024, 2048, 4096}
+
+// autoSize returns the smallest of autoSizes such that the rectangles,
+// packed with it as both the -maxwidth and the -maxheight bound, fit on a
+// single square page of that size. It fails when even the largest is too small, as the sprites
+// then need more than one page.
+func autoSize(rectangles []Rectangle, opts Options) (int, error) {
+	for _, size := range autoSizes {
+		trial := opts
+		trial.MaxWidth, trial.MaxHeight = size, size
+		layout, err := planLayout(rectangles, trial)
+		if err == nil && layout.Width <= size && layout.Height <= size {
+			return size, nil
+		}
+	}
+	largest := autoSizes[len(autoSizes)-1]
+	return 0, fmt.Errorf("%d sprites do not fit on a single %dx%d page; split them across pages with -maxperpage and set -maxwidth and -maxheight instead of -autosize", len(rectangles), largest, largest)
+}
@@ -0,0 +1,42 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+)
+
+// averageColor returns the mean color of the sprite's visible pixels as
+// "#rrggbbaa", the form colors take elsewhere in the tool. Red, green and
+// blue are averaged weighted by alpha, so faint edge pixels count for
+// little and the colors of fully transparent pixels not at all, and alpha
+// is averaged over the pixels that are not fully transparent. A fully
+// transparent sprite has no average color and gives "".
+func averageColor(img image.Image) string {
+	at := func(x, y int) (uint32, uint32, uint32, uint32) { return img.At(x, y).RGBA() }
+	if m, ok := img.(image.RGBA64Image); ok {
+		at = func(x, y int) (uint32, uint32, uint32, uint32) {
+			c := m.RGBA64At(x, y)
+			return uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+		}
+	}
+
+	var r, g, b, a, visible uint64
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pr, pg, pb, pa := at(x, y)
+			if pa == 0 {
+				continue
+			}
+			// The channels are premultiplied, so summing them weights each
+			// color by its alpha.
+			r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+			visible++
+		}
+	}
+	if visible == 0 {
+		return ""
+	}
+	straight := func(sum uint64) uint64 { return (sum*0xff + a/2) / a }
+	return fmt.Sprintf("#%02x%02x%02x%02x", straight(r), straight(g), straight(b), (a*0xff/0xffff+visible/2)/visible)
+}
@@ -0,0 +1,46 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+)
+
+// ParseBackground parses a -background color written as "#rrggbbaa", or as
+// "#rrggbb" for an opaque one, with or without the leading "#". The color
+// has straight alpha, as the atlas does.
+func ParseBackground(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	switch {
+	case err != nil || len(hex) != 6 && len(hex) != 8:
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: want #rrggbbaa or #rrggbb", s)
+	case len(hex) == 6:
+		v = v<<8 | 0xff
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// fillBackground sets every pixel of the atlas to c. Atlases with straight
+// alpha get c exactly, so a transparent color keeps its red, green and blue,
+// which draw.Draw would premultiply away; others get c converted to their
+// color model.
+func fillBackground(atlas draw.Image, c color.NRGBA) {
+	switch dst := atlas.(type) {
+	case *image.NRGBA:
+		for i := 0; i < len(dst.Pix); i += 4 {
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = c.R, c.G, c.B, c.A
+		}
+	case *image.NRGBA64:
+		// Each 8-bit channel is repeated in both bytes, so 0xff becomes 0xffff.
+		for i := 0; i < len(dst.Pix); i += 8 {
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = c.R, c.R, c.G, c.G
+			dst.Pix[i+4], dst.Pix[i+5], dst.Pix[i+6], dst.Pix[i+7] = c.B, c.B, c.A, c.A
+		}
+	default:
+		draw.Draw(atlas, atlas.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+}
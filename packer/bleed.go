@@ -0,0 +1,85 @@
+package packer
+
+import (
+	"image"
+	"image/color"
+)
+
+// bleedAlpha returns a copy of img in which the color of its visible pixels
+// has been spread into the fully transparent pixels around them. Each
+// iteration gives every transparent pixel bordering a visible or already
+// bled pixel the average color of those neighbors, growing the colored
+// region by one pixel. Alpha is left untouched, so the sprite looks the same
+// but bilinear filtering and mipmapping near its edges no longer pull in
+// black. The copy has the same bounds as img and is an *image.NRGBA64 when
+// deep is set, an *image.NRGBA otherwise. An opaque img has no transparent
+// pixels to bleed into and is returned as is.
+func bleedAlpha(img image.Image, iterations int, deep bool) image.Image {
+	if !hasAlpha(img) {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	pix := make([]color.NRGBA64, w*h)
+	filled := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA64Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA64)
+			pix[y*w+x] = c
+			filled[y*w+x] = c.A != 0
+		}
+	}
+
+	next := make([]bool, w*h)
+	for i := 0; i < iterations; i++ {
+		copy(next, filled)
+		grown := false
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if filled[y*w+x] {
+					continue
+				}
+				var r, g, bl, n uint32
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						nx, ny := x+dx, y+dy
+						if nx < 0 || ny < 0 || nx >= w || ny >= h || !filled[ny*w+nx] {
+							continue
+						}
+						c := pix[ny*w+nx]
+						r, g, bl, n = r+uint32(c.R), g+uint32(c.G), bl+uint32(c.B), n+1
+					}
+				}
+				if n == 0 {
+					continue
+				}
+				pix[y*w+x] = color.NRGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n)}
+				next[y*w+x] = true
+				grown = true
+			}
+		}
+		filled, next = next, filled
+		if !grown {
+			break
+		}
+	}
+
+	if deep {
+		out := image.NewNRGBA64(b)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				out.SetNRGBA64(b.Min.X+x, b.Min.Y+y, pix[y*w+x])
+			}
+		}
+		return out
+	}
+	out := image.NewNRGBA(b)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := pix[y*w+x]
+			out.SetNRGBA(b.Min.X+x, b.Min.Y+y, color.NRGBA{R: uint8(c.R >> 8), G: uint8(c.G >> 8), B: uint8(c.B >> 8), A: uint8(c.A >> 8)})
+		}
+	}
+	return out
+}
@@ -0,0 +1,36 @@
+package packer
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// TestAlphaBleedInAtlas checks that the colors -alphableed spreads into
+// transparent pixels are still there once the sprite is drawn into the
+// atlas, for both bit depths.
+func TestAlphaBleedInAtlas(t *testing.T) {
+	sprite := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	sprite.SetNRGBA(1, 0, color.NRGBA{R: 0xff, G: 0x80, A: 0xff})
+	for _, depth := range []int{8, 16} {
+		bled := bleedAlpha(sprite, 1, depth == 16)
+		rectangles := []Rectangle{{ID: 0, Name: "s.png", Width: 3, Height: 1, Image: bled}}
+		layout := Layout{Placements: map[int]image.Rectangle{0: image.Rect(0, 0, 3, 1)}, Width: 3, Height: 1}
+		atlas, err := drawAtlas(context.Background(), rectangles, layout, Options{BitDepth: depth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, x := range []int{0, 2} {
+			// Converting through RGBA would premultiply the color away, so
+			// the pixel is read in the atlas's own straight-alpha model.
+			var got, want color.Color = atlas.At(x, 0), color.NRGBA{R: 0xff, G: 0x80}
+			if depth == 16 {
+				want = color.NRGBA64{R: 0xffff, G: 0x8080}
+			}
+			if got != want {
+				t.Errorf("%d-bit atlas: pixel %d is %v, want the bled %v", depth, x, got, want)
+			}
+		}
+	}
+}
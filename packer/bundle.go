@@ -0,0 +1,113 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bundleMagic starts every bundle written with -bundle.
+const bundleMagic = "TPBUNDLE"
+
+// A bundle holds an atlas image and its manifest in one file, for loaders
+// that would rather open one file than two. All integers are little-endian:
+//
+//	magic          8 bytes, "TPBUNDLE"
+//	manifest size  uint32, in bytes
+//	image size     uint32, in bytes
+//	manifest       the JSON manifest
+//	image          the atlas PNG, exactly as saved beside the bundle
+//
+// The manifest's image field still names the atlas file, so a bundle can
+// be unpacked into the same pair of files the run wrote.
+
+// bundleFilename returns the filename of the bundle written beside an atlas
+// with -bundle.
+func bundleFilename(atlasFile string) string {
+	return strings.TrimSuffix(atlasFile, filepath.Ext(atlasFile)) + ".tpb"
+}
+
+// saveBundle writes a bundle of the saved atlas image atlasFile and its
+// manifest to filename, honoring overwrite.
+func saveBundle(filename, atlasFile string, manifest Manifest, overwrite bool) error {
+	img, err := os.ReadFile(atlasFile)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if uint64(len(data)) > 1<<32-1 || uint64(len(img)) > 1<<32-1 {
+		return errors.New("atlas or manifest too large for a bundle")
+	}
+
+	var b bytes.Buffer
+	b.WriteString(bundleMagic)
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	binary.Write(&b, binary.LittleEndian, uint32(len(img)))
+	b.Write(data)
+	b.Write(img)
+	return writeOutput(filename, b.Bytes(), overwrite)
+}
+
+// readBundle reads a bundle written by saveBundle, returning its manifest and
+// the encoded atlas image.
+func readBundle(r io.Reader) (Manifest, []byte, error) {
+	var header [len(bundleMagic) + 8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Manifest{}, nil, fmt.Errorf("reading bundle header: %w", err)
+	}
+	if string(header[:len(bundleMagic)]) != bundleMagic {
+		return Manifest{}, nil, errors.New("not a bundle")
+	}
+	manifestSize := binary.LittleEndian.Uint32(header[len(bundleMagic):])
+	imageSize := binary.LittleEndian.Uint32(header[len(bundleMagic)+4:])
+
+	data := make([]byte, manifestSize)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return Manifest{}, nil, fmt.Errorf("reading bundle manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, nil, fmt.Errorf("parsing bundle manifest: %w", err)
+	}
+	img := make([]byte, imageSize)
+	if _, err := io.ReadFull(r, img); err != nil {
+		return Manifest{}, nil, fmt.Errorf("reading bundle image: %w", err)
+	}
+	return manifest, img, nil
+}
+
+// verifyBundle reads a bundle back and checks that its image decodes to the
+// size its manifest gives and that the manifest lists the expected number
+// of sprites.
+func verifyBundle(filename string, sprites int) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	manifest, img, err := readBundle(f)
+	if err != nil {
+		return err
+	}
+	decoded, err := png.Decode(bytes.NewReader(img))
+	if err != nil {
+		return fmt.Errorf("decoding bundle image: %w", err)
+	}
+	if b := decoded.Bounds(); b.Dx() != manifest.Width || b.Dy() != manifest.Height {
+		return fmt.Errorf("bundle image is %dx%d, manifest says %dx%d", b.Dx(), b.Dy(), manifest.Width, manifest.Height)
+	}
+	if len(manifest.Sprites) != sprites {
+		return fmt.Errorf("bundle manifest lists %d sprites, expected %d", len(manifest.Sprites), sprites)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package packer
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// runPool calls fn for every index in [0, n) from a pool of workers, handing
+// out indices in order, and returns once every call has finished. When ctx
+// is done no further indices are handed out; the calls in progress are
+// waited for, so none is still writing to the caller's results, and the
+// cause of the cancellation is returned.
+func runPool(ctx context.Context, n, workers int, fn func(i int)) error {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := 0; i < n; i++ {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// contextReader is an io.Reader that fails with the cause of its context's
+// cancellation once the context is done, so a decoder reading from it stops
+// at its next read instead of running to completion.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done.
+func (r contextReader) Read(p []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, context.Cause(r.ctx)
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,58 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRunPoolWaitsForCalls checks that a cancelled pool returns the cause
+// only after the calls in progress have finished, so none of them writes to
+// the results afterwards.
+func TestRunPoolWaitsForCalls(t *testing.T) {
+	cause := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	results := make([]int, 100)
+	var started atomic.Int32
+	err := runPool(ctx, len(results), 4, func(i int) {
+		if started.Add(1) == 8 {
+			cancel(cause)
+		}
+		time.Sleep(time.Millisecond)
+		results[i] = i + 1
+	})
+	if !errors.Is(err, cause) {
+		t.Fatalf("runPool returned %v, want %v", err, cause)
+	}
+
+	finished := 0
+	for _, result := range results {
+		if result != 0 {
+			finished++
+		}
+	}
+	if calls := int(started.Load()); finished != calls {
+		t.Errorf("%d calls finished before runPool returned, want all %d started", finished, calls)
+	}
+	if finished == len(results) {
+		t.Errorf("all %d calls ran after the pool was cancelled", finished)
+	}
+}
+
+// TestRunPoolCallsEveryIndex checks that an uncancelled pool calls fn once
+// for each index.
+func TestRunPoolCallsEveryIndex(t *testing.T) {
+	calls := make([]int32, 50)
+	if err := runPool(context.Background(), len(calls), 3, func(i int) {
+		atomic.AddInt32(&calls[i], 1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range calls {
+		if n != 1 {
+			t.Errorf("index %d called %d times, want 1", i, n)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"os"
+)
+
+// DecodeImageFile decodes an image file named on the command line, such as
+// the -canvas or -maskshape image.
+func DecodeImageFile(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", filename, err)
+	}
+	return img, nil
+}
+
+// canvasBound returns a copy of opts whose size bounds are those of the
+// canvas.
+func canvasBound(opts Options) Options {
+	bounded := opts
+	bounded.MaxWidth, bounded.MaxHeight = opts.Canvas.Bounds().Dx(), opts.Canvas.Bounds().Dy()
+	return bounded
+}
+
+// checkCanvasFits reports the first sprite that is larger than the canvas,
+// or, failing that, a layout that does not fit within it.
+func checkCanvasFits(rectangles []Rectangle, layout Layout, canvas image.Image) error {
+	w, h := canvas.Bounds().Dx(), canvas.Bounds().Dy()
+	for _, rect := range rectangles {
+		if rect.Width > w || rect.Height > h {
+			return fmt.Errorf("sprite %s is %dx%d, larger than the %dx%d canvas", rect.Name, rect.Width, rect.Height, w, h)
+		}
+	}
+	if layout.Width > w || layout.Height > h {
+		return fmt.Errorf("sprites need %dx%d, more than the %dx%d canvas", layout.Width, layout.Height, w, h)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+// fitRectangle scales the rectangle's image down, preserving its aspect
+// ratio, so it fits inside a cell. Images that already fit are left alone.
+// The size before scaling is kept in OriginalWidth and OriginalHeight.
+func fitRectangle(rect *Rectangle, cell Size) {
+	if rect.Width <= cell.W && rect.Height <= cell.H {
+		return
+	}
+
+	w, h := fitSize(rect.Width, rect.Height, cell)
+
+	rect.Resized = true
+	rect.OriginalWidth, rect.OriginalHeight = rect.Width, rect.Height
+	rect.Image = resizeArea(rect.Image, w, h)
+	rect.Width, rect.Height = w, h
+}
+
+// packCells places every rectangle in its own cell of a uniform grid, in
+// name order from left to right and top to bottom, centering each within its
+// cell. As many columns are used as fit within the width bound, with
+// opts.Padding between cells. Rectangles larger than a cell, which
+// fitRectangle would have scaled down, are an error.
+func packCells(rectangles []Rectangle, opts Options) (Layout, error) {
+	cell, padX, padY := opts.Cell, opts.Padding.X, opts.Padding.Y
+	columns := max(1, (opts.MaxWidth+padX)/(cell.W+padX))
+	columns = min(columns, max(1, len(rectangles)))
+
+	ordered := make([]Rectangle, len(rectangles))
+	copy(ordered, rectangles)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Name < ordered[j].Name })
+
+	layout := Layout{Placements: make(map[int]image.Rectangle, len(rectangles))}
+	for i, rect := range ordered {
+		if rect.Width > cell.W || rect.Height > cell.H {
+			return Layout{}, fmt.Errorf("sprite %s is %dx%d, larger than the %dx%d cell", rect.Name, rect.Width, rect.Height, cell.W, cell.H)
+		}
+		col, row := i%columns, i/columns
+		x := col*(cell.W+padX) + (cell.W-rect.Width)/2
+		y := row*(cell.H+padY) + (cell.H-rect.Height)/2
+		layout.Placements[rect.ID] = image.Rect(x, y, x+rect.Width, y+rect.Height)
+	}
+
+	if len(ordered) > 0 {
+		rows := (len(ordered) + columns - 1) / columns
+		layout.Width = columns*cell.W + (columns-1)*padX
+		layout.Height = rows*cell.H + (rows-1)*padY
+	}
+	return layout, nil
+}
@@ -0,0 +1,31 @@
+package packer
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Values of the -channels flag, selecting what the atlas image stores.
+const (
+	// ChannelsRGBA stores color and alpha.
+	ChannelsRGBA = "rgba"
+	// ChannelsAlpha stores only the sprites' alpha, as a grayscale image.
+	ChannelsAlpha = "alpha"
+	// ChannelsGray stores only the sprites' luminance, composited over
+	// black where they are translucent.
+	ChannelsGray = "gray"
+)
+
+// alphaAsGray reinterprets a drawn alpha-only atlas as a grayscale image
+// whose values are the alpha, sharing its pixels. The PNG encoder writes
+// image.Alpha as full RGBA, but image.Gray as a single channel.
+func alphaAsGray(atlas draw.Image) draw.Image {
+	switch a := atlas.(type) {
+	case *image.Alpha:
+		return &image.Gray{Pix: a.Pix, Stride: a.Stride, Rect: a.Rect}
+	case *image.Alpha16:
+		return &image.Gray16{Pix: a.Pix, Stride: a.Stride, Rect: a.Rect}
+	default:
+		return atlas
+	}
+}
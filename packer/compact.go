@@ -0,0 +1,118 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+// compactShelves moves whole bottom shelves of a shelf layout into the free
+// space above them, one shelf at a time from the bottom, for as long as
+// every sprite of the shelf finds a gap within the atlas's width that does
+// not reach below the sprites staying put. Each sprite, largest first, takes
+// the top-left corner of the smallest free region that holds it with its
+// padding, so the atlas ends at the bottom of the shelves left. A shelf that
+// does not fit entirely is left where it is, as moving only part of it would
+// not shorten the atlas. The number of pixels saved is reported.
+func compactShelves(layout Layout, opts Options) Layout {
+	placements := make(map[int]image.Rectangle, len(layout.Placements))
+	for id, r := range layout.Placements {
+		placements[id] = r
+	}
+	height, moved, shelves := layout.Height, 0, 0
+	for {
+		shelf, rest := bottomShelf(placements)
+		if len(rest) == 0 || shelf[0] == reservedID {
+			break
+		}
+		restHeight := 0
+		for _, id := range rest {
+			restHeight = max(restHeight, placements[id].Max.Y)
+		}
+		trial := make(map[int]image.Rectangle, len(rest)+len(shelf))
+		for _, id := range rest {
+			trial[id] = placements[id]
+		}
+		if restHeight >= height {
+			break
+		}
+		bounds := image.Rect(0, 0, layout.Width, restHeight)
+		if !relocate(trial, shelf, placements, bounds, opts) {
+			break
+		}
+		placements = trial
+		height = restHeight
+		moved += len(shelf)
+		shelves++
+	}
+	if shelves == 0 {
+		return layout
+	}
+	fmt.Printf("Compaction: moved %d sprites off %d bottom shelves, saving %dpx\n", moved, shelves, layout.Height-height)
+
+	width := 0
+	for _, r := range placements {
+		width = max(width, r.Max.X)
+	}
+	layout.Placements, layout.Width, layout.Height = placements, width, height
+	return layout
+}
+
+// bottomShelf splits the placements into the IDs of the sprites on the
+// lowest shelf, the ones starting furthest down, largest first, and the IDs
+// of all others.
+func bottomShelf(placements map[int]image.Rectangle) (shelf, rest []int) {
+	y := 0
+	for _, r := range placements {
+		y = max(y, r.Min.Y)
+	}
+	for id, r := range placements {
+		if r.Min.Y == y {
+			shelf = append(shelf, id)
+		} else {
+			rest = append(rest, id)
+		}
+	}
+	sort.Slice(shelf, func(i, j int) bool {
+		a, b := placements[shelf[i]], placements[shelf[j]]
+		if a.Dx()*a.Dy() != b.Dx()*b.Dy() {
+			return a.Dx()*a.Dy() > b.Dx()*b.Dy()
+		}
+		return shelf[i] < shelf[j]
+	})
+	return shelf, rest
+}
+
+// relocate places each of the sprites with the given IDs, sized as in from,
+// into a free region of trial within bounds, adding it to trial, and reports
+// whether all of them found one. A sprite needs its padding to the right and
+// below as well, except along the edges of bounds, and starts at coordinates
+// aligned to opts.SpriteAlign.
+func relocate(trial map[int]image.Rectangle, ids []int, from map[int]image.Rectangle, bounds image.Rectangle, opts Options) bool {
+	for _, id := range ids {
+		size := from[id].Size()
+		regions := freeRegions(Layout{Placements: trial, Width: bounds.Dx(), Height: bounds.Dy()}, opts.Padding)
+		best, bestArea := image.Rectangle{}, -1
+		for _, region := range regions {
+			at := image.Pt(alignUp(region.Min.X, opts.SpriteAlign), alignUp(region.Min.Y, opts.SpriteAlign))
+			need := at.Add(size)
+			if region.Max.X < bounds.Max.X {
+				need.X += opts.Padding.X
+			}
+			if region.Max.Y < bounds.Max.Y {
+				need.Y += opts.Padding.Y
+			}
+			if need.X > region.Max.X || need.Y > region.Max.Y {
+				continue
+			}
+			if area := region.Dx() * region.Dy(); bestArea < 0 || area < bestArea {
+				best, bestArea = image.Rectangle{Min: at, Max: at.Add(size)}, area
+			}
+		}
+		if bestArea < 0 {
+			return false
+		}
+		trial[id] = best
+	}
+	return true
+}
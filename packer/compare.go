@@ -0,0 +1,98 @@
+package packer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+)
+
+// compareManifests compares each written manifest with its reference and
+// prints every difference, returning the number of manifests that differ.
+// When reference is a directory, each manifest is compared with the file of
+// the same relative path inside it; otherwise reference is the reference
+// for the single manifest written. Only the atlas size and the sprite
+// entries are compared, so renaming the outputs, for example with a name
+// template, is not reported as a change.
+func compareManifests(manifestFiles []string, reference string) (int, error) {
+	info, err := os.Stat(reference)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() && len(manifestFiles) != 1 {
+		return 0, fmt.Errorf("%s is a file but %d manifests were written; give a directory of reference manifests", reference, len(manifestFiles))
+	}
+
+	changed := 0
+	for _, file := range manifestFiles {
+		referenceFile := reference
+		if info.IsDir() {
+			referenceFile = filepath.Join(reference, file)
+		}
+		got, err := ReadManifest(file)
+		if err != nil {
+			return 0, err
+		}
+		want, err := ReadManifest(referenceFile)
+		if err != nil {
+			return 0, err
+		}
+		differences := diffManifests(got, want)
+		if len(differences) == 0 {
+			fmt.Printf("Manifest %s matches %s\n", file, referenceFile)
+			continue
+		}
+		changed++
+		fmt.Printf("Manifest %s differs from %s:\n", file, referenceFile)
+		for _, difference := range differences {
+			fmt.Println("  " + difference)
+		}
+	}
+	return changed, nil
+}
+
+// diffManifests describes how a manifest differs from its reference: a
+// changed atlas size, sprites added or removed, and sprites that moved,
+// were resized, or whose other fields, such as trim or pivot, changed. The
+// descriptions are sorted by sprite name.
+func diffManifests(got, want Manifest) []string {
+	var differences []string
+	if got.Width != want.Width || got.Height != want.Height {
+		differences = append(differences, fmt.Sprintf("atlas is %dx%d, reference is %dx%d", got.Width, got.Height, want.Width, want.Height))
+	}
+
+	names := make([]string, 0, len(got.Sprites)+len(want.Sprites))
+	for name := range got.Sprites {
+		names = append(names, name)
+	}
+	for name := range want.Sprites {
+		if _, ok := got.Sprites[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		g, inGot := got.Sprites[name]
+		w, inWant := want.Sprites[name]
+		switch {
+		case !inWant:
+			differences = append(differences, fmt.Sprintf("%s added at (%d,%d) %dx%d", name, g.X, g.Y, g.W, g.H))
+		case !inGot:
+			differences = append(differences, fmt.Sprintf("%s removed from (%d,%d) %dx%d", name, w.X, w.Y, w.W, w.H))
+		default:
+			if g.X != w.X || g.Y != w.Y {
+				differences = append(differences, fmt.Sprintf("%s moved from (%d,%d) to (%d,%d)", name, w.X, w.Y, g.X, g.Y))
+			}
+			if g.W != w.W || g.H != w.H {
+				differences = append(differences, fmt.Sprintf("%s resized from %dx%d to %dx%d", name, w.W, w.H, g.W, g.H))
+			}
+			g.X, g.Y, g.W, g.H = w.X, w.Y, w.W, w.H
+			if !reflect.DeepEqual(g, w) {
+				differences = append(differences, fmt.Sprintf("%s changed other fields", name))
+			}
+		}
+	}
+	return differences
+}
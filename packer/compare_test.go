@@ -0,0 +1,80 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// update rewrites the golden files under testdata from the current output.
+var update = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// goldenSprites is the input whose manifest testdata/golden.json records.
+func goldenSprites(t *testing.T) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 12; i++ {
+		fsys[fmt.Sprintf("sprite%02d.png", i)] = pngFile(t, patterned(4+i%4*3, 3+i%5*2, uint8(i)))
+	}
+	return fsys
+}
+
+// TestCompareManifestGolden packs a fixed set of sprites against the
+// committed manifest testdata/golden.json with -comparemanifest, which
+// passes while the layout is unchanged and fails, naming the sprites that
+// moved, once it changes. Run with -update to record a new layout.
+func TestCompareManifestGolden(t *testing.T) {
+	golden, err := filepath.Abs(filepath.Join("testdata", "golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	opts := runOptions(goldenSprites(t), dir)
+	opts.Padding = Padding{X: 1, Y: 1}
+	opts.JSONPretty = true
+	if *update {
+		if _, err := Run(context.Background(), opts); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "atlas.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The atlas path is not compared, so the temporary directory is
+		// left out of the golden file.
+		data = bytes.Replace(data, []byte(filepath.Join(dir, "atlas.png")), []byte("atlas.png"), 1)
+		if err := os.WriteFile(golden, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		opts.Overwrite = true
+	}
+
+	opts.CompareManifest = golden
+	if _, err := Run(context.Background(), opts); err != nil {
+		t.Fatalf("layout differs from %s: %v", golden, err)
+	}
+
+	opts.Padding = Padding{X: 3, Y: 3}
+	opts.Overwrite = true
+	if _, err := Run(context.Background(), opts); err == nil {
+		t.Fatal("a layout with wider padding matched the golden manifest")
+	}
+	got, err := ReadManifest(filepath.Join(dir, "atlas.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ReadManifest(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	differences := diffManifests(got, want)
+	if !slices.ContainsFunc(differences, func(d string) bool { return strings.Contains(d, ".png moved from ") }) {
+		t.Errorf("differences %q name no sprite that moved", differences)
+	}
+}
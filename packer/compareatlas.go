@@ -0,0 +1,103 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diffSuffix is inserted before the extension of the image marking where
+// an atlas differs from its reference.
+const diffSuffix = "_diff"
+
+// diffFilename returns the filename of the difference image written for an
+// atlas with -comparediff.
+func diffFilename(atlasFile string) string {
+	ext := filepath.Ext(atlasFile)
+	return strings.TrimSuffix(atlasFile, ext) + diffSuffix + ext
+}
+
+// compareAtlases compares the pixels of each written atlas image with its
+// reference and prints how many differ, returning the number of images
+// with more differing pixels than opts.CompareTolerance. When reference is
+// a directory, each image is compared with the file of the same relative
+// path inside it; otherwise reference is the reference for the single
+// image written. Pixels are compared by their straight 16-bit channels,
+// so even the color of a fully transparent pixel counts. An image of
+// another size than its reference differs as a whole. With
+// opts.CompareDiff an image marking the differing pixels in red over a
+// faded copy of the atlas is written beside each image that differs.
+func compareAtlases(atlasFiles []string, reference string, opts Options) (int, error) {
+	info, err := os.Stat(reference)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() && len(atlasFiles) != 1 {
+		return 0, fmt.Errorf("%s is a file but %d atlas images were written; give a directory of reference images", reference, len(atlasFiles))
+	}
+
+	failed := 0
+	for _, file := range atlasFiles {
+		referenceFile := reference
+		if info.IsDir() {
+			referenceFile = filepath.Join(reference, file)
+		}
+		got, err := DecodeImageFile(file)
+		if err != nil {
+			return 0, err
+		}
+		want, err := DecodeImageFile(referenceFile)
+		if err != nil {
+			return 0, err
+		}
+		gb, wb := got.Bounds(), want.Bounds()
+		if gb.Size() != wb.Size() {
+			failed++
+			fmt.Printf("Atlas %s is %dx%d, reference %s is %dx%d\n", file, gb.Dx(), gb.Dy(), referenceFile, wb.Dx(), wb.Dy())
+			continue
+		}
+		differing, diff := diffAtlas(got, want)
+		if differing == 0 {
+			fmt.Printf("Atlas %s matches %s\n", file, referenceFile)
+			continue
+		}
+		share := float64(differing) / float64(gb.Dx()*gb.Dy()) * 100
+		fmt.Printf("Atlas %s differs from %s in %d pixels (%.2f%%)\n", file, referenceFile, differing, share)
+		if differing > opts.CompareTolerance {
+			failed++
+		}
+		if opts.CompareDiff {
+			if err := saveAtlas(diffFilename(file), diff, opts); err != nil {
+				return 0, fmt.Errorf("saving difference image: %w", err)
+			}
+			fmt.Printf("  Differences marked in %s\n", diffFilename(file))
+		}
+	}
+	return failed, nil
+}
+
+// diffAtlas counts the pixels in which two images of the same size differ
+// and returns an image marking them in opaque red over a faded gray copy of
+// got.
+func diffAtlas(got, want image.Image) (int, *image.NRGBA) {
+	gb, wb := got.Bounds(), want.Bounds()
+	diff := image.NewNRGBA(image.Rect(0, 0, gb.Dx(), gb.Dy()))
+	differing := 0
+	for y := 0; y < gb.Dy(); y++ {
+		for x := 0; x < gb.Dx(); x++ {
+			g := color.NRGBA64Model.Convert(got.At(gb.Min.X+x, gb.Min.Y+y)).(color.NRGBA64)
+			w := color.NRGBA64Model.Convert(want.At(wb.Min.X+x, wb.Min.Y+y)).(color.NRGBA64)
+			if g != w {
+				differing++
+				diff.SetNRGBA(x, y, color.NRGBA{R: 0xff, A: 0xff})
+				continue
+			}
+			gray := color.GrayModel.Convert(g).(color.Gray)
+			diff.SetNRGBA(x, y, color.NRGBA{R: gray.Y, G: gray.Y, B: gray.Y, A: 0x40})
+		}
+	}
+	return differing, diff
+}
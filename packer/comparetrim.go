@@ -0,0 +1,101 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// trimPass is one of the two builds made with -comparetrim: the suffix of
+// its outputs' names and whether it trims.
+type trimPass struct {
+	suffix string
+	trim   bool
+}
+
+// trimPasses are the builds -comparetrim makes, untrimmed first.
+var trimPasses = []trimPass{{"untrimmed", false}, {"trimmed", true}}
+
+// trimTotals sums the statistics of the atlases one -comparetrim build
+// produced: their number, their area and that of the sprites on them, and
+// the size of their image files in bytes.
+type trimTotals struct {
+	pages       int
+	area        int
+	spriteArea  float64
+	bytes       int64
+	missingSize bool
+}
+
+// CompareTrim builds every atlas twice, untrimmed and then trimmed, with
+// "_untrimmed" and "_trimmed" appended to the base name of every output,
+// and to the -stats file, and prints the pages, area, occupancy and image
+// bytes of each build and how much trimming saved. Nothing is compared if
+// either build fails, and its error is returned.
+func CompareTrim(ctx context.Context, opts Options) error {
+	totals := make([]trimTotals, len(trimPasses))
+	for i, pass := range trimPasses {
+		passOpts := opts
+		passOpts.Trim = pass.trim
+		passOpts.AtlasName = opts.AtlasName + "_" + pass.suffix
+		if opts.StatsFile != "" {
+			ext := filepath.Ext(opts.StatsFile)
+			passOpts.StatsFile = strings.TrimSuffix(opts.StatsFile, ext) + "_" + pass.suffix + ext
+		}
+		stats, err := Run(ctx, passOpts)
+		if err != nil {
+			return err
+		}
+		if stats == nil {
+			return nil
+		}
+		totals[i] = totalTrimStats(stats)
+	}
+
+	fmt.Println("Trim comparison:")
+	for i, pass := range trimPasses {
+		t := totals[i]
+		occupancy := 0.0
+		if t.area > 0 {
+			occupancy = t.spriteArea / float64(t.area) * 100
+		}
+		bytes := fmt.Sprintf("%d bytes", t.bytes)
+		if t.missingSize {
+			bytes = "unknown bytes"
+		}
+		fmt.Printf("  %-11s %d pages, %d px, %.1f%% occupancy, %s\n", pass.suffix+":", t.pages, t.area, occupancy, bytes)
+	}
+	untrimmed, trimmed := totals[0], totals[1]
+	saved := untrimmed.area - trimmed.area
+	share := 0.0
+	if untrimmed.area > 0 {
+		share = float64(saved) / float64(untrimmed.area) * 100
+	}
+	fmt.Printf("Trimming saves %d px (%.1f%%) of atlas area", saved, share)
+	if !untrimmed.missingSize && !trimmed.missingSize {
+		fmt.Printf(" and %d bytes of images", untrimmed.bytes-trimmed.bytes)
+	}
+	fmt.Println(".")
+	return nil
+}
+
+// totalTrimStats sums the statistics of one build's atlases, reading the
+// size of each image file from disk.
+func totalTrimStats(atlases []AtlasStats) trimTotals {
+	var t trimTotals
+	for _, atlas := range atlases {
+		area := atlas.Width * atlas.Height
+		t.pages++
+		t.area += area
+		t.spriteArea += atlas.Occupancy * float64(area)
+		info, err := os.Stat(atlas.Image)
+		if err != nil {
+			t.missingSize = true
+			continue
+		}
+		t.bytes += info.Size()
+	}
+	return t
+}
@@ -0,0 +1,81 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// captureStdout returns what f prints to standard output.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	defer func() {
+		os.Stdout = stdout
+	}()
+	f()
+	w.Close()
+	return string(<-done)
+}
+
+// TestCompareTrim checks that CompareTrim writes an untrimmed and a trimmed
+// atlas with their own statistics files, and reports the area trimming
+// saves, which is the difference between the two atlases.
+func TestCompareTrim(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 6; i++ {
+		img := image.NewNRGBA(image.Rect(0, 0, 20, 16))
+		draw.Draw(img, image.Rect(4, 3, 10+i, 9+i), patterned(6+i, 6+i, uint8(i)), image.Point{}, draw.Src)
+		fsys[fmt.Sprintf("s%d.png", i)] = pngFile(t, img)
+	}
+	dir := t.TempDir()
+	opts := runOptions(fsys, dir)
+
+	var err error
+	output := captureStdout(t, func() { err = CompareTrim(context.Background(), opts) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	area := map[string]int{}
+	for _, pass := range trimPasses {
+		for _, name := range []string{"atlas_" + pass.suffix + ".png", "atlas_" + pass.suffix + ".json", "stats_" + pass.suffix + ".json"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Errorf("%s build: %v", pass.suffix, err)
+			}
+		}
+		f, err := os.Open(filepath.Join(dir, "atlas_"+pass.suffix+".png"))
+		if err != nil {
+			continue
+		}
+		config, _, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		area[pass.suffix] = config.Width * config.Height
+	}
+	if area["trimmed"] >= area["untrimmed"] {
+		t.Fatalf("trimmed atlas of %d px is no smaller than the untrimmed one of %d px", area["trimmed"], area["untrimmed"])
+	}
+	want := fmt.Sprintf("Trimming saves %d px", area["untrimmed"]-area["trimmed"])
+	if !strings.Contains(output, want) {
+		t.Errorf("output does not report %q:\n%s", want, output)
+	}
+}
@@ -0,0 +1,238 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NineSlice is the border of a nine-slice sprite that does not stretch: how
+// many pixels on each side of the untrimmed sprite lie outside the region
+// marked as stretchable.
+type NineSlice struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Right  int `json:"right"`
+	Bottom int `json:"bottom"`
+}
+
+// nameInfo is what the filename conventions read from a sprite's name.
+type nameInfo struct {
+	// scale is the density the sprite was drawn at, as in "@2x", or 0.
+	scale int
+	// priority is the packing priority the name gives, if it gives one.
+	priority    int
+	hasPriority bool
+	// nineSlice is set for an Android nine-patch, named "*.9.png", whose
+	// 1-pixel marker border is still part of the image.
+	nineSlice bool
+}
+
+// nameConvention is a suffix of a sprite's file name, before the extension,
+// that -nameconventions can be told to honor. parse reports whether stem
+// ends in the suffix and, if so, records what it means in info and returns
+// stem without it.
+type nameConvention struct {
+	name  string
+	parse func(stem string, info *nameInfo) (string, bool)
+}
+
+// Names of the filename conventions, the values -nameconventions lists and
+// Options.NameConventions holds.
+const (
+	// ConventionScale reads an "@2x" suffix as the sprite's source scale.
+	ConventionScale = "scale"
+	// ConventionPriority reads an "@p3" suffix as the sprite's priority.
+	ConventionPriority = "priority"
+	// ConventionNineSlice reads a ".9" suffix as an Android nine-patch.
+	ConventionNineSlice = "nineslice"
+)
+
+var (
+	scaleSuffix    = regexp.MustCompile(`@([1-9][0-9]*)x$`)
+	prioritySuffix = regexp.MustCompile(`@p(-?[0-9]+)$`)
+)
+
+// nameConventions are the filename conventions, in the order their names
+// are listed. A new convention only needs an entry here.
+var nameConventions = []nameConvention{
+	{ConventionScale, func(stem string, info *nameInfo) (string, bool) {
+		m := scaleSuffix.FindStringSubmatchIndex(stem)
+		if m == nil || info.scale != 0 {
+			return stem, false
+		}
+		info.scale, _ = strconv.Atoi(stem[m[2]:m[3]])
+		return stem[:m[0]], true
+	}},
+	{ConventionPriority, func(stem string, info *nameInfo) (string, bool) {
+		m := prioritySuffix.FindStringSubmatchIndex(stem)
+		if m == nil || info.hasPriority {
+			return stem, false
+		}
+		priority, err := strconv.Atoi(stem[m[2]:m[3]])
+		if err != nil {
+			return stem, false
+		}
+		info.priority, info.hasPriority = priority, true
+		return stem[:m[0]], true
+	}},
+	{ConventionNineSlice, func(stem string, info *nameInfo) (string, bool) {
+		if !strings.HasSuffix(stem, ".9") || info.nineSlice {
+			return stem, false
+		}
+		info.nineSlice = true
+		return strings.TrimSuffix(stem, ".9"), true
+	}},
+}
+
+// ConventionNames returns the names of the filename conventions, as listed
+// in messages.
+func ConventionNames() []string {
+	names := make([]string, len(nameConventions))
+	for i, c := range nameConventions {
+		names[i] = c.name
+	}
+	return names
+}
+
+// ParseNameConventions returns the names of the filename conventions a
+// -nameconventions value lists, or an error naming one that does not exist.
+func ParseNameConventions(value string) ([]string, error) {
+	names := SplitList(value)
+	if err := checkConventions(names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// checkConventions returns an error naming the first of names that is not
+// a filename convention.
+func checkConventions(names []string) error {
+	for _, name := range names {
+		if _, ok := findConvention(name); !ok {
+			return fmt.Errorf("unknown convention %q", name)
+		}
+	}
+	return nil
+}
+
+// findConvention returns the filename convention with the given name.
+func findConvention(name string) (nameConvention, bool) {
+	for _, c := range nameConventions {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return nameConvention{}, false
+}
+
+// parseSpriteName strips the suffixes of the named conventions from the end
+// of the name's base, before its extension, in any order and each at most
+// once, and returns the name without them and what they said. Anything
+// else in the name, including suffixes of conventions not listed, is left
+// as it is.
+func parseSpriteName(name string, names []string) (string, nameInfo) {
+	var conventions []nameConvention
+	for _, name := range names {
+		if c, ok := findConvention(name); ok {
+			conventions = append(conventions, c)
+		}
+	}
+	var info nameInfo
+	dir, base := path.Split(name)
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for stripped := true; stripped; {
+		stripped = false
+		for _, c := range conventions {
+			var ok bool
+			if stem, ok = c.parse(stem, &info); ok {
+				stripped = true
+			}
+		}
+	}
+	return dir + stem + ext, info
+}
+
+// applyNameInfo records on the rectangle what its name's conventions said.
+// A priority replaces any the sprite has, though a sidecar applied later
+// may override it in turn.
+func applyNameInfo(rect *Rectangle, info nameInfo) {
+	rect.SourceScale = info.scale
+	if info.hasPriority {
+		rect.Meta.Priority = info.priority
+	}
+}
+
+// nineSliceMarked reports whether a pixel of a nine-patch's border marks
+// a stretchable row or column, which only opaque black pixels do.
+func nineSliceMarked(img image.Image, x, y int) bool {
+	r, g, b, a := img.At(x, y).RGBA()
+	return a == 0xffff && r == 0 && g == 0 && b == 0
+}
+
+// stripNinePatch removes the 1-pixel marker border of an Android
+// nine-patch and returns the image inside it with the insets of the
+// stretchable region its top and left markers give. Several marked spans
+// on one side are taken as the single span from the first to the last; the
+// content padding markers on the bottom and right are ignored.
+func stripNinePatch(img image.Image) (image.Image, NineSlice, error) {
+	b := img.Bounds()
+	if b.Dx() < 3 || b.Dy() < 3 {
+		return nil, NineSlice{}, fmt.Errorf("nine-patch is %dx%d, too small to have a marker border", b.Dx(), b.Dy())
+	}
+	inner := image.Rect(b.Min.X+1, b.Min.Y+1, b.Max.X-1, b.Max.Y-1)
+	span := func(n int, marked func(i int) bool) (int, int, bool) {
+		first, last := -1, -1
+		for i := 0; i < n; i++ {
+			if marked(i) {
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+		}
+		return first, n - 1 - last, first >= 0
+	}
+	left, right, ok := span(inner.Dx(), func(i int) bool { return nineSliceMarked(img, inner.Min.X+i, b.Min.Y) })
+	if !ok {
+		return nil, NineSlice{}, fmt.Errorf("nine-patch has no stretch markers on its top row")
+	}
+	top, bottom, ok := span(inner.Dy(), func(i int) bool { return nineSliceMarked(img, b.Min.X, inner.Min.Y+i) })
+	if !ok {
+		return nil, NineSlice{}, fmt.Errorf("nine-patch has no stretch markers on its left column")
+	}
+	sub, ok := img.(subImager)
+	if !ok {
+		return nil, NineSlice{}, fmt.Errorf("image type %T cannot be cropped", img)
+	}
+	return sub.SubImage(inner), NineSlice{Left: left, Top: top, Right: right, Bottom: bottom}, nil
+}
+
+// checkConventionNames fails if the conventions gave two sprites that were
+// not skipped the same name, as "hero.png" and "hero@2x.png" both become
+// "hero.png" with the scale convention.
+func checkConventionNames(rectangles []Rectangle, names, skipped []string) error {
+	from := make(map[string][]string)
+	for i, rect := range rectangles {
+		if skipped[i] == "" {
+			from[rect.Name] = append(from[rect.Name], names[i])
+		}
+	}
+	var collisions []string
+	for name, sources := range from {
+		if len(sources) > 1 {
+			sort.Strings(sources)
+			collisions = append(collisions, fmt.Sprintf("%s (%s)", name, strings.Join(sources, ", ")))
+		}
+	}
+	if len(collisions) == 0 {
+		return nil
+	}
+	sort.Strings(collisions)
+	return fmt.Errorf("-nameconventions gives sprites the same name: %s", strings.Join(collisions, "; "))
+}
@@ -0,0 +1,147 @@
+package packer
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Crop is one sub-sprite of a source sheet, read from a -crops file: the
+// rectangle at (X, Y) of size W x H within the sheet, measured from its
+// top-left corner, packed as the sprite Name. A Rotated crop holds the
+// sprite turned a quarter turn clockwise, as -allowrotation packs it, and
+// is turned back upright when cut out, so -merge can repack such atlases.
+type Crop struct {
+	Name    string `json:"name"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	W       int    `json:"w"`
+	H       int    `json:"h"`
+	Rotated bool   `json:"rotated,omitempty"`
+}
+
+// Rect returns the crop's rectangle relative to the sheet's top-left corner.
+func (c Crop) Rect() image.Rectangle {
+	return image.Rect(c.X, c.Y, c.X+c.W, c.Y+c.H)
+}
+
+// spriteSource is where a sprite comes from: the whole of File, with Crop
+// set only that part of it, or with Frame set one frame of its animation.
+type spriteSource struct {
+	Name  string
+	File  string
+	Crop  *Crop
+	Frame *FrameEntry
+}
+
+// LoadCrops reads a crops JSON file mapping source file names, as they are
+// found under -filedir, to the sprites to cut out of them. Crops without a
+// name are named after their sheet and their index in its list, so
+// "sheet.png" yields "sheet_0.png", "sheet_1.png" and so on.
+func LoadCrops(filename string) (map[string][]Crop, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var crops map[string][]Crop
+	if err := json.Unmarshal(data, &crops); err != nil {
+		return nil, fmt.Errorf("failed to parse crops %s: %w", filename, err)
+	}
+	for file, list := range crops {
+		ext := path.Ext(file)
+		for i := range list {
+			if list[i].W <= 0 || list[i].H <= 0 {
+				return nil, fmt.Errorf("crops %s: crop %d of %s has size %dx%d; both must be positive", filename, i, file, list[i].W, list[i].H)
+			}
+			if list[i].Name == "" {
+				list[i].Name = strings.TrimSuffix(file, ext) + "_" + strconv.Itoa(i) + ext
+			}
+		}
+	}
+	return crops, nil
+}
+
+// spriteSources lists the sprites to load from the files, in file order: a
+// file with crops yields one sprite per crop, in the order listed, an
+// animated file one sprite per frame, named by frameName, and any other file
+// a single sprite of its own name. An animated file cannot also be cropped,
+// and two sprites may not share a name. Crop entries for files that were not
+// found produce a warning.
+func spriteSources(files []string, crops map[string][]Crop, animations map[string]*animation) ([]spriteSource, error) {
+	sources := make([]spriteSource, 0, len(files))
+	used := make(map[string]bool, len(crops))
+	for _, file := range files {
+		if anim, ok := animations[file]; ok {
+			if _, ok := crops[file]; ok {
+				return nil, fmt.Errorf("%s is animated and cannot also be cropped", file)
+			}
+			for i, frame := range anim.Frames {
+				name := frameName(file, i, len(anim.Frames))
+				sources = append(sources, spriteSource{Name: name, File: file, Frame: &FrameEntry{Animation: file, Index: i, Delay: frame.Delay}})
+			}
+			continue
+		}
+		list, ok := crops[file]
+		if !ok {
+			sources = append(sources, spriteSource{Name: file, File: file})
+			continue
+		}
+		used[file] = true
+		for i := range list {
+			sources = append(sources, spriteSource{Name: list[i].Name, File: file, Crop: &list[i]})
+		}
+	}
+
+	names := spriteNames(sources)
+	sort.Strings(names)
+	for i := 1; i < len(names); i++ {
+		if names[i] == names[i-1] {
+			return nil, fmt.Errorf("more than one sprite is named %s", names[i])
+		}
+	}
+
+	var unused []string
+	for file := range crops {
+		if !used[file] {
+			unused = append(unused, file)
+		}
+	}
+	sort.Strings(unused)
+	for _, file := range unused {
+		Warnf("crops entry %q matches no image file", file)
+	}
+	return sources, nil
+}
+
+// spriteNames returns the names of the sprites from sources, in order.
+func spriteNames(sources []spriteSource) []string {
+	names := make([]string, len(sources))
+	for i, source := range sources {
+		names[i] = source.Name
+	}
+	return names
+}
+
+// cropImage returns the part of img covered by the crop, sharing its pixels,
+// or for a rotated crop a copy of it turned back upright, and fails if the
+// crop reaches outside the image.
+func cropImage(img image.Image, crop Crop) (image.Image, error) {
+	b := img.Bounds()
+	r := crop.Rect()
+	if !r.In(image.Rect(0, 0, b.Dx(), b.Dy())) {
+		return nil, fmt.Errorf("crop %s at %v lies outside the %dx%d image", crop.Name, r, b.Dx(), b.Dy())
+	}
+	sub, ok := img.(subImager)
+	if !ok {
+		return nil, fmt.Errorf("crop %s: %T images cannot be cropped", crop.Name, img)
+	}
+	if crop.Rotated {
+		return turnBack(sub.SubImage(r.Add(b.Min))), nil
+	}
+	return sub.SubImage(r.Add(b.Min)), nil
+}
@@ -0,0 +1,118 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DebugSuffix is inserted before the extension of an atlas's debug overlay.
+const DebugSuffix = "_debug"
+
+// debugFilename returns the filename of the debug overlay written beside an
+// atlas with -debug.
+func debugFilename(atlasFile string) string {
+	ext := filepath.Ext(atlasFile)
+	return strings.TrimSuffix(atlasFile, ext) + DebugSuffix + ext
+}
+
+// debugCategory returns the category a sprite is colored by in the debug
+// overlay: the first capture group of pattern matched against its name, or
+// without a pattern the directory holding it. Sprites the pattern does not
+// match, and files at the root, are in the category "(none)".
+func debugCategory(name string, pattern *regexp.Regexp) string {
+	category := path.Dir(name)
+	if pattern != nil {
+		category = ""
+		if m := pattern.FindStringSubmatch(name); m != nil {
+			category = m[1]
+		}
+	}
+	if category == "" || category == "." {
+		return "(none)"
+	}
+	return category
+}
+
+// categoryColors assigns each category a distinct, fully saturated color,
+// spacing hues by the golden angle in sorted category order so the colors
+// stay the same from run to run.
+func categoryColors(categories []string) map[string]color.NRGBA {
+	colors := make(map[string]color.NRGBA, len(categories))
+	for i, category := range categories {
+		hue := math.Mod(float64(i)*137.508, 360)
+		colors[category] = hueColor(hue)
+	}
+	return colors
+}
+
+// hueColor returns the opaque color of full saturation and value at the
+// given hue, in degrees.
+func hueColor(hue float64) color.NRGBA {
+	x := 1 - math.Abs(math.Mod(hue/60, 2)-1)
+	var r, g, b float64
+	switch int(hue / 60) {
+	case 0:
+		r, g = 1, x
+	case 1:
+		r, g = x, 1
+	case 2:
+		g, b = 1, x
+	case 3:
+		g, b = x, 1
+	case 4:
+		r, b = x, 1
+	default:
+		r, b = 1, x
+	}
+	return color.NRGBA{R: uint8(math.Round(r * 255)), G: uint8(math.Round(g * 255)), B: uint8(math.Round(b * 255)), A: 0xff}
+}
+
+// saveDebugOverlay writes a copy of the atlas with a one-pixel border drawn
+// just inside each sprite's placement, colored by the sprite's category
+// under opts.DebugCategory, and prints a legend of the colors. It makes
+// sprites that landed in the wrong group or category easy to spot.
+func saveDebugOverlay(filename string, atlas image.Image, rectangles []Rectangle, layout Layout, opts Options) error {
+	counts := make(map[string]int)
+	for _, rect := range rectangles {
+		counts[debugCategory(rect.Name, opts.DebugCategory)]++
+	}
+	categories := make([]string, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	colors := categoryColors(categories)
+
+	overlay := image.NewNRGBA(atlas.Bounds())
+	draw.Draw(overlay, overlay.Bounds(), atlas, atlas.Bounds().Min, draw.Src)
+	for _, rect := range rectangles {
+		r := layout.Placements[rect.ID]
+		c := colors[debugCategory(rect.Name, opts.DebugCategory)]
+		for x := r.Min.X; x < r.Max.X; x++ {
+			overlay.SetNRGBA(x, r.Min.Y, c)
+			overlay.SetNRGBA(x, r.Max.Y-1, c)
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			overlay.SetNRGBA(r.Min.X, y, c)
+			overlay.SetNRGBA(r.Max.X-1, y, c)
+		}
+	}
+	if err := saveAtlas(filename, overlay, opts); err != nil {
+		return err
+	}
+
+	fmt.Printf("Debug overlay %s:\n", filename)
+	for _, category := range categories {
+		c := colors[category]
+		fmt.Printf("  #%02x%02x%02x %s (%d sprites)\n", c.R, c.G, c.B, category, counts[category])
+	}
+	return nil
+}
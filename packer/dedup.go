@@ -0,0 +1,82 @@
+package packer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+// pixelHash returns the SHA-256 of the image's size and of its pixels as
+// straight 16-bit RGBA, so images holding the same pixels hash alike
+// whatever format they were decoded from: an opaque PNG and a lossless
+// export of it as another format, say, but not two images that differ only
+// in the color hidden under fully transparent pixels.
+func pixelHash(img image.Image) [sha256.Size]byte {
+	b := img.Bounds()
+	h := sha256.New()
+	var size [8]byte
+	binary.LittleEndian.PutUint32(size[:4], uint32(b.Dx()))
+	binary.LittleEndian.PutUint32(size[4:], uint32(b.Dy()))
+	h.Write(size[:])
+
+	row := make([]byte, 8*b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		switch src := img.(type) {
+		case *image.NRGBA:
+			pix := src.Pix[src.PixOffset(b.Min.X, y):]
+			for x := 0; x < b.Dx(); x++ {
+				for c := 0; c < 4; c++ {
+					// 8-bit channels widen to 16 bits as color.NRGBA64Model widens them.
+					row[8*x+2*c], row[8*x+2*c+1] = pix[4*x+c], pix[4*x+c]
+				}
+			}
+		case *image.NRGBA64:
+			copy(row, src.Pix[src.PixOffset(b.Min.X, y):])
+		default:
+			for x := 0; x < b.Dx(); x++ {
+				c := color.NRGBA64Model.Convert(img.At(b.Min.X+x, y)).(color.NRGBA64)
+				binary.BigEndian.PutUint16(row[8*x:], c.R)
+				binary.BigEndian.PutUint16(row[8*x+2:], c.G)
+				binary.BigEndian.PutUint16(row[8*x+4:], c.B)
+				binary.BigEndian.PutUint16(row[8*x+6:], c.A)
+			}
+		}
+		h.Write(row)
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// dedupRectangles returns the rectangles with each one whose PixelHash
+// matches that of one before it moved into that one's Duplicates, so its
+// pixels are packed once and its manifest entry points at the same region.
+// Rectangles with no hash are all kept. The order of the rest is unchanged.
+func dedupRectangles(rectangles []Rectangle) []Rectangle {
+	var kept []Rectangle
+	first := make(map[[sha256.Size]byte]int)
+	for _, rect := range rectangles {
+		if rect.PixelHash == ([sha256.Size]byte{}) {
+			kept = append(kept, rect)
+			continue
+		}
+		if i, ok := first[rect.PixelHash]; ok {
+			kept[i].Duplicates = append(kept[i].Duplicates, rect)
+			continue
+		}
+		first[rect.PixelHash] = len(kept)
+		kept = append(kept, rect)
+	}
+	return kept
+}
+
+// spriteCount returns the number of sprites the rectangles stand for in
+// their manifest: one each, and one for each of their duplicates.
+func spriteCount(rectangles []Rectangle) int {
+	n := len(rectangles)
+	for _, rect := range rectangles {
+		n += len(rect.Duplicates)
+	}
+	return n
+}
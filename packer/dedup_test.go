@@ -0,0 +1,52 @@
+package packer
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// TestDedup checks that with opts.Dedup two files with the same pixels are
+// packed into one region, which both of their manifest entries point at,
+// while a third, different file gets a region of its own.
+func TestDedup(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.png":    pngFile(t, patterned(6, 5, 1)),
+		"copy.png": pngFile(t, patterned(6, 5, 1)),
+		"b.png":    pngFile(t, patterned(6, 5, 2)),
+	}
+	dir := t.TempDir()
+	opts := runOptions(fsys, dir)
+	opts.Dedup = true
+	if _, err := Run(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "atlas.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Sprites) != 3 {
+		t.Fatalf("manifest has %d sprites, want an entry for each of the 3 files", len(manifest.Sprites))
+	}
+	regions := make(map[image.Rectangle]bool)
+	for _, entry := range manifest.Sprites {
+		regions[image.Rect(entry.X, entry.Y, entry.X+entry.W, entry.Y+entry.H)] = true
+	}
+	if len(regions) != 2 {
+		t.Errorf("sprites packed into %d regions, want 2", len(regions))
+	}
+	if a, copied := manifest.Sprites["a.png"], manifest.Sprites["copy.png"]; a.X != copied.X || a.Y != copied.Y || a.W != copied.W || a.H != copied.H {
+		t.Errorf("a.png at %+v and copy.png at %+v, want the same region", a, copied)
+	}
+	if a, b := manifest.Sprites["a.png"], manifest.Sprites["b.png"]; a.X == b.X && a.Y == b.Y {
+		t.Errorf("b.png shares the region of a.png at %d,%d", a.X, a.Y)
+	}
+}
@@ -0,0 +1,40 @@
+package packer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// normalizeDepth converts img to straight-alpha pixels of the given bit depth
+// per channel: an *image.NRGBA for 8 and an *image.NRGBA64 for 16. Images
+// already of that type are returned as they are. Widening to 16 bits scales
+// every 8-bit value v to v*0x101, so 0xff becomes 0xffff exactly. lossy
+// reports whether narrowing to 8 bits dropped precision, that is whether any
+// channel of the source held a 16-bit value that is not such a multiple.
+func normalizeDepth(img image.Image, depth int) (out image.Image, lossy bool) {
+	b := img.Bounds()
+	if depth == 16 {
+		if _, ok := img.(*image.NRGBA64); ok {
+			return img, false
+		}
+		dst := image.NewNRGBA64(b)
+		draw.Draw(dst, b, img, b.Min, draw.Src)
+		return dst, false
+	}
+
+	if _, ok := img.(*image.NRGBA); ok {
+		return img, false
+	}
+	dst := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			if c.R%0x101 != 0 || c.G%0x101 != 0 || c.B%0x101 != 0 || c.A%0x101 != 0 {
+				lossy = true
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{R: uint8(c.R >> 8), G: uint8(c.G >> 8), B: uint8(c.B >> 8), A: uint8(c.A >> 8)})
+		}
+	}
+	return dst, lossy
+}
@@ -0,0 +1,171 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// runOptions returns the options the command line runs with by default, for
+// the images in fsys, writing the atlas, its manifest and the statistics
+// file into dir.
+func runOptions(fsys fs.FS, dir string) Options {
+	return Options{
+		MaxWidth:       1024,
+		MaxHeight:      1024,
+		FS:             fsys,
+		AtlasName:      filepath.Join(dir, DefaultAtlasName),
+		AtlasExtension: ".png",
+		StatsFile:      filepath.Join(dir, "stats.json"),
+		Alpha:          AlphaStraight,
+		Channels:       ChannelsRGBA,
+		Format:         FormatJSON,
+		Packer:         PackerShelf,
+		ShelfFit:       ShelfFitFirst,
+		Sort:           SortHeight,
+		TieBreak:       TieBreakSmallestY,
+		Growth:         GrowthWidth,
+		Origin:         OriginTopLeft,
+		PathMode:       PathModeRelative,
+		Scale:          1,
+	}
+}
+
+// translucentSprites returns a MapFS of n sprites of a few sizes, many of
+// the same height, with translucent pixels, named in an order the packer
+// does not place them in.
+func translucentSprites(t *testing.T, n int) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for i := 0; i < n; i++ {
+		img := image.NewNRGBA(image.Rect(0, 0, 3+i%4, 2+i%3))
+		b := img.Bounds()
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				img.SetNRGBA(x, y, color.NRGBA{R: uint8(i * 7), G: uint8(x * 40), B: uint8(y * 60), A: uint8(40 + (x+y+i)%200)})
+			}
+		}
+		fsys[fmt.Sprintf("sprite%02d.png", (i*7)%n)] = pngFile(t, img)
+	}
+	return fsys
+}
+
+// TestDeterministic checks that running twice on the same files writes the
+// same atlas and manifest byte for byte, with or without -deterministic,
+// and that with it the statistics file is identical too.
+func TestDeterministic(t *testing.T) {
+	fsys := translucentSprites(t, 60)
+	for _, deterministic := range []bool{false, true} {
+		// The manifest names the atlas by its path, so both runs write
+		// into the same directory.
+		dir := t.TempDir()
+		var outputs [2]map[string][]byte
+		for run := range outputs {
+			opts := runOptions(fsys, dir)
+			opts.Deterministic = deterministic
+			opts.Overwrite = true
+			if _, err := Run(context.Background(), opts); err != nil {
+				t.Fatal(err)
+			}
+			outputs[run] = map[string][]byte{}
+			for _, name := range []string{"atlas.png", "atlas.json", "stats.json"} {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				outputs[run][name] = data
+			}
+		}
+		for name, data := range outputs[0] {
+			if name == "stats.json" && !deterministic {
+				continue
+			}
+			if !bytes.Equal(data, outputs[1][name]) {
+				t.Errorf("deterministic %v: %s differs between two runs", deterministic, name)
+			}
+		}
+	}
+}
+
+// TestLayoutStable checks that loading the same files on a pool of workers
+// and packing them gives the same placements and atlas pixels on every one
+// of 100 runs.
+func TestLayoutStable(t *testing.T) {
+	fsys := translucentSprites(t, 80)
+	files, err := collectImageFiles(fsys, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{MaxWidth: 128, MaxHeight: 1024, FS: fsys, Padding: Padding{X: 1, Y: 1}, Alpha: AlphaStraight}
+	pack := func() (map[string]image.Rectangle, [sha256.Size]byte) {
+		rectangles, err := loadImages(context.Background(), files, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		atlas, layout, err := generateAtlas(context.Background(), rectangles, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		placements := make(map[string]image.Rectangle, len(rectangles))
+		for _, rect := range rectangles {
+			placements[rect.Name] = layout.Placements[rect.ID]
+		}
+		return placements, sha256.Sum256(atlas.(*image.NRGBA).Pix)
+	}
+
+	want, wantHash := pack()
+	for run := 1; run < 100; run++ {
+		got, hash := pack()
+		if !maps.Equal(got, want) {
+			t.Fatalf("run %d placed the sprites differently", run)
+		}
+		if hash != wantHash {
+			t.Fatalf("run %d drew a different atlas", run)
+		}
+	}
+}
+
+// TestPipelineStress runs the whole pipeline repeatedly with trimming,
+// alpha bleeding, extrusion and both alpha variants, loading and drawing
+// concurrently, and checks that every run writes files with the same hash.
+func TestPipelineStress(t *testing.T) {
+	fsys := translucentSprites(t, 120)
+	dir := t.TempDir()
+	files := []string{"atlas.png", "atlas" + PremultipliedSuffix + ".png", "atlas.json"}
+	var want [sha256.Size]byte
+	for run := 0; run < 20; run++ {
+		opts := runOptions(fsys, dir)
+		opts.MaxWidth, opts.MaxHeight = 256, 256
+		opts.Trim, opts.TrimAlpha = true, 60
+		opts.AlphaBleed, opts.Extrude = 2, 1
+		opts.Padding = Padding{X: 2, Y: 2}
+		opts.Alpha = AlphaBoth
+		opts.Overwrite = true
+		if _, err := Run(context.Background(), opts); err != nil {
+			t.Fatal(err)
+		}
+		hash := sha256.New()
+		for _, name := range files {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			hash.Write(data)
+		}
+		var got [sha256.Size]byte
+		hash.Sum(got[:0])
+		if run == 0 {
+			want = got
+		} else if got != want {
+			t.Fatalf("run %d wrote outputs hashing to %x, want %x", run, got, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package packer
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dirsFS joins several -filedir directories into one file system, each
+// appearing as a directory at its root named after its base name, so
+// "hero.png" in both "assets/ui" and "assets/fx" is read as "ui/hero.png"
+// and "fx/hero.png" and the sprite names never collide.
+type dirsFS map[string]fs.FS
+
+// OpenDirs returns the file system to read images from: the directory itself
+// when only one is given, so sprite names stay relative to it, or a dirsFS
+// over all of them. Two directories with the same base name are an error, as
+// their sprites could not be told apart.
+func OpenDirs(dirs []string) (fs.FS, error) {
+	if len(dirs) == 1 {
+		return os.DirFS(dirs[0]), nil
+	}
+	fsys := make(dirsFS, len(dirs))
+	from := make(map[string]string, len(dirs))
+	for _, dir := range dirs {
+		name := filepath.Base(filepath.Clean(dir))
+		if name == "." || name == ".." || name == string(filepath.Separator) {
+			return nil, fmt.Errorf("directory %s has no base name to prefix its sprites with", dir)
+		}
+		if other, ok := from[name]; ok {
+			return nil, fmt.Errorf("directories %s and %s share the base name %s", other, dir, name)
+		}
+		from[name] = dir
+		fsys[name] = os.DirFS(dir)
+	}
+	return fsys, nil
+}
+
+// split returns the directory's file system holding name and the name
+// within it.
+func (d dirsFS) split(op, name string) (fs.FS, string, error) {
+	if !fs.ValidPath(name) {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	dir, rest, _ := strings.Cut(name, "/")
+	fsys, ok := d[dir]
+	if !ok {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if rest == "" {
+		rest = "."
+	}
+	return fsys, rest, nil
+}
+
+// Open opens the named file, implementing fs.FS.
+func (d dirsFS) Open(name string) (fs.File, error) {
+	if name == "." {
+		return &dirsRoot{entries: d.entries()}, nil
+	}
+	fsys, rest, err := d.split("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Open(rest)
+}
+
+// ReadDir reads the named directory, implementing fs.ReadDirFS.
+func (d dirsFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == "." {
+		return d.entries(), nil
+	}
+	fsys, rest, err := d.split("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadDir(fsys, rest)
+}
+
+// entries lists the directories at the root, sorted by name.
+func (d dirsFS) entries() []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(d))
+	for name := range d {
+		entries = append(entries, fs.FileInfoToDirEntry(dirInfo(name)))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+// dirsRoot is the root directory of a dirsFS, as returned by Open.
+type dirsRoot struct {
+	entries []fs.DirEntry
+}
+
+// Stat implements fs.File.
+func (r *dirsRoot) Stat() (fs.FileInfo, error) { return dirInfo("."), nil }
+
+// Read implements fs.File; the root is a directory and cannot be read.
+func (r *dirsRoot) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: fs.ErrInvalid}
+}
+
+// Close implements fs.File.
+func (r *dirsRoot) Close() error { return nil }
+
+// ReadDir implements fs.ReadDirFile, returning the remaining entries.
+func (r *dirsRoot) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := r.entries
+		r.entries = nil
+		return entries, nil
+	}
+	if len(r.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(r.entries))
+	entries := r.entries[:n]
+	r.entries = r.entries[n:]
+	return entries, nil
+}
+
+// dirInfo is the fs.FileInfo of a directory synthesized by dirsFS.
+type dirInfo string
+
+// Name implements fs.FileInfo.
+func (d dirInfo) Name() string { return string(d) }
+
+// Size implements fs.FileInfo.
+func (d dirInfo) Size() int64 { return 0 }
+
+// Mode implements fs.FileInfo.
+func (d dirInfo) Mode() fs.FileMode { return fs.ModeDir | 0o555 }
+
+// ModTime implements fs.FileInfo.
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+
+// IsDir implements fs.FileInfo.
+func (d dirInfo) IsDir() bool { return true }
+
+// Sys implements fs.FileInfo.
+func (d dirInfo) Sys() any { return nil }
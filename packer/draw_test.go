@@ -0,0 +1,69 @@
+package packer
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"testing"
+)
+
+// TestDrawOffsetBounds checks that sprites whose bounds do not start at the
+// origin, such as sub-images, are drawn from their own top-left pixel, both
+// upright and turned.
+func TestDrawOffsetBounds(t *testing.T) {
+	sheet := patterned(16, 16, 5)
+	upright := sheet.SubImage(image.Rect(3, 2, 9, 6))
+	turned := sheet.SubImage(image.Rect(10, 8, 16, 12))
+	rectangles := []Rectangle{
+		{ID: 0, Name: "upright", Width: 6, Height: 4, Image: upright},
+		{ID: 1, Name: "turned", Width: 6, Height: 4, Image: turned},
+	}
+	layout := Layout{
+		Placements: map[int]image.Rectangle{0: image.Rect(1, 1, 7, 5), 1: image.Rect(8, 0, 12, 6)},
+		Width:      12,
+		Height:     6,
+	}
+	atlas, err := drawAtlas(context.Background(), rectangles, layout, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := buildManifest("atlas.png", rectangles, layout)
+	sprites := Unpack(atlas, manifest)
+	for _, rect := range rectangles {
+		want := image.NewNRGBA(image.Rect(0, 0, 6, 4))
+		draw.Draw(want, want.Bounds(), rect.Image, rect.Image.Bounds().Min, draw.Src)
+		sameImage(t, rect.Name, sprites[rect.Name], want)
+	}
+}
+
+// BenchmarkDrawAtlas compares drawing a few hundred large sprites into the
+// atlas one at a time with drawAtlas, which draws them on a pool of
+// workers.
+func BenchmarkDrawAtlas(b *testing.B) {
+	rectangles := make([]Rectangle, 300)
+	for i := range rectangles {
+		w, h := 96+i%5*16, 64+i%7*16
+		rectangles[i] = Rectangle{ID: i, Name: "s", Width: w, Height: h, Image: patterned(w, h, uint8(i))}
+	}
+	opts := Options{MaxWidth: 4096, MaxHeight: 4096}
+	layout, err := planLayout(rectangles, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		for b.Loop() {
+			atlas := image.NewNRGBA(image.Rect(0, 0, layout.Width, layout.Height))
+			for _, rect := range rectangles {
+				draw.Draw(atlas, layout.Placements[rect.ID], rect.Image, image.Point{}, draw.Src)
+			}
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := drawAtlas(context.Background(), rectangles, layout, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// dumpFilename returns the file a sprite is dumped to inside dir: its name
+// exactly as in the manifest, keeping the directories within it, such as
+// "BIG.PNG". A name with another extension, or none, has it replaced by
+// ".png", since the file is always a PNG.
+func dumpFilename(dir, name string) string {
+	if !strings.EqualFold(path.Ext(name), ".png") {
+		name = strings.TrimSuffix(name, path.Ext(name)) + ".png"
+	}
+	return filepath.Join(dir, filepath.FromSlash(name))
+}
+
+// dumpSprites writes the image of every rectangle, as it will be packed
+// after trimming and any other processing, as its own PNG file in dir,
+// creating the directories it needs. Files already present are only
+// replaced when opts.Overwrite is set. Writing stops once ctx is done.
+func dumpSprites(ctx context.Context, dir string, rectangles []Rectangle, opts Options) error {
+	errs := make([]error, len(rectangles))
+	err := runPool(ctx, len(rectangles), runtime.NumCPU(), func(i int) {
+		rect := rectangles[i]
+		filename := dumpFilename(dir, rect.Name)
+		img, err := rect.pixels()
+		if err == nil {
+			err = saveAtlas(filename, img, opts)
+		}
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %w", rect.Name, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
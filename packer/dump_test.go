@@ -0,0 +1,41 @@
+package packer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// TestDumpTrimmedNames checks that -dumptrimmed writes each sprite under
+// its manifest name exactly, keeping the case of its extension, and only
+// gives non-PNG names a .png extension.
+func TestDumpTrimmedNames(t *testing.T) {
+	fsys := fstest.MapFS{
+		"BIG.PNG":         pngFile(t, patterned(4, 4, 1)),
+		"chars/hero.png":  pngFile(t, patterned(3, 5, 2)),
+		"chars/badge.gif": pngFile(t, patterned(2, 2, 3)),
+	}
+	dir := t.TempDir()
+	opts := runOptions(fsys, dir)
+	opts.Recursive = true
+	opts.DumpTrimmed = filepath.Join(dir, "dump")
+	captureStdout(t, func() {
+		if _, err := Run(context.Background(), opts); err != nil {
+			t.Error(err)
+		}
+	})
+	var got []string
+	filepath.WalkDir(opts.DumpTrimmed, func(file string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(opts.DumpTrimmed, file)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if want := []string{"BIG.PNG", "chars/badge.png", "chars/hero.png"}; !slices.Equal(got, want) {
+		t.Errorf("dumped %q, want %q", got, want)
+	}
+}
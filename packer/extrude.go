@@ -0,0 +1,28 @@
+package packer
+
+import (
+	"image"
+	"image/draw"
+)
+
+// extrude repeats the outermost columns and then rows of the pixels at r in
+// the atlas n pixels outward on every side, so the corners take the corner
+// pixels, and texture filtering just outside a sprite samples its own edge
+// colors rather than the gap. Pixels beyond the atlas are left out. The
+// padding around the sprite must leave room for n pixels, or they overwrite
+// its neighbours.
+func extrude(atlas draw.Image, r image.Rectangle, n int) {
+	if r.Empty() {
+		return
+	}
+	// draw.Draw clips each band to the atlas and shifts its source to match.
+	for k := 1; k <= n; k++ {
+		draw.Draw(atlas, image.Rect(r.Min.X-k, r.Min.Y, r.Min.X-k+1, r.Max.Y), atlas, r.Min, draw.Src)
+		draw.Draw(atlas, image.Rect(r.Max.X+k-1, r.Min.Y, r.Max.X+k, r.Max.Y), atlas, image.Pt(r.Max.X-1, r.Min.Y), draw.Src)
+	}
+	left, right := r.Min.X-n, r.Max.X+n
+	for k := 1; k <= n; k++ {
+		draw.Draw(atlas, image.Rect(left, r.Min.Y-k, right, r.Min.Y-k+1), atlas, image.Pt(left, r.Min.Y), draw.Src)
+		draw.Draw(atlas, image.Rect(left, r.Max.Y+k-1, right, r.Max.Y+k), atlas, image.Pt(left, r.Max.Y-1), draw.Src)
+	}
+}
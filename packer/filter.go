@@ -0,0 +1,69 @@
+package packer
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ParseGlobs parses the comma-separated glob patterns of -include or
+// -exclude, in the syntax of path.Match, failing on the first malformed one.
+func ParseGlobs(value string) ([]string, error) {
+	patterns := SplitList(value)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+	}
+	return patterns, nil
+}
+
+// matchesGlobs reports whether the base name of the file matches one of
+// the include patterns, or there are none, and none of the exclude ones.
+func matchesGlobs(name string, include, exclude []string) bool {
+	base := path.Base(name)
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			// The patterns were checked by ParseGlobs, so Match cannot fail.
+			if ok, _ := path.Match(pattern, base); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(include) == 0 || matches(include)) && !matches(exclude)
+}
+
+// filtersImages reports whether opts leaves any image files out of
+// walkImageFiles.
+func filtersImages(opts Options) bool {
+	return !opts.Recursive || len(opts.Include) > 0 || len(opts.Exclude) > 0
+}
+
+// walkImageFiles calls fn, in lexical order, for each image file in fsys
+// that opts.Include and opts.Exclude select. Without opts.Recursive, only
+// the files at the top of each -filedir directory are visited: those at the
+// root of fsys, or, when it joins several directories, those directly in
+// each of them.
+func walkImageFiles(fsys fs.FS, opts Options, fn func(name string, d fs.DirEntry) error) error {
+	top := 0
+	if _, ok := fsys.(dirsFS); ok {
+		top = 1
+	}
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if !opts.Recursive && name != "." && strings.Count(name, "/") >= top {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !isImageFile(name) || !matchesGlobs(name, opts.Include, opts.Exclude) {
+			return nil
+		}
+		return fn(name, d)
+	})
+}
@@ -0,0 +1,163 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GlyphFont is the glyph metadata read with -glyphs: the font's face, size
+// and line metrics, the character each glyph sprite stands for, keyed by
+// sprite name as it appears in the manifest, and the font's kerning pairs.
+// Offsets and advances are in pixels of the source glyph images.
+type GlyphFont struct {
+	Face       string           `json:"face"`
+	Size       int              `json:"size"`
+	LineHeight int              `json:"lineHeight"`
+	Base       int              `json:"base"`
+	Glyphs     map[string]Glyph `json:"glyphs"`
+	Kernings   []Kerning        `json:"kernings"`
+}
+
+// Glyph is the character code of a glyph sprite and where it is drawn
+// relative to the pen position: XOffset and YOffset place the top-left of
+// the untrimmed glyph image, and XAdvance moves the pen to the next glyph.
+type Glyph struct {
+	ID       int `json:"id"`
+	XOffset  int `json:"xoffset"`
+	YOffset  int `json:"yoffset"`
+	XAdvance int `json:"xadvance"`
+}
+
+// Kerning adjusts the advance between the characters First and Second by
+// Amount pixels.
+type Kerning struct {
+	First  int `json:"first"`
+	Second int `json:"second"`
+	Amount int `json:"amount"`
+}
+
+// LoadGlyphs reads a glyph metadata JSON file, rejecting one that gives two
+// glyphs the same character code.
+func LoadGlyphs(filename string) (*GlyphFont, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var font GlyphFont
+	if err := json.Unmarshal(data, &font); err != nil {
+		return nil, fmt.Errorf("failed to parse glyphs %s: %w", filename, err)
+	}
+	names := make(map[int]string, len(font.Glyphs))
+	for name, glyph := range font.Glyphs {
+		if other, ok := names[glyph.ID]; ok {
+			first, second := min(name, other), max(name, other)
+			return nil, fmt.Errorf("glyphs %s: %q and %q both have id %d", filename, first, second, glyph.ID)
+		}
+		names[glyph.ID] = name
+	}
+	return &font, nil
+}
+
+// checkGlyphs warns about glyph entries that match no sprite, which usually
+// means a glyph image was renamed or left out of -filedir.
+func checkGlyphs(rectangles []Rectangle, font *GlyphFont) {
+	found := make(map[string]bool, len(rectangles))
+	for _, rect := range rectangles {
+		found[rect.Name] = true
+	}
+	var unused []string
+	for name := range font.Glyphs {
+		if !found[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	for _, name := range unused {
+		Warnf("glyph entry %q matches no sprite", name)
+	}
+}
+
+// fontFilename returns the filename of the BMFont file written beside an
+// atlas with -glyphs.
+func fontFilename(atlasFile string) string {
+	return strings.TrimSuffix(atlasFile, filepath.Ext(atlasFile)) + ".fnt"
+}
+
+// bmfont renders the glyphs packed into the manifest's atlas as a BMFont
+// text descriptor with one page, the atlas, and returns false when the atlas
+// holds none of them. Glyph offsets account for trimmed borders, the -sdf
+// spread and -scale, so text laid out from the descriptor lands where the
+// source glyph images would. Only kerning pairs between glyphs on the atlas
+// are listed.
+func bmfont(manifest Manifest, font *GlyphFont, opts Options) ([]byte, bool) {
+	type char struct {
+		glyph Glyph
+		entry SpriteEntry
+	}
+	var chars []char
+	ids := make(map[int]bool)
+	for name, entry := range manifest.Sprites {
+		glyph, ok := font.Glyphs[name]
+		if !ok {
+			continue
+		}
+		chars = append(chars, char{glyph, entry})
+		ids[glyph.ID] = true
+	}
+	if len(chars) == 0 {
+		return nil, false
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i].glyph.ID < chars[j].glyph.ID })
+
+	scale := max(opts.Scale, 1)
+	spread := 0
+	if opts.SDF {
+		spread = opts.SDFSpread
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "info face=%q size=%d bold=0 italic=0 charset=\"\" unicode=1 stretchH=100 smooth=1 aa=1 padding=0,0,0,0 spacing=%d,%d\n",
+		font.Face, font.Size*scale, opts.Padding.X, opts.Padding.Y)
+	fmt.Fprintf(&buf, "common lineHeight=%d base=%d scaleW=%d scaleH=%d pages=1 packed=0\n",
+		font.LineHeight*scale, font.Base*scale, manifest.Width, manifest.Height)
+	fmt.Fprintf(&buf, "page id=0 file=%q\n", filepath.Base(manifest.Image))
+	fmt.Fprintf(&buf, "chars count=%d\n", len(chars))
+	for _, c := range chars {
+		x, y := c.glyph.XOffset*scale-spread, c.glyph.YOffset*scale-spread
+		if c.entry.Trim != nil {
+			x += c.entry.Trim.X
+			y += c.entry.Trim.Y
+		}
+		fmt.Fprintf(&buf, "char id=%d x=%d y=%d width=%d height=%d xoffset=%d yoffset=%d xadvance=%d page=0 chnl=15\n",
+			c.glyph.ID, c.entry.X, c.entry.Y, c.entry.W, c.entry.H, x, y, c.glyph.XAdvance*scale)
+	}
+
+	var kernings []Kerning
+	for _, k := range font.Kernings {
+		if ids[k.First] && ids[k.Second] {
+			kernings = append(kernings, k)
+		}
+	}
+	if len(kernings) > 0 {
+		fmt.Fprintf(&buf, "kernings count=%d\n", len(kernings))
+		for _, k := range kernings {
+			fmt.Fprintf(&buf, "kerning first=%d second=%d amount=%d\n", k.First, k.Second, k.Amount*scale)
+		}
+	}
+	return buf.Bytes(), true
+}
+
+// saveFont writes the BMFont descriptor for the manifest's atlas to
+// filename, honoring opts.Overwrite, unless the atlas holds no glyphs.
+func saveFont(filename string, manifest Manifest, opts Options) error {
+	data, ok := bmfont(manifest, opts.Glyphs, opts)
+	if !ok {
+		return nil
+	}
+	return writeOutput(filename, data, opts.Overwrite)
+}
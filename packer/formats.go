@@ -0,0 +1,83 @@
+package packer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	// Decoders for the input formats the standard library does not read.
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+// imageFormat is an image file format that source images are collected in
+// or atlases are written in. Sample is the start of a file in an input
+// format, long enough for the image package to recognize it when a decoder
+// for the format is registered.
+type imageFormat struct {
+	Name       string
+	Extensions []string
+	Sample     string
+}
+
+// inputFormats lists the formats whose files are collected from -filedir.
+// A file is only collected by extension; whether it can be decoded depends
+// on the decoders compiled into the binary, which decoderRegistered reports.
+var inputFormats = []imageFormat{
+	{Name: "png", Extensions: []string{".png"}, Sample: pngSignature},
+	{Name: "jpeg", Extensions: []string{".jpg", ".jpeg"}, Sample: "\xff\xd8"},
+	{Name: "gif", Extensions: []string{".gif"}, Sample: "GIF89a"},
+	{Name: "bmp", Extensions: []string{".bmp"}, Sample: "BM"},
+	{Name: "webp", Extensions: []string{".webp"}, Sample: "RIFF\x00\x00\x00\x00WEBPVP8 "},
+	{Name: "svg", Extensions: []string{".svg"}, Sample: "<svg"},
+}
+
+// outputFormats lists the formats atlases are written in, chosen by the
+// extension of the atlas filename.
+var outputFormats = []imageFormat{
+	{Name: "png", Extensions: []string{".png"}},
+	{Name: "gif", Extensions: []string{".gif"}},
+	{Name: "jpeg", Extensions: []string{".jpg", ".jpeg"}},
+}
+
+// OutputFormat returns the name of the format an image file is written in,
+// by its extension in any case, or "" if no output format has it.
+func OutputFormat(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, format := range outputFormats {
+		if slices.Contains(format.Extensions, ext) {
+			return format.Name
+		}
+	}
+	return ""
+}
+
+// decoderRegistered reports whether the image package has a decoder for the
+// format, by asking it to decode the format's sample: without a decoder the
+// sample is rejected as an unknown format before anything is read.
+func decoderRegistered(format imageFormat) bool {
+	sample := append([]byte(format.Sample), make([]byte, 64)...)
+	_, _, err := image.DecodeConfig(bytes.NewReader(sample))
+	return !errors.Is(err, image.ErrFormat)
+}
+
+// PrintFormats lists the input formats, with their extensions and whether a
+// decoder for each is compiled in, and the output formats with theirs.
+func PrintFormats() {
+	fmt.Println("Input formats:")
+	for _, format := range inputFormats {
+		status := "available"
+		if !decoderRegistered(format) {
+			status = "not compiled in"
+		}
+		fmt.Printf("  %-5s %-14s %s\n", format.Name, strings.Join(format.Extensions, ", "), status)
+	}
+	fmt.Println("Output formats:")
+	for _, format := range outputFormats {
+		fmt.Printf("  %-5s %s\n", format.Name, strings.Join(format.Extensions, ", "))
+	}
+}
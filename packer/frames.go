@@ -0,0 +1,443 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"io/fs"
+	"math"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Ways a frame of an animation leaves the canvas before the next frame is
+// drawn, shared by APNG's dispose_op and WebP's disposal method.
+const (
+	// disposeNone leaves the frame on the canvas.
+	disposeNone = iota
+	// disposeBackground clears the frame's region to transparent.
+	disposeBackground
+	// disposePrevious restores the region to what it was before the frame.
+	disposePrevious
+)
+
+// FrameEntry records which animation a sprite extracted with -frames came
+// from: the animation's source file, the frame's position in it counting
+// from 0, and how long the frame is shown in milliseconds.
+type FrameEntry struct {
+	Animation string `json:"animation"`
+	Index     int    `json:"index"`
+	Delay     int    `json:"delay"`
+}
+
+// animation is an animated source image: the size of its canvas and how
+// each frame is drawn onto it. Deep is set when the frames have 16 bits per
+// channel.
+type animation struct {
+	Width  int
+	Height int
+	Deep   bool
+	Frames []animationFrame
+}
+
+// animationFrame is one frame of an animation: the canvas region it covers,
+// its delay in milliseconds, whether it is alpha-blended over the canvas or
+// replaces it, how it is disposed of, and the frame encoded as a standalone
+// image of its region for the image package to decode.
+type animationFrame struct {
+	Bounds  image.Rectangle
+	Delay   int
+	Blend   bool
+	Dispose int
+	data    []byte
+}
+
+// scanAnimations reads the PNG and WebP files among files and returns the
+// ones holding more than one frame, keyed by file name. With skipBad, a file
+// whose animation cannot be parsed is treated as a still image, so loading
+// it reports the problem or skips it. Reading stops once ctx is done.
+func scanAnimations(ctx context.Context, fsys fs.FS, files []string, skipBad bool) (map[string]*animation, error) {
+	found := make([]*animation, len(files))
+	errs := make([]error, len(files))
+	err := runPool(ctx, len(files), runtime.NumCPU(), func(i int) {
+		var parse func([]byte) (*animation, error)
+		switch strings.ToLower(path.Ext(files[i])) {
+		case ".png":
+			parse = parseAPNG
+		case ".webp":
+			parse = parseAnimatedWebP
+		default:
+			return
+		}
+		data, err := fs.ReadFile(fsys, files[i])
+		if err == nil {
+			found[i], err = parse(data)
+		}
+		if err != nil && !skipBad {
+			errs[i] = fmt.Errorf("%s: %w", files[i], err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	animations := make(map[string]*animation)
+	for i, anim := range found {
+		if anim != nil && len(anim.Frames) > 1 {
+			animations[files[i]] = anim
+		}
+	}
+	return animations, nil
+}
+
+// frameName returns the sprite name of frame i of the animation in file,
+// which has n frames: the file's name with the frame number, padded to the
+// same width for every frame so names sort in frame order, before the
+// extension. Frame 3 of 12 of "walk.png" is "walk_03.png".
+func frameName(file string, i, n int) string {
+	ext := path.Ext(file)
+	digits := len(strconv.Itoa(n - 1))
+	return fmt.Sprintf("%s_%0*d%s", strings.TrimSuffix(file, ext), digits, i, ext)
+}
+
+// render decodes every frame and composes it onto the canvas, returning a
+// full-canvas image of each frame as it is shown.
+func (a *animation) render() ([]image.Image, error) {
+	var canvas draw.Image = image.NewNRGBA(image.Rect(0, 0, a.Width, a.Height))
+	if a.Deep {
+		canvas = image.NewNRGBA64(canvas.Bounds())
+	}
+
+	images := make([]image.Image, len(a.Frames))
+	for i, frame := range a.Frames {
+		img, _, err := image.Decode(bytes.NewReader(frame.data))
+		if errors.Is(err, image.ErrFormat) {
+			return nil, fmt.Errorf("frame %d: no decoder for its format is compiled in", i)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i, err)
+		}
+		var previous draw.Image
+		if frame.Dispose == disposePrevious {
+			previous = cloneImage(canvas)
+		}
+		op := draw.Src
+		if frame.Blend {
+			op = draw.Over
+		}
+		draw.Draw(canvas, frame.Bounds, img, img.Bounds().Min, op)
+		images[i] = cloneImage(canvas)
+
+		switch frame.Dispose {
+		case disposeBackground:
+			draw.Draw(canvas, frame.Bounds, image.Transparent, image.Point{}, draw.Src)
+		case disposePrevious:
+			draw.Draw(canvas, frame.Bounds, previous, frame.Bounds.Min, draw.Src)
+		}
+	}
+	return images, nil
+}
+
+// cloneImage returns a copy of img in the same color model.
+func cloneImage(img draw.Image) draw.Image {
+	var clone draw.Image
+	if _, ok := img.(*image.NRGBA64); ok {
+		clone = image.NewNRGBA64(img.Bounds())
+	} else {
+		clone = image.NewNRGBA(img.Bounds())
+	}
+	draw.Draw(clone, img.Bounds(), img, img.Bounds().Min, draw.Src)
+	return clone
+}
+
+// frameDelay converts a frame delay given as a fraction of a second into
+// milliseconds, rounded to the nearest one.
+func frameDelay(num, den int) int {
+	if den == 0 {
+		// APNG treats a zero denominator as hundredths of a second.
+		den = 100
+	}
+	return int(math.Round(float64(num) * 1000 / float64(den)))
+}
+
+// parseAPNG reads the frames of an animated PNG, returning nil for a PNG
+// without an acTL chunk. Each frame is rebuilt as a PNG of its own region from
+// the header chunks before the image data and its IDAT or fdAT data. The
+// default image is only a frame when an fcTL chunk precedes its IDAT.
+func parseAPNG(data []byte) (*animation, error) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, errors.New("not a PNG file")
+	}
+	var (
+		ihdr     []byte
+		header   bytes.Buffer
+		anim     *animation
+		payloads [][]byte
+		count    int
+		seenData bool
+	)
+	for rest := data[len(pngSignature):]; ; {
+		if len(rest) < 12 {
+			return nil, errors.New("truncated chunk")
+		}
+		length := binary.BigEndian.Uint32(rest[:4])
+		if uint64(length) > uint64(len(rest)-12) {
+			return nil, errors.New("truncated chunk")
+		}
+		kind, body := string(rest[4:8]), rest[8:8+length]
+		chunk := rest[:12+length]
+		rest = rest[12+length:]
+
+		switch kind {
+		case "IHDR":
+			if len(body) != 13 {
+				return nil, fmt.Errorf("IHDR chunk of %d bytes, want 13", len(body))
+			}
+			ihdr = body
+		case "acTL":
+			if ihdr == nil || len(body) != 8 {
+				return nil, errors.New("invalid acTL chunk")
+			}
+			count = int(binary.BigEndian.Uint32(body[:4]))
+			anim = &animation{
+				Width:  int(binary.BigEndian.Uint32(ihdr[0:4])),
+				Height: int(binary.BigEndian.Uint32(ihdr[4:8])),
+				Deep:   ihdr[8] == 16,
+			}
+		case "fcTL":
+			if anim == nil {
+				continue
+			}
+			if len(body) != 26 {
+				return nil, fmt.Errorf("fcTL chunk of %d bytes, want 26", len(body))
+			}
+			w, h := int(binary.BigEndian.Uint32(body[4:8])), int(binary.BigEndian.Uint32(body[8:12]))
+			x, y := int(binary.BigEndian.Uint32(body[12:16])), int(binary.BigEndian.Uint32(body[16:20]))
+			bounds := image.Rect(x, y, x+w, y+h)
+			if w == 0 || h == 0 || !bounds.In(image.Rect(0, 0, anim.Width, anim.Height)) {
+				return nil, fmt.Errorf("frame %d at %v lies outside the %dx%d canvas", len(anim.Frames), bounds, anim.Width, anim.Height)
+			}
+			frame := animationFrame{
+				Bounds:  bounds,
+				Delay:   frameDelay(int(binary.BigEndian.Uint16(body[20:22])), int(binary.BigEndian.Uint16(body[22:24]))),
+				Dispose: int(body[24]),
+				Blend:   body[25] == 1,
+			}
+			if frame.Dispose > disposePrevious {
+				return nil, fmt.Errorf("frame %d has unknown dispose_op %d", len(anim.Frames), frame.Dispose)
+			}
+			if len(anim.Frames) == 0 && frame.Dispose == disposePrevious {
+				frame.Dispose = disposeBackground
+			}
+			anim.Frames = append(anim.Frames, frame)
+			payloads = append(payloads, nil)
+		case "IDAT", "fdAT":
+			seenData = seenData || kind == "IDAT"
+			if anim == nil {
+				if kind == "IDAT" {
+					return nil, nil
+				}
+				continue
+			}
+			if len(payloads) == 0 {
+				continue
+			}
+			if kind == "fdAT" {
+				if len(body) < 4 {
+					return nil, errors.New("truncated fdAT chunk")
+				}
+				body = body[4:]
+			}
+			last := len(payloads) - 1
+			payloads[last] = append(payloads[last], body...)
+		case "IEND":
+			if anim == nil {
+				return nil, nil
+			}
+			if len(anim.Frames) != count {
+				return nil, fmt.Errorf("acTL promises %d frames but %d were found", count, len(anim.Frames))
+			}
+			for i := range anim.Frames {
+				anim.Frames[i].data = apngFrame(ihdr, header.Bytes(), anim.Frames[i].Bounds, payloads[i])
+			}
+			return anim, nil
+		default:
+			// Chunks before the image data, such as PLTE and tRNS, apply
+			// to every frame, so they are copied into each rebuilt PNG.
+			if !seenData {
+				header.Write(chunk)
+			}
+		}
+	}
+}
+
+// apngFrame builds a standalone PNG for a frame of the given bounds from the
+// animation's IHDR chunk body, the copied header chunks and the frame's
+// compressed image data.
+func apngFrame(ihdr, header []byte, bounds image.Rectangle, payload []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(pngSignature)
+	frameIHDR := append([]byte(nil), ihdr...)
+	binary.BigEndian.PutUint32(frameIHDR[0:4], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(frameIHDR[4:8], uint32(bounds.Dy()))
+	writePNGChunk(&b, "IHDR", frameIHDR)
+	b.Write(header)
+	writePNGChunk(&b, "IDAT", payload)
+	writePNGChunk(&b, "IEND", nil)
+	return b.Bytes()
+}
+
+// writePNGChunk appends a PNG chunk with its length and CRC.
+func writePNGChunk(b *bytes.Buffer, kind string, data []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	b.Write(n[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(data)
+	b.WriteString(kind)
+	b.Write(data)
+	binary.BigEndian.PutUint32(n[:], crc.Sum32())
+	b.Write(n[:])
+}
+
+// parseAnimatedWebP reads the frames of an animated WebP, returning nil for a
+// WebP without the animation flag. Each ANMF frame is rebuilt as a WebP of its
+// own region, so decoding the frames needs a WebP decoder registered with the
+// image package.
+func parseAnimatedWebP(data []byte) (*animation, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("not a WebP file")
+	}
+	var anim *animation
+	for rest := data[12:]; len(rest) > 0; {
+		kind, body, next, err := riffChunk(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = next
+
+		switch kind {
+		case "VP8X":
+			if len(body) < 10 {
+				return nil, errors.New("truncated VP8X chunk")
+			}
+			if body[0]&0x02 == 0 {
+				return nil, nil
+			}
+			anim = &animation{Width: 1 + uint24(body[4:7]), Height: 1 + uint24(body[7:10])}
+		case "ANMF":
+			if anim == nil {
+				return nil, errors.New("ANMF chunk without an animated VP8X header")
+			}
+			if len(body) < 16 {
+				return nil, errors.New("truncated ANMF chunk")
+			}
+			x, y := 2*uint24(body[0:3]), 2*uint24(body[3:6])
+			w, h := 1+uint24(body[6:9]), 1+uint24(body[9:12])
+			bounds := image.Rect(x, y, x+w, y+h)
+			if !bounds.In(image.Rect(0, 0, anim.Width, anim.Height)) {
+				return nil, fmt.Errorf("frame %d at %v lies outside the %dx%d canvas", len(anim.Frames), bounds, anim.Width, anim.Height)
+			}
+			frame := animationFrame{
+				Bounds: bounds,
+				Delay:  uint24(body[12:15]),
+				Blend:  body[15]&0x02 == 0,
+			}
+			if body[15]&0x01 != 0 {
+				frame.Dispose = disposeBackground
+			}
+			if frame.data, err = webpFrame(body[16:], w, h); err != nil {
+				return nil, fmt.Errorf("frame %d: %w", len(anim.Frames), err)
+			}
+			anim.Frames = append(anim.Frames, frame)
+		case "VP8 ", "VP8L":
+			if anim == nil {
+				return nil, nil
+			}
+		}
+	}
+	return anim, nil
+}
+
+// webpFrame wraps the ALPH and VP8 or VP8L chunks of an ANMF frame of the
+// given size in a WebP file of their own. Lossy frames with an alpha channel
+// need a VP8X header announcing it.
+func webpFrame(chunks []byte, w, h int) ([]byte, error) {
+	var alpha, bitstream []byte
+	for rest := chunks; len(rest) > 0; {
+		kind, _, next, err := riffChunk(rest)
+		if err != nil {
+			return nil, err
+		}
+		chunk := rest[:len(rest)-len(next)]
+		rest = next
+		switch kind {
+		case "ALPH":
+			alpha = chunk
+		case "VP8 ", "VP8L":
+			bitstream = chunk
+		}
+	}
+	if bitstream == nil {
+		return nil, errors.New("no VP8 or VP8L image data")
+	}
+
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	if alpha != nil && string(bitstream[:4]) == "VP8 " {
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x10
+		putUint24(vp8x[4:7], w-1)
+		putUint24(vp8x[7:10], h-1)
+		body.WriteString("VP8X")
+		binary.Write(&body, binary.LittleEndian, uint32(len(vp8x)))
+		body.Write(vp8x)
+		body.Write(alpha)
+	}
+	body.Write(bitstream)
+
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(body.Len()))
+	b.Write(body.Bytes())
+	return b.Bytes(), nil
+}
+
+// riffChunk splits the first RIFF chunk off data, returning its FourCC, its
+// body and what follows it, skipping the pad byte after an odd-sized body.
+func riffChunk(data []byte) (kind string, body, rest []byte, err error) {
+	if len(data) < 8 {
+		return "", nil, nil, errors.New("truncated chunk")
+	}
+	size := binary.LittleEndian.Uint32(data[4:8])
+	if uint64(size) > uint64(len(data)-8) {
+		return "", nil, nil, fmt.Errorf("truncated %s chunk", data[0:4])
+	}
+	end := 8 + int(size)
+	body = data[8:end]
+	if size%2 == 1 && end < len(data) {
+		end++
+	}
+	return string(data[0:4]), body, data[end:], nil
+}
+
+// uint24 decodes a little-endian 24-bit integer.
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+// putUint24 encodes v as a little-endian 24-bit integer.
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -0,0 +1,119 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+// freeRegions partitions the space of the layout that no sprite or reserved
+// region uses into disjoint rectangles, largest first, leaving out the
+// margin of -borderpadding along the edges. A sprite's footprint
+// includes the padding to its right and below, which nothing else may use
+// either. Free space is swept in horizontal bands between sprite edges, and a
+// free span that continues unchanged into the next band extends the same
+// rectangle, so the tail of a shelf or the gap above a short sprite comes
+// out whole.
+func freeRegions(layout Layout, padding Padding) []image.Rectangle {
+	bounds := image.Rect(0, 0, layout.Width, layout.Height).Inset(layout.Border)
+	footprints := make([]image.Rectangle, 0, len(layout.Placements))
+	ys := []int{bounds.Min.Y, bounds.Max.Y}
+	used := make([]image.Rectangle, 0, len(layout.Placements)+1)
+	for _, r := range layout.Placements {
+		used = append(used, r)
+	}
+	if !layout.Reserved.Empty() {
+		used = append(used, layout.Reserved)
+	}
+	for _, r := range used {
+		r.Max = r.Max.Add(image.Pt(padding.X, padding.Y))
+		r = r.Intersect(bounds)
+		if r.Empty() {
+			continue
+		}
+		footprints = append(footprints, r)
+		ys = append(ys, r.Min.Y, r.Max.Y)
+	}
+	sort.Ints(ys)
+
+	var regions []image.Rectangle
+	open := make(map[[2]int]image.Rectangle)
+	for i := 0; i+1 < len(ys); i++ {
+		y0, y1 := ys[i], ys[i+1]
+		if y0 == y1 {
+			continue
+		}
+		var used [][2]int
+		for _, r := range footprints {
+			if r.Min.Y < y1 && r.Max.Y > y0 {
+				used = append(used, [2]int{r.Min.X, r.Max.X})
+			}
+		}
+		sort.Slice(used, func(a, b int) bool { return used[a][0] < used[b][0] })
+
+		next := make(map[[2]int]image.Rectangle)
+		x := bounds.Min.X
+		for _, span := range append(used, [2]int{bounds.Max.X, bounds.Max.X}) {
+			if span[0] > x {
+				key := [2]int{x, span[0]}
+				r, ok := open[key]
+				if !ok {
+					r = image.Rect(x, y0, span[0], y0)
+				}
+				r.Max.Y = y1
+				next[key] = r
+				delete(open, key)
+			}
+			x = max(x, span[1])
+		}
+		for _, r := range open {
+			regions = append(regions, r)
+		}
+		open = next
+	}
+	for _, r := range open {
+		regions = append(regions, r)
+	}
+
+	sort.Slice(regions, func(i, j int) bool {
+		ai, aj := regions[i].Dx()*regions[i].Dy(), regions[j].Dx()*regions[j].Dy()
+		if ai != aj {
+			return ai > aj
+		}
+		if regions[i].Min.Y != regions[j].Min.Y {
+			return regions[i].Min.Y < regions[j].Min.Y
+		}
+		return regions[i].Min.X < regions[j].Min.X
+	})
+	return regions
+}
+
+// freeEntries returns the free regions of the layout, as freeRegions gives
+// them, for the manifest.
+func freeEntries(layout Layout, padding Padding) []RegionEntry {
+	regions := freeRegions(layout, padding)
+	entries := make([]RegionEntry, len(regions))
+	for i, r := range regions {
+		entries[i] = RegionEntry{X: r.Min.X, Y: r.Min.Y, W: r.Dx(), H: r.Dy()}
+	}
+	return entries
+}
+
+// printFreeRegions lists the free regions of an atlas, largest first, with
+// their total area and its share of the atlas, for judging how much space
+// the packer wasted and where.
+func printFreeRegions(filename string, layout Layout, padding Padding) {
+	regions := freeRegions(layout, padding)
+	total := 0
+	for _, r := range regions {
+		total += r.Dx() * r.Dy()
+	}
+	share := 0.0
+	if area := layout.Width * layout.Height; area > 0 {
+		share = float64(total) / float64(area) * 100
+	}
+	fmt.Printf("Free regions of %s: %d, %d px (%.1f%% of the atlas)\n", filename, len(regions), total, share)
+	for _, r := range regions {
+		fmt.Printf("  %v: %d x %d, %d px\n", r, r.Dx(), r.Dy(), r.Dx()*r.Dy())
+	}
+}
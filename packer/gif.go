@@ -0,0 +1,286 @@
+package packer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"sort"
+)
+
+// gifAlphaThreshold is the alpha from which a pixel is opaque in a GIF,
+// which only has fully transparent and fully opaque pixels.
+const gifAlphaThreshold = 128
+
+// gifBucketBits is the number of bits per channel of the color histogram
+// GIF palettes are chosen from when an atlas has too many colors to keep,
+// so the cost of choosing them does not grow with the number of colors.
+const gifBucketBits = 5
+
+// colorBucket sums the pixels whose colors fall in one cell of the color
+// histogram: their number and the totals of their channels.
+type colorBucket struct {
+	count   int
+	r, g, b int
+}
+
+// bucketIndex returns the cell of the color histogram a color falls in.
+func bucketIndex(r, g, b uint8) int {
+	const shift = 8 - gifBucketBits
+	return int(r>>shift)<<(2*gifBucketBits) | int(g>>shift)<<gifBucketBits | int(b>>shift)
+}
+
+// bucketChannel returns the red, green or blue coordinate, for channel 0, 1
+// or 2, of a cell of the color histogram.
+func bucketChannel(bucket, channel int) int {
+	return bucket >> ((2 - channel) * gifBucketBits) & (1<<gifBucketBits - 1)
+}
+
+// encodeGIF writes the image to w as a GIF with at most opts.GIFColors
+// colors, one of which is transparent when any pixel is. An image with few
+// enough colors keeps them exactly, so GIF sources packed into a GIF atlas
+// round-trip; otherwise the palette is chosen by median cut and, with
+// opts.GIFDither, the colors are dithered with Floyd-Steinberg error
+// diffusion. A warning names the file when colors are merged or translucent
+// pixels lose their alpha.
+func encodeGIF(w io.Writer, filename string, img image.Image, opts Options) error {
+	src, translucent, transparent := gifSource(img)
+	if translucent {
+		Warnf("%s: GIF has no translucency; pixels with alpha below %d become transparent and the rest opaque", filename, gifAlphaThreshold)
+	}
+	var palette []color.NRGBA
+	if transparent {
+		palette = append(palette, color.NRGBA{})
+	}
+	limit := opts.GIFColors - len(palette)
+	colors := exactColors(src, limit)
+	lossy := colors == nil
+	if lossy {
+		Warnf("%s: more than %d colors; reducing them for GIF loses color", filename, limit)
+		colors = medianCut(colorBuckets(src), limit)
+	}
+	palette = append(palette, colors...)
+
+	paletted := image.NewPaletted(src.Bounds(), make(color.Palette, len(palette)))
+	for i, c := range palette {
+		paletted.Palette[i] = c
+	}
+	if lossy {
+		quantizeGIF(paletted, src, palette, opts.GIFDither)
+	} else {
+		index := make(map[color.NRGBA]uint8, len(palette))
+		for i, c := range palette {
+			index[c] = uint8(i)
+		}
+		for i := 0; i < len(src.Pix); i += 4 {
+			paletted.Pix[i/4] = index[color.NRGBA{src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3]}]
+		}
+	}
+	return gif.Encode(w, paletted, &gif.Options{NumColors: len(palette)})
+}
+
+// gifSource returns the pixels of the image as a GIF can hold them: every
+// pixel below gifAlphaThreshold fully transparent black and every other one
+// fully opaque, with its straight color. It also reports whether any pixel
+// was translucent, and so lost its alpha, and whether any is transparent.
+func gifSource(img image.Image) (src *image.NRGBA, translucent, transparent bool) {
+	b := img.Bounds()
+	src = image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	for i := 0; i < len(src.Pix); i += 4 {
+		a := src.Pix[i+3]
+		translucent = translucent || a != 0 && a != 0xff
+		if a < gifAlphaThreshold {
+			src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3] = 0, 0, 0, 0
+			transparent = true
+		} else {
+			src.Pix[i+3] = 0xff
+		}
+	}
+	return src, translucent, transparent
+}
+
+// exactColors returns the opaque colors of the image in RGB order, so the
+// palette is the same from run to run, or nil if there are more than limit
+// of them.
+func exactColors(img *image.NRGBA, limit int) []color.NRGBA {
+	seen := make(map[color.NRGBA]struct{})
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i+3] == 0 {
+			continue
+		}
+		seen[color.NRGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], 0xff}] = struct{}{}
+		if len(seen) > limit {
+			return nil
+		}
+	}
+	colors := make([]color.NRGBA, 0, len(seen))
+	for c := range seen {
+		colors = append(colors, c)
+	}
+	sort.Slice(colors, func(i, j int) bool {
+		a, b := colors[i], colors[j]
+		return uint32(a.R)<<16|uint32(a.G)<<8|uint32(a.B) < uint32(b.R)<<16|uint32(b.G)<<8|uint32(b.B)
+	})
+	return colors
+}
+
+// colorBuckets returns the color histogram of the image's opaque pixels.
+func colorBuckets(img *image.NRGBA) []colorBucket {
+	buckets := make([]colorBucket, 1<<(3*gifBucketBits))
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i+3] == 0 {
+			continue
+		}
+		r, g, b := img.Pix[i], img.Pix[i+1], img.Pix[i+2]
+		bucket := &buckets[bucketIndex(r, g, b)]
+		bucket.count++
+		bucket.r += int(r)
+		bucket.g += int(g)
+		bucket.b += int(b)
+	}
+	return buckets
+}
+
+// medianCut returns a palette of at most n opaque colors for the color
+// histogram: starting from a box holding every nonempty cell, the box
+// whose widest channel spans the most cells is split at its median pixel
+// along that channel until there are n boxes or none can be split, and
+// each box contributes the average color of its pixels.
+func medianCut(buckets []colorBucket, n int) []color.NRGBA {
+	var all []int
+	for i, bucket := range buckets {
+		if bucket.count > 0 {
+			all = append(all, i)
+		}
+	}
+	boxes := [][]int{all}
+	for len(boxes) < n {
+		widest, channel, span := -1, 0, 0
+		for i, box := range boxes {
+			if c, s := widestChannel(box); s > span {
+				widest, channel, span = i, c, s
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		box := boxes[widest]
+		sort.SliceStable(box, func(i, j int) bool {
+			return bucketChannel(box[i], channel) < bucketChannel(box[j], channel)
+		})
+		total := 0
+		for _, cell := range box {
+			total += buckets[cell].count
+		}
+		split, seen := 1, buckets[box[0]].count
+		for split < len(box)-1 && seen*2 < total {
+			seen += buckets[box[split]].count
+			split++
+		}
+		boxes[widest] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	palette := make([]color.NRGBA, 0, len(boxes))
+	for _, box := range boxes {
+		var sum colorBucket
+		for _, cell := range box {
+			bucket := buckets[cell]
+			sum.count += bucket.count
+			sum.r += bucket.r
+			sum.g += bucket.g
+			sum.b += bucket.b
+		}
+		if sum.count == 0 {
+			continue
+		}
+		average := func(total int) uint8 { return uint8((total + sum.count/2) / sum.count) }
+		palette = append(palette, color.NRGBA{average(sum.r), average(sum.g), average(sum.b), 0xff})
+	}
+	return palette
+}
+
+// widestChannel returns the channel, 0 to 2 for red, green and blue, whose
+// cell coordinates span the most in the box, and that span, which is 0 for
+// a box that cannot be split.
+func widestChannel(box []int) (int, int) {
+	widest, span := 0, 0
+	for channel := 0; channel < 3; channel++ {
+		lo, hi := 1<<gifBucketBits, -1
+		for _, cell := range box {
+			v := bucketChannel(cell, channel)
+			lo, hi = min(lo, v), max(hi, v)
+		}
+		if hi-lo > span {
+			widest, span = channel, hi-lo
+		}
+	}
+	return widest, span
+}
+
+// quantizeGIF sets every pixel of dst to the palette entry nearest the
+// pixel of src, transparent pixels to the transparent entry, looking the
+// nearest entry up once per cell of the color histogram. With dither the
+// difference between each opaque pixel and its entry is spread to its
+// opaque neighbors to the right and below with Floyd-Steinberg weights.
+func quantizeGIF(dst *image.Paletted, src *image.NRGBA, palette []color.NRGBA, dither bool) {
+	nearest := make([]int16, 1<<(3*gifBucketBits))
+	for i := range nearest {
+		nearest[i] = -1
+	}
+	lookup := func(r, g, b uint8) uint8 {
+		cell := bucketIndex(r, g, b)
+		if nearest[cell] < 0 {
+			best, bestDistance := 0, -1
+			for i, c := range palette {
+				if c.A == 0 {
+					continue
+				}
+				dr, dg, db := int(c.R)-int(r), int(c.G)-int(g), int(c.B)-int(b)
+				if d := dr*dr + dg*dg + db*db; bestDistance < 0 || d < bestDistance {
+					best, bestDistance = i, d
+				}
+			}
+			nearest[cell] = int16(best)
+		}
+		return uint8(nearest[cell])
+	}
+
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	// Errors carried to the current and the next row, offset by one so the
+	// pixels left of the first and right of the last have entries.
+	current, next := make([][3]int, w+2), make([][3]int, w+2)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*src.Stride + x*4
+			if src.Pix[i+3] == 0 {
+				dst.Pix[y*dst.Stride+x] = 0
+				continue
+			}
+			var v [3]int
+			for c := range v {
+				v[c] = int(src.Pix[i+c])
+				if dither {
+					v[c] = min(255, max(0, v[c]+current[x+1][c]/16))
+				}
+			}
+			index := lookup(uint8(v[0]), uint8(v[1]), uint8(v[2]))
+			dst.Pix[y*dst.Stride+x] = index
+			if !dither {
+				continue
+			}
+			p := palette[index]
+			e := [3]int{v[0] - int(p.R), v[1] - int(p.G), v[2] - int(p.B)}
+			for c := range e {
+				current[x+2][c] += e[c] * 7
+				next[x][c] += e[c] * 3
+				next[x+1][c] += e[c] * 5
+				next[x+2][c] += e[c]
+			}
+		}
+		current, next = next, current
+		clear(next)
+	}
+}
@@ -0,0 +1,150 @@
+package packer
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultGoPackage is the package declared by manifests written with
+// -format go unless -gopackage names another.
+const DefaultGoPackage = "atlas"
+
+// goManifest renders a manifest as gofmt-formatted Go source in package pkg.
+// Every declaration is prefixed with an identifier derived from the
+// manifest's filename, so the manifests of several groups and pages can sit
+// in the same package. The source declares the image filename and size as
+// constants, each sprite's rectangle as an image.Rectangle variable named
+// after its sanitized filename, and a map from sprite name to rectangle.
+// Texture arrays also list their layer images and the layer of each sprite,
+// and sprites packed rotated are listed in a set.
+func goManifest(filename string, manifest Manifest, pkg string) ([]byte, error) {
+	prefix := goIdentifier(strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)))
+	if prefix == "" || !unicode.IsLetter([]rune(prefix)[0]) {
+		prefix = "Atlas" + prefix
+	}
+
+	names := make([]string, 0, len(manifest.Sprites))
+	for name := range manifest.Sprites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by texturepacker; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	fmt.Fprintf(&b, "import \"image\"\n\n")
+
+	fmt.Fprintf(&b, "// The atlas image and its size in pixels.\n")
+	fmt.Fprintf(&b, "const (\n")
+	fmt.Fprintf(&b, "%sImage = %q\n", prefix, manifest.Image)
+	if manifest.PremultipliedImage != "" {
+		fmt.Fprintf(&b, "%sPremultipliedImage = %q\n", prefix, manifest.PremultipliedImage)
+	}
+	if manifest.PremultipliedAlpha {
+		fmt.Fprintf(&b, "%sPremultipliedAlpha = true\n", prefix)
+	}
+	if manifest.Scale > 1 {
+		fmt.Fprintf(&b, "%sScale = %d\n", prefix, manifest.Scale)
+	}
+	if manifest.SDFSpread > 0 {
+		fmt.Fprintf(&b, "%sSDFSpread = %d\n", prefix, manifest.SDFSpread)
+	}
+	fmt.Fprintf(&b, "%sWidth = %d\n", prefix, manifest.Width)
+	fmt.Fprintf(&b, "%sHeight = %d\n", prefix, manifest.Height)
+	fmt.Fprintf(&b, ")\n\n")
+
+	idents := make(map[string]string, len(names))
+	used := make(map[string]bool, len(names))
+	if len(names) > 0 {
+		fmt.Fprintf(&b, "// The rectangle of each sprite in the atlas image.\n")
+		fmt.Fprintf(&b, "var (\n")
+		for _, name := range names {
+			base := prefix + "Sprite" + goIdentifier(strings.TrimSuffix(name, filepath.Ext(name)))
+			ident := base
+			for n := 2; used[ident]; n++ {
+				ident = base + "_" + strconv.Itoa(n)
+			}
+			used[ident] = true
+			idents[name] = ident
+			s := manifest.Sprites[name]
+			fmt.Fprintf(&b, "%s = image.Rect(%d, %d, %d, %d)\n", ident, s.X, s.Y, s.X+s.W, s.Y+s.H)
+		}
+		fmt.Fprintf(&b, ")\n\n")
+	}
+
+	fmt.Fprintf(&b, "// %sSprites maps each sprite's name to its rectangle.\n", prefix)
+	fmt.Fprintf(&b, "var %sSprites = map[string]image.Rectangle{\n", prefix)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%q: %s,\n", name, idents[name])
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	if r := manifest.Reserved; r != nil {
+		fmt.Fprintf(&b, "\n// %sReserved is the region of the atlas kept blank with -reserve.\n", prefix)
+		fmt.Fprintf(&b, "var %sReserved = image.Rect(%d, %d, %d, %d)\n", prefix, r.X, r.Y, r.X+r.W, r.Y+r.H)
+	}
+
+	if len(manifest.Layers) > 0 {
+		writeGoStrings(&b, prefix+"Layers", "lists the texture array's layer images in order", manifest.Layers)
+		if len(manifest.PremultipliedLayers) > 0 {
+			writeGoStrings(&b, prefix+"PremultipliedLayers", "lists the premultiplied copies of the layer images", manifest.PremultipliedLayers)
+		}
+		fmt.Fprintf(&b, "\n// %sSpriteLayers maps each sprite's name to the layer holding it.\n", prefix)
+		fmt.Fprintf(&b, "var %sSpriteLayers = map[string]int{\n", prefix)
+		for _, name := range names {
+			if layer := manifest.Sprites[name].Layer; layer != nil {
+				fmt.Fprintf(&b, "%q: %d,\n", name, *layer)
+			}
+		}
+		fmt.Fprintf(&b, "}\n")
+	}
+
+	var turned []string
+	for _, name := range names {
+		if manifest.Sprites[name].Rotated {
+			turned = append(turned, name)
+		}
+	}
+	if len(turned) > 0 {
+		fmt.Fprintf(&b, "\n// %sSpritesRotated holds the names of the sprites packed turned a quarter\n// turn clockwise, whose rectangles have their width and height swapped.\n", prefix)
+		fmt.Fprintf(&b, "var %sSpritesRotated = map[string]bool{\n", prefix)
+		for _, name := range turned {
+			fmt.Fprintf(&b, "%q: true,\n", name)
+		}
+		fmt.Fprintf(&b, "}\n")
+	}
+	return format.Source(b.Bytes())
+}
+
+// writeGoStrings writes a documented string slice variable.
+func writeGoStrings(b *bytes.Buffer, name, doc string, values []string) {
+	fmt.Fprintf(b, "\n// %s %s.\n", name, doc)
+	fmt.Fprintf(b, "var %s = []string{\n", name)
+	for _, v := range values {
+		fmt.Fprintf(b, "%q,\n", v)
+	}
+	fmt.Fprintf(b, "}\n")
+}
+
+// goIdentifier turns a filename into the tail of an exported Go identifier:
+// it splits the name at every character that is not a letter or digit and
+// joins the parts with their first letters upper-cased, so
+// "chars/hero_idle" becomes "CharsHeroIdle".
+func goIdentifier(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, part := range parts {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
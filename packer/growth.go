@@ -0,0 +1,99 @@
+package packer
+
+import (
+	"image"
+	"math"
+)
+
+// Values of the -growth flag, selecting the direction in which the shelf
+// packer grows the atlas once content no longer fits.
+const (
+	// GrowthWidth fills rows up to the width bound and grows the atlas
+	// downward, one shelf at a time.
+	GrowthWidth = "width"
+	// GrowthHeight fills columns up to the height bound and grows the atlas
+	// to the right, one column at a time.
+	GrowthHeight = "height"
+	// GrowthSquare narrows the rows to keep the atlas close to square,
+	// never exceeding the width bound.
+	GrowthSquare = "square"
+)
+
+// packGrowth runs a shelf packer in the direction selected by opts.Growth.
+// Width growth runs it as is. Height growth runs it on the transposed
+// rectangles, with the padding and the size bounds swapped to match, and
+// transposes the result, so shelves become columns. Square growth first finds the narrowest row
+// width, up to the bound, for which the atlas is no taller than it is wide,
+// and runs the packer with that bound. With opts.Compact the bottom shelves
+// of the packer's layout are compacted before it is transposed back.
+func packGrowth(rectangles []Rectangle, opts Options, pack func([]Rectangle, Options) Layout) Layout {
+	if opts.Compact {
+		shelf := pack
+		pack = func(rectangles []Rectangle, opts Options) Layout {
+			return compactShelves(shelf(rectangles, opts), opts)
+		}
+	}
+	switch opts.Growth {
+	case GrowthHeight:
+		transposed := opts
+		transposed.Padding = Padding{X: opts.Padding.Y, Y: opts.Padding.X}
+		transposed.MaxWidth, transposed.MaxHeight = opts.MaxHeight, opts.MaxWidth
+		return transposeLayout(pack(transposeRectangles(rectangles, opts.Sort), transposed))
+	case GrowthSquare:
+		trial := packRectangles
+		if opts.Packer == PackerMaxRects {
+			trial = packMaxRects
+		}
+		squared := opts
+		squared.MaxWidth = squareBound(rectangles, opts, trial)
+		return pack(rectangles, squared)
+	default:
+		return pack(rectangles, opts)
+	}
+}
+
+// squareBound returns the smallest row width, between the widest rectangle
+// and opts.MaxWidth, at which trial, plain shelf packing or MaxRects,
+// produces an atlas at least as wide as it is tall. It starts from the side
+// of a square holding the rectangles' total area and widens from there,
+// returning the bound itself if no narrower width squares the atlas.
+func squareBound(rectangles []Rectangle, opts Options, trial func([]Rectangle, Options) Layout) int {
+	area, widest := 0, 0
+	for _, rect := range rectangles {
+		area += (rect.Width + opts.Padding.X) * (rect.Height + opts.Padding.Y)
+		widest = max(widest, rect.Width)
+	}
+
+	bound := max(widest, int(math.Ceil(math.Sqrt(float64(area)))))
+	for ; bound < opts.MaxWidth; bound += max(1, bound/32) {
+		bounded := opts
+		bounded.MaxWidth = bound
+		if layout := trial(rectangles, bounded); layout.Height <= layout.Width {
+			return bound
+		}
+	}
+	return opts.MaxWidth
+}
+
+// transposeRectangles returns copies of the rectangles with width and height
+// swapped, sorted for the -sort mode by their new dimensions as the shelf
+// packer expects.
+func transposeRectangles(rectangles []Rectangle, mode string) []Rectangle {
+	transposed := make([]Rectangle, len(rectangles))
+	for i, rect := range rectangles {
+		rect.Width, rect.Height = rect.Height, rect.Width
+		transposed[i] = rect
+	}
+	sortRectangles(transposed, mode)
+	return transposed
+}
+
+// transposeLayout swaps the axes of a layout produced from transposed
+// rectangles, turning it back into a layout of the original rectangles.
+func transposeLayout(layout Layout) Layout {
+	placements := make(map[int]image.Rectangle, len(layout.Placements))
+	for id, r := range layout.Placements {
+		placements[id] = image.Rect(r.Min.Y, r.Min.X, r.Max.Y, r.Max.X)
+	}
+	return Layout{Placements: placements, Width: layout.Height, Height: layout.Width}
+}
@@ -0,0 +1,65 @@
+package packer
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrInterrupted is returned when an output is started or committed after
+// the run was interrupted.
+var ErrInterrupted = errors.New("interrupted")
+
+// pendingOutputs holds the temporary file of every output being written, so
+// that an interrupted run can remove them. Once closed, no output can be
+// created or committed any more.
+var pendingOutputs = struct {
+	sync.Mutex
+	files  map[string]bool
+	closed bool
+}{files: make(map[string]bool)}
+
+// trackOutput records the temporary file of an output being written.
+func trackOutput(tmp string) error {
+	pendingOutputs.Lock()
+	defer pendingOutputs.Unlock()
+	if pendingOutputs.closed {
+		return ErrInterrupted
+	}
+	pendingOutputs.files[tmp] = true
+	return nil
+}
+
+// untrackOutput forgets the temporary file of an output that was committed
+// or aborted.
+func untrackOutput(tmp string) {
+	pendingOutputs.Lock()
+	defer pendingOutputs.Unlock()
+	delete(pendingOutputs.files, tmp)
+}
+
+// unlessInterrupted calls fn, which moves an output into place, unless the
+// run has been interrupted, and keeps the pending outputs from being removed
+// until it returns.
+func unlessInterrupted(fn func() error) error {
+	pendingOutputs.Lock()
+	defer pendingOutputs.Unlock()
+	if pendingOutputs.closed {
+		return ErrInterrupted
+	}
+	return fn()
+}
+
+// RemovePendingOutputs deletes the temporary file of every output still
+// being written and stops any more from being created or committed, so an
+// interrupted run leaves the previous outputs in place and no partial ones
+// behind.
+func RemovePendingOutputs() {
+	pendingOutputs.Lock()
+	defer pendingOutputs.Unlock()
+	pendingOutputs.closed = true
+	for tmp := range pendingOutputs.files {
+		os.Remove(tmp)
+	}
+	pendingOutputs.files = nil
+}
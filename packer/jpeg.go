@@ -0,0 +1,25 @@
+package packer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+)
+
+// DefaultJPEGQuality is the default -quality of JPEG atlases, high enough
+// that the edges of small sprites do not visibly ring.
+const DefaultJPEGQuality = 90
+
+// encodeJPEG writes the image to w as a JPEG at opts.JPEGQuality. JPEG has
+// no alpha, so the image is first drawn over an opaque black background:
+// transparent pixels become black and translucent ones are darkened by
+// their alpha, as premultiplied alpha would store them.
+func encodeJPEG(w io.Writer, img image.Image, opts Options) error {
+	b := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+	return jpeg.Encode(w, flat, &jpeg.Options{Quality: opts.JPEGQuality})
+}
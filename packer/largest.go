@@ -0,0 +1,49 @@
+package packer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// printLargest lists the n sprites with the largest packed area, and, when
+// there is padding, the n with the largest footprint: the area they take up
+// in the atlas including the padding to their right and below. Each line
+// gives the sprite's name, size, and share of the total, which makes an
+// oversized sprite that slipped into an atlas easy to spot.
+func printLargest(rectangles []Rectangle, n int, padding Padding) {
+	area := func(r Rectangle) int { return r.Width * r.Height }
+	footprint := func(r Rectangle) int { return (r.Width + padding.X) * (r.Height + padding.Y) }
+
+	printLargestBy("area", rectangles, n, area)
+	if padding.X != 0 || padding.Y != 0 {
+		printLargestBy("footprint including padding", rectangles, n, footprint)
+	}
+}
+
+// printLargestBy prints the n rectangles with the largest size as measured
+// by size, breaking ties by name.
+func printLargestBy(label string, rectangles []Rectangle, n int, size func(Rectangle) int) {
+	ordered := make([]Rectangle, len(rectangles))
+	copy(ordered, rectangles)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		si, sj := size(ordered[i]), size(ordered[j])
+		if si != sj {
+			return si > sj
+		}
+		return ordered[i].Name < ordered[j].Name
+	})
+
+	total := 0
+	for _, rect := range ordered {
+		total += size(rect)
+	}
+
+	fmt.Printf("Largest sprites by %s:\n", label)
+	for i, rect := range ordered[:min(n, len(ordered))] {
+		share := 0.0
+		if total > 0 {
+			share = float64(size(rect)) / float64(total) * 100
+		}
+		fmt.Printf("%d. %s: %d x %d, %d px (%.1f%%)\n", i+1, rect.Name, rect.Width, rect.Height, size(rect), share)
+	}
+}
@@ -0,0 +1,154 @@
+package packer
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestLoadFS checks that images are read from an in-memory file system,
+// named by their paths within it, subject to the -recursive and -exclude
+// selection, trimmed, and then packed.
+func TestLoadFS(t *testing.T) {
+	padded := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := 3; y < 7; y++ {
+		for x := 2; x < 5; x++ {
+			padded.SetNRGBA(x, y, color.NRGBA{R: 200, A: 255})
+		}
+	}
+	fsys := fstest.MapFS{
+		"hero.png":          pngFile(t, patterned(6, 8, 1)),
+		"ui/button.png":     pngFile(t, padded),
+		"ui/skip.png":       pngFile(t, patterned(3, 3, 2)),
+		"ui/deep/coin.png":  pngFile(t, patterned(4, 4, 3)),
+		"notes.txt":         &fstest.MapFile{Data: []byte("not an image")},
+		"ui/deep/readme.md": &fstest.MapFile{Data: []byte("neither")},
+	}
+	opts := Options{
+		MaxWidth:  64,
+		MaxHeight: 64,
+		Recursive: true,
+		Exclude:   []string{"skip.png"},
+		Trim:      true,
+		Alpha:     AlphaStraight,
+	}
+	rectangles, err := LoadFS(context.Background(), fsys, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]Rectangle)
+	for _, rect := range rectangles {
+		byName[rect.Name] = rect
+	}
+	if len(byName) != 3 {
+		t.Fatalf("loaded %d images, want hero.png, ui/button.png and ui/deep/coin.png: %v", len(byName), byName)
+	}
+	button, ok := byName["ui/button.png"]
+	if !ok {
+		t.Fatal("ui/button.png was not loaded")
+	}
+	if !button.Trimmed || button.Width != 3 || button.Height != 4 || button.TrimOffset != image.Pt(2, 3) {
+		t.Errorf("ui/button.png trimmed to %dx%d at %v, want 3x4 at (2,3)", button.Width, button.Height, button.TrimOffset)
+	}
+	if _, ok := byName["ui/deep/coin.png"]; !ok {
+		t.Error("ui/deep/coin.png was not loaded with Recursive set")
+	}
+
+	_, rects, err := Pack(rectangles, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rects["ui/button.png"].Size(); got != image.Pt(3, 4) {
+		t.Errorf("ui/button.png packed at size %v, want its trimmed 3x4", got)
+	}
+
+	opts.Recursive = false
+	rectangles, err = LoadFS(context.Background(), fsys, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rectangles) != 1 || rectangles[0].Name != "hero.png" {
+		t.Errorf("without Recursive loaded %v, want only hero.png", rectangles)
+	}
+}
+
+// TestLoadCorruptImages checks that when several images fail to decode the
+// error names every one of them, and that with opts.SkipBad they are left
+// out rather than reaching the packer without pixels.
+func TestLoadCorruptImages(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bad1.png": {Data: []byte(pngSignature + "not a png")},
+		"good.png": pngFile(t, patterned(4, 4, 1)),
+		"bad2.png": {Data: []byte("garbage")},
+	}
+	opts := Options{MaxWidth: 64, MaxHeight: 64, Alpha: AlphaStraight}
+	_, err := LoadFS(context.Background(), fsys, opts)
+	if err == nil {
+		t.Fatal("corrupt images loaded without an error")
+	}
+	for _, name := range []string{"bad1.png", "bad2.png"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not name %s", err, name)
+		}
+	}
+
+	opts.SkipBad = true
+	rectangles, err := LoadFS(context.Background(), fsys, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rectangles) != 1 || rectangles[0].Name != "good.png" || rectangles[0].Image == nil {
+		t.Fatalf("loaded %+v, want only good.png with its pixels", rectangles)
+	}
+	if _, _, err := Pack(rectangles, opts); err != nil {
+		t.Errorf("packing what was left: %v", err)
+	}
+}
+
+// TestLoadFSPreprocessing checks that LoadFS processes the sprites as Run
+// does before packing: the sidecar applies, -pathmode renames them and
+// -dedup packs identical ones once, with Pack placing both at the region.
+// Options only Run supports are rejected.
+func TestLoadFSPreprocessing(t *testing.T) {
+	fsys := fstest.MapFS{
+		"ui/a.png":    pngFile(t, patterned(6, 5, 1)),
+		"ui/copy.png": pngFile(t, patterned(6, 5, 1)),
+		"fx/b.png":    pngFile(t, patterned(4, 4, 2)),
+	}
+	sidecar := filepath.Join(t.TempDir(), "sidecar.json")
+	if err := os.WriteFile(sidecar, []byte(`{"fx/b.png": {"pivot": {"x": 0.5, "y": 1}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{MaxWidth: 64, MaxHeight: 64, Recursive: true, Alpha: AlphaStraight, PathMode: PathModeBase, SidecarFile: sidecar, Dedup: true}
+	rectangles, err := LoadFS(context.Background(), fsys, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]Rectangle)
+	for _, rect := range rectangles {
+		byName[rect.Name] = rect
+	}
+	if len(byName) != 2 || len(byName["a.png"].Duplicates) != 1 || byName["a.png"].Duplicates[0].Name != "copy.png" {
+		t.Fatalf("loaded %v, want b.png and a.png with copy.png as its duplicate", byName)
+	}
+	if pivot := byName["b.png"].Meta.Pivot; pivot == nil || *pivot != (Pivot{X: 0.5, Y: 1}) {
+		t.Errorf("b.png has pivot %v, want the sidecar's", pivot)
+	}
+	_, rects, err := Pack(rectangles, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rects["copy.png"] != rects["a.png"] || rects["a.png"].Empty() {
+		t.Errorf("copy.png packed at %v and a.png at %v, want the same region", rects["copy.png"], rects["a.png"])
+	}
+
+	opts.GroupBy = "dir"
+	if _, err := LoadFS(context.Background(), fsys, opts); err == nil {
+		t.Error("LoadFS accepted GroupBy, which it cannot return groups for")
+	}
+}
@@ -0,0 +1,87 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// jsonLogger receives diagnostics as JSON lines when -logjson is set; while
+// it is nil they are printed as plain text.
+var jsonLogger *slog.Logger
+
+// EnableJSONLogging sends every later warning and error to w as one JSON
+// object per line, with "time", "level" and "msg" fields and any details as
+// further fields, for ingestion into a log pipeline.
+func EnableJSONLogging(w io.Writer) {
+	jsonLogger = slog.New(slog.NewJSONHandler(w, nil))
+}
+
+// Warnf prints a warning to standard error, or logs it as JSON.
+func Warnf(format string, args ...any) {
+	if jsonLogger != nil {
+		jsonLogger.Warn(fmt.Sprintf(format, args...))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+}
+
+// warnSkipped reports that a sprite was left out of the atlas and why, with
+// the sprite and reason as separate fields when logging JSON.
+func warnSkipped(name, reason string) {
+	if jsonLogger != nil {
+		jsonLogger.Warn("skipping sprite", "sprite", name, "reason", reason)
+		return
+	}
+	Warnf("skipping %s: %s", name, reason)
+}
+
+// taskError is an error that ended the run, with the task that failed as
+// LogError describes it.
+type taskError struct {
+	task string
+	err  error
+}
+
+// taskFailed returns err as the failure of the task, e.g. "loading images".
+func taskFailed(task string, err error) error {
+	return &taskError{task: task, err: err}
+}
+
+// Error describes the task and how it failed.
+func (e *taskError) Error() string { return e.task + ": " + e.err.Error() }
+
+// Unwrap returns the error the task failed with.
+func (e *taskError) Unwrap() error { return e.err }
+
+// ReportError logs an error that ended the run, with its task when it has
+// one.
+func ReportError(err error) {
+	var failed *taskError
+	if errors.As(err, &failed) {
+		LogError(failed.task, failed.err)
+		return
+	}
+	LogError("", err)
+}
+
+// LogError reports an error that ends the run, described as the task that
+// failed, e.g. "loading images", which may be empty. It is printed to
+// standard output as "Error <task>: <err>", or logged as JSON to standard
+// error with the task as the message and the error as a field.
+func LogError(task string, err error) {
+	if jsonLogger != nil {
+		if task == "" {
+			task = "failed"
+		}
+		jsonLogger.Error(task, "error", err.Error())
+		return
+	}
+	if task == "" {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Error %s: %v\n", task, err)
+}
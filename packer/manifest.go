@@ -0,0 +1,260 @@
+package packer
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+)
+
+// Values of the -format flag, selecting how manifests are written.
+const (
+	// FormatJSON writes each manifest as a JSON file.
+	FormatJSON = "json"
+	// FormatNDJSON writes each manifest as newline-delimited JSON: a header
+	// line followed by one line per sprite, for streaming parsers.
+	FormatNDJSON = "ndjson"
+	// FormatGo writes each manifest as a Go source file declaring the
+	// sprite rectangles, to be compiled into a program.
+	FormatGo = "go"
+	// FormatXML writes each manifest as a generic XML file of sprite
+	// placements.
+	FormatXML = "xml"
+	// FormatMinimal writes each manifest as a JSON object mapping sprite
+	// names to [x, y, w, h], and nothing else.
+	FormatMinimal = "minimal"
+	// FormatPlist writes each manifest as a property list in the layout of
+	// TexturePacker's cocos2d exporter, for engines that import those.
+	FormatPlist = "plist"
+)
+
+// Manifest describes a generated atlas: the image it belongs to, its
+// dimensions, and the rectangle each sprite occupies, keyed by sprite name.
+// Page is the index of the image among the pages of its group, starting at 0.
+// PremultipliedAlpha is set when the image holds premultiplied colors, and
+// PremultipliedImage names the premultiplied copy written beside a straight
+// image; both copies share the same layout. For a texture array, Layers and
+// PremultipliedLayers list the layer images in order, Image is the first
+// layer, and every sprite records its layer. SDFSpread is set for atlases of
+// signed distance fields: the fall-off distance of the fields, and the
+// border by which every sprite was grown on each side. Scale is the integer
+// factor by which every sprite was enlarged with -scale. Reserved is the
+// blank region kept with -reserve, the same on every layer of a texture array,
+// SpatialIndex the grid of sprites written with -spatialindex, and Mips the
+// downscaled levels written with -miplevels, level 0 being Image itself.
+// TileSize and Tiles describe the tiles the image is sliced into with
+// -tilesize. Meta records the tool and options that generated the manifest
+// with -recordoptions. Origin is the -origin positions are measured from,
+// when it is not the top-left corner. Free lists the space no sprite uses,
+// largest first, with -freeregions.
+type Manifest struct {
+	Image               string                 `json:"image"`
+	PremultipliedImage  string                 `json:"premultipliedImage,omitempty"`
+	PremultipliedAlpha  bool                   `json:"premultipliedAlpha,omitempty"`
+	Page                int                    `json:"page"`
+	Width               int                    `json:"width"`
+	Height              int                    `json:"height"`
+	Sprites             map[string]SpriteEntry `json:"sprites"`
+	Rows                []StripRow             `json:"rows,omitempty"`
+	Layers              []string               `json:"layers,omitempty"`
+	PremultipliedLayers []string               `json:"premultipliedLayers,omitempty"`
+	SDFSpread           int                    `json:"sdfSpread,omitempty"`
+	Scale               int                    `json:"scale,omitempty"`
+	Reserved            *RegionEntry           `json:"reserved,omitempty"`
+	SpatialIndex        *SpatialIndex          `json:"spatialIndex,omitempty"`
+	Mips                []MipLevel             `json:"mips,omitempty"`
+	TileSize            int                    `json:"tileSize,omitempty"`
+	Tiles               []TileEntry            `json:"tiles,omitempty"`
+	Meta                *ManifestMeta          `json:"meta,omitempty"`
+	Origin              string                 `json:"origin,omitempty"`
+	Free                []RegionEntry          `json:"free,omitempty"`
+}
+
+// SpriteEntry is the position and size of a single sprite within the atlas.
+// Trim is set when the sprite's borders were trimmed before packing,
+// OriginalSize when the sprite was scaled before packing, and Pivot when the
+// sidecar file gives the sprite an anchor point. Layer is the texture array
+// layer holding the sprite, and is only set for texture arrays. Polygons are
+// the outlines traced with -polygon, as clockwise lists of [x, y] vertices
+// relative to the sprite's top-left corner. DPI and Text are
+// the resolution and text chunks of the source PNG, set with -metadata.
+// Mirror is set when only half of a symmetric sprite was packed, and Frame
+// when the sprite is a frame extracted from an animation with -frames. RLE is
+// the sprite's run-length analysis, set with -rle, and AverageColor its mean
+// color as "#rrggbbaa", set with -averagecolor. SourceScale and NineSlice
+// are the density and stretch insets read from the file name with
+// -nameconventions. Quad holds the sprite's vertex positions and texture
+// coordinates with -emitquads. Rotated is set when -allowrotation packed the
+// sprite turned a quarter turn clockwise; X, Y, W and H are then the region
+// it occupies in the atlas, so W is the sprite's height, while trims, pivots
+// and polygons stay in the sprite's own orientation.
+type SpriteEntry struct {
+	X            int               `json:"x"`
+	Y            int               `json:"y"`
+	W            int               `json:"w"`
+	H            int               `json:"h"`
+	Trim         *TrimEntry        `json:"trim,omitempty"`
+	OriginalSize *SizeEntry        `json:"originalSize,omitempty"`
+	Pivot        *Pivot            `json:"pivot,omitempty"`
+	Layer        *int              `json:"layer,omitempty"`
+	Polygons     [][][2]int        `json:"polygons,omitempty"`
+	DPI          *DPI              `json:"dpi,omitempty"`
+	Text         map[string]string `json:"text,omitempty"`
+	Mirror       *MirrorEntry      `json:"mirror,omitempty"`
+	Frame        *FrameEntry       `json:"frame,omitempty"`
+	RLE          *RunStats         `json:"rle,omitempty"`
+	AverageColor string            `json:"averageColor,omitempty"`
+	SourceScale  int               `json:"sourceScale,omitempty"`
+	NineSlice    *NineSlice        `json:"nineSlice,omitempty"`
+	Quad         *Quad             `json:"quad,omitempty"`
+	Rotated      bool              `json:"rotated,omitempty"`
+}
+
+// SizeEntry is a width and height in pixels.
+type SizeEntry struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// TrimEntry records how a trimmed sprite relates to its source image: the
+// offset of the packed pixels within the source and the source's full size,
+// from which a consumer can reconstruct the original placement.
+type TrimEntry struct {
+	X       int `json:"x"`
+	Y       int `json:"y"`
+	SourceW int `json:"sourceW"`
+	SourceH int `json:"sourceH"`
+}
+
+// buildManifest creates the manifest for an atlas from the packed rectangles,
+// giving the duplicates of each an entry of their own at its region.
+func buildManifest(imageFile string, rectangles []Rectangle, layout Layout) Manifest {
+	manifest := Manifest{
+		Image:    imageFile,
+		Width:    layout.Width,
+		Height:   layout.Height,
+		Sprites:  make(map[string]SpriteEntry, len(rectangles)),
+		Rows:     layout.Rows,
+		Reserved: reservedEntry(layout),
+	}
+	for _, rect := range rectangles {
+		placed := layout.Placements[rect.ID]
+		manifest.Sprites[rect.Name] = spriteEntry(rect, placed)
+		for _, dup := range rect.Duplicates {
+			manifest.Sprites[dup.Name] = spriteEntry(dup, placed)
+		}
+	}
+	return manifest
+}
+
+// spriteEntry returns the manifest entry of the sprite placed at placed in
+// the atlas: the region itself and what the sprite records about how its
+// pixels came to be.
+func spriteEntry(rect Rectangle, placed image.Rectangle) SpriteEntry {
+	entry := SpriteEntry{
+		X:            placed.Min.X,
+		Y:            placed.Min.Y,
+		W:            placed.Dx(),
+		H:            placed.Dy(),
+		Pivot:        rect.Meta.Pivot,
+		Polygons:     rect.Outlines,
+		DPI:          rect.Metadata.DPI,
+		Text:         rect.Metadata.Text,
+		Mirror:       rect.Mirror,
+		Frame:        rect.Frame,
+		RLE:          rect.Runs,
+		AverageColor: rect.AverageColor,
+		SourceScale:  rect.SourceScale,
+		NineSlice:    rect.NineSlice,
+		Rotated:      rotated(rect, placed),
+	}
+	if rect.Trimmed {
+		entry.Trim = &TrimEntry{
+			X:       rect.TrimOffset.X,
+			Y:       rect.TrimOffset.Y,
+			SourceW: rect.SourceWidth,
+			SourceH: rect.SourceHeight,
+		}
+	}
+	if rect.Resized {
+		entry.OriginalSize = &SizeEntry{W: rect.OriginalWidth, H: rect.OriginalHeight}
+	}
+	return entry
+}
+
+// saveManifest writes the manifest to the specified filename in the format
+// selected by opts.Format: as JSON, indented when opts.JSONPretty is set and
+// compact otherwise, as newline-delimited JSON, as Go source in package
+// opts.GoPackage, as XML, as a TexturePacker property list, or as the
+// minimal JSON of placements alone. Sprites are keyed by name, so the output is sorted and stable across runs. Unless
+// opts.Overwrite is set, it fails if the file already exists. The manifest
+// is written as part of set, and moved into place when set is committed.
+func saveManifest(set *outputSet, filename string, manifest Manifest, opts Options) error {
+	switch opts.Format {
+	case FormatGo:
+		data, err := goManifest(filename, manifest, opts.GoPackage)
+		if err != nil {
+			return err
+		}
+		return set.Write(filename, data, opts.Overwrite)
+	case FormatNDJSON:
+		data, err := ndjsonManifest(manifest)
+		if err != nil {
+			return err
+		}
+		return set.Write(filename, data, opts.Overwrite)
+	case FormatXML:
+		data, err := xmlManifest(manifest)
+		if err != nil {
+			return err
+		}
+		return set.Write(filename, data, opts.Overwrite)
+	case FormatPlist:
+		return set.Write(filename, plistManifest(manifest), opts.Overwrite)
+	case FormatMinimal:
+		data, err := minimalManifest(manifest, opts.JSONPretty)
+		if err != nil {
+			return err
+		}
+		return set.Write(filename, append(data, '\n'), opts.Overwrite)
+	}
+
+	var data []byte
+	var err error
+	if opts.JSONPretty {
+		data, err = json.MarshalIndent(manifest, "", "  ")
+	} else {
+		data, err = json.Marshal(manifest)
+	}
+	if err != nil {
+		return err
+	}
+	return set.Write(filename, append(data, '\n'), opts.Overwrite)
+}
+
+// ReadManifest reads and parses a manifest written as JSON, or as
+// newline-delimited JSON, XML or a property list when its extension is
+// ".ndjson", ".xml" or ".plist".
+// Positions written with -origin are turned back into top-left ones.
+func ReadManifest(filename string) (Manifest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Manifest{}, err
+	}
+	var manifest Manifest
+	switch filepath.Ext(filename) {
+	case "." + FormatNDJSON:
+		manifest, err = parseNDJSONManifest(data)
+	case "." + FormatXML:
+		manifest, err = parseXMLManifest(data)
+	case "." + FormatPlist:
+		manifest, err = parsePlistManifest(data)
+	default:
+		err = json.Unmarshal(data, &manifest)
+	}
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to parse manifest %s: %w", filename, err)
+	}
+	return withOrigin(manifest, "", true), nil
+}
@@ -0,0 +1,97 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Mask is the region sprites may be packed into with -maskshape: the fully
+// opaque pixels of a mask image the size of the atlas.
+type Mask struct {
+	Width, Height int
+	// blocked is a summed-area table of the mask pixels that are not fully
+	// opaque: entry (x, y), at y*(Width+1)+x, counts those above and to the
+	// left of that point.
+	blocked []int32
+}
+
+// NewMask builds the mask for the opaque pixels of img.
+func NewMask(img image.Image) *Mask {
+	b := img.Bounds()
+	m := &Mask{Width: b.Dx(), Height: b.Dy(), blocked: make([]int32, (b.Dx()+1)*(b.Dy()+1))}
+	stride := m.Width + 1
+	for y := 0; y < m.Height; y++ {
+		var row int32
+		for x := 0; x < m.Width; x++ {
+			if _, _, _, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA(); a != 0xffff {
+				row++
+			}
+			m.blocked[(y+1)*stride+x+1] = m.blocked[y*stride+x+1] + row
+		}
+	}
+	return m
+}
+
+// holds reports whether every pixel of r, which must lie within the mask's
+// bounds, is opaque.
+func (m *Mask) holds(r image.Rectangle) bool {
+	stride := m.Width + 1
+	n := m.blocked[r.Max.Y*stride+r.Max.X] - m.blocked[r.Min.Y*stride+r.Max.X] -
+		m.blocked[r.Max.Y*stride+r.Min.X] + m.blocked[r.Min.Y*stride+r.Min.X]
+	return n == 0
+}
+
+// packMask places the rectangles, in the order given, inside the opaque
+// region of opts.Mask. Each takes the first position, scanning rows from
+// the top and positions from the left, where it covers only opaque mask
+// pixels and is opts.Padding away from every rectangle placed before it,
+// so it works for round or irregular regions the shelf packer cannot
+// follow. With opts.SpriteAlign positions are aligned to it. The layout
+// takes the mask's size; rectangles that find no room are an error naming
+// them all.
+func packMask(rectangles []Rectangle, opts Options) (Layout, error) {
+	mask := opts.Mask
+	grow := image.Pt(opts.Padding.X, opts.Padding.Y)
+	placements := make(map[int]image.Rectangle, len(rectangles))
+	var padded []image.Rectangle
+	var unplaced []string
+
+	step := func(n int) int { return alignUp(n+1, opts.SpriteAlign) }
+	for _, rect := range rectangles {
+		size := image.Pt(rect.Width, rect.Height)
+		found := false
+	search:
+		for y := 0; y+size.Y <= mask.Height; y = step(y) {
+			for x := 0; x+size.X <= mask.Width; {
+				r := image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x, y).Add(size)}
+				if !mask.holds(r) {
+					x = step(x)
+					continue
+				}
+				reach := image.Rectangle{Min: r.Min, Max: r.Max.Add(grow)}
+				blocker := -1
+				for i, p := range padded {
+					if reach.Overlaps(p) && (blocker < 0 || p.Max.X > padded[blocker].Max.X) {
+						blocker = i
+					}
+				}
+				if blocker >= 0 {
+					x = alignUp(padded[blocker].Max.X, opts.SpriteAlign)
+					continue
+				}
+				placements[rect.ID] = r
+				padded = append(padded, reach)
+				found = true
+				break search
+			}
+		}
+		if !found {
+			unplaced = append(unplaced, rect.Name)
+		}
+	}
+	if len(unplaced) > 0 {
+		return Layout{}, fmt.Errorf("%d of %d sprites do not fit in the opaque region of the -maskshape mask: %s", len(unplaced), len(rectangles), strings.Join(unplaced, ", "))
+	}
+	return Layout{Placements: placements, Width: mask.Width, Height: mask.Height}, nil
+}
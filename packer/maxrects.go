@@ -0,0 +1,152 @@
+package packer
+
+import "image"
+
+// Values of the -packer flag, selecting how sprites are placed when neither
+// -strips, -cell nor -maskshape chooses a packer of its own.
+const (
+	// PackerShelf places sprites left to right on shelves as tall as the
+	// first sprite on them.
+	PackerShelf = "shelf"
+	// PackerMaxRects tracks every maximal free rectangle and puts each
+	// sprite in the one where it ends highest, so short sprites fill the
+	// space above short neighbours on a tall row.
+	PackerMaxRects = "maxrects"
+)
+
+// packMaxRects places the rectangles with MaxRects, which tracks every
+// maximal rectangle of free space, so a sprite can go in the gap above a
+// shorter one, leaving opts.Padding to the right of and below every sprite.
+// Rows are bounded by opts.MaxWidth and the height is left to planLayout.
+// The rectangles are first placed in an atlas that grows downward as far as
+// it needs, each where its bottom edge ends highest. That height is then
+// bisected for the shortest atlas into which placing each rectangle where
+// it fits most snugly, by the shorter of the gaps it leaves beside and
+// below it in its free rectangle, still fits them all; the shorter of the
+// two layouts is returned.
+func packMaxRects(rectangles []Rectangle, opts Options) Layout {
+	pad := image.Pt(opts.Padding.X, opts.Padding.Y)
+	// Tall enough to stack every sprite, however it is turned.
+	height, area, tallest := 0, 0, 0
+	for _, rect := range rectangles {
+		height += max(rect.Width, rect.Height) + pad.Y + opts.SpriteAlign
+		area += (rect.Width + pad.X) * (rect.Height + pad.Y)
+		tallest = max(tallest, rect.Height)
+	}
+	layout, _ := maxRectsInto(rectangles, opts, max(height, 1), false)
+	// No atlas shorter than the tallest sprite, or than the padded sprites'
+	// total area spread over a row, can hold them.
+	low, high := max(tallest, area/(opts.MaxWidth+pad.X)), layout.Height
+	for low < high {
+		mid := (low + high) / 2
+		if trial, ok := maxRectsInto(rectangles, opts, mid+pad.Y, true); ok {
+			layout, high = trial, mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return layout
+}
+
+// maxRectsInto places the rectangles one at a time, in the order given, in
+// the free space of a bin opts.MaxWidth wide and binHeight tall, both
+// including the padding beyond the last column and row. Of the positions at
+// the top-left corner of a free rectangle that hold a sprite, it takes the
+// one where the sprite ends highest, then the leftmost, or with snug the one
+// leaving the least space beside or below it in that free rectangle. With
+// opts.AllowRotation each sprite is also tried turned a quarter turn, which
+// it is only if that places it strictly better. It reports whether every
+// sprite fit; without snug, one that fits nowhere is put below the others,
+// where it makes the layout too wide for the bounds.
+func maxRectsInto(rectangles []Rectangle, opts Options, binHeight int, snug bool) (Layout, bool) {
+	pad := image.Pt(opts.Padding.X, opts.Padding.Y)
+	// The padding past the right edge and the bottom sprites is not part of
+	// the atlas, so the space holding it may extend beyond the bound.
+	free := []image.Rectangle{image.Rect(0, 0, opts.MaxWidth+pad.X, binHeight)}
+
+	placements := make(map[int]image.Rectangle, len(rectangles))
+	width, bottom, padded := 0, 0, 0
+	for _, rect := range rectangles {
+		sizes := []image.Point{image.Pt(rect.Width, rect.Height)}
+		if opts.AllowRotation && rect.Width != rect.Height {
+			sizes = append(sizes, image.Pt(rect.Height, rect.Width))
+		}
+		// best scores lowest by score, then by tie.
+		var best image.Rectangle
+		found, bestScore, bestTie := false, 0, 0
+		for _, size := range sizes {
+			for _, f := range free {
+				at := image.Pt(alignUp(f.Min.X, opts.SpriteAlign), alignUp(f.Min.Y, opts.SpriteAlign))
+				r := image.Rectangle{Min: at, Max: at.Add(size).Add(pad)}
+				if !r.In(f) {
+					continue
+				}
+				score, tie := r.Max.Y, r.Min.X
+				if snug {
+					dx, dy := f.Max.X-r.Max.X, f.Max.Y-r.Max.Y
+					score, tie = min(dx, dy), max(dx, dy)
+				}
+				if !found || score < bestScore || score == bestScore && tie < bestTie {
+					best, found, bestScore, bestTie = r, true, score, tie
+				}
+			}
+		}
+		if !found {
+			if snug {
+				return Layout{}, false
+			}
+			at := image.Pt(0, alignUp(padded, opts.SpriteAlign))
+			best = image.Rectangle{Min: at, Max: at.Add(sizes[0]).Add(pad)}
+		}
+
+		placed := image.Rectangle{Min: best.Min, Max: best.Max.Sub(pad)}
+		placements[rect.ID] = placed
+		width, bottom, padded = max(width, placed.Max.X), max(bottom, placed.Max.Y), max(padded, best.Max.Y)
+		free = splitFree(free, best)
+	}
+	return Layout{Placements: placements, Width: width, Height: bottom}, true
+}
+
+// splitFree returns the maximal free rectangles left once used is taken out
+// of free: each free rectangle overlapping it is replaced by the up to four
+// parts of it to the left, right, top and bottom of used, which may
+// overlap one another, and any part lying within another free rectangle is
+// dropped.
+func splitFree(free []image.Rectangle, used image.Rectangle) []image.Rectangle {
+	var kept, split []image.Rectangle
+	for _, f := range free {
+		if !f.Overlaps(used) {
+			kept = append(kept, f)
+			continue
+		}
+		if used.Min.X > f.Min.X {
+			split = append(split, image.Rect(f.Min.X, f.Min.Y, used.Min.X, f.Max.Y))
+		}
+		if used.Max.X < f.Max.X {
+			split = append(split, image.Rect(used.Max.X, f.Min.Y, f.Max.X, f.Max.Y))
+		}
+		if used.Min.Y > f.Min.Y {
+			split = append(split, image.Rect(f.Min.X, f.Min.Y, f.Max.X, used.Min.Y))
+		}
+		if used.Max.Y < f.Max.Y {
+			split = append(split, image.Rect(f.Min.X, used.Max.Y, f.Max.X, f.Max.Y))
+		}
+	}
+
+	// No free rectangle contains another, so none kept can lie within a part
+	// of one; only the parts need checking, against each other and the rest.
+	for i, r := range split {
+		contained := false
+		for _, f := range kept {
+			contained = contained || r.In(f)
+		}
+		for j, other := range split {
+			// Of two equal parts, the first is kept.
+			contained = contained || i != j && r.In(other) && (r != other || j < i)
+		}
+		if !contained {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
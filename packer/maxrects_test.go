@@ -0,0 +1,39 @@
+package packer
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// TestMaxRectsOccupancy checks that on sprites of mixed heights, where the
+// shelf packer leaves space above the short sprites on a tall shelf,
+// MaxRects fills some of it and so covers more of a smaller atlas.
+func TestMaxRectsOccupancy(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	sprites := make([]Rectangle, 120)
+	for i := range sprites {
+		w, h := 8+rng.IntN(40), 4+rng.IntN(12)
+		if i%4 == 0 {
+			h = 40 + rng.IntN(24)
+		}
+		sprites[i] = Rectangle{ID: i, Name: "s", Width: w, Height: h}
+	}
+	covered := make(map[string]float64)
+	for _, packer := range []string{PackerShelf, PackerMaxRects} {
+		rectangles := slices.Clone(sprites)
+		sortRectangles(rectangles, SortHeight)
+		opts := Options{MaxWidth: 256, MaxHeight: 4096, Packer: packer}
+		layout, err := planLayout(rectangles, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", packer, err)
+		}
+		if slices.Contains(overlappingSprites(rectangles, layout), true) {
+			t.Errorf("%s: sprites overlap", packer)
+		}
+		covered[packer] = occupancy(rectangles, layout)
+	}
+	if covered[PackerMaxRects] <= covered[PackerShelf] {
+		t.Errorf("maxrects covers %.1f%% of its atlas, no more than shelf's %.1f%%", 100*covered[PackerMaxRects], 100*covered[PackerShelf])
+	}
+}
@@ -0,0 +1,122 @@
+package packer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileMap is a file system of the listed files only, each opened from its
+// path on the operating system's file system. It lets the atlases being
+// merged be read from wherever their manifests place them.
+type fileMap map[string]string
+
+// Open opens the named file, implementing fs.FS.
+func (m fileMap) Open(name string) (fs.File, error) {
+	path, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return os.Open(path)
+}
+
+// MergeSet describes the atlases to merge with -merge: the atlas images to
+// load, a file system holding them, the sprites to crop out of each, and
+// each sprite's entry in its original manifest. Run packs its sprites when
+// it is Options.Merge, with FS and Crops as Options.FS and Options.Crops.
+type MergeSet struct {
+	FS      fs.FS
+	Files   []string
+	Crops   map[string][]Crop
+	Entries map[string]SpriteEntry
+}
+
+// LoadMergeSet reads the manifests of the atlases to merge. Each manifest's
+// images are taken to lie beside it, as this tool writes them, and texture
+// array manifests are supported by cropping each sprite out of its layer.
+// Two manifests listing the same sprite name are an error.
+func LoadMergeSet(manifestFiles []string) (*MergeSet, error) {
+	images := make(fileMap)
+	set := &MergeSet{
+		FS:      images,
+		Crops:   make(map[string][]Crop),
+		Entries: make(map[string]SpriteEntry),
+	}
+	from := make(map[string]string)
+	for _, manifestFile := range manifestFiles {
+		manifest, err := ReadManifest(manifestFile)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(manifest.Sprites))
+		for name := range manifest.Sprites {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if other, ok := from[name]; ok {
+				return nil, fmt.Errorf("sprite %s is listed by both %s and %s", name, other, manifestFile)
+			}
+			from[name] = manifestFile
+
+			entry := manifest.Sprites[name]
+			imageFile := manifest.Image
+			if entry.Layer != nil {
+				if *entry.Layer < 0 || *entry.Layer >= len(manifest.Layers) {
+					return nil, fmt.Errorf("%s: sprite %s is on layer %d, which the manifest does not list", manifestFile, name, *entry.Layer)
+				}
+				imageFile = manifest.Layers[*entry.Layer]
+			}
+			path := filepath.Join(filepath.Dir(manifestFile), filepath.Base(filepath.FromSlash(imageFile)))
+			file := filepath.ToSlash(path)
+			if _, ok := images[file]; !ok {
+				images[file] = path
+				set.Files = append(set.Files, file)
+			}
+			set.Crops[file] = append(set.Crops[file], Crop{Name: name, X: entry.X, Y: entry.Y, W: entry.W, H: entry.H, Rotated: entry.Rotated})
+			set.Entries[name] = entry
+		}
+	}
+	sort.Strings(set.Files)
+	return set, nil
+}
+
+// restoreMerged carries what the original manifests recorded about each
+// merged sprite over to its rectangle: the pivot, the source scale and
+// nine-slice insets from -nameconventions, and the trim, composed
+// with any further trim applied while merging so the offset stays relative
+// to the sprite's original source image.
+func restoreMerged(rectangles []Rectangle, entries map[string]SpriteEntry) {
+	for i := range rectangles {
+		rect := &rectangles[i]
+		entry, ok := entries[rect.Name]
+		if !ok {
+			continue
+		}
+		rect.Meta.Pivot = entry.Pivot
+		rect.SourceScale, rect.NineSlice = entry.SourceScale, entry.NineSlice
+		if entry.Trim == nil {
+			continue
+		}
+		rect.Trimmed = true
+		rect.TrimOffset.X += entry.Trim.X
+		rect.TrimOffset.Y += entry.Trim.Y
+		rect.SourceWidth, rect.SourceHeight = entry.Trim.SourceW, entry.Trim.SourceH
+	}
+}
+
+// SplitList splits a comma-separated flag value into its non-empty,
+// space-trimmed items.
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
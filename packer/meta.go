@@ -0,0 +1,41 @@
+package packer
+
+import (
+	"runtime/debug"
+)
+
+// ManifestMeta records how a manifest was generated, written with
+// -recordoptions: the version and source revision of the tool, as stamped
+// into its build, the packing algorithm, and the effective value of every
+// flag after -config and -tps files were applied, keyed by flag name.
+type ManifestMeta struct {
+	Version   string            `json:"version,omitempty"`
+	Revision  string            `json:"revision,omitempty"`
+	Algorithm string            `json:"algorithm"`
+	Options   map[string]string `json:"options"`
+}
+
+// manifestMeta returns the metadata to record in manifests built with opts.
+// The version and revision are left empty when the binary carries no build
+// information; a revision built with uncommitted changes ends in "+modified".
+func manifestMeta(opts Options) *ManifestMeta {
+	meta := &ManifestMeta{Algorithm: algorithmName(opts), Options: opts.Recorded}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return meta
+	}
+	meta.Version = info.Main.Version
+	modified := false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			meta.Revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if modified && meta.Revision != "" {
+		meta.Revision += "+modified"
+	}
+	return meta
+}
@@ -0,0 +1,113 @@
+package packer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"sort"
+)
+
+// minifyAtlas returns the lossless representation of the atlas that encodes
+// to the smallest PNG, along with its encoded size and the size of the
+// standard encoding. Besides the atlas itself, a palette image is tried when
+// an 8-bit atlas uses at most 256 distinct colors and a grayscale image when
+// every pixel is opaque gray. Go's PNG encoder writes no ancillary chunks
+// other than the tRNS chunk a palette with transparency requires, so the
+// candidates only differ in color type and filtering. 16-bit atlases are
+// returned unchanged, as no smaller representation preserves their precision.
+func minifyAtlas(atlas image.Image) (image.Image, int, int, error) {
+	standardSize, err := encodedPNGSize(atlas)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	nrgba, ok := atlas.(*image.NRGBA)
+	if !ok {
+		return atlas, standardSize, standardSize, nil
+	}
+
+	best, bestSize := atlas, standardSize
+	for _, candidate := range []image.Image{paletteImage(nrgba), grayImage(nrgba)} {
+		if candidate == nil {
+			continue
+		}
+		size, err := encodedPNGSize(candidate)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if size < bestSize {
+			best, bestSize = candidate, size
+		}
+	}
+	return best, bestSize, standardSize, nil
+}
+
+// encodedPNGSize returns the number of bytes the image occupies when encoded
+// as a PNG with the best compression level.
+func encodedPNGSize(img image.Image) (int, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
+}
+
+// paletteImage converts the atlas to a paletted image holding exactly the
+// same colors, or returns nil if the atlas uses more than 256 colors.
+// The palette is sorted so the output is deterministic.
+func paletteImage(atlas *image.NRGBA) image.Image {
+	seen := make(map[color.NRGBA]struct{})
+	b := atlas.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			seen[atlas.NRGBAAt(x, y)] = struct{}{}
+			if len(seen) > 256 {
+				return nil
+			}
+		}
+	}
+
+	colors := make([]color.NRGBA, 0, len(seen))
+	for c := range seen {
+		colors = append(colors, c)
+	}
+	sort.Slice(colors, func(i, j int) bool {
+		a, b := colors[i], colors[j]
+		return uint32(a.R)<<24|uint32(a.G)<<16|uint32(a.B)<<8|uint32(a.A) <
+			uint32(b.R)<<24|uint32(b.G)<<16|uint32(b.B)<<8|uint32(b.A)
+	})
+
+	palette := make(color.Palette, len(colors))
+	index := make(map[color.NRGBA]uint8, len(colors))
+	for i, c := range colors {
+		palette[i] = c
+		index[c] = uint8(i)
+	}
+
+	paletted := image.NewPaletted(b, palette)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			paletted.SetColorIndex(x, y, index[atlas.NRGBAAt(x, y)])
+		}
+	}
+	return paletted
+}
+
+// grayImage converts the atlas to a grayscale image, or returns nil if any
+// pixel is translucent or not a shade of gray.
+func grayImage(atlas *image.NRGBA) image.Image {
+	b := atlas.Bounds()
+	gray := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := atlas.NRGBAAt(x, y)
+			if c.A != 0xff || c.R != c.G || c.G != c.B {
+				return nil
+			}
+			gray.Pix[gray.PixOffset(x, y)] = c.R
+		}
+	}
+	return gray
+}
@@ -0,0 +1,48 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// minimalManifest renders only the placements of a manifest, as a JSON
+// object mapping each sprite's name to its [x, y, w, h] rectangle, for
+// consumers that want the smallest payload to parse. Keys are written in
+// name order; when pretty is set each sprite gets a line of its own.
+// Everything else in the manifest, including the atlas image name and
+// size, is left out.
+func minimalManifest(manifest Manifest, pretty bool) ([]byte, error) {
+	names := make([]string, 0, len(manifest.Sprites))
+	for name := range manifest.Sprites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if pretty {
+			b.WriteString("\n  ")
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		entry := manifest.Sprites[name]
+		format := "%s:[%d,%d,%d,%d]"
+		if pretty {
+			format = "%s: [%d, %d, %d, %d]"
+		}
+		fmt.Fprintf(&b, format, key, entry.X, entry.Y, entry.W, entry.H)
+	}
+	if pretty && len(names) > 0 {
+		b.WriteByte('\n')
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
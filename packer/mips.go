@@ -0,0 +1,71 @@
+package packer
+
+import (
+	"image"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MipLevel is one downscaled copy of an atlas written with -miplevels: its
+// level, where level n is 1/2^n of the atlas's size on each side, the image
+// holding it and its dimensions, and the rectangle each sprite covers at that
+// size, keyed by sprite name as in Manifest.Sprites.
+type MipLevel struct {
+	Level   int                    `json:"level"`
+	Image   string                 `json:"image"`
+	Width   int                    `json:"width"`
+	Height  int                    `json:"height"`
+	Sprites map[string]RegionEntry `json:"sprites"`
+}
+
+// mipFilename returns the filename of the given mip level of an atlas,
+// written beside it with -miplevels.
+func mipFilename(atlasFile string, level int) string {
+	ext := filepath.Ext(atlasFile)
+	return strings.TrimSuffix(atlasFile, ext) + "_mip" + strconv.Itoa(level) + ext
+}
+
+// mipSize returns the size of a side of n pixels at the given mip level,
+// halved once per level and never below one pixel.
+func mipSize(n, level int) int {
+	return max(1, n>>level)
+}
+
+// mipImage returns the atlas shrunk to the given mip level with area
+// averaging, a box filter, so each level is filtered straight from the full
+// atlas rather than from the level above.
+func mipImage(atlas image.Image, level int) *image.NRGBA {
+	b := atlas.Bounds()
+	return resizeArea(atlas, mipSize(b.Dx(), level), mipSize(b.Dy(), level))
+}
+
+// mipLevels describes levels 1 to levels of the manifest's atlas. Each
+// sprite's rectangle is scaled in proportion to the level's size and rounded
+// outwards, so it always covers every texel its pixels were filtered into.
+func mipLevels(manifest Manifest, levels int) []MipLevel {
+	mips := make([]MipLevel, levels)
+	for i := range mips {
+		level := i + 1
+		w, h := mipSize(manifest.Width, level), mipSize(manifest.Height, level)
+		mip := MipLevel{
+			Level:   level,
+			Image:   mipFilename(manifest.Image, level),
+			Width:   w,
+			Height:  h,
+			Sprites: make(map[string]RegionEntry, len(manifest.Sprites)),
+		}
+		for name, entry := range manifest.Sprites {
+			x0, x1 := entry.X*w/manifest.Width, ceilDiv((entry.X+entry.W)*w, manifest.Width)
+			y0, y1 := entry.Y*h/manifest.Height, ceilDiv((entry.Y+entry.H)*h, manifest.Height)
+			mip.Sprites[name] = RegionEntry{X: x0, Y: y0, W: x1 - x0, H: y1 - y0}
+		}
+		mips[i] = mip
+	}
+	return mips
+}
+
+// ceilDiv returns a/b rounded up, for a non-negative and b positive.
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,101 @@
+package packer
+
+import (
+	"image"
+)
+
+// Values of a sidecar entry's mirror field, naming the axis across which a
+// sprite is symmetric.
+const (
+	// mirrorX marks a sprite whose right half mirrors its left half.
+	mirrorX = "x"
+	// mirrorY marks a sprite whose bottom half mirrors its top half.
+	mirrorY = "y"
+)
+
+// MirrorEntry records that only the canonical half of a symmetric sprite was
+// packed: the left half for axis "x" and the top half for axis "y". The
+// packed half includes the middle column or row of an odd-sized sprite, and
+// reflecting it across its right or bottom edge, without repeating that
+// middle line, restores the sprite at its full size W x H.
+type MirrorEntry struct {
+	Axis string `json:"axis"`
+	W    int    `json:"w"`
+	H    int    `json:"h"`
+}
+
+// mirrorRectangles replaces the image of every rectangle that the sidecar
+// marks as mirrorable with its canonical half. A sprite that is not exactly
+// symmetric across the given axis is packed whole with a warning, as
+// mirroring its half would not reproduce it, and so is one with an unknown
+// axis.
+func mirrorRectangles(rectangles []Rectangle) {
+	for i := range rectangles {
+		rect := &rectangles[i]
+		axis := rect.Meta.Mirror
+		if axis == "" {
+			continue
+		}
+		if axis != mirrorX && axis != mirrorY {
+			Warnf("sprite %s: unsupported mirror axis %q; supported axes: x, y", rect.Name, axis)
+			continue
+		}
+		img, err := rect.pixels()
+		if err != nil {
+			Warnf("sprite %s: %v; packing it whole", rect.Name, err)
+			continue
+		}
+		if !isSymmetric(img, axis) {
+			Warnf("sprite %s is not symmetric across the %s axis; packing it whole", rect.Name, axis)
+			continue
+		}
+		sub, ok := img.(subImager)
+		if !ok {
+			continue
+		}
+
+		b := img.Bounds()
+		half := b
+		if axis == mirrorX {
+			half.Max.X = b.Min.X + (b.Dx()+1)/2
+		} else {
+			half.Max.Y = b.Min.Y + (b.Dy()+1)/2
+		}
+		rect.Mirror = &MirrorEntry{Axis: axis, W: b.Dx(), H: b.Dy()}
+		if rect.Image != nil {
+			rect.Image = sub.SubImage(half)
+		} else {
+			reload := rect.Reload
+			rect.Reload = func() (image.Image, error) {
+				img, err := reload()
+				if err != nil {
+					return nil, err
+				}
+				return img.(subImager).SubImage(half), nil
+			}
+		}
+		rect.Width, rect.Height = half.Dx(), half.Dy()
+	}
+}
+
+// isSymmetric reports whether every pixel of img equals its reflection
+// across the vertical center line, for axis "x", or the horizontal one, for
+// axis "y". Pixels are compared premultiplied at 16 bits per channel, so
+// fully transparent pixels are equal whatever their color.
+func isSymmetric(img image.Image, axis string) bool {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			mx, my := x, b.Max.Y-1-(y-b.Min.Y)
+			if axis == mirrorX {
+				mx, my = b.Max.X-1-(x-b.Min.X), y
+			}
+			r1, g1, b1, a1 := img.At(x, y).RGBA()
+			r2, g2, b2, a2 := img.At(mx, my).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return false
+			}
+		}
+	}
+	return true
+}
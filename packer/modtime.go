@@ -0,0 +1,60 @@
+package packer
+
+import (
+	"fmt"
+	"time"
+)
+
+// modifiedSinceLayouts are the forms of absolute time -modifiedsince
+// accepts, besides a duration before now.
+var modifiedSinceLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// Since is the time from which -modifiedsince keeps files, given on the
+// command line as a duration back from when the flag is parsed, such as
+// "2h", or as an absolute time, such as "2024-05-01" or
+// "2024-05-01T12:00:00Z", taken in local time when it has no zone. The zero
+// Since keeps every file.
+type Since struct {
+	time.Time
+	value string
+}
+
+// String returns the value as given, implementing flag.Value.
+func (s *Since) String() string {
+	return s.value
+}
+
+// Set parses a duration or an absolute time, implementing flag.Value.
+func (s *Since) Set(value string) error {
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return fmt.Errorf("invalid duration %q: must not be negative", value)
+		}
+		s.Time, s.value = time.Now().Add(-d), value
+		return nil
+	}
+	for _, layout := range modifiedSinceLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			s.Time, s.value = t, value
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time %q: want a duration such as 2h or a time such as 2006-01-02 or %s", value, time.RFC3339)
+}
+
+// onlyModified returns the entries of a -crops or -sidecar map whose key is
+// in keep, so that with -modifiedsince, or the filters of -recursive,
+// -include and -exclude, the entries for files left out are not reported as
+// matching nothing.
+func onlyModified[V any](entries map[string]V, keep []string) map[string]V {
+	if entries == nil {
+		return nil
+	}
+	kept := make(map[string]V, len(keep))
+	for _, key := range keep {
+		if v, ok := entries[key]; ok {
+			kept[key] = v
+		}
+	}
+	return kept
+}
@@ -0,0 +1,98 @@
+package packer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultAtlasName is the default {name} token: the base name of every
+// output file.
+const DefaultAtlasName = "atlas"
+
+// AtlasKindDiffuse is the {type} token for the color atlas.
+const AtlasKindDiffuse = "diffuse"
+
+// AtlasFilename returns the filename of an atlas image whose base name, the
+// {name} token, is base. With an empty template the built-in scheme is used:
+// atlas.png, or atlas_<group>.png when grouping, with _<page> appended for
+// every page after the first, and with ext, the extension of -out, in place
+// of ".png". Otherwise the tokens {name}, {group}, {page} and {type} in the
+// template are substituted, and ext is appended if it has no extension.
+func AtlasFilename(base, ext, template, group string, page int, kind string) string {
+	if template == "" {
+		name := base
+		if group != "" {
+			name += "_" + group
+		}
+		if page > 0 {
+			name += "_" + strconv.Itoa(page)
+		}
+		return name + ext
+	}
+
+	name := expandTemplate(template, base, group, page, kind)
+	if filepath.Ext(name) == "" {
+		name += ext
+	}
+	return name
+}
+
+// expandTemplate substitutes the tokens {name}, {group}, {page} and {type}
+// in a -nametemplate or -manifest template.
+func expandTemplate(template, base, group string, page int, kind string) string {
+	return strings.NewReplacer(
+		"{name}", base,
+		"{group}", group,
+		"{page}", strconv.Itoa(page),
+		"{type}", kind,
+	).Replace(template)
+}
+
+// manifestPath returns the filename of the manifest for the atlas image
+// atlasFile of a group and page: with an empty opts.ManifestTemplate the
+// image's filename with the manifest format's extension, and otherwise the
+// template with its tokens substituted, with that extension appended if it
+// has none.
+func manifestPath(atlasFile, group string, page int, opts Options) string {
+	if opts.ManifestTemplate == "" {
+		return manifestFilename(atlasFile, opts.Format)
+	}
+	name := expandTemplate(opts.ManifestTemplate, opts.AtlasName, group, page, AtlasKindDiffuse)
+	if filepath.Ext(name) == "" {
+		name = manifestFilename(name, opts.Format)
+	}
+	return name
+}
+
+// manifestFilename returns the manifest filename for an atlas image: the
+// image filename with its extension replaced by that of the manifest
+// format: ".json", ".ndjson", ".go", ".xml" or ".plist", and ".json" for
+// minimal manifests.
+func manifestFilename(atlasFile, format string) string {
+	if format == FormatMinimal {
+		format = FormatJSON
+	}
+	return strings.TrimSuffix(atlasFile, filepath.Ext(atlasFile)) + "." + format
+}
+
+// ValidateNameTemplate reports unknown tokens in the template, and a missing
+// {group} token when grouping or {page} token when paging, which would make
+// every group's or page's output overwrite the previous one.
+func ValidateNameTemplate(template string, grouping, paging bool) error {
+	if template == "" {
+		return nil
+	}
+	rest := strings.NewReplacer("{name}", "", "{group}", "", "{page}", "", "{type}", "").Replace(template)
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("invalid name template %q: supported tokens are {name}, {group}, {page} and {type}", template)
+	}
+	if grouping && !strings.Contains(template, "{group}") {
+		return fmt.Errorf("name template %q must contain {group} when grouping", template)
+	}
+	if paging && !strings.Contains(template, "{page}") {
+		return fmt.Errorf("name template %q must contain {page} when paging", template)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package packer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ndjsonHeader is the first line of a newline-delimited JSON manifest: the
+// atlas fields of the manifest, with the number of sprites that follow in
+// place of the sprite map.
+type ndjsonHeader struct {
+	Manifest
+	Sprites int `json:"sprites"`
+}
+
+// ndjsonSprite is one sprite line of a newline-delimited JSON manifest: the
+// sprite's manifest entry together with its name.
+type ndjsonSprite struct {
+	Name string `json:"name"`
+	SpriteEntry
+}
+
+// ndjsonManifest renders a manifest as newline-delimited JSON: a header
+// line describing the atlas, then one line per sprite in name order, so a
+// loader can parse it a sprite at a time.
+func ndjsonManifest(manifest Manifest) ([]byte, error) {
+	names := make([]string, 0, len(manifest.Sprites))
+	for name := range manifest.Sprites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	header := ndjsonHeader{Manifest: manifest, Sprites: len(names)}
+	header.Manifest.Sprites = nil
+	if err := encoder.Encode(header); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := encoder.Encode(ndjsonSprite{Name: name, SpriteEntry: manifest.Sprites[name]}); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// parseNDJSONManifest reads a manifest written by ndjsonManifest back into
+// a Manifest, checking that it holds as many sprites as its header says.
+func parseNDJSONManifest(data []byte) (Manifest, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	if !scanner.Scan() {
+		return Manifest{}, errors.New("missing header line")
+	}
+	var header ndjsonHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return Manifest{}, fmt.Errorf("header: %w", err)
+	}
+
+	manifest := header.Manifest
+	manifest.Sprites = make(map[string]SpriteEntry, header.Sprites)
+	for line := 2; scanner.Scan(); line++ {
+		var sprite ndjsonSprite
+		if err := json.Unmarshal(scanner.Bytes(), &sprite); err != nil {
+			return Manifest{}, fmt.Errorf("line %d: %w", line, err)
+		}
+		manifest.Sprites[sprite.Name] = sprite.SpriteEntry
+	}
+	if err := scanner.Err(); err != nil {
+		return Manifest{}, err
+	}
+	if len(manifest.Sprites) != header.Sprites {
+		return Manifest{}, fmt.Errorf("header lists %d sprites, but %d follow", header.Sprites, len(manifest.Sprites))
+	}
+	return manifest, nil
+}
@@ -0,0 +1,111 @@
+package packer
+
+import "maps"
+
+// Values of the -origin flag, choosing the corner or point of the atlas
+// that manifest coordinates are measured from.
+const (
+	// OriginTopLeft measures x rightwards and y downwards from the top-left
+	// corner, as the pixels are stored.
+	OriginTopLeft = "topleft"
+	// OriginBottomLeft measures y upwards from the bottom-left corner, and
+	// places each rectangle by its bottom-left corner.
+	OriginBottomLeft = "bottomleft"
+	// OriginCenter measures x rightwards and y downwards from the center of
+	// the atlas, rounded down to a whole pixel on odd sides.
+	OriginCenter = "center"
+)
+
+// originRect returns the position of a rectangle at (x, y), h pixels tall,
+// inside a width by height space, measured from the origin; inverse turns a
+// position measured from the origin back into one from the top-left.
+func originRect(origin string, x, y, h, width, height int, inverse bool) (int, int) {
+	switch origin {
+	case OriginBottomLeft:
+		// Flipping is its own inverse.
+		return x, height - y - h
+	case OriginCenter:
+		if inverse {
+			return x + width/2, y + height/2
+		}
+		return x - width/2, y - height/2
+	}
+	return x, y
+}
+
+// withOrigin returns a copy of the manifest with the positions of its
+// sprites, reserved region, strip rows, mip levels, tiles and free regions
+// measured from the origin instead of the top-left corner, recording the
+// origin in the manifest. Offsets within a sprite, such as trim offsets,
+// polygons and pivots, are left as they are, and so are the pixels, except
+// that quads from -emitquads have y pointing up with bottomleft. With
+// inverse set, the manifest's recorded origin is undone instead, turning a
+// manifest written with -origin back into top-left coordinates.
+func withOrigin(manifest Manifest, origin string, inverse bool) Manifest {
+	if inverse {
+		origin = manifest.Origin
+		manifest.Origin = ""
+	} else if origin != OriginTopLeft {
+		manifest.Origin = origin
+	}
+	if origin == "" || origin == OriginTopLeft {
+		return manifest
+	}
+	move := func(x, y, h, width, height int) (int, int) {
+		return originRect(origin, x, y, h, width, height, inverse)
+	}
+
+	sprites := maps.Clone(manifest.Sprites)
+	for name, entry := range sprites {
+		entry.X, entry.Y = move(entry.X, entry.Y, entry.H, manifest.Width, manifest.Height)
+		if entry.Quad != nil && origin == OriginBottomLeft {
+			quad := flipQuad(*entry.Quad)
+			entry.Quad = &quad
+		}
+		sprites[name] = entry
+	}
+	manifest.Sprites = sprites
+	if r := manifest.Reserved; r != nil {
+		reserved := *r
+		reserved.X, reserved.Y = move(r.X, r.Y, r.H, manifest.Width, manifest.Height)
+		manifest.Reserved = &reserved
+	}
+	if manifest.Rows != nil {
+		rows := make([]StripRow, len(manifest.Rows))
+		for i, row := range manifest.Rows {
+			_, row.Y = move(0, row.Y, row.Height, manifest.Width, manifest.Height)
+			rows[i] = row
+		}
+		manifest.Rows = rows
+	}
+	if manifest.Mips != nil {
+		mips := make([]MipLevel, len(manifest.Mips))
+		for i, mip := range manifest.Mips {
+			regions := make(map[string]RegionEntry, len(mip.Sprites))
+			for name, r := range mip.Sprites {
+				r.X, r.Y = move(r.X, r.Y, r.H, mip.Width, mip.Height)
+				regions[name] = r
+			}
+			mip.Sprites = regions
+			mips[i] = mip
+		}
+		manifest.Mips = mips
+	}
+	if manifest.Free != nil {
+		free := make([]RegionEntry, len(manifest.Free))
+		for i, r := range manifest.Free {
+			r.X, r.Y = move(r.X, r.Y, r.H, manifest.Width, manifest.Height)
+			free[i] = r
+		}
+		manifest.Free = free
+	}
+	if manifest.Tiles != nil {
+		tiles := make([]TileEntry, len(manifest.Tiles))
+		for i, tile := range manifest.Tiles {
+			tile.X, tile.Y = move(tile.X, tile.Y, tile.H, manifest.Width, manifest.Height)
+			tiles[i] = tile
+		}
+		manifest.Tiles = tiles
+	}
+	return manifest
+}
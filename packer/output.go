@@ -0,0 +1,223 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// committedOutputs holds the absolute path of every output moved into place,
+// so -watch can tell the files the run writes from the inputs it reads.
+var committedOutputs = struct {
+	sync.Mutex
+	files map[string]bool
+}{files: make(map[string]bool)}
+
+// recordOutput adds filename to the committed outputs.
+func recordOutput(filename string) {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return
+	}
+	committedOutputs.Lock()
+	defer committedOutputs.Unlock()
+	committedOutputs.files[path] = true
+}
+
+// isCommittedOutput reports whether the file at the absolute path is an
+// output the run has written.
+func isCommittedOutput(path string) bool {
+	committedOutputs.Lock()
+	defer committedOutputs.Unlock()
+	return committedOutputs.files[path]
+}
+
+// outputFile is an output being written to a temporary file beside its
+// final name. Commit moves it into place in a single step, so readers of the
+// final name see either the previous file or the complete new one, never a
+// partial write.
+type outputFile struct {
+	*os.File
+	filename  string
+	overwrite bool
+	done      bool
+}
+
+// createOutput starts writing filename by creating a temporary file in the
+// same directory, creating the directory first if it is missing. The output
+// only replaces filename once committed. Unless
+// overwrite is set, it fails if the file already exists, both now and at
+// commit time, so hand-tuned outputs are never clobbered.
+func createOutput(filename string, overwrite bool) (*outputFile, error) {
+	if !overwrite {
+		if err := checkOutputsAbsent(filename); err != nil {
+			return nil, err
+		}
+	}
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", filename, err)
+	}
+	f, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", filename, err)
+	}
+	if err := trackOutput(f.Name()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &outputFile{File: f, filename: filename, overwrite: overwrite}, nil
+}
+
+// Commit closes the temporary file and moves it to the output's filename.
+// Without overwrite the move is a hard link, which fails rather than replace
+// a file that appeared in the meantime. Once the run has been interrupted,
+// the output is discarded instead, so nothing changes on disk after the
+// signal.
+func (f *outputFile) Commit() error {
+	defer f.release()
+	if err := f.finish(); err != nil {
+		return err
+	}
+	return unlessInterrupted(f.place)
+}
+
+// finish closes the temporary file, readable by all, ready to be placed.
+func (f *outputFile) finish() error {
+	f.done = true
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// place moves the finished temporary file to the output's filename and
+// records it among the committed outputs.
+func (f *outputFile) place() error {
+	var err error
+	if f.overwrite {
+		err = os.Rename(f.Name(), f.filename)
+	} else {
+		err = os.Link(f.Name(), f.filename)
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists and -overwrite=false", f.filename)
+	}
+	if err == nil {
+		recordOutput(f.filename)
+	}
+	return err
+}
+
+// release removes what is left of the temporary file once the output is
+// placed or has failed.
+func (f *outputFile) release() {
+	os.Remove(f.Name())
+	untrackOutput(f.Name())
+}
+
+// Abort discards the output unless it was committed, leaving any existing
+// file untouched. It is safe to defer after a successful Commit.
+func (f *outputFile) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.Close()
+	os.Remove(f.Name())
+	untrackOutput(f.Name())
+}
+
+// writeOutput atomically writes data to filename, honoring overwrite as
+// createOutput does.
+func writeOutput(filename string, data []byte, overwrite bool) error {
+	var set outputSet
+	defer set.Abort()
+	if err := set.Write(filename, data, overwrite); err != nil {
+		return err
+	}
+	return set.Commit()
+}
+
+// outputSet gathers outputs that belong together, such as an atlas and its
+// manifest, so that they are all moved into place at once after every one of
+// them has been written. A failure or interrupt before then leaves all of
+// the previous files as they were.
+type outputSet struct {
+	files []*outputFile
+}
+
+// Create starts writing filename as createOutput does, as part of the set.
+func (s *outputSet) Create(filename string, overwrite bool) (*outputFile, error) {
+	f, err := createOutput(filename, overwrite)
+	if err != nil {
+		return nil, err
+	}
+	s.files = append(s.files, f)
+	return f, nil
+}
+
+// Write writes data to filename as part of the set.
+func (s *outputSet) Write(filename string, data []byte, overwrite bool) error {
+	f, err := s.Create(filename, overwrite)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// Commit moves every output of the set into place, in the order they were
+// created. The run cannot be interrupted between the moves, so either all
+// of the outputs are committed or, after a signal, none of them is.
+func (s *outputSet) Commit() error {
+	files := s.files
+	s.files = nil
+	defer func() {
+		for _, f := range files {
+			f.release()
+		}
+	}()
+	for _, f := range files {
+		if err := f.finish(); err != nil {
+			return err
+		}
+	}
+	return unlessInterrupted(func() error {
+		for _, f := range files {
+			if err := f.place(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Abort discards the outputs of the set not yet committed. It is safe to
+// defer after a successful Commit.
+func (s *outputSet) Abort() {
+	for _, f := range s.files {
+		f.Abort()
+	}
+	s.files = nil
+}
+
+// checkOutputsAbsent returns an error naming the first of filenames that
+// already exists. It lets a run refuse to start writing when any of the
+// files it would produce is present, rather than stopping half-way through.
+func checkOutputsAbsent(filenames ...string) error {
+	for _, filename := range filenames {
+		if _, err := os.Stat(filename); err == nil {
+			return fmt.Errorf("%s already exists and -overwrite=false", filename)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package packer
+
+import (
+	"fmt"
+	"image/png"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OutputOptions are encoder settings for the format atlases are written
+// in, given with -outputopts as comma-separated key=value pairs, e.g.
+// "compression=fast". Repeating the flag adds more pairs.
+type OutputOptions map[string]string
+
+// outputOptionKeys lists the -outputopts keys each output format reads.
+var outputOptionKeys = map[string][]string{
+	"png":  {"compression"},
+	"gif":  {"colors", "dither"},
+	"jpeg": {"quality"},
+}
+
+// pngCompressionLevels maps the values of the PNG compression option to
+// encoder levels.
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"best":    png.BestCompression,
+	"default": png.DefaultCompression,
+	"fast":    png.BestSpeed,
+	"none":    png.NoCompression,
+}
+
+// String formats the options as accepted by Set, in key order,
+// implementing flag.Value.
+func (o *OutputOptions) String() string {
+	pairs := make([]string, 0, len(*o))
+	for key, value := range *o {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set parses comma-separated key=value pairs, implementing flag.Value.
+// Whether the keys apply to the output format is checked by Apply.
+func (o *OutputOptions) Set(value string) error {
+	if *o == nil {
+		*o = make(OutputOptions)
+	}
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || key == "" {
+			return fmt.Errorf("invalid output option %q: want key=value", pair)
+		}
+		if _, dup := (*o)[key]; dup {
+			return fmt.Errorf("output option %s given twice", key)
+		}
+		(*o)[key] = val
+	}
+	return nil
+}
+
+// Apply checks that every option is one the format reads and sets the
+// encoder settings it names, leaving the others as they are.
+func (o OutputOptions) Apply(format string, opts *Options) error {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := o[key]
+		if !slices.Contains(outputOptionKeys[format], key) {
+			return fmt.Errorf("%s output has no option %q; supported options: %s", format, key, strings.Join(outputOptionKeys[format], ", "))
+		}
+		switch key {
+		case "compression":
+			level, ok := pngCompressionLevels[value]
+			if !ok {
+				return fmt.Errorf("unsupported compression %q; supported values: best, default, fast, none", value)
+			}
+			opts.PNGCompression = level
+		case "colors":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 2 || n > 256 {
+				return fmt.Errorf("invalid colors %q: must be between 2 and 256", value)
+			}
+			opts.GIFColors = n
+		case "dither":
+			dither, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid dither %q: must be true or false", value)
+			}
+			opts.GIFDither = dither
+		case "quality":
+			quality, err := strconv.Atoi(value)
+			if err != nil || quality < 1 || quality > 100 {
+				return fmt.Errorf("invalid quality %q: must be between 1 and 100", value)
+			}
+			opts.JPEGQuality = quality
+		}
+	}
+	return nil
+}
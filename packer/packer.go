@@ -0,0 +1,209 @@
+// Package packer builds texture atlases: it loads sprites from a file
+// system, trims and processes them, packs them with the shelf, max-rects or
+// other packers, draws the atlas and saves it with its manifest, as the
+// texturepacker command does. Run does all of that for one set of Options;
+// Pack packs images already held in memory and returns the atlas without
+// writing anything.
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// ShelfOptions holds the settings of the shelf packer Place runs.
+type ShelfOptions struct {
+	// MaxWidth is the widest a shelf may grow, and so the widest the atlas
+	// may be.
+	MaxWidth int
+	// MaxHeight, above zero, is the tallest an image may stand. It only
+	// matters under Rotate, which turns an image taller than it.
+	MaxHeight int
+	// Padding is the number of pixels left between neighbours on a shelf,
+	// in X, and between consecutive shelves, in Y.
+	Padding image.Point
+	// BestFit puts each image on the shelf that leaves the least unused
+	// height above it rather than on the first one, from the top, with room.
+	BestFit bool
+	// Prefer reports whether position a wins a tie against position b
+	// between two shelves that fit an image equally well under BestFit.
+	// When nil, the highest position wins, then the leftmost.
+	Prefer func(a, b image.Point) bool
+	// Bucket rounds the height of every new shelf up to a multiple of it,
+	// above zero, so images of similar height can share a shelf.
+	Bucket int
+	// Align, above one, starts images and shelves at x and y coordinates
+	// rounded up to a multiple of it.
+	Align int
+	// Rotate lets an image that fits on no shelf as it is go on one turned
+	// a quarter turn clockwise, and lets one wider than MaxWidth or taller
+	// than MaxHeight open a new shelf turned when it fits that way. The
+	// placement of a turned image has its width and height swapped.
+	Rotate bool
+}
+
+// shelf is a horizontal row of the atlas: where it starts, how tall it is
+// and how far along it images already reach.
+type shelf struct {
+	Y      int
+	Height int
+	Width  int
+}
+
+// Pack packs the images into a single new atlas no larger than
+// opts.MaxWidth by opts.MaxHeight, with opts.Padding between them, and
+// returns it with the rectangle each image was drawn at, keyed by name,
+// including the duplicates of each. Only the Name and Image of each
+// rectangle need be set. They are packed in opts.Sort order and then by
+// name, so the same images always give the same atlas whatever order they
+// are passed in.
+// Only the settings of opts that place and draw the sprites apply, as they
+// do to Run: the packer and its settings, such as Packer, AllowRotation,
+// Growth, BorderPadding, Reserve, Canvas and Mask, and the drawing ones,
+// BitDepth, Channels, Extrude, Background and SDF. The images are packed
+// as they are, so the settings applied as they load, such as Trim, Scale,
+// Resize or AlphaBleed, only take effect through LoadFS, and nothing is
+// written, so those of the output files have none. MaxPerPage,
+// TextureArray and AutoSize, which spread the sprites over pages or size
+// them, are an error.
+// With opts.AllowRotation, an image whose rectangle has its width and
+// height swapped was drawn turned, as Rotate turns it. It fails if the
+// images cannot fit within the bounds or two share a name.
+func Pack(images []Rectangle, opts Options) (*image.RGBA, map[string]image.Rectangle, error) {
+	if opts.MaxWidth <= 0 || opts.MaxHeight <= 0 {
+		return nil, nil, fmt.Errorf("invalid bounds %dx%d; both must be positive", opts.MaxWidth, opts.MaxHeight)
+	}
+	if opts.MaxPerPage > 0 || opts.TextureArray || opts.AutoSize {
+		return nil, nil, errors.New("Pack builds a single atlas and does not support MaxPerPage, TextureArray or AutoSize")
+	}
+	rectangles := make([]Rectangle, len(images))
+	seen := make(map[string]bool, len(images))
+	for i, rect := range images {
+		if rect.Image == nil {
+			return nil, nil, fmt.Errorf("image %q is nil", rect.Name)
+		}
+		if seen[rect.Name] {
+			return nil, nil, fmt.Errorf("two images are named %q", rect.Name)
+		}
+		seen[rect.Name] = true
+		rect.ID = i
+		rect.Width, rect.Height = rect.Image.Bounds().Dx(), rect.Image.Bounds().Dy()
+		rectangles[i] = rect
+	}
+	sortRectangles(rectangles, opts.Sort)
+
+	atlas, layout, err := generateAtlas(context.Background(), rectangles, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rgba, ok := atlas.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(atlas.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), atlas, atlas.Bounds().Min, draw.Src)
+	}
+	packed := make(map[string]image.Rectangle, len(rectangles))
+	for _, rect := range rectangles {
+		packed[rect.Name] = layout.Placements[rect.ID]
+		// Sprites LoadFS found to have the same pixels share the region.
+		for _, dup := range rect.Duplicates {
+			packed[dup.Name] = layout.Placements[rect.ID]
+		}
+	}
+	return rgba, packed, nil
+}
+
+// Place computes shelf placements for rectangles of the given sizes, in
+// the order given, without drawing anything, and returns the rectangle of
+// each along with the size of the atlas holding them all. Shelves are
+// bounded by opts.MaxWidth, though a rectangle wider than that still gets
+// a shelf of its own, turned if opts.Rotate allows and that makes it fit;
+// the atlas grows downwards as far as it needs. The atlas ends at the
+// lowest rectangle rather than at the bottom of its shelf, which may be
+// taller with opts.Bucket.
+func Place(sizes []image.Point, opts ShelfOptions) ([]image.Rectangle, image.Point) {
+	placements := make([]image.Rectangle, len(sizes))
+	shelves := []shelf{{Y: 0, Height: 0, Width: 0}}
+	prefer := opts.Prefer
+	if prefer == nil {
+		prefer = func(a, b image.Point) bool { return a.Y < b.Y || a.Y == b.Y && a.X < b.X }
+	}
+	maxWidth, bottom := 0, 0
+	padX, padY := opts.Padding.X, opts.Padding.Y
+
+	// fit returns the shelf a rectangle of the given size goes on, and where
+	// along it, or -1 if none has room.
+	fit := func(size image.Point) (int, int) {
+		chosen, chosenX := -1, 0
+		for i, s := range shelves {
+			x := s.Width
+			if x > 0 {
+				x += padX
+			}
+			x = alignUp(x, opts.Align)
+			if size.Y > s.Height || x+size.X > opts.MaxWidth {
+				continue
+			}
+			if chosen < 0 || s.Height < shelves[chosen].Height ||
+				s.Height == shelves[chosen].Height && prefer(image.Pt(x, s.Y), image.Pt(chosenX, shelves[chosen].Y)) {
+				chosen, chosenX = i, x
+			}
+			if !opts.BestFit {
+				break
+			}
+		}
+		return chosen, chosenX
+	}
+
+	for n, size := range sizes {
+		chosen, chosenX := fit(size)
+		turned := image.Pt(size.Y, size.X)
+		if chosen < 0 && opts.Rotate && turned != size {
+			tooTall := opts.MaxHeight > 0 && size.Y > opts.MaxHeight && turned.Y <= opts.MaxHeight
+			if chosen, chosenX = fit(turned); chosen >= 0 || turned.X <= opts.MaxWidth && (size.X > opts.MaxWidth || tooTall) {
+				size = turned
+			}
+		}
+
+		if chosen >= 0 {
+			s := shelves[chosen]
+			placements[n] = image.Rect(chosenX, s.Y, chosenX+size.X, s.Y+size.Y)
+			shelves[chosen].Width = chosenX + size.X
+			maxWidth = max(maxWidth, shelves[chosen].Width)
+			bottom = max(bottom, s.Y+size.Y)
+		} else {
+			last := shelves[len(shelves)-1]
+			y := last.Y + last.Height
+			if last.Height > 0 {
+				y += padY
+			}
+			y = alignUp(y, opts.Align)
+			shelves = append(shelves, shelf{Y: y, Height: shelfHeight(size.Y, opts.Bucket), Width: size.X})
+			placements[n] = image.Rect(0, y, size.X, y+size.Y)
+			maxWidth = max(maxWidth, size.X)
+			bottom = max(bottom, y+size.Y)
+		}
+	}
+	return placements, image.Pt(maxWidth, bottom)
+}
+
+// shelfHeight returns the height of a new shelf opened for a rectangle of
+// the given height: the height itself, or with a bucket size above zero the
+// height rounded up to the next multiple of it.
+func shelfHeight(height, bucket int) int {
+	if bucket <= 0 {
+		return height
+	}
+	return (height + bucket - 1) / bucket * bucket
+}
+
+// alignUp rounds v up to a multiple of align, or returns it unchanged when
+// align is below 2.
+func alignUp(v, align int) int {
+	if align < 2 {
+		return v
+	}
+	return (v + align - 1) / align * align
+}
@@ -0,0 +1,225 @@
+package packer
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// solid returns a w by h image filled with c.
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// TestPack checks that Pack draws every image at the rectangle it returns
+// for it, with the padding between them, whatever order they are given in.
+func TestPack(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	images := []Rectangle{
+		{Name: "coin", Image: solid(8, 8, blue)},
+		{Name: "hero", Image: solid(16, 24, red)},
+	}
+	opts := Options{MaxWidth: 64, MaxHeight: 64, Padding: Padding{X: 2, Y: 2}}
+	atlas, rects, err := Pack(images, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(0, 0, 16, 24); rects["hero"] != want {
+		t.Errorf("hero at %v, want %v", rects["hero"], want)
+	}
+	if want := image.Rect(18, 0, 26, 8); rects["coin"] != want {
+		t.Errorf("coin at %v, want %v", rects["coin"], want)
+	}
+	if got := atlas.Bounds(); got != image.Rect(0, 0, 26, 24) {
+		t.Errorf("atlas bounds %v, want 26x24", got)
+	}
+	for _, rect := range images {
+		r := rects[rect.Name]
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if got, want := atlas.At(x, y), rect.Image.At(x-r.Min.X, y-r.Min.Y); got != want {
+					t.Fatalf("%s: atlas pixel (%d, %d) is %v, want %v", rect.Name, x, y, got, want)
+				}
+			}
+		}
+	}
+
+	reversed := []Rectangle{images[1], images[0]}
+	_, again, err := Pack(reversed, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, r := range rects {
+		if again[name] != r {
+			t.Errorf("%s at %v when given in reverse order, want %v", name, again[name], r)
+		}
+	}
+}
+
+// TestPackErrors checks that Pack rejects duplicate names, images too
+// large for its bounds and options that would spread them over pages.
+func TestPackErrors(t *testing.T) {
+	img := solid(4, 4, color.White)
+	if _, _, err := Pack([]Rectangle{{Name: "a", Image: img}, {Name: "a", Image: img}}, Options{MaxWidth: 16, MaxHeight: 16}); err == nil {
+		t.Error("Pack accepted two images with the same name")
+	}
+	if _, _, err := Pack([]Rectangle{{Name: "big", Image: solid(32, 4, color.White)}}, Options{MaxWidth: 16, MaxHeight: 16}); err == nil {
+		t.Error("Pack accepted an image wider than MaxWidth")
+	}
+	if _, _, err := Pack([]Rectangle{{Name: "a", Image: img}}, Options{MaxWidth: 16, MaxHeight: 16, MaxPerPage: 1}); err == nil {
+		t.Error("Pack accepted MaxPerPage, though it builds a single page")
+	}
+}
+
+// TestSingleSprite checks that an atlas of one sprite is exactly the
+// sprite's size plus the border, whichever packer places it and even when
+// the bounds leave no room to spare, and holds the sprite's pixels 1:1.
+func TestSingleSprite(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts Options
+	}{
+		{"shelf", Options{MaxWidth: 64, MaxHeight: 64}},
+		{"padded", Options{MaxWidth: 64, MaxHeight: 64, Padding: Padding{X: 3, Y: 3}}},
+		{"exact bounds", Options{MaxWidth: 7, MaxHeight: 5}},
+		{"border", Options{MaxWidth: 11, MaxHeight: 9, BorderPadding: 2}},
+		{"maxrects", Options{MaxWidth: 64, MaxHeight: 64, Packer: PackerMaxRects, Padding: Padding{X: 3, Y: 3}}},
+		{"two pass", Options{MaxWidth: 7, MaxHeight: 5, TwoPass: true}},
+	} {
+		sprite := patterned(7, 5, 9)
+		rectangles := []Rectangle{{ID: 0, Name: "only.png", Width: 7, Height: 5, Image: sprite}}
+		atlas, layout, err := generateAtlas(context.Background(), rectangles, tc.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		border := tc.opts.BorderPadding
+		if want := image.Rect(0, 0, 7+2*border, 5+2*border); atlas.Bounds() != want {
+			t.Errorf("%s: atlas bounds %v, want %v", tc.name, atlas.Bounds(), want)
+			continue
+		}
+		if want := image.Rect(border, border, border+7, border+5); layout.Placements[0] != want {
+			t.Errorf("%s: sprite at %v, want %v", tc.name, layout.Placements[0], want)
+			continue
+		}
+		sameImage(t, tc.name, atlas.(subImager).SubImage(layout.Placements[0]), sprite)
+	}
+}
+
+// TestSpriteLargerThanBounds checks that a sprite larger than the bounds is
+// an error naming the largest such sprite and the bound it needs, counting
+// the border, rather than an atlas that clips it, unless it fits turned.
+func TestSpriteLargerThanBounds(t *testing.T) {
+	rectangles := []Rectangle{
+		{ID: 0, Name: "small.png", Width: 8, Height: 8},
+		{ID: 1, Name: "tall.png", Width: 8, Height: 40},
+		{ID: 2, Name: "taller.png", Width: 6, Height: 48},
+	}
+	for _, tc := range []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"height", Options{MaxWidth: 64, MaxHeight: 32}, "sprite taller.png is 48px tall, more than the -maxheight bound of 32px; raise -maxheight to at least 48"},
+		{"width", Options{MaxWidth: 4, MaxHeight: 64}, "sprite small.png is 8px wide, more than the -maxwidth bound of 4px; raise -maxwidth to at least 8"},
+		{"border", Options{MaxWidth: 64, MaxHeight: 48, BorderPadding: 2}, "sprite taller.png is 48px tall, more than the 44px the -maxheight bound of 48px leaves inside -borderpadding; raise -maxheight to at least 52"},
+	} {
+		_, err := planLayout(rectangles, tc.opts)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.want)
+		}
+	}
+
+	for _, packer := range []string{PackerShelf, PackerMaxRects} {
+		opts := Options{MaxWidth: 64, MaxHeight: 32, AllowRotation: true, Packer: packer}
+		layout, err := planLayout(rectangles, opts)
+		if err != nil {
+			t.Errorf("%s: sprites that fit turned rejected: %v", packer, err)
+		} else if layout.Width > opts.MaxWidth || layout.Height > opts.MaxHeight {
+			t.Errorf("%s: turned sprites packed into %dx%d, beyond the bounds", packer, layout.Width, layout.Height)
+		}
+	}
+}
+
+// TestRunCreatesOutputDirs checks that the atlas and the manifest are
+// written into directories that do not exist yet, each created as needed.
+func TestRunCreatesOutputDirs(t *testing.T) {
+	dir := t.TempDir()
+	opts := runOptions(translucentSprites(t, 4), dir)
+	opts.AtlasName = "atlas"
+	opts.NameTemplate = filepath.Join(dir, "out", "{name}.png")
+	opts.ManifestTemplate = filepath.Join(dir, "data", "{name}.json")
+	if _, err := Run(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"out/atlas.png", "data/atlas.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// TestRunNothingToPack checks that a run whose filters match no image, or
+// whose every image is trimmed away, writes nothing and is not an error.
+func TestRunNothingToPack(t *testing.T) {
+	fsys := translucentSprites(t, 4)
+	fsys["blank.png"] = pngFile(t, image.NewNRGBA(image.Rect(0, 0, 4, 4)))
+	for name, configure := range map[string]func(*Options){
+		"include": func(opts *Options) { opts.Include = []string{"*.jpg"} },
+		"trim": func(opts *Options) {
+			opts.Include = []string{"blank.png"}
+			opts.Trim = true
+		},
+	} {
+		dir := t.TempDir()
+		opts := runOptions(fsys, dir)
+		configure(&opts)
+		var stats []AtlasStats
+		var err error
+		captureStdout(t, func() { stats, err = Run(context.Background(), opts) })
+		if err != nil || len(stats) > 0 {
+			t.Errorf("%s: got %d atlases, error %v, want neither", name, len(stats), err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+			t.Errorf("%s: wrote %s", name, entries[0].Name())
+		}
+	}
+}
+
+// TestGroupRectangles checks that grouping by directory keeps directories
+// of the same name apart, and fails rather than let two directories write
+// the same atlas.
+func TestGroupRectangles(t *testing.T) {
+	opts := Options{GroupBy: "dir", FileDir: "assets"}
+	var rectangles []Rectangle
+	for _, name := range []string{"ui/icons/a.png", "chars/icons/b.png", "chars/c.png", "d.png"} {
+		rectangles = append(rectangles, Rectangle{Name: name})
+	}
+	names, groups, err := groupRectangles(rectangles, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"assets", "chars", "chars_icons", "ui_icons"}; !slices.Equal(names, want) {
+		t.Errorf("groups %q, want %q", names, want)
+	}
+	if got := groups["chars_icons"]; len(got) != 1 || got[0].Name != "chars/icons/b.png" {
+		t.Errorf("group chars_icons holds %+v, want chars/icons/b.png", got)
+	}
+
+	for _, name := range []string{"ui_icons/e.png", "assets/f.png"} {
+		if _, _, err := groupRectangles(append(rectangles, Rectangle{Name: name}), opts); err == nil {
+			t.Errorf("%s: grouped without an error, sharing a group with another directory", name)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// Padding is the gap, in pixels, left between packed sprites: X between
+// neighbours on the same shelf and Y between consecutive shelves. Every
+// packer keeps any two sprites at least X apart horizontally or Y apart
+// vertically, so sprites on adjacent shelves are never closer than that
+// diagonally either.
+type Padding struct {
+	X int
+	Y int
+}
+
+// String formats the padding as accepted by Set, implementing flag.Value.
+func (p *Padding) String() string {
+	if p.X == p.Y {
+		return strconv.Itoa(p.X)
+	}
+	return fmt.Sprintf("%d,%d", p.X, p.Y)
+}
+
+// Set parses either a single value used for both directions or an "X,Y"
+// pair, implementing flag.Value.
+func (p *Padding) Set(value string) error {
+	parts := strings.Split(value, ",")
+	if len(parts) > 2 {
+		return fmt.Errorf("invalid padding %q: want N or X,Y", value)
+	}
+
+	values := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid padding %q: values must be non-negative integers", value)
+		}
+		values[i] = n
+	}
+
+	p.X, p.Y = values[0], values[len(values)-1]
+	return nil
+}
+
+// withBorder returns the layout moved border pixels right and down and grown
+// by border pixels on every side, so that no sprite comes closer than that
+// to an edge of the atlas, recording the margin in the layout.
+func withBorder(layout Layout, border int) Layout {
+	offset := image.Pt(border, border)
+	placements := make(map[int]image.Rectangle, len(layout.Placements))
+	for id, r := range layout.Placements {
+		placements[id] = r.Add(offset)
+	}
+	layout.Placements = placements
+	if layout.Rows != nil {
+		rows := make([]StripRow, len(layout.Rows))
+		for i, row := range layout.Rows {
+			row.Y += border
+			rows[i] = row
+		}
+		layout.Rows = rows
+	}
+	if !layout.Reserved.Empty() {
+		layout.Reserved = layout.Reserved.Add(offset)
+	}
+	layout.Width += 2 * border
+	layout.Height += 2 * border
+	layout.Border = border
+	return layout
+}
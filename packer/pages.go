@@ -0,0 +1,196 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+)
+
+// boundsError is the error of a layout that came out larger than the
+// -maxwidth and -maxheight bounds, though every sprite fits within them on
+// its own, which paginate answers by starting another page.
+type boundsError struct {
+	Size  image.Point
+	Limit image.Point
+}
+
+// Error describes the layout's size and the bounds it exceeds.
+func (e *boundsError) Error() string {
+	return fmt.Sprintf("atlas would be %dx%d, larger than the -maxwidth and -maxheight bounds of %dx%d", e.Size.X, e.Size.Y, e.Limit.X, e.Limit.Y)
+}
+
+// paginate splits the rectangles of one atlas into pages, each packed into
+// its own image. With opts.MaxPerPage set a new page is started once a page
+// holds MaxPerPage sprites, however much space is left on it. Then every
+// page whose layout would outgrow the -maxwidth and -maxheight bounds keeps
+// as many of its sprites as fit and spills the rest onto the pages after
+// it. Rectangles are paged in the order their packer places them: by height
+// for shelf packing and by name for cells and strips. With strips, an
+// animation is never split across pages; a page is closed early rather than
+// break one, and an animation with more frames than MaxPerPage is an error.
+// So is needing more than opts.MaxPages pages, when it is set, a sprite
+// too large for a page of its own, and several pages when a name template
+// has no {page} token to tell them apart.
+func paginate(rectangles []Rectangle, opts Options) ([][]Rectangle, error) {
+	pages, err := paginateCount(rectangles, opts)
+	if err != nil {
+		return nil, err
+	}
+	if pages, err = paginateSize(pages, opts); err != nil {
+		return nil, err
+	}
+	if len(pages) > 1 && !opts.TextureArray {
+		for _, template := range []struct{ flag, value string }{{"-nametemplate", opts.NameTemplate}, {"-manifest", opts.ManifestTemplate}} {
+			if template.value != "" && !strings.Contains(template.value, "{page}") {
+				return nil, fmt.Errorf("sprites need %d pages within the -maxwidth and -maxheight bounds, but %s %q has no {page} token to name them by", len(pages), template.flag, template.value)
+			}
+		}
+	}
+	return pages, checkPageLimit(pages, opts.MaxPages)
+}
+
+// pageOrder returns the rectangles in the order they are paged in, for
+// cells and strips by name, animations kept together, and otherwise as
+// given.
+func pageOrder(rectangles []Rectangle, opts Options) []Rectangle {
+	ordered := make([]Rectangle, len(rectangles))
+	copy(ordered, rectangles)
+	if opts.Strips || !opts.Cell.IsZero() {
+		key := func(r Rectangle) string { return r.Name }
+		if opts.Strips {
+			key = func(r Rectangle) string { return animationName(opts.AnimRegex, r.Name) }
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if ki, kj := key(ordered[i]), key(ordered[j]); ki != kj {
+				return ki < kj
+			}
+			return ordered[i].Name < ordered[j].Name
+		})
+	}
+	return ordered
+}
+
+// paginateSize splits each page whose layout outgrows the size bounds,
+// moving the sprites after the longest leading run that fits, found by
+// bisection, to a new page after it; with strips a run only ends at the
+// end of an animation. Pages already within the bounds are kept as they
+// are.
+func paginateSize(pages [][]Rectangle, opts Options) ([][]Rectangle, error) {
+	if opts.Mask != nil || opts.Canvas != nil {
+		return pages, nil
+	}
+	var sized [][]Rectangle
+	for _, page := range pages {
+		var bounds *boundsError
+		_, err := planLayout(page, opts)
+		if err == nil || !errors.As(err, &bounds) {
+			sized = append(sized, page)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		rest := pageOrder(page, opts)
+		for len(rest) > 0 {
+			cuts := pageCuts(rest, opts)
+			// Find the most sprites, cuts[lo], that still fit; cuts[0] is 0.
+			lo, hi := 0, len(cuts)-1
+			for lo < hi {
+				mid := (lo + hi + 1) / 2
+				if _, err := planLayout(rest[:cuts[mid]], opts); err == nil {
+					lo = mid
+				} else if errors.As(err, &bounds) {
+					hi = mid - 1
+				} else {
+					return nil, err
+				}
+			}
+			if lo == 0 {
+				// Even the first sprite or animation alone is too large.
+				_, err := planLayout(rest[:cuts[min(1, len(cuts)-1)]], opts)
+				return nil, err
+			}
+			n := cuts[lo]
+			sized = append(sized, rest[:n:n])
+			rest = rest[n:]
+		}
+	}
+	return sized, nil
+}
+
+// pageCuts returns the numbers of leading rectangles a page may end after,
+// in increasing order from 0 to all of them: any number, or with strips
+// only the ends of animations.
+func pageCuts(rectangles []Rectangle, opts Options) []int {
+	cuts := []int{0}
+	for i := 1; i <= len(rectangles); i++ {
+		if opts.Strips && i < len(rectangles) &&
+			animationName(opts.AnimRegex, rectangles[i].Name) == animationName(opts.AnimRegex, rectangles[i-1].Name) {
+			continue
+		}
+		cuts = append(cuts, i)
+	}
+	return cuts
+}
+
+// paginateCount splits the rectangles into pages of at most
+// opts.MaxPerPage sprites, or puts them all on one page with MaxPerPage
+// unset.
+func paginateCount(rectangles []Rectangle, opts Options) ([][]Rectangle, error) {
+	if opts.MaxPerPage <= 0 || len(rectangles) <= opts.MaxPerPage {
+		return [][]Rectangle{rectangles}, nil
+	}
+
+	ordered := pageOrder(rectangles, opts)
+	if !opts.Strips {
+		var pages [][]Rectangle
+		for len(ordered) > 0 {
+			n := min(opts.MaxPerPage, len(ordered))
+			pages = append(pages, ordered[:n:n])
+			ordered = ordered[n:]
+		}
+		return pages, nil
+	}
+
+	var pages [][]Rectangle
+	var page []Rectangle
+	for start := 0; start < len(ordered); {
+		name := animationName(opts.AnimRegex, ordered[start].Name)
+		end := start + 1
+		for end < len(ordered) && animationName(opts.AnimRegex, ordered[end].Name) == name {
+			end++
+		}
+		frames := ordered[start:end]
+		if len(frames) > opts.MaxPerPage {
+			return nil, fmt.Errorf("animation %q has %d frames, more than -maxperpage %d", name, len(frames), opts.MaxPerPage)
+		}
+		if len(page)+len(frames) > opts.MaxPerPage {
+			pages = append(pages, page)
+			page = nil
+		}
+		page = append(page, frames...)
+		start = end
+	}
+	pages = append(pages, page)
+	return pages, nil
+}
+
+// checkPageLimit fails when there are more than maxPages pages, naming the
+// sprites on the pages beyond the limit, which could not be placed. A
+// maxPages of 0 or less sets no limit.
+func checkPageLimit(pages [][]Rectangle, maxPages int) error {
+	if maxPages <= 0 || len(pages) <= maxPages {
+		return nil
+	}
+	var unplaced []string
+	for _, page := range pages[maxPages:] {
+		for _, rect := range page {
+			unplaced = append(unplaced, rect.Name)
+		}
+	}
+	sort.Strings(unplaced)
+	return fmt.Errorf("sprites need %d pages, more than -maxpages %d; %d sprites could not be placed: %s",
+		len(pages), maxPages, len(unplaced), strings.Join(unplaced, ", "))
+}
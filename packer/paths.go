@@ -0,0 +1,120 @@
+package packer
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The -pathmode values, choosing how sprite names are written to manifests.
+const (
+	PathModeBase     = "base"
+	PathModeRelative = "relative"
+	PathModeAbsolute = "absolute"
+)
+
+// spritePath returns the name a sprite is listed under for the path mode:
+// its base name, its name as found under -filedir, or the absolute path it
+// would have on disk, all with forward slashes. With several -filedir
+// directories the name's first element is the base name of the directory it
+// came from.
+func spritePath(name, mode string, dirs []string) (string, error) {
+	switch mode {
+	case PathModeBase:
+		return path.Base(name), nil
+	case PathModeAbsolute:
+		dir, rest := dirs[0], name
+		if len(dirs) > 1 {
+			var prefix string
+			prefix, rest, _ = strings.Cut(name, "/")
+			dir = ""
+			for _, d := range dirs {
+				if filepath.Base(filepath.Clean(d)) == prefix {
+					dir = d
+				}
+			}
+			if dir == "" {
+				return "", fmt.Errorf("sprite %s is not under any -filedir directory", name)
+			}
+		}
+		abs, err := filepath.Abs(filepath.Join(dir, filepath.FromSlash(rest)))
+		if err != nil {
+			return "", err
+		}
+		return filepath.ToSlash(abs), nil
+	}
+	return name, nil
+}
+
+// spriteName returns the name a sprite is listed under: its path for
+// opts.PathMode, then with every match of opts.NameRegex replaced by
+// opts.NameReplace, as regexp.ReplaceAllString expands it.
+func spriteName(name string, dirs []string, opts Options) (string, error) {
+	name, err := spritePath(name, opts.PathMode, dirs)
+	if err != nil || opts.NameRegex == nil {
+		return name, err
+	}
+	return opts.NameRegex.ReplaceAllString(name, opts.NameReplace), nil
+}
+
+// renameSprites renames the rectangles, and the animations their frames
+// belong to, for opts.PathMode and opts.NameRegex. Two sprites given the
+// same name, as files of the same name in different directories are with
+// base names, are an error.
+func renameSprites(rectangles []Rectangle, opts Options) error {
+	if (opts.PathMode == "" || opts.PathMode == PathModeRelative) && opts.NameRegex == nil {
+		return nil
+	}
+	dirs := SplitList(opts.FileDir)
+	from := make(map[string]string, len(rectangles))
+	for i := range rectangles {
+		rect := &rectangles[i]
+		name, err := spriteName(rect.Name, dirs, opts)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return fmt.Errorf("sprite %s would have an empty name after -nameregex", rect.Name)
+		}
+		if other, ok := from[name]; ok {
+			first, second := min(rect.Name, other), max(rect.Name, other)
+			return fmt.Errorf("sprites %s and %s would both be named %s", first, second, name)
+		}
+		from[name] = rect.Name
+		rect.Name = name
+		if rect.Frame != nil {
+			frame := *rect.Frame
+			if frame.Animation, err = spriteName(frame.Animation, dirs, opts); err != nil {
+				return err
+			}
+			rect.Frame = &frame
+		}
+	}
+	return nil
+}
+
+// checkUniqueNames fails if sprites in different directories share a base
+// name, listing every such name with the sprites that have it, so
+// collisions can be resolved before consumers that key sprites by file
+// name alone see one replace another.
+func checkUniqueNames(rectangles []Rectangle) error {
+	byBase := make(map[string][]string)
+	for _, rect := range rectangles {
+		base := path.Base(rect.Name)
+		byBase[base] = append(byBase[base], rect.Name)
+	}
+	var collisions []string
+	for base, names := range byBase {
+		if len(names) > 1 {
+			sort.Strings(names)
+			collisions = append(collisions, fmt.Sprintf("%s (%s)", base, strings.Join(names, ", ")))
+		}
+	}
+	if len(collisions) == 0 {
+		return nil
+	}
+	sort.Strings(collisions)
+	return fmt.Errorf("sprites share base names: %s", strings.Join(collisions, "; "))
+}
@@ -0,0 +1,71 @@
+package packer
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// placementsSuffix and placementsExtension name the placement dump written
+// beside a manifest with -placementdump.
+const (
+	placementsSuffix    = "_placements"
+	placementsExtension = ".txt"
+)
+
+// placementsFilename returns the filename of the placement dump written
+// for a manifest with -placementdump.
+func placementsFilename(manifestFile string) string {
+	return strings.TrimSuffix(manifestFile, filepath.Ext(manifestFile)) + placementsSuffix + placementsExtension
+}
+
+// placementDump returns the manifest's placements as text meant to be
+// committed and diffed: a header naming the columns, then one line per
+// sprite, sorted by name, giving its name, x, y, w and h as written to the
+// manifest, whether it is rotated and whether it was trimmed, as 0 or 1,
+// and for texture arrays its layer. Fields are separated by single spaces
+// without alignment, so a change to one sprite changes only its line;
+// names containing spaces or quotes are quoted as Go strings.
+func placementDump(manifest Manifest) []byte {
+	names := make([]string, 0, len(manifest.Sprites))
+	layered := false
+	for name, entry := range manifest.Sprites {
+		names = append(names, name)
+		layered = layered || entry.Layer != nil
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("# name x y w h rotated trimmed")
+	if layered {
+		buf.WriteString(" layer")
+	}
+	buf.WriteByte('\n')
+	bit := func(set bool) int {
+		if set {
+			return 1
+		}
+		return 0
+	}
+	for _, name := range names {
+		entry := manifest.Sprites[name]
+		if strings.ContainsAny(name, " \t\"\\") || !strconv.CanBackquote(name) {
+			name = strconv.Quote(name)
+		}
+		fmt.Fprintf(&buf, "%s %d %d %d %d %d %d", name, entry.X, entry.Y, entry.W, entry.H, bit(entry.Rotated), bit(entry.Trim != nil))
+		if layered {
+			fmt.Fprintf(&buf, " %d", *entry.Layer)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// savePlacementDump writes the manifest's placement dump to filename,
+// failing if it exists unless overwrite is set.
+func savePlacementDump(filename string, manifest Manifest, overwrite bool) error {
+	return writeOutput(filename, placementDump(manifest), overwrite)
+}
@@ -0,0 +1,48 @@
+package packer
+
+import (
+	"context"
+	"image"
+	"strings"
+	"testing"
+)
+
+// TestValidatePlacements checks that a layout missing a sprite, or holding
+// a placement for none, is rejected by validatePlacements and so by
+// drawAtlas, which would otherwise leave the sprite out of the atlas.
+func TestValidatePlacements(t *testing.T) {
+	rectangles := []Rectangle{
+		{ID: 1, Name: "a.png", Width: 4, Height: 4, Image: patterned(4, 4, 1)},
+		{ID: 2, Name: "b.png", Width: 4, Height: 4, Image: patterned(4, 4, 2)},
+	}
+	placed := Layout{
+		Placements: map[int]image.Rectangle{1: image.Rect(0, 0, 4, 4), 2: image.Rect(4, 0, 8, 4)},
+		Width:      8,
+		Height:     4,
+	}
+	if err := validatePlacements(rectangles, placed); err != nil {
+		t.Fatalf("complete layout rejected: %v", err)
+	}
+	if _, err := drawAtlas(context.Background(), rectangles, placed, Options{}); err != nil {
+		t.Fatalf("drawing complete layout: %v", err)
+	}
+
+	missing := placed
+	missing.Placements = map[int]image.Rectangle{1: image.Rect(0, 0, 4, 4)}
+	err := validatePlacements(rectangles, missing)
+	if err == nil || !strings.Contains(err.Error(), "b.png") {
+		t.Errorf("layout without b.png: got error %v, want one naming b.png", err)
+	}
+	if _, err := drawAtlas(context.Background(), rectangles, missing, Options{}); err == nil {
+		t.Error("drawAtlas drew a layout without b.png")
+	}
+
+	extra := placed
+	extra.Placements = map[int]image.Rectangle{1: image.Rect(0, 0, 4, 4), 2: image.Rect(4, 0, 8, 4), 3: image.Rect(0, 4, 4, 8)}
+	if err := validatePlacements(rectangles, extra); err == nil {
+		t.Error("layout with a placement for no sprite accepted")
+	}
+	if _, err := drawAtlas(context.Background(), rectangles, extra, Options{}); err == nil {
+		t.Error("drawAtlas drew a layout with a placement for no sprite")
+	}
+}
@@ -0,0 +1,180 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"runtime"
+)
+
+// loadImageConfig reads only the header of an image file, returning its
+// dimensions and color model without decoding any pixel data. Transient
+// failures are retried as loadImage retries them.
+func loadImageConfig(ctx context.Context, file string, opts Options) (image.Config, error) {
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		config, err := decodeImageConfig(ctx, file, opts)
+		if err == nil || attempt >= opts.Retries || !isTransient(err) || ctx.Err() != nil {
+			return config, err
+		}
+		Warnf("reading the header of %s failed: %v; retrying in %s", file, err, delay)
+		if err := sleepContext(ctx, delay); err != nil {
+			return image.Config{}, err
+		}
+		delay *= 2
+	}
+}
+
+// decodeImageConfig makes a single attempt at reading the header of an
+// image file, marking failures to open or read it as transient. The size
+// of an SVG file is the one decodeImage rasterizes it at.
+func decodeImageConfig(ctx context.Context, file string, opts Options) (image.Config, error) {
+	f, err := openTransient(opts.FS, file)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer f.Close()
+
+	r := &errorRecorder{r: f}
+	var config image.Config
+	if isSVGFile(file) {
+		config, err = decodeSVGConfig(contextReader{ctx, r}, opts.SVGSize)
+	} else {
+		config, _, err = image.DecodeConfig(contextReader{ctx, r})
+	}
+	if err != nil && r.err != nil {
+		return image.Config{}, transientError{fmt.Errorf("failed to read image header: %w", r.err)}
+	}
+	if err != nil {
+		return image.Config{}, fmt.Errorf("failed to decode image header: %w", err)
+	}
+	return config, nil
+}
+
+// loadImageSizes reads the dimensions of image files concurrently from their
+// headers and returns rectangles in the same order, with the same IDs, names
+// and sizes that loadImages would produce for opts, but without image data.
+// The sizes go through the same steps as the pixels would: crops, the
+// marker border of nine-patches, -scale, -resize, fitting to -cell and the
+// -sdf spread. Options that depend on the pixels themselves, -trim,
+// -skipempty, -mirrorhalves and -dedup, cannot be planned for. The result
+// can be fed to planLayout to find out how the atlas will be laid out before
+// committing to decoding every image. With opts.SkipBad, files whose header
+// cannot be read are left out with a warning. Reading stops once ctx is
+// done, returning the cause.
+func loadImageSizes(ctx context.Context, files []string, opts Options) ([]Rectangle, error) {
+	sources, err := spriteSources(files, opts.Crops, opts.animations)
+	if err != nil {
+		return nil, err
+	}
+	rectangles := make([]Rectangle, len(sources))
+	skipped := make([]string, len(sources))
+	errs := make([]error, len(sources))
+
+	err = runPool(ctx, len(sources), runtime.NumCPU(), func(i int) {
+		source := sources[i]
+		file := source.File
+		var config image.Config
+		var err error
+		if source.Frame != nil {
+			anim := opts.animations[file]
+			config = image.Config{Width: anim.Width, Height: anim.Height}
+		} else {
+			config, err = loadImageConfig(ctx, file, opts)
+		}
+		if err != nil && opts.SkipBad && ctx.Err() == nil {
+			skipped[i] = err.Error()
+			return
+		}
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to read image %s: %w", file, err)
+			return
+		}
+		w, h := config.Width, config.Height
+		if source.Crop != nil {
+			w, h = source.Crop.W, source.Crop.H
+			if source.Crop.Rotated {
+				w, h = source.Crop.H, source.Crop.W
+			}
+		}
+		name, info := parseSpriteName(source.Name, opts.NameConventions)
+		if info.nineSlice {
+			if w < 3 || h < 3 {
+				err := fmt.Errorf("nine-patch is %dx%d, too small to have a marker border", w, h)
+				if opts.SkipBad {
+					skipped[i] = err.Error()
+				} else {
+					errs[i] = fmt.Errorf("%s: %w", source.Name, err)
+				}
+				return
+			}
+			w, h = w-2, h-2
+		}
+		rect := Rectangle{ID: i + 1, Name: name, Width: w, Height: h, Frame: source.Frame}
+		applyNameInfo(&rect, info)
+		if opts.Scale > 1 {
+			rect.Width, rect.Height = rect.Width*opts.Scale, rect.Height*opts.Scale
+		}
+		if box, ok := opts.Resize.match(source.Name); ok {
+			rect.Width, rect.Height = fitSize(rect.Width, rect.Height, box)
+		}
+		if !opts.Cell.IsZero() && (rect.Width > opts.Cell.W || rect.Height > opts.Cell.H) {
+			rect.Width, rect.Height = fitSize(rect.Width, rect.Height, opts.Cell)
+		}
+		if opts.SDF {
+			rect.Width += 2 * opts.SDFSpread
+			rect.Height += 2 * opts.SDFSpread
+		}
+		rectangles[i] = rect
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	rectangles = dropSkipped(rectangles, spriteNames(sources), skipped)
+	sortRectangles(rectangles, opts.Sort)
+	return rectangles, nil
+}
+
+// planAtlases lays out every atlas using image headers only and prints the
+// planned dimensions and occupancy of each, without decoding or writing
+// anything. It returns the error that stopped the plan, if any.
+func planAtlases(ctx context.Context, files []string, opts Options) error {
+	rectangles, err := loadImageSizes(ctx, files, opts)
+	if err != nil {
+		return taskFailed("reading image sizes", err)
+	}
+
+	names, groups, err := groupRectangles(rectangles, opts)
+	if err != nil {
+		return taskFailed("grouping sprites", err)
+	}
+	for _, name := range names {
+		opts := opts
+		if opts.AutoSize {
+			size, err := autoSize(groups[name], opts)
+			if err != nil {
+				return taskFailed("choosing atlas size", err)
+			}
+			opts.MaxWidth, opts.MaxHeight = size, size
+		}
+		pages, err := paginate(groups[name], opts)
+		if err != nil {
+			return taskFailed("paging sprites", err)
+		}
+		for page, pageRectangles := range pages {
+			layout, err := planLayout(pageRectangles, opts)
+			if err != nil {
+				return taskFailed("planning atlas", err)
+			}
+			atlasFile := AtlasFilename(opts.AtlasName, opts.AtlasExtension, opts.NameTemplate, name, page, AtlasKindDiffuse)
+			fmt.Printf("Planned %s: %d x %d, %d sprites, occupancy %.1f%%\n",
+				atlasFile, layout.Width, layout.Height, len(pageRectangles), occupancy(pageRectangles, layout)*100)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// ninePatch returns a w by h nine-patch: a transparent image whose 1-pixel
+// border marks its middle row and column as stretchable.
+func ninePatch(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	black := color.NRGBA{A: 255}
+	img.SetNRGBA(w/2, 0, black)
+	img.SetNRGBA(0, h/2, black)
+	return img
+}
+
+// TestLoadImageSizes checks that the sizes planned from image headers are
+// those loadImages gives the decoded images, with the options that change
+// sizes without depending on the pixels.
+func TestLoadImageSizes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.png":       pngFile(t, patterned(30, 12, 1)),
+		"b.png":       pngFile(t, patterned(7, 9, 2)),
+		"panel.9.png": pngFile(t, ninePatch(12, 10)),
+	}
+	files := []string{"a.png", "b.png", "panel.9.png"}
+	conventions, err := ParseNameConventions(ConventionNineSlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := Options{MaxWidth: 256, MaxHeight: 256, FS: fsys, Alpha: AlphaStraight, Scale: 1, NameConventions: conventions}
+	for _, tc := range []struct {
+		name string
+		set  func(*Options)
+	}{
+		{"plain", func(*Options) {}},
+		{"cell", func(opts *Options) { opts.Cell = Size{W: 16, H: 16} }},
+		{"scale", func(opts *Options) { opts.Scale = 2 }},
+		{"sdf", func(opts *Options) { opts.SDF, opts.SDFSpread = true, 3 }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := base
+			tc.set(&opts)
+			planned, err := loadImageSizes(context.Background(), files, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := loadImages(context.Background(), files, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(planned) != len(loaded) {
+				t.Fatalf("planned %d sprites, loaded %d", len(planned), len(loaded))
+			}
+			for i := range loaded {
+				p, l := planned[i], loaded[i]
+				if p.ID != l.ID || p.Name != l.Name || p.Width != l.Width || p.Height != l.Height {
+					t.Errorf("planned %d %s %dx%d, loaded %d %s %dx%d", p.ID, p.Name, p.Width, p.Height, l.ID, l.Name, l.Width, l.Height)
+				}
+			}
+		})
+	}
+}
+
+// flakyFS fails to open each file the first failures times with an error
+// that is neither a missing file nor a permission problem.
+type flakyFS struct {
+	fs.FS
+	failures int
+	opened   map[string]int
+}
+
+// Open fails the first failures opens of each file.
+func (f *flakyFS) Open(name string) (fs.File, error) {
+	f.opened[name]++
+	if f.opened[name] <= f.failures {
+		return nil, errors.New("device busy")
+	}
+	return f.FS.Open(name)
+}
+
+// TestLoadImageConfigRetries checks that reading a header is retried after
+// a transient failure as -retries allows, and fails without retries.
+func TestLoadImageConfigRetries(t *testing.T) {
+	fsys := &flakyFS{FS: fstest.MapFS{"a.png": pngFile(t, patterned(5, 6, 1))}, failures: 1, opened: map[string]int{}}
+	if _, err := loadImageConfig(context.Background(), "a.png", Options{FS: fsys}); err == nil {
+		t.Fatal("header read despite a failed open and no retries")
+	}
+	fsys.opened = map[string]int{}
+	config, err := loadImageConfig(context.Background(), "a.png", Options{FS: fsys, Retries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 5 || config.Height != 6 {
+		t.Errorf("header gives %dx%d, want 5x6", config.Width, config.Height)
+	}
+}
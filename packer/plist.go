@@ -0,0 +1,237 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// plistHeader opens every property list, as Apple's tools write it.
+const plistHeader = xml.Header + `<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n"
+
+// plistManifest renders the placements of a manifest as a property list in
+// format 2 of TexturePacker's cocos2d exporter, which cocos2d-x and several
+// Unity importers read: a frames dictionary holding, for each sprite in
+// name order, its frame in the atlas at its own size before any turn, the
+// sprite's rotated flag, its sourceColorRect within the source image and
+// sourceSize, and the offset of its center from that of the source image,
+// with y pointing up. A metadata dictionary names the atlas image and gives
+// its size. Fields beyond placement, trimming and rotation are left out.
+func plistManifest(manifest Manifest) []byte {
+	names := make([]string, 0, len(manifest.Sprites))
+	for name := range manifest.Sprites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString(plistHeader)
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	writePlistKey(&b, 1, "frames")
+	b.WriteString("\t<dict>\n")
+	for _, name := range names {
+		entry := manifest.Sprites[name]
+		w, h := entry.W, entry.H
+		if entry.Rotated {
+			w, h = h, w
+		}
+		trim := TrimEntry{SourceW: w, SourceH: h}
+		if entry.Trim != nil {
+			trim = *entry.Trim
+		}
+		offsetX := float64(trim.X) + float64(w)/2 - float64(trim.SourceW)/2
+		offsetY := float64(trim.SourceH)/2 - float64(trim.Y) - float64(h)/2
+
+		writePlistKey(&b, 2, name)
+		b.WriteString("\t\t<dict>\n")
+		writePlistString(&b, 3, "frame", fmt.Sprintf("{{%d,%d},{%d,%d}}", entry.X, entry.Y, w, h))
+		writePlistString(&b, 3, "offset", fmt.Sprintf("{%s,%s}", plistNumber(offsetX), plistNumber(offsetY)))
+		writePlistKey(&b, 3, "rotated")
+		fmt.Fprintf(&b, "\t\t\t<%t/>\n", entry.Rotated)
+		writePlistString(&b, 3, "sourceColorRect", fmt.Sprintf("{{%d,%d},{%d,%d}}", trim.X, trim.Y, w, h))
+		writePlistString(&b, 3, "sourceSize", fmt.Sprintf("{%d,%d}", trim.SourceW, trim.SourceH))
+		b.WriteString("\t\t</dict>\n")
+	}
+	b.WriteString("\t</dict>\n")
+	writePlistKey(&b, 1, "metadata")
+	b.WriteString("\t<dict>\n")
+	writePlistKey(&b, 2, "format")
+	b.WriteString("\t\t<integer>2</integer>\n")
+	writePlistString(&b, 2, "realTextureFileName", manifest.Image)
+	writePlistString(&b, 2, "size", fmt.Sprintf("{%d,%d}", manifest.Width, manifest.Height))
+	writePlistString(&b, 2, "textureFileName", manifest.Image)
+	b.WriteString("\t</dict>\n</dict>\n</plist>\n")
+	return b.Bytes()
+}
+
+// writePlistKey writes a key element indented by depth tabs.
+func writePlistKey(b *bytes.Buffer, depth int, key string) {
+	b.WriteString(strings.Repeat("\t", depth) + "<key>")
+	xml.EscapeText(b, []byte(key))
+	b.WriteString("</key>\n")
+}
+
+// writePlistString writes a key element and the string element holding its
+// value, both indented by depth tabs.
+func writePlistString(b *bytes.Buffer, depth int, key, value string) {
+	writePlistKey(b, depth, key)
+	b.WriteString(strings.Repeat("\t", depth) + "<string>")
+	xml.EscapeText(b, []byte(value))
+	b.WriteString("</string>\n")
+}
+
+// plistNumber formats an offset, which is a whole or half pixel, as
+// briefly as possible.
+func plistNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// parsePlistManifest reads a manifest written by plistManifest back into a
+// Manifest holding the fields it records: the atlas image and size, and for
+// each sprite the region it occupies, whether it is turned and, when its
+// source color rectangle is not the whole of its source, its trim.
+func parsePlistManifest(data []byte) (Manifest, error) {
+	root, err := parsePlist(data)
+	if err != nil {
+		return Manifest{}, err
+	}
+	frames, ok := root["frames"].(map[string]any)
+	if !ok {
+		return Manifest{}, errors.New("no frames dictionary")
+	}
+	metadata, ok := root["metadata"].(map[string]any)
+	if !ok {
+		return Manifest{}, errors.New("no metadata dictionary")
+	}
+	image, _ := metadata["textureFileName"].(string)
+	size, err := plistNumbers(metadata["size"], 2)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("metadata size: %w", err)
+	}
+
+	manifest := Manifest{Image: image, Width: size[0], Height: size[1], Sprites: make(map[string]SpriteEntry, len(frames))}
+	for name, value := range frames {
+		frame, ok := value.(map[string]any)
+		if !ok {
+			return Manifest{}, fmt.Errorf("frame %s is not a dictionary", name)
+		}
+		rect, err := plistNumbers(frame["frame"], 4)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("frame %s: %w", name, err)
+		}
+		colorRect, err := plistNumbers(frame["sourceColorRect"], 4)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("frame %s: sourceColorRect: %w", name, err)
+		}
+		source, err := plistNumbers(frame["sourceSize"], 2)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("frame %s: sourceSize: %w", name, err)
+		}
+		rotated, _ := frame["rotated"].(bool)
+
+		entry := SpriteEntry{X: rect[0], Y: rect[1], W: rect[2], H: rect[3], Rotated: rotated}
+		if rotated {
+			entry.W, entry.H = entry.H, entry.W
+		}
+		if colorRect[0] != 0 || colorRect[1] != 0 || source[0] != rect[2] || source[1] != rect[3] {
+			entry.Trim = &TrimEntry{X: colorRect[0], Y: colorRect[1], SourceW: source[0], SourceH: source[1]}
+		}
+		manifest.Sprites[name] = entry
+	}
+	return manifest, nil
+}
+
+// plistNumbers parses a string of n whole numbers in braces, such as the
+// "{{x,y},{w,h}}" of a rectangle or the "{w,h}" of a size.
+func plistNumbers(value any, n int) ([]int, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("missing or not a string")
+	}
+	fields := strings.Split(strings.NewReplacer("{", "", "}", "").Replace(s), ",")
+	if len(fields) != n {
+		return nil, fmt.Errorf("invalid value %q: want %d numbers", s, n)
+	}
+	numbers := make([]int, n)
+	for i, field := range fields {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q: %w", s, err)
+		}
+		numbers[i] = v
+	}
+	return numbers, nil
+}
+
+// parsePlist decodes a property list whose root is a dictionary, as
+// parsePlistValue decodes its values.
+func parsePlist(data []byte) (map[string]any, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local == "plist" {
+			continue
+		}
+		value, err := parsePlistValue(d, start)
+		if err != nil {
+			return nil, err
+		}
+		root, ok := value.(map[string]any)
+		if !ok {
+			return nil, errors.New("root of property list is not a dictionary")
+		}
+		return root, nil
+	}
+}
+
+// parsePlistValue decodes the property list element that start opens: a
+// dict as a map from its keys to their values, true and false as bools, and
+// string, integer and real elements as their text.
+func parsePlistValue(d *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case "true", "false":
+		return start.Name.Local == "true", d.Skip()
+	case "string", "integer", "real":
+		var text string
+		err := d.DecodeElement(&text, &start)
+		return text, err
+	case "dict":
+		dict := make(map[string]any)
+		var key string
+		for {
+			tok, err := d.Token()
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return nil, err
+			}
+			switch tok := tok.(type) {
+			case xml.EndElement:
+				return dict, nil
+			case xml.StartElement:
+				if tok.Name.Local == "key" {
+					if err := d.DecodeElement(&key, &tok); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				value, err := parsePlistValue(d, tok)
+				if err != nil {
+					return nil, err
+				}
+				dict[key] = value
+			}
+		}
+	}
+	return nil, fmt.Errorf("unsupported property list element <%s>", start.Name.Local)
+}
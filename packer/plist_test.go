@@ -0,0 +1,40 @@
+package packer
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestPlistRoundTrip checks that parsePlistManifest reads back what
+// plistManifest writes: the atlas and, for sprites trimmed, turned, both or
+// neither, their regions, turns and trims, with names that need escaping.
+func TestPlistRoundTrip(t *testing.T) {
+	manifest := Manifest{
+		Image:  "atlas & co.png",
+		Width:  64,
+		Height: 32,
+		Sprites: map[string]SpriteEntry{
+			"plain.png":   {X: 0, Y: 0, W: 10, H: 6},
+			"trimmed.png": {X: 10, Y: 0, W: 7, H: 5, Trim: &TrimEntry{X: 2, Y: 1, SourceW: 12, SourceH: 8}},
+			"turned.png":  {X: 17, Y: 0, W: 4, H: 12, Rotated: true},
+			"<both>.png":  {X: 21, Y: 0, W: 3, H: 9, Rotated: true, Trim: &TrimEntry{X: 1, Y: 3, SourceW: 11, SourceH: 6}},
+		},
+	}
+	got, err := parsePlistManifest(plistManifest(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Image != manifest.Image || got.Width != manifest.Width || got.Height != manifest.Height {
+		t.Errorf("atlas read back as %q %dx%d, want %q %dx%d", got.Image, got.Width, got.Height, manifest.Image, manifest.Width, manifest.Height)
+	}
+	if len(got.Sprites) != len(manifest.Sprites) {
+		t.Errorf("read back %d sprites, want %d", len(got.Sprites), len(manifest.Sprites))
+	}
+	for name, want := range manifest.Sprites {
+		if entry, ok := got.Sprites[name]; !ok {
+			t.Errorf("%s not read back", name)
+		} else if !reflect.DeepEqual(entry, want) {
+			t.Errorf("%s read back as %+v trim %+v, want %+v trim %+v", name, entry, entry.Trim, want, want.Trim)
+		}
+	}
+}
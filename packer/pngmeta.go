@@ -0,0 +1,169 @@
+package packer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"unicode/utf8"
+)
+
+// pngSignature starts every PNG file.
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// metersPerInch converts the pixels-per-meter of a pHYs chunk to DPI.
+const metersPerInch = 0.0254
+
+// maxTextChunk bounds the size of a text chunk that readPNGMetadata reads,
+// so a malformed length cannot make it allocate without limit.
+const maxTextChunk = 1 << 20
+
+// DPI is the physical resolution of a sprite's source image, in dots per
+// inch horizontally and vertically.
+type DPI struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ImageMetadata holds the metadata read from a source image that the image
+// package does not expose: its resolution and its textual key-value pairs.
+type ImageMetadata struct {
+	DPI  *DPI
+	Text map[string]string
+}
+
+// readPNGMetadata reads the ancillary chunks before the image data of a PNG
+// file: a pHYs chunk with a unit of meters gives the DPI, and tEXt, zTXt and
+// iTXt chunks give the text. Files that are not PNGs have no metadata.
+func readPNGMetadata(fsys fs.FS, file string) (ImageMetadata, error) {
+	f, err := fsys.Open(file)
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, signature); err != nil || string(signature) != pngSignature {
+		return ImageMetadata{}, nil
+	}
+
+	var meta ImageMetadata
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return meta, nil
+			}
+			return ImageMetadata{}, err
+		}
+		length, kind := binary.BigEndian.Uint32(header[:4]), string(header[4:])
+		switch kind {
+		case "IDAT", "IEND":
+			return meta, nil
+		case "pHYs", "tEXt", "zTXt", "iTXt":
+			if length > maxTextChunk {
+				return ImageMetadata{}, fmt.Errorf("%s chunk of %d bytes is too large", kind, length)
+			}
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return ImageMetadata{}, err
+			}
+			if err := meta.addChunk(kind, data); err != nil {
+				return ImageMetadata{}, fmt.Errorf("invalid %s chunk: %w", kind, err)
+			}
+			length = 0
+		}
+		// Skip the rest of the chunk and its CRC.
+		if _, err := r.Discard(int(length) + 4); err != nil {
+			return ImageMetadata{}, err
+		}
+	}
+}
+
+// addChunk records what an ancillary chunk says about the image.
+func (m *ImageMetadata) addChunk(kind string, data []byte) error {
+	if kind == "pHYs" {
+		if len(data) != 9 {
+			return fmt.Errorf("length %d, want 9", len(data))
+		}
+		if data[8] == 1 {
+			m.DPI = &DPI{
+				X: float64(binary.BigEndian.Uint32(data[0:4])) * metersPerInch,
+				Y: float64(binary.BigEndian.Uint32(data[4:8])) * metersPerInch,
+			}
+		}
+		return nil
+	}
+
+	keyword, rest, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return errors.New("missing keyword terminator")
+	}
+	var text []byte
+	latin1 := true
+	switch kind {
+	case "tEXt":
+		text = rest
+	case "zTXt":
+		if len(rest) < 1 {
+			return errors.New("missing compression method")
+		}
+		inflated, err := inflate(rest[1:])
+		if err != nil {
+			return err
+		}
+		text = inflated
+	case "iTXt":
+		// Compression flag and method, then language tag and translated
+		// keyword, both terminated by a zero byte.
+		if len(rest) < 2 {
+			return errors.New("missing compression flag")
+		}
+		compressed := rest[0] == 1
+		parts := bytes.SplitN(rest[2:], []byte{0}, 3)
+		if len(parts) != 3 {
+			return errors.New("missing language or translated keyword")
+		}
+		text, latin1 = parts[2], false
+		if compressed {
+			inflated, err := inflate(text)
+			if err != nil {
+				return err
+			}
+			text = inflated
+		}
+	}
+	if m.Text == nil {
+		m.Text = make(map[string]string)
+	}
+	if latin1 {
+		text = latin1ToUTF8(text)
+	}
+	m.Text[string(latin1ToUTF8(keyword))] = string(text)
+	return nil
+}
+
+// latin1ToUTF8 converts ISO 8859-1 text, which tEXt and zTXt chunks and all
+// keywords use, to UTF-8.
+func latin1ToUTF8(text []byte) []byte {
+	out := make([]byte, 0, len(text))
+	for _, c := range text {
+		out = utf8.AppendRune(out, rune(c))
+	}
+	return out
+}
+
+// inflate decompresses zlib data from a text chunk, up to maxTextChunk bytes.
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(io.LimitReader(zr, maxTextChunk))
+}
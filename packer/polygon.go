@@ -0,0 +1,182 @@
+package packer
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// DefaultPolygonTolerance is the default maximum distance, in pixels, that a
+// simplified outline may stray from the traced silhouette.
+const DefaultPolygonTolerance = 1.5
+
+// traceOutlines returns the outlines of the visible regions of img: one
+// closed polygon per region of pixels with non-zero alpha, connected through
+// their edges. Vertices lie on pixel corners relative to the image's top-left
+// corner and run clockwise, and holes inside a region are ignored. Each
+// outline is simplified so it stays within tolerance pixels of the traced
+// silhouette; outlines that collapse to fewer than three vertices are
+// dropped.
+func traceOutlines(img image.Image, tolerance float64) [][][2]int {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	filled := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= w || y >= h {
+			return false
+		}
+		return color.NRGBA64Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA64).A != 0
+	}
+
+	// Collect the edges between visible and transparent pixels, directed so
+	// that the visible pixel is on the right. Each corner then has as many
+	// edges leaving it as arriving, and following them yields closed loops.
+	edges := make(map[[2]int][][2]int)
+	addEdge := func(from, to [2]int) { edges[from] = append(edges[from], to) }
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !filled(x, y) {
+				continue
+			}
+			if !filled(x, y-1) {
+				addEdge([2]int{x, y}, [2]int{x + 1, y})
+			}
+			if !filled(x+1, y) {
+				addEdge([2]int{x + 1, y}, [2]int{x + 1, y + 1})
+			}
+			if !filled(x, y+1) {
+				addEdge([2]int{x + 1, y + 1}, [2]int{x, y + 1})
+			}
+			if !filled(x-1, y) {
+				addEdge([2]int{x, y + 1}, [2]int{x, y})
+			}
+		}
+	}
+
+	var outlines [][][2]int
+	for y := 0; y <= h; y++ {
+		for x := 0; x <= w; x++ {
+			start := [2]int{x, y}
+			for len(edges[start]) > 0 {
+				loop := followLoop(edges, start)
+				if loopArea(loop) <= 0 {
+					continue // a hole
+				}
+				if outline := simplifyLoop(removeCollinear(loop), tolerance); len(outline) >= 3 {
+					outlines = append(outlines, outline)
+				}
+			}
+		}
+	}
+	return outlines
+}
+
+// followLoop walks and removes edges starting at start until it returns
+// there, and returns the corners visited. Where two loops touch at a corner,
+// the walk turns right, keeping pixels that only touch diagonally in
+// separate regions.
+func followLoop(edges map[[2]int][][2]int, start [2]int) [][2]int {
+	loop := [][2]int{start}
+	from, dir := start, [2]int{0, 0}
+	for {
+		outs := edges[from]
+		pick := 0
+		if len(outs) > 1 {
+			// Turning right from (dx, dy) in image coordinates heads (-dy, dx).
+			right := [2]int{from[0] - dir[1], from[1] + dir[0]}
+			for i, to := range outs {
+				if to == right {
+					pick = i
+				}
+			}
+		}
+		to := outs[pick]
+		edges[from] = append(outs[:pick:pick], outs[pick+1:]...)
+		if len(edges[from]) == 0 {
+			delete(edges, from)
+		}
+		if to == start {
+			return loop
+		}
+		loop = append(loop, to)
+		dir = [2]int{to[0] - from[0], to[1] - from[1]}
+		from = to
+	}
+}
+
+// loopArea returns twice the signed area of a closed loop, positive for
+// loops running clockwise in image coordinates.
+func loopArea(loop [][2]int) int {
+	area := 0
+	for i, p := range loop {
+		q := loop[(i+1)%len(loop)]
+		area += p[0]*q[1] - q[0]*p[1]
+	}
+	return area
+}
+
+// removeCollinear drops the vertices of a closed loop that lie on a straight
+// line between their neighbors.
+func removeCollinear(loop [][2]int) [][2]int {
+	var out [][2]int
+	for i, p := range loop {
+		prev, next := loop[(i+len(loop)-1)%len(loop)], loop[(i+1)%len(loop)]
+		cross := (p[0]-prev[0])*(next[1]-p[1]) - (p[1]-prev[1])*(next[0]-p[0])
+		if cross != 0 {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// simplifyLoop reduces a closed loop with the Ramer-Douglas-Peucker
+// algorithm, splitting it at its first vertex and the vertex farthest from
+// it and simplifying each half.
+func simplifyLoop(loop [][2]int, tolerance float64) [][2]int {
+	if len(loop) < 4 || tolerance <= 0 {
+		return loop
+	}
+	far, farDist := 0, -1.0
+	for i, p := range loop {
+		if d := math.Hypot(float64(p[0]-loop[0][0]), float64(p[1]-loop[0][1])); d > farDist {
+			far, farDist = i, d
+		}
+	}
+	first := simplifyPath(loop[:far+1], tolerance)
+	second := simplifyPath(append(loop[far:len(loop):len(loop)], loop[0]), tolerance)
+	out := append([][2]int(nil), first[:len(first)-1]...)
+	return append(out, second[:len(second)-1]...)
+}
+
+// simplifyPath applies the Ramer-Douglas-Peucker algorithm to an open path,
+// always keeping its end points.
+func simplifyPath(path [][2]int, tolerance float64) [][2]int {
+	if len(path) < 3 {
+		return path
+	}
+	a, b := path[0], path[len(path)-1]
+	far, farDist := 0, 0.0
+	for i := 1; i < len(path)-1; i++ {
+		if d := segmentDistance(path[i], a, b); d > farDist {
+			far, farDist = i, d
+		}
+	}
+	if farDist <= tolerance {
+		return [][2]int{a, b}
+	}
+	left := simplifyPath(path[:far+1], tolerance)
+	right := simplifyPath(path[far:], tolerance)
+	return append(left[:len(left)-1:len(left)-1], right...)
+}
+
+// segmentDistance returns the distance from p to the segment from a to b.
+func segmentDistance(p, a, b [2]int) float64 {
+	px, py := float64(p[0]), float64(p[1])
+	ax, ay := float64(a[0]), float64(a[1])
+	dx, dy := float64(b[0])-ax, float64(b[1])-ay
+	length := dx*dx + dy*dy
+	if length == 0 {
+		return math.Hypot(px-ax, py-ay)
+	}
+	t := math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/length))
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
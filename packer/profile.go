@@ -0,0 +1,16 @@
+package packer
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// profilePhase labels the profile samples of the calling goroutine, and of
+// the goroutines it starts, with the phase of the build until the returned
+// function restores the labels of ctx, as in
+//
+//	defer profilePhase(ctx, "load")()
+func profilePhase(ctx context.Context, phase string) func() {
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, pprof.Labels("phase", phase)))
+	return func() { pprof.SetGoroutineLabels(ctx) }
+}
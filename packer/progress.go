@@ -0,0 +1,71 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"sync"
+)
+
+// ProgressStage identifies the phase of atlas generation a ProgressEvent belongs to.
+type ProgressStage string
+
+const (
+	// StageLoad events are reported as each image finishes loading.
+	StageLoad ProgressStage = "load"
+	// StagePlace events are reported as each sprite is placed in the atlas.
+	StagePlace ProgressStage = "place"
+)
+
+// ProgressEvent describes a single sprite completing a stage. Done counts the
+// sprites finished so far in the stage, including this one, out of Total.
+// Rect is the sprite's placement and is only set for StagePlace events.
+type ProgressEvent struct {
+	Stage ProgressStage
+	Name  string
+	Done  int
+	Total int
+	Rect  image.Rectangle
+}
+
+// ProgressFunc is called with progress events during atlas generation.
+// Invocations are serialized, so a ProgressFunc never runs concurrently with
+// itself even though images are loaded from several goroutines.
+type ProgressFunc func(ProgressEvent)
+
+// progressReporter counts completed sprites for one stage and forwards events
+// to a ProgressFunc while holding a lock. A nil reporter, or one without a
+// callback, ignores events.
+type progressReporter struct {
+	mu    sync.Mutex
+	fn    ProgressFunc
+	stage ProgressStage
+	done  int
+	total int
+}
+
+// newProgressReporter returns a reporter for a stage with total sprites,
+// or nil if no callback is set.
+func newProgressReporter(fn ProgressFunc, stage ProgressStage, total int) *progressReporter {
+	if fn == nil {
+		return nil
+	}
+	return &progressReporter{fn: fn, stage: stage, total: total}
+}
+
+// report records that the named sprite finished the stage.
+func (p *progressReporter) report(name string, rect image.Rectangle) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.fn(ProgressEvent{Stage: p.stage, Name: name, Done: p.done, Total: p.total, Rect: rect})
+}
+
+// PrintProgress is the ProgressFunc used by the -progress flag; it writes
+// one line per event to standard error.
+func PrintProgress(ev ProgressEvent) {
+	fmt.Fprintf(os.Stderr, "%s %d/%d %s\n", ev.Stage, ev.Done, ev.Total, ev.Name)
+}
@@ -0,0 +1,68 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"testing"
+	"testing/fstest"
+)
+
+// pngFile encodes img as a file of a MapFS.
+func pngFile(t *testing.T, img image.Image) *fstest.MapFile {
+	t.Helper()
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return &fstest.MapFile{Data: b.Bytes()}
+}
+
+// checkEvents fails unless events are the total events of a stage, counted
+// one by one from 1.
+func checkEvents(t *testing.T, events []ProgressEvent, stage ProgressStage, total int) {
+	t.Helper()
+	if len(events) != total {
+		t.Fatalf("%d %s events, want %d", len(events), stage, total)
+	}
+	for i, ev := range events {
+		if ev.Stage != stage || ev.Done != i+1 || ev.Total != total {
+			t.Errorf("%s event %d is %+v, want done %d of %d", stage, i, ev, i+1, total)
+		}
+	}
+}
+
+// TestProgress checks that opts.Progress is called once per sprite as images
+// load on several goroutines and again as Pack places them, one call at a
+// time, with the count of sprites done so far.
+func TestProgress(t *testing.T) {
+	fsys := fstest.MapFS{}
+	var files []string
+	for i := 0; i < 40; i++ {
+		name := fmt.Sprintf("s%02d.png", i)
+		fsys[name] = pngFile(t, patterned(4+i%5, 3+i%7, uint8(i)))
+		files = append(files, name)
+	}
+
+	var events []ProgressEvent
+	opts := Options{MaxWidth: 256, MaxHeight: 256, FS: fsys, Alpha: AlphaStraight}
+	opts.Progress = func(ev ProgressEvent) { events = append(events, ev) }
+	rectangles, err := loadImages(context.Background(), files, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, events, StageLoad, len(files))
+
+	events = nil
+	if _, _, err := Pack(rectangles, opts); err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, events, StagePlace, len(files))
+	for _, ev := range events {
+		if ev.Rect.Empty() {
+			t.Errorf("place event for %s has no rectangle", ev.Name)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package packer
+
+// Quad is a sprite ready for a vertex buffer: the positions of its four
+// corners, in pixels relative to its pivot, and their texture coordinates,
+// normalized to the atlas size. Both list the top-left, top-right,
+// bottom-right and bottom-left corners of the packed pixels in that order,
+// so the quad is two triangles, 0-1-2 and 0-2-3.
+type Quad struct {
+	Positions [4][2]float64 `json:"positions"`
+	UVs       [4][2]float64 `json:"uvs"`
+}
+
+// spriteQuad returns the quad of a sprite placed in an atlas of the given
+// size, measured from the atlas's top-left corner. Positions have x to the
+// right and y down from the pivot, a point of the untrimmed sprite, or its
+// top-left corner when it has none, so a trimmed sprite's quad covers only
+// its packed pixels but sits where they were in the source. The corners of
+// a sprite packed rotated map to its region of the atlas turned back, so
+// the quad is upright.
+func spriteQuad(entry SpriteEntry, width, height int) Quad {
+	w, h := float64(entry.W), float64(entry.H)
+	if entry.Rotated {
+		w, h = h, w
+	}
+	left, top := 0.0, 0.0
+	if entry.Trim != nil {
+		left, top = float64(entry.Trim.X), float64(entry.Trim.Y)
+	}
+	if p := entry.Pivot; p != nil {
+		sourceW, sourceH := w, h
+		if entry.Trim != nil {
+			sourceW, sourceH = float64(entry.Trim.SourceW), float64(entry.Trim.SourceH)
+		}
+		left -= p.X * sourceW
+		top -= p.Y * sourceH
+	}
+	right, bottom := left+w, top+h
+
+	u0, v0 := float64(entry.X)/float64(width), float64(entry.Y)/float64(height)
+	u1, v1 := float64(entry.X+entry.W)/float64(width), float64(entry.Y+entry.H)/float64(height)
+	uvs := [4][2]float64{{u0, v0}, {u1, v0}, {u1, v1}, {u0, v1}}
+	if entry.Rotated {
+		// Turned clockwise, the sprite's top-left corner is at the region's
+		// top-right, and so on around.
+		uvs = [4][2]float64{uvs[1], uvs[2], uvs[3], uvs[0]}
+	}
+	return Quad{
+		Positions: [4][2]float64{{left, top}, {right, top}, {right, bottom}, {left, bottom}},
+		UVs:       uvs,
+	}
+}
+
+// addQuads sets the quad of every sprite in the manifest, whose positions
+// must still be measured from the top-left corner.
+func addQuads(manifest *Manifest) {
+	for name, entry := range manifest.Sprites {
+		quad := spriteQuad(entry, manifest.Width, manifest.Height)
+		entry.Quad = &quad
+		manifest.Sprites[name] = entry
+	}
+}
+
+// flipQuad returns the quad with y pointing up instead of down, in both
+// its positions and its texture coordinates, for -origin bottomleft.
+func flipQuad(quad Quad) Quad {
+	for i := range quad.Positions {
+		// Leave zero alone rather than writing -0.
+		if y := quad.Positions[i][1]; y != 0 {
+			quad.Positions[i][1] = -y
+		}
+		quad.UVs[i][1] = 1 - quad.UVs[i][1]
+	}
+	return quad
+}
@@ -0,0 +1,154 @@
+package packer
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// resizeArea scales img to w x h pixels. Each destination pixel is the
+// average of the source pixels it covers, with partially covered pixels
+// weighted by their coverage, which gives smooth results when shrinking.
+// Colors are averaged premultiplied by alpha so transparent pixels do not
+// darken the edges of a sprite.
+func resizeArea(img image.Image, w, h int) *image.NRGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	src := make([]float64, sw*sh*4)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			i := (y*sw + x) * 4
+			src[i], src[i+1], src[i+2], src[i+3] = float64(r), float64(g), float64(bl), float64(a)
+		}
+	}
+
+	// Resize horizontally into tmp (w x sh), then vertically into dst (w x h).
+	tmp := make([]float64, w*sh*4)
+	for x, span := range areaSpans(sw, w) {
+		for y := 0; y < sh; y++ {
+			for _, c := range span {
+				si, di := (y*sw+c.index)*4, (y*w+x)*4
+				for k := 0; k < 4; k++ {
+					tmp[di+k] += src[si+k] * c.weight
+				}
+			}
+		}
+	}
+	dst := make([]float64, w*h*4)
+	for y, span := range areaSpans(sh, h) {
+		for x := 0; x < w; x++ {
+			for _, c := range span {
+				si, di := (c.index*w+x)*4, (y*w+x)*4
+				for k := 0; k < 4; k++ {
+					dst[di+k] += tmp[si+k] * c.weight
+				}
+			}
+		}
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := (y*w + x) * 4
+			c := color.RGBA64{
+				R: clamp16(dst[i]),
+				G: clamp16(dst[i+1]),
+				B: clamp16(dst[i+2]),
+				A: clamp16(dst[i+3]),
+			}
+			out.Set(x, y, c)
+		}
+	}
+	return out
+}
+
+// areaContribution is the weight a source pixel contributes to a
+// destination pixel.
+type areaContribution struct {
+	index  int
+	weight float64
+}
+
+// areaSpans returns, for each of the dst destination pixels along one axis,
+// the source pixels it covers when src pixels are mapped onto dst, with
+// weights summing to one.
+func areaSpans(src, dst int) [][]areaContribution {
+	spans := make([][]areaContribution, dst)
+	scale := float64(src) / float64(dst)
+	for i := range spans {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		for j := int(start); j < src && float64(j) < end; j++ {
+			overlap := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if overlap > 0 {
+				spans[i] = append(spans[i], areaContribution{index: j, weight: overlap / scale})
+			}
+		}
+	}
+	return spans
+}
+
+// clamp16 rounds v to the nearest 16-bit color value.
+func clamp16(v float64) uint16 {
+	return uint16(math.Max(0, math.Min(0xffff, math.Round(v))))
+}
+
+// scaleNearest enlarges img by an integer factor with nearest-neighbor
+// sampling, so each source pixel becomes a factor by factor block of
+// exactly its color, keeping pixel art crisp. The result is an
+// *image.NRGBA64 when deep is set, preserving 16-bit sources, and an
+// *image.NRGBA otherwise.
+func scaleNearest(img image.Image, factor int, deep bool) image.Image {
+	b := img.Bounds()
+	dst := image.Rect(0, 0, b.Dx()*factor, b.Dy()*factor)
+	if deep {
+		out := image.NewNRGBA64(dst)
+		for y := 0; y < dst.Dy(); y++ {
+			for x := 0; x < dst.Dx(); x++ {
+				out.Set(x, y, img.At(b.Min.X+x/factor, b.Min.Y+y/factor))
+			}
+		}
+		return out
+	}
+
+	out := image.NewNRGBA(dst)
+	read := nrgbaReader(img)
+	for y := 0; y < b.Dy(); y++ {
+		row := out.Pix[y*factor*out.Stride : (y*factor+1)*out.Stride]
+		for x := 0; x < b.Dx(); x++ {
+			c := read(b.Min.X+x, b.Min.Y+y)
+			for i := x * factor * 4; i < (x+1)*factor*4; i += 4 {
+				row[i], row[i+1], row[i+2], row[i+3] = c.R, c.G, c.B, c.A
+			}
+		}
+		// Repeat the scaled row for the rest of its block.
+		for k := 1; k < factor; k++ {
+			copy(out.Pix[(y*factor+k)*out.Stride:], row)
+		}
+	}
+	return out
+}
+
+// PreviewSuffix is inserted before the extension of an atlas's preview.
+const PreviewSuffix = "_preview"
+
+// previewFilename returns the filename of the preview written beside an
+// atlas with -preview.
+func previewFilename(atlasFile string) string {
+	ext := filepath.Ext(atlasFile)
+	return strings.TrimSuffix(atlasFile, ext) + PreviewSuffix + ext
+}
+
+// previewImage returns a copy of the atlas shrunk with area averaging so
+// that neither side exceeds maxSide pixels, preserving its aspect ratio.
+// An atlas that already fits is copied at its full size.
+func previewImage(atlas image.Image, maxSide int) *image.NRGBA {
+	b := atlas.Bounds()
+	scale := math.Min(1, float64(maxSide)/float64(max(b.Dx(), b.Dy())))
+	w := max(1, int(math.Round(float64(b.Dx())*scale)))
+	h := max(1, int(math.Round(float64(b.Dy())*scale)))
+	return resizeArea(atlas, w, h)
+}
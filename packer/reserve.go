@@ -0,0 +1,48 @@
+package packer
+
+import "math"
+
+// reservedID is the rectangle ID of the region kept free with -reserve. Sprite
+// IDs start at 1, so it never collides with one.
+const reservedID = 0
+
+// RegionEntry is a rectangle of the atlas that holds no sprite, or one a
+// sprite covers on a mip level.
+type RegionEntry struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// withReserved returns the rectangles with a blank one of the given size in
+// front, at the highest priority so every packer places it first, in the
+// atlas's top-left corner.
+func withReserved(rectangles []Rectangle, size Size) []Rectangle {
+	reserved := Rectangle{
+		ID:     reservedID,
+		Name:   "(reserved)",
+		Width:  size.W,
+		Height: size.H,
+		Meta:   SpriteMeta{Priority: math.MaxInt},
+	}
+	return append([]Rectangle{reserved}, rectangles...)
+}
+
+// takeReserved moves the reserved region's placement out of the layout's
+// placements into layout.Reserved.
+func takeReserved(layout Layout) Layout {
+	layout.Reserved = layout.Placements[reservedID]
+	delete(layout.Placements, reservedID)
+	return layout
+}
+
+// reservedEntry returns the manifest entry for the layout's reserved region,
+// or nil when it has none.
+func reservedEntry(layout Layout) *RegionEntry {
+	r := layout.Reserved
+	if r.Empty() {
+		return nil
+	}
+	return &RegionEntry{X: r.Min.X, Y: r.Min.Y, W: r.Dx(), H: r.Dy()}
+}
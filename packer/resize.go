@@ -0,0 +1,79 @@
+package packer
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strings"
+)
+
+// resizeRule scales every sprite whose name matches Pattern, a path.Match
+// glob such as "icons/*.png", to fit within Size.
+type resizeRule struct {
+	Pattern string
+	Size    Size
+}
+
+// ResizeRules is the list of -resize rules, each given on the command line
+// as "PATTERN=WxH" with the flag repeated for several rules.
+type ResizeRules []resizeRule
+
+// String formats the rules as accepted by Set, comma-separated, implementing
+// flag.Value.
+func (r *ResizeRules) String() string {
+	rules := make([]string, len(*r))
+	for i, rule := range *r {
+		rules[i] = rule.Pattern + "=" + rule.Size.String()
+	}
+	return strings.Join(rules, ",")
+}
+
+// Set parses a "PATTERN=WxH" rule and appends it, implementing flag.Value.
+func (r *ResizeRules) Set(value string) error {
+	pattern, size, ok := strings.Cut(value, "=")
+	if !ok || pattern == "" {
+		return fmt.Errorf("invalid resize rule %q: want PATTERN=WxH", value)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid resize pattern %q: %w", pattern, err)
+	}
+	var s Size
+	if err := s.Set(size); err != nil {
+		return err
+	}
+	*r = append(*r, resizeRule{Pattern: pattern, Size: s})
+	return nil
+}
+
+// match returns the size of the first rule whose pattern matches the sprite
+// name.
+func (r ResizeRules) match(name string) (Size, bool) {
+	for _, rule := range r {
+		if ok, _ := path.Match(rule.Pattern, name); ok {
+			return rule.Size, true
+		}
+	}
+	return Size{}, false
+}
+
+// fitSize returns the largest size with the aspect ratio of w x h that fits
+// within box, scaling up as well as down.
+func fitSize(w, h int, box Size) (int, int) {
+	scale := math.Min(float64(box.W)/float64(w), float64(box.H)/float64(h))
+	return max(1, min(box.W, int(math.Round(float64(w)*scale)))),
+		max(1, min(box.H, int(math.Round(float64(h)*scale))))
+}
+
+// resizeRectangle scales the rectangle's image with area averaging to fit
+// within box, keeping its aspect ratio, and records its size before
+// resizing. An image that already fits exactly along one side is left as is.
+func resizeRectangle(rect *Rectangle, box Size) {
+	w, h := fitSize(rect.Width, rect.Height, box)
+	if w == rect.Width && h == rect.Height {
+		return
+	}
+	rect.Resized = true
+	rect.OriginalWidth, rect.OriginalHeight = rect.Width, rect.Height
+	rect.Image = resizeArea(rect.Image, w, h)
+	rect.Width, rect.Height = w, h
+}
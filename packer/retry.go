@@ -0,0 +1,70 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// retryDelay is how long loadImage waits before its first retry; the delay
+// doubles with every further attempt.
+const retryDelay = 100 * time.Millisecond
+
+// transientError marks a failure to open or read a file that may succeed if
+// tried again, as opposed to a missing file or one that does not decode.
+type transientError struct {
+	err error
+}
+
+// Error returns the message of the underlying error.
+func (e transientError) Error() string { return e.err.Error() }
+
+// Unwrap returns the underlying error.
+func (e transientError) Unwrap() error { return e.err }
+
+// isTransient reports whether err, or an error it wraps, is transient.
+func isTransient(err error) bool {
+	var transient transientError
+	return errors.As(err, &transient)
+}
+
+// openTransient opens a file, marking the failure as transient unless the
+// file does not exist or may not be read, which retrying will not change.
+func openTransient(fsys fs.FS, file string) (fs.File, error) {
+	f, err := fsys.Open(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission) {
+		return nil, transientError{err}
+	}
+	return f, err
+}
+
+// errorRecorder is an io.Reader that remembers the first error other than
+// io.EOF returned by the underlying reader, so a failed decode can be told
+// apart from a failed read.
+type errorRecorder struct {
+	r   io.Reader
+	err error
+}
+
+// Read reads from the underlying reader, recording any read error.
+func (r *errorRecorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// sleepContext waits for d, returning the cause early if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
@@ -0,0 +1,52 @@
+package packer
+
+import (
+	"image"
+	"image/color"
+)
+
+// RunStats is the run-length analysis of a sprite written with -rle: how
+// many horizontal runs of a single color its rows break into, the length of
+// the longest, and how many rows are one color throughout. A sprite with
+// few runs for its area is a candidate for storing as runs rather than as
+// atlas pixels.
+type RunStats struct {
+	Runs       int `json:"runs"`
+	LongestRun int `json:"longestRun"`
+	FlatRows   int `json:"flatRows"`
+}
+
+// analyzeRuns counts the runs of identical pixels along each row of img.
+// Pixels are compared premultiplied at 16 bits per channel, so fully
+// transparent pixels form one run whatever their color.
+func analyzeRuns(img image.Image) *RunStats {
+	at := func(x, y int) color.RGBA64 {
+		r, g, b, a := img.At(x, y).RGBA()
+		return color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)}
+	}
+	if m, ok := img.(image.RGBA64Image); ok {
+		at = m.RGBA64At
+	}
+
+	bounds := img.Bounds()
+	stats := &RunStats{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		runs, length := 0, 0
+		var last color.RGBA64
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := at(x, y)
+			if x == bounds.Min.X || c != last {
+				runs++
+				length = 0
+			}
+			length++
+			last = c
+			stats.LongestRun = max(stats.LongestRun, length)
+		}
+		stats.Runs += runs
+		if runs == 1 {
+			stats.FlatRows++
+		}
+	}
+	return stats
+}
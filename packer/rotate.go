@@ -0,0 +1,69 @@
+package packer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// quarterTurn is a view of an image turned a quarter turn, clockwise or,
+// with back set, counterclockwise, with its bounds at the origin.
+type quarterTurn struct {
+	src  image.Image
+	back bool
+}
+
+// ColorModel returns the color model of the image turned, implementing
+// image.Image.
+func (q quarterTurn) ColorModel() color.Model {
+	return q.src.ColorModel()
+}
+
+// Bounds returns the turned image's bounds, with the source's width and
+// height swapped, implementing image.Image.
+func (q quarterTurn) Bounds() image.Rectangle {
+	b := q.src.Bounds()
+	return image.Rect(0, 0, b.Dy(), b.Dx())
+}
+
+// At returns the source pixel that turning moves to (x, y), implementing
+// image.Image.
+func (q quarterTurn) At(x, y int) color.Color {
+	b := q.src.Bounds()
+	if q.back {
+		return q.src.At(b.Max.X-1-y, b.Min.Y+x)
+	}
+	return q.src.At(b.Min.X+y, b.Max.Y-1-x)
+}
+
+// Rotate returns img turned a quarter turn clockwise, as Pack draws the
+// images it turns, or with back set counterclockwise, turning such an image
+// back. The result is a view of img's pixels with its bounds at the origin.
+func Rotate(img image.Image, back bool) image.Image {
+	return quarterTurn{src: img, back: back}
+}
+
+// rotated reports whether a rectangle was placed turned a quarter turn
+// clockwise, as the shelf packer may place it with -allowrotation. Its
+// placement then has the rectangle's width and height swapped; a square is
+// never turned.
+func rotated(rect Rectangle, placed image.Rectangle) bool {
+	return rect.Width != rect.Height && placed.Dx() == rect.Height && placed.Dy() == rect.Width
+}
+
+// turnBack returns a copy of img, a sprite's pixels as drawn into the atlas
+// turned a quarter turn clockwise, turned back to the sprite's own
+// orientation, with its bounds at the origin and in 16 bits per channel
+// when img has them.
+func turnBack(img image.Image) draw.Image {
+	turned := Rotate(img, true)
+	var out draw.Image
+	switch img.(type) {
+	case *image.NRGBA64, *image.RGBA64, *image.Gray16, *image.Alpha16:
+		out = image.NewNRGBA64(turned.Bounds())
+	default:
+		out = image.NewNRGBA(turned.Bounds())
+	}
+	draw.Draw(out, out.Bounds(), turned, image.Point{}, draw.Src)
+	return out
+}
@@ -0,0 +1,64 @@
+package packer
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"testing"
+	"testing/fstest"
+)
+
+// TestRotatedPaddedTrimmed checks a trimmed sprite that only fits turned:
+// its padding applies to the turned size, so it keeps its distance from its
+// neighbours, and its trimmed pixels sit inside the placement the right way
+// round, with the trim recorded against the upright source. The sprites go
+// through Place, the shelf packer, which pads the turned size.
+func TestRotatedPaddedTrimmed(t *testing.T) {
+	// The visible 12x4 part of wide.png is wider than the atlas, so it has
+	// to be turned to fit.
+	visible := patterned(12, 4, 3)
+	wide := image.NewNRGBA(image.Rect(0, 0, 16, 6))
+	draw.Draw(wide, image.Rect(2, 1, 14, 5), visible, image.Point{}, draw.Src)
+	fsys := fstest.MapFS{
+		"a.png":    pngFile(t, patterned(6, 8, 1)),
+		"b.png":    pngFile(t, patterned(3, 3, 2)),
+		"wide.png": pngFile(t, wide),
+	}
+	pad := image.Pt(1, 1)
+	opts := Options{MaxWidth: 10, MaxHeight: 64, Padding: Padding{X: pad.X, Y: pad.Y}, AllowRotation: true, Trim: true, Alpha: AlphaStraight}
+	rectangles, err := LoadFS(context.Background(), fsys, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	atlas, layout, err := generateAtlas(context.Background(), rectangles, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := buildManifest("atlas.png", rectangles, layout)
+
+	entry := manifest.Sprites["wide.png"]
+	if !entry.Rotated || entry.W != 4 || entry.H != 12 {
+		t.Fatalf("wide.png packed as %+v, want turned to 4x12", entry)
+	}
+	if want := (TrimEntry{X: 2, Y: 1, SourceW: 16, SourceH: 6}); entry.Trim == nil || *entry.Trim != want {
+		t.Errorf("wide.png trim is %+v, want %+v", entry.Trim, want)
+	}
+
+	for i, a := range rectangles {
+		ra := layout.Placements[a.ID]
+		if ra.Max.X > layout.Width || ra.Max.Y > layout.Height {
+			t.Errorf("%s at %v reaches outside the %dx%d atlas", a.Name, ra, layout.Width, layout.Height)
+		}
+		padded := image.Rectangle{Min: ra.Min.Sub(pad), Max: ra.Max.Add(pad)}
+		for _, b := range rectangles[i+1:] {
+			if rb := layout.Placements[b.ID]; padded.Overlaps(rb) {
+				t.Errorf("%s at %v is within the padding of %s at %v", b.Name, rb, a.Name, ra)
+			}
+		}
+	}
+
+	sprites := Unpack(atlas, manifest)
+	sameImage(t, "wide.png", sprites["wide.png"], visible)
+	sameImage(t, "a.png", sprites["a.png"], patterned(6, 8, 1))
+	sameImage(t, "b.png", sprites["b.png"], patterned(3, 3, 2))
+}
@@ -0,0 +1,97 @@
+package packer
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// manifestSchemaID identifies the JSON Schema dialect of the schema printed
+// with -reportschema.
+const manifestSchemaID = "https://json-schema.org/draft/2020-12/schema"
+
+// manifestSchema returns a JSON Schema of the JSON manifest format, derived
+// from the Manifest type and its JSON field tags, so it always matches what
+// this build writes. Every named struct type becomes a definition under
+// "$defs", fields without omitempty are required, and objects allow no
+// properties besides those the type declares, so a manifest written by a
+// newer build with fields this one lacks fails validation.
+func manifestSchema() map[string]any {
+	defs := make(map[string]any)
+	schema := structSchema(reflect.TypeOf(Manifest{}), defs)
+	schema["$schema"] = manifestSchemaID
+	schema["title"] = "Texture atlas manifest"
+	schema["$defs"] = defs
+	return schema
+}
+
+// typeSchema returns the JSON Schema of the values encoding/json writes for
+// t, adding the schema of each named struct type it refers to to defs.
+func typeSchema(t reflect.Type, defs map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), defs)
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate.
+			defs[t.Name()] = nil
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), defs)}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), defs)}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), defs), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	}
+	panic(fmt.Sprintf("no JSON Schema for manifest field type %s", t))
+}
+
+// structSchema returns the JSON Schema of a struct type's exported fields,
+// named and made optional as their json tags say.
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	properties := make(map[string]any)
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = typeSchema(field.Type, defs)
+		if !strings.Contains(","+options+",", ",omitempty,") {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// PrintManifestSchema writes the manifest's JSON Schema to standard output.
+func PrintManifestSchema() error {
+	data, err := json.MarshalIndent(manifestSchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,150 @@
+package packer
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// DefaultSDFSpread is the default distance, in pixels, over which a signed
+// distance field ramps from fully inside to fully outside a silhouette.
+const DefaultSDFSpread = 8
+
+// sdfInfinity stands in for an infinite squared distance in the distance
+// transform; it is far larger than any squared distance within an image but
+// small enough to keep the arithmetic exact.
+const sdfInfinity = 1e20
+
+// sdfRectangle replaces a rectangle's image with the signed distance field
+// of its silhouette, grown by spread pixels on every side so the field has
+// room to fall off outside the sprite. Traced outlines are shifted to match.
+func sdfRectangle(rect *Rectangle, spread int, deep bool) {
+	rect.Image = signedDistanceField(rect.Image, spread, deep)
+	rect.Width, rect.Height = rect.Image.Bounds().Dx(), rect.Image.Bounds().Dy()
+	for _, outline := range rect.Outlines {
+		for i := range outline {
+			outline[i][0] += spread
+			outline[i][1] += spread
+		}
+	}
+}
+
+// signedDistanceField returns a single-channel image, spread pixels larger
+// than img on every side, holding the distance from each pixel to the edge
+// of img's silhouette: the pixels whose alpha is at least one half. The
+// distance is encoded so the edge lies at mid-gray, pixels spread or more
+// inside the silhouette are white and those spread or more outside are
+// black. Distances are exact Euclidean distances between pixel centers. The
+// result is an *image.Gray16 when deep is set, an *image.Gray otherwise.
+func signedDistanceField(img image.Image, spread int, deep bool) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx()+2*spread, b.Dy()+2*spread
+	inside := make([]bool, w*h)
+	read := nrgbaReader(img)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			inside[(y+spread)*w+x+spread] = read(b.Min.X+x, b.Min.Y+y).A >= 0x80
+		}
+	}
+
+	// toInside holds each pixel's squared distance to the nearest pixel
+	// inside the silhouette, and toOutside to the nearest one outside it.
+	toInside := make([]float64, w*h)
+	toOutside := make([]float64, w*h)
+	for i, in := range inside {
+		if in {
+			toOutside[i] = sdfInfinity
+		} else {
+			toInside[i] = sdfInfinity
+		}
+	}
+	distanceTransform(toInside, w, h)
+	distanceTransform(toOutside, w, h)
+
+	var gray *image.Gray
+	var gray16 *image.Gray16
+	if deep {
+		gray16 = image.NewGray16(image.Rect(0, 0, w, h))
+	} else {
+		gray = image.NewGray(image.Rect(0, 0, w, h))
+	}
+	for i, in := range inside {
+		// Pixels next to the edge are half a pixel from it.
+		var distance float64
+		if in {
+			distance = math.Sqrt(toOutside[i]) - 0.5
+		} else {
+			distance = -(math.Sqrt(toInside[i]) - 0.5)
+		}
+		v := math.Max(0, math.Min(1, 0.5+distance/float64(2*spread)))
+		if deep {
+			gray16.SetGray16(i%w, i/w, color.Gray16{Y: uint16(math.Round(v * 0xffff))})
+		} else {
+			gray.Pix[i] = uint8(math.Round(v * 0xff))
+		}
+	}
+	if deep {
+		return gray16
+	}
+	return gray
+}
+
+// distanceTransform replaces each value of a w by h grid, zero at feature
+// pixels and sdfInfinity elsewhere, with the squared Euclidean distance to
+// the nearest feature pixel. It runs the linear-time transform of
+// Felzenszwalb and Huttenlocher along every column and then every row.
+func distanceTransform(grid []float64, w, h int) {
+	n := max(w, h)
+	f := make([]float64, n)
+	d := make([]float64, n)
+	v := make([]int, n)
+	z := make([]float64, n+1)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			f[y] = grid[y*w+x]
+		}
+		distanceTransform1D(f[:h], d[:h], v, z)
+		for y := 0; y < h; y++ {
+			grid[y*w+x] = d[y]
+		}
+	}
+	for y := 0; y < h; y++ {
+		copy(f, grid[y*w:(y+1)*w])
+		distanceTransform1D(f[:w], d[:w], v, z)
+		copy(grid[y*w:(y+1)*w], d[:w])
+	}
+}
+
+// distanceTransform1D computes into d the squared distance transform of the
+// sampled function f: the lower envelope of the parabolas rooted at each
+// sample. v and z are scratch space for the envelope's parabolas and their
+// boundaries, with room for at least len(f) and len(f)+1 entries.
+func distanceTransform1D(f, d []float64, v []int, z []float64) {
+	k := 0
+	v[0] = 0
+	z[0], z[1] = math.Inf(-1), math.Inf(1)
+	for q := 1; q < len(f); q++ {
+		s := intersect(f, q, v[k])
+		for s <= z[k] {
+			k--
+			s = intersect(f, q, v[k])
+		}
+		k++
+		v[k] = q
+		z[k], z[k+1] = s, math.Inf(1)
+	}
+	k = 0
+	for q := range f {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		dq := float64(q - v[k])
+		d[q] = dq*dq + f[v[k]]
+	}
+}
+
+// intersect returns where the parabolas rooted at samples q and p of f meet.
+func intersect(f []float64, q, p int) float64 {
+	fq, fp := float64(q), float64(p)
+	return ((f[q] + fq*fq) - (f[p] + fp*fp)) / (2*fq - 2*fp)
+}
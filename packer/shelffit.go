@@ -0,0 +1,68 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+)
+
+// Values of the -shelffit flag, selecting the shelf a sprite goes on when
+// several have room for it.
+const (
+	// ShelfFitFirst uses the first shelf, from the top, that has room.
+	ShelfFitFirst = "first"
+	// ShelfFitBest uses the shelf that leaves the least height unused above
+	// the sprite.
+	ShelfFitBest = "best"
+)
+
+// Values of the -tiebreak flag, selecting among shelves that fit a sprite
+// equally well under -shelffit best by where the sprite would go on them.
+const (
+	// TieBreakSmallestY takes the highest position, then the leftmost.
+	TieBreakSmallestY = "smallest-y"
+	// TieBreakSmallestX takes the leftmost position, then the highest.
+	TieBreakSmallestX = "smallest-x"
+	// TieBreakTopLeft takes the position closest to the top-left corner,
+	// by the sum of its coordinates, then the highest.
+	TieBreakTopLeft = "topleft"
+	// TieBreakBottomLeft takes the lowest position, then the leftmost.
+	TieBreakBottomLeft = "bottomleft"
+)
+
+// preferPosition reports whether position a wins a tie against position b
+// under the tie-break rule.
+func preferPosition(tieBreak string, a, b image.Point) bool {
+	switch tieBreak {
+	case TieBreakSmallestX:
+		return a.X < b.X || a.X == b.X && a.Y < b.Y
+	case TieBreakTopLeft:
+		return a.X+a.Y < b.X+b.Y || a.X+a.Y == b.X+b.Y && a.Y < b.Y
+	case TieBreakBottomLeft:
+		return a.Y > b.Y || a.Y == b.Y && a.X < b.X
+	default:
+		return a.Y < b.Y || a.Y == b.Y && a.X < b.X
+	}
+}
+
+// packBestFit packs the rectangles with the best-fit shelf heuristic and
+// reports its occupancy next to that of first-fit on the same input, so the
+// choice of heuristic can be judged. The best-fit layout is returned either
+// way.
+func packBestFit(rectangles []Rectangle, opts Options) Layout {
+	best := packRectangles(rectangles, opts)
+
+	first := opts
+	first.ShelfFit = ShelfFitFirst
+	bestOccupancy := occupancy(rectangles, best)
+	firstOccupancy := occupancy(rectangles, packRectangles(rectangles, first))
+
+	verdict := "same as first-fit"
+	switch {
+	case bestOccupancy > firstOccupancy:
+		verdict = "best-fit is better"
+	case bestOccupancy < firstOccupancy:
+		verdict = "first-fit is better"
+	}
+	fmt.Printf("Shelf fit: best-fit occupancy %.1f%%, first-fit %.1f%% (%s)\n", bestOccupancy*100, firstOccupancy*100, verdict)
+	return best
+}
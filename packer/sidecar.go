@@ -0,0 +1,71 @@
+package packer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// SpriteMeta holds per-sprite metadata read from a sidecar file. The pivot
+// is carried through to the manifest. Priority is a packing hint: sprites
+// with a higher priority are packed before those with a lower one, and so
+// land nearer the top-left of the atlas and on earlier pages; sprites
+// without one have priority 0. Mirror marks a sprite as symmetric across
+// the "x" or "y" axis, so that with -mirrorhalves only half of it is packed.
+// Variants lists tinted copies of the sprite to pack alongside it.
+type SpriteMeta struct {
+	Pivot    *Pivot    `json:"pivot,omitempty"`
+	Priority int       `json:"priority,omitempty"`
+	Mirror   string    `json:"mirror,omitempty"`
+	Variants []Variant `json:"variants,omitempty"`
+}
+
+// Pivot is a sprite's anchor point, normalized so that (0, 0) is the
+// top-left and (1, 1) the bottom-right corner of the untrimmed sprite.
+type Pivot struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// loadSidecar reads a sidecar JSON file mapping sprite names, as they appear
+// in the manifest, to their metadata.
+func loadSidecar(filename string) (map[string]SpriteMeta, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var meta map[string]SpriteMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse sidecar %s: %w", filename, err)
+	}
+	return meta, nil
+}
+
+// applySidecar attaches sidecar metadata to the rectangles it names, keeping
+// a priority from -nameconventions unless the sidecar gives another, and
+// warns about entries that match no sprite, which usually means a sprite was
+// renamed and the sidecar has drifted out of sync.
+func applySidecar(rectangles []Rectangle, meta map[string]SpriteMeta) {
+	used := make(map[string]bool, len(meta))
+	for i := range rectangles {
+		if m, ok := meta[rectangles[i].Name]; ok {
+			if m.Priority == 0 {
+				m.Priority = rectangles[i].Meta.Priority
+			}
+			rectangles[i].Meta = m
+			used[rectangles[i].Name] = true
+		}
+	}
+
+	var unused []string
+	for name := range meta {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	for _, name := range unused {
+		Warnf("sidecar entry %q matches no sprite", name)
+	}
+}
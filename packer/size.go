@@ -0,0 +1,64 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// Size is a width and height in pixels, given on the command line as "WxH".
+// The zero Size means the option it configures is disabled.
+type Size struct {
+	W int
+	H int
+}
+
+// IsZero reports whether the size is unset.
+func (s Size) IsZero() bool {
+	return s.W == 0 && s.H == 0
+}
+
+// String formats the size as accepted by Set, implementing flag.Value.
+func (s *Size) String() string {
+	if s.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", s.W, s.H)
+}
+
+// Set parses a "WxH" size with positive dimensions, implementing flag.Value.
+func (s *Size) Set(value string) error {
+	w, h, ok := strings.Cut(strings.ToLower(value), "x")
+	if !ok {
+		return fmt.Errorf("invalid size %q: want WxH", value)
+	}
+	width, errW := strconv.Atoi(strings.TrimSpace(w))
+	height, errH := strconv.Atoi(strings.TrimSpace(h))
+	if errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid size %q: width and height must be positive integers", value)
+	}
+	s.W, s.H = width, height
+	return nil
+}
+
+// checkSizes fails unless every sprite not skipped was loaded at the
+// expected size, listing those that were not in file order with the size
+// each has.
+func checkSizes(names []string, sizes []image.Point, skipped []string, want Size) error {
+	var wrong []string
+	checked := 0
+	for i, size := range sizes {
+		if skipped[i] != "" {
+			continue
+		}
+		checked++
+		if size.X != want.W || size.Y != want.H {
+			wrong = append(wrong, fmt.Sprintf("%s (%dx%d)", names[i], size.X, size.Y))
+		}
+	}
+	if len(wrong) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d sprites are not %s: %s", len(wrong), checked, want.String(), strings.Join(wrong, ", "))
+}
@@ -0,0 +1,64 @@
+package packer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LoadSkipFile reads a -skipfile list of files to leave out: one path per
+// line, relative to -filedir, with blank lines and lines starting with "#"
+// ignored. Paths are cleaned and use forward slashes, so they compare equal
+// to the names collectImageFiles returns.
+func LoadSkipFile(filename string) (map[string]bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	skip := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		skip[path.Clean(filepath.ToSlash(line))] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read skip file %s: %w", filename, err)
+	}
+	return skip, nil
+}
+
+// skipFiles removes the files listed in skip from files, keeping their
+// order, and warns about entries that match no file, which usually means a
+// sprite was renamed or already removed.
+func skipFiles(files []string, skip map[string]bool) []string {
+	kept := files[:0]
+	used := make(map[string]bool, len(skip))
+	for _, file := range files {
+		if skip[file] {
+			used[file] = true
+			continue
+		}
+		kept = append(kept, file)
+	}
+
+	var unused []string
+	for file := range skip {
+		if !used[file] {
+			unused = append(unused, file)
+		}
+	}
+	sort.Strings(unused)
+	for _, file := range unused {
+		Warnf("skip file entry %q matches no image file", file)
+	}
+	return kept
+}
@@ -0,0 +1,66 @@
+package packer
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// TestSameHeightFilenameOrder checks that sprites of the same height are
+// placed left to right in filename order, however they were loaded and
+// whatever their widths.
+func TestSameHeightFilenameOrder(t *testing.T) {
+	names := []string{"d.png", "a.png", "e.png", "c.png", "b.png"}
+	widths := []int{4, 9, 2, 9, 6}
+	rectangles := make([]Rectangle, len(names))
+	for i, name := range names {
+		rectangles[i] = Rectangle{ID: len(names) - i, Name: name, Width: widths[i], Height: 8}
+	}
+	sortRectangles(rectangles, SortHeight)
+	layout := packRectangles(rectangles, Options{MaxWidth: 256, MaxHeight: 256})
+
+	byX := slices.Clone(rectangles)
+	slices.SortFunc(byX, func(a, b Rectangle) int {
+		return layout.Placements[a.ID].Min.X - layout.Placements[b.ID].Min.X
+	})
+	var order []string
+	for _, rect := range byX {
+		if layout.Placements[rect.ID].Min.Y != 0 {
+			t.Fatalf("%s placed on a second shelf at %v", rect.Name, layout.Placements[rect.ID])
+		}
+		order = append(order, rect.Name)
+	}
+	want := []string{"a.png", "b.png", "c.png", "d.png", "e.png"}
+	if !slices.Equal(order, want) {
+		t.Errorf("left to right the sprites are %q, want %q", order, want)
+	}
+}
+
+// BenchmarkSortModes packs sprites of mixed aspect ratios in the order of
+// each -sort mode with the shelf packer, reporting the share of the atlas
+// the sprites cover alongside the time taken.
+func BenchmarkSortModes(b *testing.B) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	sprites := make([]Rectangle, 400)
+	for i := range sprites {
+		w, h := 8+rng.IntN(120), 8+rng.IntN(24)
+		if i%3 == 0 {
+			w, h = h, w
+		}
+		sprites[i] = Rectangle{ID: i, Name: "s", Width: w, Height: h}
+	}
+	for _, mode := range []string{SortHeight, SortMaxSide, SortArea} {
+		b.Run(mode, func(b *testing.B) {
+			var layout Layout
+			for b.Loop() {
+				rectangles := slices.Clone(sprites)
+				sortRectangles(rectangles, mode)
+				var err error
+				if layout, err = planLayout(rectangles, Options{MaxWidth: 1024, MaxHeight: 4096, Sort: mode}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(100*occupancy(sprites, layout), "%occupancy")
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package packer
+
+// Values of the -sort flag, selecting the order sprites are packed in.
+const (
+	// SortHeight packs the tallest sprites first, the order the shelf packer
+	// was designed around.
+	SortHeight = "height"
+	// SortMaxSide packs the sprites with the longest side first, be it their
+	// width or their height, which places long, flat sprites earlier.
+	SortMaxSide = "maxside"
+	// SortArea packs the sprites covering the most pixels first.
+	SortArea = "area"
+)
+
+// sortKey returns the value rectangles are ordered by, largest first, for
+// the -sort mode.
+func sortKey(rect Rectangle, mode string) int {
+	switch mode {
+	case SortMaxSide:
+		return max(rect.Width, rect.Height)
+	case SortArea:
+		return rect.Width * rect.Height
+	}
+	return rect.Height
+}
@@ -0,0 +1,46 @@
+package packer
+
+import "sort"
+
+// SpatialIndex partitions an atlas into a grid of square cells, CellSize
+// pixels on a side, Columns across and Rows down, with the cells at the
+// right and bottom edges cut short by the atlas. Cells lists, row by row from
+// the top-left, the names of the sprites overlapping each cell in name
+// order, so a consumer can find the sprites near a point of the atlas
+// without testing every one: the cell holding (x, y) is
+// Cells[y/CellSize*Columns+x/CellSize].
+type SpatialIndex struct {
+	CellSize int        `json:"cellSize"`
+	Columns  int        `json:"columns"`
+	Rows     int        `json:"rows"`
+	Cells    [][]string `json:"cells"`
+}
+
+// buildSpatialIndex indexes the sprites of the manifest in a grid of cells
+// of the given size.
+func buildSpatialIndex(manifest Manifest, cellSize int) *SpatialIndex {
+	index := &SpatialIndex{
+		CellSize: cellSize,
+		Columns:  (manifest.Width + cellSize - 1) / cellSize,
+		Rows:     (manifest.Height + cellSize - 1) / cellSize,
+	}
+	index.Cells = make([][]string, index.Columns*index.Rows)
+	for i := range index.Cells {
+		index.Cells[i] = []string{}
+	}
+	for name, entry := range manifest.Sprites {
+		if entry.W <= 0 || entry.H <= 0 {
+			continue
+		}
+		for row := entry.Y / cellSize; row <= (entry.Y+entry.H-1)/cellSize && row < index.Rows; row++ {
+			for col := entry.X / cellSize; col <= (entry.X+entry.W-1)/cellSize && col < index.Columns; col++ {
+				cell := row*index.Columns + col
+				index.Cells[cell] = append(index.Cells[cell], name)
+			}
+		}
+	}
+	for _, cell := range index.Cells {
+		sort.Strings(cell)
+	}
+	return index
+}
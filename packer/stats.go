@@ -0,0 +1,93 @@
+package packer
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Stats summarizes a whole run for tooling that tracks atlas efficiency.
+type Stats struct {
+	Algorithm  string       `json:"algorithm"`
+	Sprites    int          `json:"sprites"`
+	Pages      int          `json:"pages"`
+	PackTimeMs float64      `json:"packTimeMs"`
+	Atlases    []AtlasStats `json:"atlases"`
+}
+
+// AtlasStats describes a single generated atlas image. PackTimeMs is the
+// time spent laying out and compositing the atlas, excluding loading and
+// encoding. Manifest is the manifest describing the atlas, which the layers
+// of a texture array share; it is not part of the statistics file.
+type AtlasStats struct {
+	Image      string  `json:"image"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	Sprites    int     `json:"sprites"`
+	Occupancy  float64 `json:"occupancy"`
+	PackTimeMs float64 `json:"packTimeMs"`
+	Manifest   string  `json:"-"`
+}
+
+// newAtlasStats collects the statistics of one atlas.
+func newAtlasStats(imageFile string, rectangles []Rectangle, layout Layout, packTime time.Duration) AtlasStats {
+	return AtlasStats{
+		Image:      imageFile,
+		Width:      layout.Width,
+		Height:     layout.Height,
+		Sprites:    len(rectangles),
+		Occupancy:  occupancy(rectangles, layout),
+		PackTimeMs: float64(packTime.Microseconds()) / 1000,
+	}
+}
+
+// newStats totals the statistics of every atlas produced by a run. With
+// opts.Deterministic the pack times, which differ from run to run, are
+// recorded as zero, so the statistics file is as reproducible as the atlas.
+func newStats(opts Options, atlases []AtlasStats) Stats {
+	stats := Stats{Algorithm: algorithmName(opts), Pages: len(atlases), Atlases: atlases}
+	if opts.Deterministic {
+		stats.Atlases = make([]AtlasStats, len(atlases))
+		for i, atlas := range atlases {
+			atlas.PackTimeMs = 0
+			stats.Atlases[i] = atlas
+		}
+	}
+	for _, atlas := range stats.Atlases {
+		stats.Sprites += atlas.Sprites
+		stats.PackTimeMs += atlas.PackTimeMs
+	}
+	return stats
+}
+
+// algorithmName names the packer selected by opts. Shelf packing with the
+// best-fit heuristic gains a "-bestfit" suffix.
+func algorithmName(opts Options) string {
+	switch {
+	case opts.Mask != nil:
+		return "mask"
+	case !opts.Cell.IsZero():
+		return "cells"
+	case opts.Strips:
+		return "strips"
+	case opts.Packer == PackerMaxRects:
+		return "maxrects"
+	}
+	name := "shelf"
+	if opts.TwoPass {
+		name += "-twopass"
+	}
+	if opts.ShelfFit == ShelfFitBest {
+		name += "-bestfit"
+	}
+	return name
+}
+
+// saveStats writes the statistics as indented JSON to the specified filename.
+// Unless overwrite is set, it fails if the file already exists.
+func saveStats(filename string, stats Stats, overwrite bool) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOutput(filename, append(data, '\n'), overwrite)
+}
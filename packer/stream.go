@@ -0,0 +1,90 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is an amount of memory given on the command line as a number of
+// bytes, optionally followed by K, M or G for kibibytes, mebibytes or
+// gibibytes, e.g. 512M. It implements flag.Value.
+type ByteSize int64
+
+// String formats the size with the largest unit that divides it exactly.
+func (b *ByteSize) String() string {
+	n := int64(*b)
+	for _, unit := range []string{"", "K", "M", "G"} {
+		if n%1024 != 0 || n < 1024 || unit == "G" {
+			return strconv.FormatInt(n, 10) + unit
+		}
+		n /= 1024
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// Set parses a size such as 2048, 64K, 512M or 2G. A trailing B, as in
+// 512MB, is accepted and ignored.
+func (b *ByteSize) Set(value string) error {
+	s := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B")
+	shift := 0
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		}
+		if shift > 0 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)>>shift {
+		return fmt.Errorf("invalid size %q, want bytes optionally followed by K, M or G", value)
+	}
+	*b = ByteSize(n << shift)
+	return nil
+}
+
+// estimateMemory estimates how many bytes the decoded source images take up
+// when loaded all at once, from their headers: every pixel of every file,
+// and of every frame of an animation with -frames, at 4 bytes, or 8 with
+// -bitdepth 16, enlarged by -scale. Files whose header cannot be read are
+// left out of the estimate; loading reports them. Reading stops once ctx is
+// done.
+func estimateMemory(ctx context.Context, files []string, opts Options) (int64, error) {
+	bytesPerPixel := int64(4)
+	if opts.BitDepth == 16 {
+		bytesPerPixel = 8
+	}
+	scale := int64(max(opts.Scale, 1))
+
+	var total int64
+	for _, file := range files {
+		if err := context.Cause(ctx); err != nil {
+			return 0, err
+		}
+		var pixels int64
+		if anim, ok := opts.animations[file]; ok {
+			pixels = int64(anim.Width) * int64(anim.Height) * int64(len(anim.Frames))
+		} else if config, err := loadImageConfig(ctx, file, opts); err == nil {
+			pixels = int64(config.Width) * int64(config.Height)
+		}
+		total += pixels * scale * scale * bytesPerPixel
+	}
+	return total, nil
+}
+
+// pixels returns the rectangle's image, reloading it from its source if it
+// was released to save memory.
+func (r Rectangle) pixels() (image.Image, error) {
+	if r.Image == nil && r.Reload != nil {
+		return r.Reload()
+	}
+	return r.Image, nil
+}
@@ -0,0 +1,89 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultAnimRegex groups frames such as "walk_01.png" and "walk-02.png" into
+// the animation "walk" by stripping a trailing frame number.
+const DefaultAnimRegex = `^(.*?)[_-]?\d+$`
+
+// StripRow describes one animation laid out as a horizontal strip by the
+// strip packer: the row's vertical position and height, and its frame count.
+// Layer is the texture array layer holding the row, and is only set for
+// texture arrays.
+type StripRow struct {
+	Animation string `json:"animation"`
+	Y         int    `json:"y"`
+	Height    int    `json:"height"`
+	Frames    int    `json:"frames"`
+	Layer     *int   `json:"layer,omitempty"`
+}
+
+// animationName returns the animation a sprite belongs to: the first capture
+// group of pattern matched against the file's base name without extension,
+// or the whole base name when the pattern does not match.
+func animationName(pattern *regexp.Regexp, name string) string {
+	base := strings.TrimSuffix(path.Base(name), path.Ext(name))
+	if m := pattern.FindStringSubmatch(base); m != nil && m[1] != "" {
+		return m[1]
+	}
+	return base
+}
+
+// packStrips lays out every animation on its own row, with frames ordered by
+// name from left to right. Rows are sorted by animation name and are as tall
+// as their tallest frame; shorter animations leave the rest of their row
+// empty. opts.Padding applies between frames and between rows, and with
+// opts.SpriteAlign every frame and row starts on a multiple of it. It is an
+// error for a strip to be wider than the width bound, since strips never
+// wrap.
+func packStrips(rectangles []Rectangle, opts Options) (Layout, error) {
+	animations := make(map[string][]Rectangle)
+	for _, rect := range rectangles {
+		name := animationName(opts.AnimRegex, rect.Name)
+		animations[name] = append(animations[name], rect)
+	}
+
+	names := make([]string, 0, len(animations))
+	for name := range animations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	layout := Layout{Placements: make(map[int]image.Rectangle, len(rectangles))}
+	y := 0
+	for i, name := range names {
+		frames := animations[name]
+		sort.SliceStable(frames, func(a, b int) bool { return frames[a].Name < frames[b].Name })
+
+		if i > 0 {
+			y += opts.Padding.Y
+		}
+		y = alignUp(y, opts.SpriteAlign)
+		x, height := 0, 0
+		for j, frame := range frames {
+			if j > 0 {
+				x += opts.Padding.X
+			}
+			x = alignUp(x, opts.SpriteAlign)
+			layout.Placements[frame.ID] = image.Rect(x, y, x+frame.Width, y+frame.Height)
+			x += frame.Width
+			height = max(height, frame.Height)
+		}
+		if x > opts.MaxWidth {
+			return Layout{}, fmt.Errorf("animation %q is %dpx wide, exceeding the -maxwidth of %dpx", name, x, opts.MaxWidth)
+		}
+
+		layout.Rows = append(layout.Rows, StripRow{Animation: name, Y: y, Height: height, Frames: len(frames)})
+		layout.Width = max(layout.Width, x)
+		y += height
+	}
+	layout.Height = y
+	return layout, nil
+}
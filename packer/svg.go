@@ -0,0 +1,971 @@
+package packer
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/vector"
+)
+
+// svgSkipped lists the SVG elements whose content is not drawn: definitions,
+// text and styling the rasterizer does not support. Those marked true change
+// the picture when left out and are reported as unsupported; definitions
+// only matter when referenced, which is reported where they are, and the
+// rest are never drawn.
+var svgSkipped = map[string]bool{
+	"defs": false, "symbol": false, "clipPath": false, "mask": false, "pattern": false, "marker": false,
+	"linearGradient": false, "radialGradient": false, "filter": false, "style": true, "script": false,
+	"title": false, "desc": false, "metadata": false, "text": true, "foreignObject": true, "use": true,
+}
+
+// svgReferences lists the attributes that apply a definition the rasterizer
+// does not draw, reported as unsupported unless they are "none". Dashes are
+// among them: a dashed stroke is drawn solid.
+var svgReferences = []string{"stroke-dasharray", "clip-path", "mask", "filter", "marker-start", "marker-mid", "marker-end"}
+
+// svgNamedColors holds the color keywords most often found in icons; others
+// leave the inherited fill unchanged and are reported as unsupported.
+var svgNamedColors = map[string]color.NRGBA{
+	"black":   {0, 0, 0, 0xff},
+	"white":   {0xff, 0xff, 0xff, 0xff},
+	"red":     {0xff, 0, 0, 0xff},
+	"lime":    {0, 0xff, 0, 0xff},
+	"green":   {0, 0x80, 0, 0xff},
+	"blue":    {0, 0, 0xff, 0xff},
+	"yellow":  {0xff, 0xff, 0, 0xff},
+	"cyan":    {0, 0xff, 0xff, 0xff},
+	"aqua":    {0, 0xff, 0xff, 0xff},
+	"magenta": {0xff, 0, 0xff, 0xff},
+	"fuchsia": {0xff, 0, 0xff, 0xff},
+	"orange":  {0xff, 0xa5, 0, 0xff},
+	"purple":  {0x80, 0, 0x80, 0xff},
+	"gray":    {0x80, 0x80, 0x80, 0xff},
+	"grey":    {0x80, 0x80, 0x80, 0xff},
+	"silver":  {0xc0, 0xc0, 0xc0, 0xff},
+	"maroon":  {0x80, 0, 0, 0xff},
+	"navy":    {0, 0, 0x80, 0xff},
+	"teal":    {0, 0x80, 0x80, 0xff},
+	"olive":   {0x80, 0x80, 0, 0xff},
+}
+
+// svgTransformPattern matches one transform function and its arguments.
+var svgTransformPattern = regexp.MustCompile(`([a-zA-Z]+)\s*\(([^)]*)\)`)
+
+// svgRegistered registers the SVG format with the image package only once.
+var svgRegistered sync.Once
+
+// RegisterSVGFormat registers the SVG decoder with the image package for
+// documents that start with their root element, rasterized at their own
+// size, so image.Decode reads them too, as for -canvas. Source files need
+// no registration: they are recognized by their .svg extension, so one with
+// an XML prolog is read too and -svgsize applies, without claiming every
+// XML file. It is safe to call more than once.
+func RegisterSVGFormat() {
+	svgRegistered.Do(func() {
+		image.RegisterFormat("svg", "<svg", func(r io.Reader) (image.Image, error) {
+			img, _, err := decodeSVG(r, Size{})
+			return img, err
+		}, func(r io.Reader) (image.Config, error) {
+			return decodeSVGConfig(r, Size{})
+		})
+	})
+}
+
+// isSVGFile reports whether a source file is an SVG document, by its
+// extension in any case.
+func isSVGFile(file string) bool {
+	return strings.EqualFold(path.Ext(file), ".svg")
+}
+
+// decodeSVG rasterizes an SVG document to fit size, or at its own size when
+// size is zero. Shapes are filled and stroked with solid colors: path,
+// rect, circle, ellipse, line, polygon and polyline elements, in groups with
+// transforms and opacity. Gradients, dashes, text, clipping and references
+// are not drawn; what the document uses of them is returned as unsupported,
+// once each in the order first met.
+func decodeSVG(r io.Reader, size Size) (image.Image, []string, error) {
+	doc, err := parseSVG(r, size, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rasterizeSVG(doc.Width, doc.Height, doc.Shapes), doc.Unsupported, nil
+}
+
+// decodeSVGConfig returns the size decodeSVG rasterizes an SVG document at,
+// reading only its root element.
+func decodeSVGConfig(r io.Reader, size Size) (image.Config, error) {
+	doc, err := parseSVG(r, size, false)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: doc.Width, Height: doc.Height}, nil
+}
+
+// svgPoint is a point in pixels of the rasterized image.
+type svgPoint struct{ X, Y float64 }
+
+// svgMatrix is an affine transform [a b c d e f], mapping (x, y) to
+// (a*x + c*y + e, b*x + d*y + f) as in the SVG matrix() function.
+type svgMatrix [6]float64
+
+// svgIdentity is the transform that leaves points unchanged.
+var svgIdentity = svgMatrix{1, 0, 0, 1, 0, 0}
+
+// apply transforms the point (x, y).
+func (m svgMatrix) apply(x, y float64) svgPoint {
+	return svgPoint{m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]}
+}
+
+// then returns the transform applying n first and then m.
+func (m svgMatrix) then(n svgMatrix) svgMatrix {
+	return svgMatrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+// svgShape is a filled shape in pixels: closed polygons filled with Color
+// under the nonzero rule, or the even-odd rule when EvenOdd is set. A
+// stroke is the shape of its outline, filled under the nonzero rule.
+type svgShape struct {
+	Polygons [][]svgPoint
+	Color    color.NRGBA
+	Opacity  float64
+	EvenOdd  bool
+}
+
+// svgDocument is a parsed SVG document: the size it is rasterized at, the
+// shapes to fill, in painting order, and the content it uses that is not
+// drawn.
+type svgDocument struct {
+	Width, Height int
+	Shapes        []svgShape
+	Unsupported   []string
+}
+
+// unsupported records content of the document that is not drawn, once.
+func (d *svgDocument) unsupported(what string) {
+	if !slices.Contains(d.Unsupported, what) {
+		d.Unsupported = append(d.Unsupported, what)
+	}
+}
+
+// check records what an element's attributes use that is not drawn: paint
+// other than a solid color and the definitions listed in svgReferences.
+func (d *svgDocument) check(attrs map[string]string) {
+	for _, paint := range []string{"fill", "stroke"} {
+		value, ok := attrs[paint]
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(value, "url(") {
+			d.unsupported(paint + " " + value)
+		} else if _, _, ok := parseSVGColor(value); !ok && value != "inherit" {
+			d.unsupported(fmt.Sprintf("%s color %q", paint, value))
+		}
+	}
+	for _, name := range svgReferences {
+		if value, ok := attrs[name]; ok && value != "none" {
+			d.unsupported(name)
+		}
+	}
+}
+
+// svgStyle is the painting state inherited down the element tree. Opacity
+// is that of the element and its groups; FillOpacity and StrokeOpacity are
+// inherited as they are.
+type svgStyle struct {
+	Transform     svgMatrix
+	Fill          color.NRGBA
+	NoFill        bool
+	Opacity       float64
+	FillOpacity   float64
+	EvenOdd       bool
+	Stroke        color.NRGBA
+	NoStroke      bool
+	StrokeOpacity float64
+	StrokeWidth   float64
+	LineJoin      string
+	LineCap       string
+	MiterLimit    float64
+}
+
+// svgDefaultStyle is the style of the root element, as SVG specifies: a
+// black fill and no stroke.
+var svgDefaultStyle = svgStyle{
+	Fill: color.NRGBA{A: 0xff}, Opacity: 1, FillOpacity: 1,
+	NoStroke: true, StrokeOpacity: 1, StrokeWidth: 1, LineJoin: "miter", LineCap: "butt", MiterLimit: 4,
+}
+
+// parseSVG reads an SVG document's size from its root element, fitted to
+// size when it is set, and, when shapes is set, the shapes of every element
+// below it.
+func parseSVG(r io.Reader, size Size, shapes bool) (*svgDocument, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var doc *svgDocument
+	var stack []svgStyle
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			attrs := svgAttributes(t)
+			if doc == nil {
+				if t.Name.Local != "svg" {
+					return nil, errors.New("svg: no <svg> root element")
+				}
+				var base svgMatrix
+				doc, base = svgViewport(attrs, size)
+				if !shapes {
+					return doc, nil
+				}
+				style := svgDefaultStyle
+				style.Transform = base
+				stack = append(stack, style)
+				continue
+			}
+			if reported, ok := svgSkipped[t.Name.Local]; ok || attrs["display"] == "none" {
+				if reported {
+					doc.unsupported("<" + t.Name.Local + ">")
+				}
+				if err := decoder.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			doc.check(attrs)
+			style := stack[len(stack)-1].inherit(attrs)
+			stack = append(stack, style)
+			doc.Shapes = append(doc.Shapes, style.shapes(svgElement(t.Name.Local, attrs, style.Transform))...)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	if doc == nil {
+		return nil, errors.New("svg: no <svg> root element")
+	}
+	return doc, nil
+}
+
+// svgAttributes returns the element's attributes by local name, with the
+// declarations of its style attribute taking precedence.
+func svgAttributes(t xml.StartElement) map[string]string {
+	attrs := make(map[string]string, len(t.Attr))
+	for _, attr := range t.Attr {
+		attrs[attr.Name.Local] = strings.TrimSpace(attr.Value)
+	}
+	for _, declaration := range strings.Split(attrs["style"], ";") {
+		if name, value, ok := strings.Cut(declaration, ":"); ok {
+			attrs[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return attrs
+}
+
+// svgViewport returns the document for the root element's size, fitted to
+// size when it is set, and the transform from its user space to pixels.
+// Without a width or height the viewBox size is used, and without either
+// the SVG default of 300x150.
+func svgViewport(attrs map[string]string, size Size) (*svgDocument, svgMatrix) {
+	viewBox := svgNumbers(attrs["viewBox"])
+	hasViewBox := len(viewBox) == 4 && viewBox[2] > 0 && viewBox[3] > 0
+	w, h := 300.0, 150.0
+	if hasViewBox {
+		w, h = viewBox[2], viewBox[3]
+	}
+	if v, ok := svgLength(attrs["width"]); ok && v > 0 {
+		w = v
+	}
+	if v, ok := svgLength(attrs["height"]); ok && v > 0 {
+		h = v
+	}
+	width, height := max(1, int(math.Round(w))), max(1, int(math.Round(h)))
+	if !size.IsZero() {
+		width, height = fitSize(width, height, size)
+	}
+	doc := &svgDocument{Width: width, Height: height}
+
+	if !hasViewBox {
+		return doc, svgMatrix{float64(width) / w, 0, 0, float64(height) / h, 0, 0}
+	}
+	sx, sy := float64(width)/viewBox[2], float64(height)/viewBox[3]
+	if strings.HasPrefix(attrs["preserveAspectRatio"], "none") {
+		return doc, svgMatrix{sx, 0, 0, sy, -viewBox[0] * sx, -viewBox[1] * sy}
+	}
+	s := math.Min(sx, sy)
+	tx := (float64(width)-viewBox[2]*s)/2 - viewBox[0]*s
+	ty := (float64(height)-viewBox[3]*s)/2 - viewBox[1]*s
+	return doc, svgMatrix{s, 0, 0, s, tx, ty}
+}
+
+// inherit returns the style of an element with the given attributes below
+// one of this style. Opacity multiplies down the tree, which matches SVG
+// group opacity for shapes that do not overlap.
+func (s svgStyle) inherit(attrs map[string]string) svgStyle {
+	if transform, ok := attrs["transform"]; ok {
+		s.Transform = s.Transform.then(parseSVGTransform(transform))
+	}
+	if fill, ok := attrs["fill"]; ok {
+		if c, none, ok := parseSVGColor(fill); ok {
+			s.Fill, s.NoFill = c, none
+		}
+	}
+	if stroke, ok := attrs["stroke"]; ok {
+		if c, none, ok := parseSVGColor(stroke); ok {
+			s.Stroke, s.NoStroke = c, none
+		}
+	}
+	if v, ok := svgOpacity(attrs["opacity"]); ok {
+		s.Opacity *= v
+	}
+	if v, ok := svgOpacity(attrs["fill-opacity"]); ok {
+		s.FillOpacity = v
+	}
+	if v, ok := svgOpacity(attrs["stroke-opacity"]); ok {
+		s.StrokeOpacity = v
+	}
+	switch attrs["fill-rule"] {
+	case "evenodd":
+		s.EvenOdd = true
+	case "nonzero":
+		s.EvenOdd = false
+	}
+	if v, ok := svgLength(attrs["stroke-width"]); ok && v >= 0 {
+		s.StrokeWidth = v
+	}
+	switch join := attrs["stroke-linejoin"]; join {
+	case "miter", "round", "bevel":
+		s.LineJoin = join
+	case "miter-clip", "arcs":
+		s.LineJoin = "miter"
+	}
+	switch lineCap := attrs["stroke-linecap"]; lineCap {
+	case "butt", "round", "square":
+		s.LineCap = lineCap
+	}
+	if v, err := strconv.ParseFloat(attrs["stroke-miterlimit"], 64); err == nil && v >= 1 {
+		s.MiterLimit = v
+	}
+	return s
+}
+
+// shapes returns what an element with the given subpaths paints in this
+// style: its fill, then its stroke on top.
+func (s svgStyle) shapes(subpaths []svgSubpath) []svgShape {
+	var shapes []svgShape
+	if !s.NoFill {
+		var polygons [][]svgPoint
+		for _, sub := range subpaths {
+			if len(sub.Points) > 2 {
+				polygons = append(polygons, sub.Points)
+			}
+		}
+		if len(polygons) > 0 {
+			shapes = append(shapes, svgShape{Polygons: polygons, Color: s.Fill, Opacity: s.Opacity * s.FillOpacity, EvenOdd: s.EvenOdd})
+		}
+	}
+	m := s.Transform
+	// Stroke widths scale with the transform, by its mean scale factor when
+	// it stretches one axis more than the other.
+	halfWidth := s.StrokeWidth * math.Sqrt(math.Abs(m[0]*m[3]-m[1]*m[2])) / 2
+	if !s.NoStroke && halfWidth > 0 {
+		stroke := svgStroke{HalfWidth: halfWidth, Join: s.LineJoin, Cap: s.LineCap, MiterLimit: s.MiterLimit}
+		if polygons := stroke.outline(subpaths); len(polygons) > 0 {
+			shapes = append(shapes, svgShape{Polygons: polygons, Color: s.Stroke, Opacity: s.Opacity * s.StrokeOpacity})
+		}
+	}
+	return shapes
+}
+
+// svgElement returns the subpaths of a shape element in pixels, or nil for
+// elements that are not shapes.
+func svgElement(name string, attrs map[string]string, m svgMatrix) []svgSubpath {
+	num := func(key string) float64 {
+		v, _ := svgLength(attrs[key])
+		return v
+	}
+	p := &svgPath{m: m}
+	switch name {
+	case "path":
+		p.parse(attrs["d"])
+	case "rect":
+		x, y, w, h := num("x"), num("y"), num("width"), num("height")
+		if w <= 0 || h <= 0 {
+			return nil
+		}
+		rx, hasRX := svgLength(attrs["rx"])
+		ry, hasRY := svgLength(attrs["ry"])
+		if !hasRX {
+			rx = ry
+		}
+		if !hasRY {
+			ry = rx
+		}
+		p.rect(x, y, w, h, math.Min(math.Max(rx, 0), w/2), math.Min(math.Max(ry, 0), h/2))
+	case "circle":
+		r := num("r")
+		p.ellipse(num("cx"), num("cy"), r, r)
+	case "ellipse":
+		p.ellipse(num("cx"), num("cy"), num("rx"), num("ry"))
+	case "line":
+		p.moveTo(num("x1"), num("y1"))
+		p.lineTo(num("x2"), num("y2"))
+	case "polygon", "polyline":
+		points := svgNumbers(attrs["points"])
+		for i := 0; i+1 < len(points); i += 2 {
+			if i == 0 {
+				p.moveTo(points[i], points[i+1])
+			} else {
+				p.lineTo(points[i], points[i+1])
+			}
+		}
+		if name == "polygon" {
+			p.closePath()
+		}
+	}
+	p.flush(false)
+	return p.subpaths
+}
+
+// svgSubpath is a flattened subpath in pixels. Fills close every subpath;
+// strokes join the ends of closed ones and cap those of open ones.
+type svgSubpath struct {
+	Points []svgPoint
+	Closed bool
+}
+
+// svgPath flattens SVG path data into subpaths in pixels. Coordinates are
+// given in user space and transformed by m, and curves are split into line
+// segments a couple of pixels long.
+type svgPath struct {
+	m        svgMatrix
+	subpaths []svgSubpath
+	current  []svgPoint
+	// x, y is the current point and sx, sy the start of the subpath, in user
+	// space.
+	x, y, sx, sy float64
+}
+
+// flush ends the current subpath, closed or not. A lone point is kept when
+// closed, as in "M1 1 Z", for the caps a stroke draws around it.
+func (p *svgPath) flush(closed bool) {
+	if len(p.current) > 1 || (closed && len(p.current) == 1) {
+		p.subpaths = append(p.subpaths, svgSubpath{Points: p.current, Closed: closed})
+	}
+	p.current = nil
+}
+
+// moveTo starts a new subpath at (x, y).
+func (p *svgPath) moveTo(x, y float64) {
+	p.flush(false)
+	p.x, p.y, p.sx, p.sy = x, y, x, y
+	p.current = []svgPoint{p.m.apply(x, y)}
+}
+
+// lineTo adds a straight segment to (x, y).
+func (p *svgPath) lineTo(x, y float64) {
+	if p.current == nil {
+		p.current = []svgPoint{p.m.apply(p.x, p.y)}
+	}
+	p.x, p.y = x, y
+	p.current = append(p.current, p.m.apply(x, y))
+}
+
+// cubicTo adds a cubic Bézier segment with control points (x1, y1) and
+// (x2, y2) ending at (x, y).
+func (p *svgPath) cubicTo(x1, y1, x2, y2, x, y float64) {
+	p0, p1, p2, p3 := p.m.apply(p.x, p.y), p.m.apply(x1, y1), p.m.apply(x2, y2), p.m.apply(x, y)
+	n := svgSegments(p0, p1, p2, p3)
+	if p.current == nil {
+		p.current = []svgPoint{p0}
+	}
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		p.current = append(p.current, svgPoint{
+			a*p0.X + b*p1.X + c*p2.X + d*p3.X,
+			a*p0.Y + b*p1.Y + c*p2.Y + d*p3.Y,
+		})
+	}
+	p.x, p.y = x, y
+}
+
+// quadTo adds a quadratic Bézier segment with control point (x1, y1) ending
+// at (x, y), as the equivalent cubic.
+func (p *svgPath) quadTo(x1, y1, x, y float64) {
+	p.cubicTo(p.x+2.0/3*(x1-p.x), p.y+2.0/3*(y1-p.y), x+2.0/3*(x1-x), y+2.0/3*(y1-y), x, y)
+}
+
+// closePath closes the current subpath and returns to its start.
+func (p *svgPath) closePath() {
+	p.flush(true)
+	p.x, p.y = p.sx, p.sy
+}
+
+// svgSegments returns how many line segments to split a cubic Bézier into,
+// about one per two pixels of its control polygon's length.
+func svgSegments(points ...svgPoint) int {
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		length += math.Hypot(points[i].X-points[i-1].X, points[i].Y-points[i-1].Y)
+	}
+	return min(256, max(1, int(length/2)+1))
+}
+
+// svgKappa places the control points of a cubic Bézier approximating a
+// quarter of a circle.
+const svgKappa = 0.5522847498
+
+// ellipse adds the ellipse centered at (cx, cy) with radii rx and ry as a
+// subpath of four cubic segments.
+func (p *svgPath) ellipse(cx, cy, rx, ry float64) {
+	if rx <= 0 || ry <= 0 {
+		return
+	}
+	kx, ky := rx*svgKappa, ry*svgKappa
+	p.moveTo(cx+rx, cy)
+	p.cubicTo(cx+rx, cy+ky, cx+kx, cy+ry, cx, cy+ry)
+	p.cubicTo(cx-kx, cy+ry, cx-rx, cy+ky, cx-rx, cy)
+	p.cubicTo(cx-rx, cy-ky, cx-kx, cy-ry, cx, cy-ry)
+	p.cubicTo(cx+kx, cy-ry, cx+rx, cy-ky, cx+rx, cy)
+	p.closePath()
+}
+
+// rect adds the rectangle at (x, y) of size w x h, with corners rounded by
+// radii rx and ry, as a subpath.
+func (p *svgPath) rect(x, y, w, h, rx, ry float64) {
+	kx, ky := rx*svgKappa, ry*svgKappa
+	p.moveTo(x+rx, y)
+	p.lineTo(x+w-rx, y)
+	if rx > 0 && ry > 0 {
+		p.cubicTo(x+w-rx+kx, y, x+w, y+ry-ky, x+w, y+ry)
+	}
+	p.lineTo(x+w, y+h-ry)
+	if rx > 0 && ry > 0 {
+		p.cubicTo(x+w, y+h-ry+ky, x+w-rx+kx, y+h, x+w-rx, y+h)
+	}
+	p.lineTo(x+rx, y+h)
+	if rx > 0 && ry > 0 {
+		p.cubicTo(x+rx-kx, y+h, x, y+h-ry+ky, x, y+h-ry)
+	}
+	p.lineTo(x, y+ry)
+	if rx > 0 && ry > 0 {
+		p.cubicTo(x, y+ry-ky, x+rx-kx, y, x+rx, y)
+	}
+	p.closePath()
+}
+
+// arcTo adds an elliptical arc to (x, y) as in the SVG A command, converted
+// to cubic segments of at most a quarter turn each. Out-of-range radii are
+// scaled up as the SVG specification requires.
+func (p *svgPath) arcTo(rx, ry, rotation float64, large, sweep bool, x, y float64) {
+	x0, y0 := p.x, p.y
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || (x0 == x && y0 == y) {
+		p.lineTo(x, y)
+		return
+	}
+	phi := rotation * math.Pi / 180
+	cos, sin := math.Cos(phi), math.Sin(phi)
+	dx, dy := (x0-x)/2, (y0-y)/2
+	x1 := cos*dx + sin*dy
+	y1 := -sin*dx + cos*dy
+	if l := x1*x1/(rx*rx) + y1*y1/(ry*ry); l > 1 {
+		rx, ry = rx*math.Sqrt(l), ry*math.Sqrt(l)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cx1, cy1 := coef*rx*y1/ry, -coef*ry*x1/rx
+	cx := cos*cx1 - sin*cy1 + (x0+x)/2
+	cy := sin*cx1 + cos*cy1 + (y0+y)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	start := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	k := 4.0 / 3 * math.Tan(step/4)
+	point := func(theta float64) (float64, float64, float64, float64) {
+		ex, ey := rx*math.Cos(theta), ry*math.Sin(theta)
+		tx, ty := -rx*math.Sin(theta), ry*math.Cos(theta)
+		return cos*ex - sin*ey + cx, sin*ex + cos*ey + cy, cos*tx - sin*ty, sin*tx + cos*ty
+	}
+	for i := 0; i < n; i++ {
+		a, b := start+float64(i)*step, start+float64(i+1)*step
+		ax, ay, atx, aty := point(a)
+		bx, by, btx, bty := point(b)
+		if i == n-1 {
+			bx, by = x, y
+		}
+		p.cubicTo(ax+k*atx, ay+k*aty, bx-k*btx, by-k*bty, bx, by)
+	}
+}
+
+// parse adds the subpaths of SVG path data. Data after a malformed command
+// is ignored, as SVG renderers draw a path up to its first error.
+func (p *svgPath) parse(d string) {
+	s := svgScanner{s: d}
+	var cmd byte
+	var cx, cy float64 // the last control point, for S and T
+	for {
+		s.skipSeparators()
+		if s.done() {
+			return
+		}
+		if c := s.s[s.i]; isSVGCommand(c) {
+			cmd = c
+			s.i++
+		} else if cmd == 0 || cmd == 'Z' || cmd == 'z' {
+			return
+		}
+		rel := cmd >= 'a'
+		ox, oy := 0.0, 0.0
+		if rel {
+			ox, oy = p.x, p.y
+		}
+		args := svgArgCounts[cmd|0x20]
+		v := make([]float64, args)
+		for i := range v {
+			var ok bool
+			if cmd|0x20 == 'a' && (i == 3 || i == 4) {
+				v[i], ok = s.flag()
+			} else {
+				v[i], ok = s.number()
+			}
+			if !ok {
+				return
+			}
+		}
+		lastX, lastY := p.x, p.y
+		switch cmd | 0x20 {
+		case 'm':
+			p.moveTo(ox+v[0], oy+v[1])
+			if rel {
+				cmd = 'l'
+			} else {
+				cmd = 'L'
+			}
+		case 'l':
+			p.lineTo(ox+v[0], oy+v[1])
+		case 'h':
+			p.lineTo(ox+v[0], p.y)
+		case 'v':
+			p.lineTo(p.x, oy+v[0])
+		case 'c':
+			p.cubicTo(ox+v[0], oy+v[1], ox+v[2], oy+v[3], ox+v[4], oy+v[5])
+			cx, cy = ox+v[2], oy+v[3]
+		case 's':
+			x1, y1 := 2*lastX-cx, 2*lastY-cy
+			p.cubicTo(x1, y1, ox+v[0], oy+v[1], ox+v[2], oy+v[3])
+			cx, cy = ox+v[0], oy+v[1]
+		case 'q':
+			p.quadTo(ox+v[0], oy+v[1], ox+v[2], oy+v[3])
+			cx, cy = ox+v[0], oy+v[1]
+		case 't':
+			x1, y1 := 2*lastX-cx, 2*lastY-cy
+			p.quadTo(x1, y1, ox+v[0], oy+v[1])
+			cx, cy = x1, y1
+		case 'a':
+			p.arcTo(v[0], v[1], v[2], v[3] != 0, v[4] != 0, ox+v[5], oy+v[6])
+		case 'z':
+			p.closePath()
+		}
+		// Reflected control points only follow curves of the same kind.
+		switch cmd | 0x20 {
+		case 'c', 's', 'q', 't':
+		default:
+			cx, cy = p.x, p.y
+		}
+	}
+}
+
+// svgArgCounts is the number of arguments each path command takes.
+var svgArgCounts = map[byte]int{'m': 2, 'l': 2, 'h': 1, 'v': 1, 'c': 6, 's': 4, 'q': 4, 't': 2, 'a': 7, 'z': 0}
+
+// isSVGCommand reports whether c is a path command letter.
+func isSVGCommand(c byte) bool {
+	_, ok := svgArgCounts[c|0x20]
+	return ok
+}
+
+// svgScanner reads the numbers of SVG path data and attribute lists, which
+// may be separated by whitespace, commas or nothing at all, as in "1-2.5.5".
+type svgScanner struct {
+	s string
+	i int
+}
+
+// done reports whether the whole string was read.
+func (s *svgScanner) done() bool { return s.i >= len(s.s) }
+
+// skipSeparators skips whitespace and commas.
+func (s *svgScanner) skipSeparators() {
+	for !s.done() && strings.IndexByte(" \t\r\n,", s.s[s.i]) >= 0 {
+		s.i++
+	}
+}
+
+// number reads the next number.
+func (s *svgScanner) number() (float64, bool) {
+	s.skipSeparators()
+	start := s.i
+	if !s.done() && (s.s[s.i] == '+' || s.s[s.i] == '-') {
+		s.i++
+	}
+	digits, dot := 0, false
+	for !s.done() {
+		c := s.s[s.i]
+		if c >= '0' && c <= '9' {
+			digits++
+		} else if c == '.' && !dot {
+			dot = true
+		} else {
+			break
+		}
+		s.i++
+	}
+	if digits > 0 && !s.done() && (s.s[s.i] == 'e' || s.s[s.i] == 'E') {
+		j := s.i + 1
+		if j < len(s.s) && (s.s[j] == '+' || s.s[j] == '-') {
+			j++
+		}
+		if j < len(s.s) && s.s[j] >= '0' && s.s[j] <= '9' {
+			for j < len(s.s) && s.s[j] >= '0' && s.s[j] <= '9' {
+				j++
+			}
+			s.i = j
+		}
+	}
+	if digits == 0 {
+		s.i = start
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s.s[start:s.i], 64)
+	return v, err == nil
+}
+
+// flag reads an arc flag, a single 0 or 1 that needs no separator.
+func (s *svgScanner) flag() (float64, bool) {
+	s.skipSeparators()
+	if s.done() || (s.s[s.i] != '0' && s.s[s.i] != '1') {
+		return 0, false
+	}
+	s.i++
+	return float64(s.s[s.i-1] - '0'), true
+}
+
+// svgNumbers reads a list of numbers, such as a viewBox or polygon points,
+// stopping at the first malformed one.
+func svgNumbers(value string) []float64 {
+	s := svgScanner{s: value}
+	var numbers []float64
+	for {
+		v, ok := s.number()
+		if !ok {
+			return numbers
+		}
+		numbers = append(numbers, v)
+	}
+}
+
+// svgLength reads a length attribute in user units. Units are ignored, so
+// "16px" and "16" are the same, and percentages are not supported.
+func svgLength(value string) (float64, bool) {
+	if value == "" || strings.HasSuffix(value, "%") {
+		return 0, false
+	}
+	s := svgScanner{s: value}
+	return s.number()
+}
+
+// svgOpacity reads an opacity value between 0 and 1, or a percentage.
+func svgOpacity(value string) (float64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	percent := strings.HasSuffix(value, "%")
+	v, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return 0, false
+	}
+	if percent {
+		v /= 100
+	}
+	return math.Min(1, math.Max(0, v)), true
+}
+
+// parseSVGTransform reads a transform attribute's list of translate, scale,
+// rotate, skewX, skewY and matrix functions, applied right to left.
+func parseSVGTransform(value string) svgMatrix {
+	m := svgIdentity
+	for _, match := range svgTransformPattern.FindAllStringSubmatch(value, -1) {
+		v := svgNumbers(match[2])
+		arg := func(i int, fallback float64) float64 {
+			if i < len(v) {
+				return v[i]
+			}
+			return fallback
+		}
+		var t svgMatrix
+		switch match[1] {
+		case "matrix":
+			if len(v) != 6 {
+				continue
+			}
+			copy(t[:], v)
+		case "translate":
+			t = svgMatrix{1, 0, 0, 1, arg(0, 0), arg(1, 0)}
+		case "scale":
+			sx := arg(0, 1)
+			t = svgMatrix{sx, 0, 0, arg(1, sx), 0, 0}
+		case "rotate":
+			a := arg(0, 0) * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			cos, sin := math.Cos(a), math.Sin(a)
+			t = svgMatrix{1, 0, 0, 1, cx, cy}.
+				then(svgMatrix{cos, sin, -sin, cos, 0, 0}).
+				then(svgMatrix{1, 0, 0, 1, -cx, -cy})
+		case "skewX":
+			t = svgMatrix{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			t = svgMatrix{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			continue
+		}
+		m = m.then(t)
+	}
+	return m
+}
+
+// parseSVGColor reads a fill or stroke value: a color keyword, "#rgb", "#rrggbb" or
+// "rgb(r, g, b)", with none set for "none" and for paint servers such as
+// gradients, which are not drawn. It reports false for values it does not
+// understand, so the inherited paint stays.
+func parseSVGColor(value string) (c color.NRGBA, none bool, ok bool) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "none" || value == "transparent" || strings.HasPrefix(value, "url("):
+		return color.NRGBA{}, true, true
+	case value == "currentColor":
+		return color.NRGBA{A: 0xff}, false, true
+	case strings.HasPrefix(value, "#") && len(value) == 4:
+		value = "#" + strings.Repeat(value[1:2], 2) + strings.Repeat(value[2:3], 2) + strings.Repeat(value[3:4], 2)
+		fallthrough
+	case strings.HasPrefix(value, "#"):
+		c, err := parseHexColor(value)
+		return c, false, err == nil
+	case strings.HasPrefix(value, "rgb(") && strings.HasSuffix(value, ")"):
+		parts := strings.Split(value[4:len(value)-1], ",")
+		if len(parts) != 3 {
+			return color.NRGBA{}, false, false
+		}
+		var channels [3]uint8
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			scale := 1.0
+			if strings.HasSuffix(part, "%") {
+				part, scale = strings.TrimSuffix(part, "%"), 2.55
+			}
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return color.NRGBA{}, false, false
+			}
+			channels[i] = uint8(math.Round(math.Min(255, math.Max(0, v*scale))))
+		}
+		return color.NRGBA{channels[0], channels[1], channels[2], 0xff}, false, true
+	}
+	c, ok = svgNamedColors[strings.ToLower(value)]
+	return c, false, ok
+}
+
+// rasterizeSVG paints the shapes in order over a transparent image of w x h
+// pixels. x/image/vector fills under the nonzero rule; even-odd shapes are
+// drawn through evenOddMask.
+func rasterizeSVG(w, h int, shapes []svgShape) *image.NRGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	z := vector.NewRasterizer(w, h)
+	for _, shape := range shapes {
+		c := shape.Color
+		c.A = uint8(math.Round(float64(c.A) * shape.Opacity))
+		src := image.NewUniform(c)
+		if shape.EvenOdd {
+			draw.DrawMask(dst, dst.Bounds(), src, image.Point{}, evenOddMask(z, w, h, shape.Polygons), image.Point{}, draw.Over)
+			continue
+		}
+		z.Reset(w, h)
+		for _, polygon := range shape.Polygons {
+			addSVGPolygon(z, polygon)
+		}
+		z.Draw(dst, dst.Bounds(), src, image.Point{})
+	}
+	img := image.NewNRGBA(dst.Bounds())
+	draw.Draw(img, img.Bounds(), dst, image.Point{}, draw.Src)
+	return img
+}
+
+// evenOddMask returns the coverage of polygons under the even-odd rule,
+// combining that of each polygon as an exclusive or, so a pixel inside two
+// of them is left out.
+func evenOddMask(z *vector.Rasterizer, w, h int, polygons [][]svgPoint) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	single := image.NewAlpha(mask.Rect)
+	for _, polygon := range polygons {
+		z.Reset(w, h)
+		z.DrawOp = draw.Src
+		addSVGPolygon(z, polygon)
+		z.Draw(single, single.Bounds(), image.Opaque, image.Point{})
+		for i, b := range single.Pix {
+			a := int(mask.Pix[i])
+			mask.Pix[i] = uint8((a*(255-int(b)) + int(b)*(255-a) + 127) / 255)
+		}
+	}
+	return mask
+}
+
+// addSVGPolygon adds a closed polygon to the rasterizer's path.
+func addSVGPolygon(z *vector.Rasterizer, polygon []svgPoint) {
+	z.MoveTo(float32(polygon[0].X), float32(polygon[0].Y))
+	for _, p := range polygon[1:] {
+		z.LineTo(float32(p.X), float32(p.Y))
+	}
+	z.ClosePath()
+}
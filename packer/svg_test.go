@@ -0,0 +1,152 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// halfRed is a 10x10 document whose left half is filled red.
+const halfRed = `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10" viewBox="0 0 10 10"><rect width="5" height="10" fill="red"/></svg>`
+
+// TestDecodeSVG checks that a document is rasterized at its own size, or
+// scaled to fit the size it is given, with its shapes filled where its
+// viewBox puts them.
+func TestDecodeSVG(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	for _, tc := range []struct {
+		size          Size
+		width, height int
+	}{
+		{Size{}, 10, 10},
+		{Size{W: 20, H: 20}, 20, 20},
+		{Size{W: 40, H: 8}, 8, 8},
+	} {
+		img, unsupported, err := decodeSVG(strings.NewReader(halfRed), tc.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(unsupported) > 0 {
+			t.Errorf("size %v: unsupported content %q in a plain rect", tc.size, unsupported)
+		}
+		if got := img.Bounds().Size(); got != image.Pt(tc.width, tc.height) {
+			t.Errorf("size %v: rasterized at %v, want %dx%d", tc.size, got, tc.width, tc.height)
+			continue
+		}
+		if got := color.NRGBAModel.Convert(img.At(tc.width/4, tc.height/2)); got != red {
+			t.Errorf("size %v: left half is %v, want red", tc.size, got)
+		}
+		if got := color.NRGBAModel.Convert(img.At(tc.width*3/4, tc.height/2)); got.(color.NRGBA).A != 0 {
+			t.Errorf("size %v: right half is %v, want transparent", tc.size, got)
+		}
+		config, err := decodeSVGConfig(strings.NewReader(halfRed), tc.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Width != tc.width || config.Height != tc.height {
+			t.Errorf("size %v: config is %dx%d, want %dx%d", tc.size, config.Width, config.Height, tc.width, tc.height)
+		}
+	}
+}
+
+// TestSVGUnsupported checks that content the rasterizer leaves out is
+// reported once each, while what is only defined and never used, and
+// metadata, is not.
+func TestSVGUnsupported(t *testing.T) {
+	doc := `<svg xmlns="http://www.w3.org/2000/svg" width="8" height="8">
+	<title>icon</title>
+	<defs><linearGradient id="g"/></defs>
+	<rect width="4" height="4" fill="url(#g)"/>
+	<rect width="4" height="4" fill="blue" stroke="black" stroke-dasharray="1 1"/>
+	<line x2="4" stroke="url(#g)"/>
+	<rect width="4" height="4" style="stroke: none; fill: chartreuse"/>
+	<g fill="inherit" clip-path="url(#c)"><circle r="2" stroke="red"/></g>
+	<text>hi</text>
+	<use href="#g"/>
+</svg>`
+	_, unsupported, err := decodeSVG(strings.NewReader(doc), Size{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"fill url(#g)", "stroke-dasharray", "stroke url(#g)", `fill color "chartreuse"`, "clip-path", "<text>", "<use>"}
+	if !slices.Equal(unsupported, want) {
+		t.Errorf("unsupported content %q, want %q", unsupported, want)
+	}
+}
+
+// TestSVGStroke checks that strokes are drawn centered on the path, their
+// width scaled by the transform, with the line caps and joins asked for.
+func TestSVGStroke(t *testing.T) {
+	for _, tc := range []struct {
+		name, shape string
+		inside      []image.Point
+		outside     []image.Point
+	}{
+		{"butt", `<path d="M2 5 H8" stroke-width="2"/>`, []image.Point{{2, 4}, {7, 5}}, []image.Point{{1, 5}, {8, 5}, {5, 3}, {5, 6}}},
+		{"square", `<path d="M2 5 H8" stroke-width="2" stroke-linecap="square"/>`, []image.Point{{1, 5}, {8, 5}}, []image.Point{{0, 5}, {9, 5}}},
+		{"scaled", `<line x1="1" y1="2.5" x2="4" y2="2.5" transform="scale(2)"/>`, []image.Point{{2, 4}, {7, 5}}, []image.Point{{5, 3}, {5, 6}}},
+		{"miter", `<polyline points="2 8 2 2 8 2" stroke-width="2"/>`, []image.Point{{1, 1}, {1, 7}, {7, 1}}, []image.Point{{3, 3}, {0, 0}}},
+		{"bevel", `<polyline points="2 8 2 2 8 2" stroke-width="2" stroke-linejoin="bevel"/>`, []image.Point{{1, 2}, {2, 1}}, []image.Point{{3, 3}}},
+	} {
+		doc := `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"><g fill="none" stroke="red">` + tc.shape + `</g></svg>`
+		img, unsupported, err := decodeSVG(strings.NewReader(doc), Size{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(unsupported) > 0 {
+			t.Errorf("%s: unsupported content %q", tc.name, unsupported)
+		}
+		for _, p := range tc.inside {
+			if got := color.NRGBAModel.Convert(img.At(p.X, p.Y)).(color.NRGBA); got != (color.NRGBA{R: 0xff, A: 0xff}) {
+				t.Errorf("%s: pixel %v is %v, want red", tc.name, p, got)
+			}
+		}
+		for _, p := range tc.outside {
+			if got := color.NRGBAModel.Convert(img.At(p.X, p.Y)).(color.NRGBA); got.A != 0 {
+				t.Errorf("%s: pixel %v is %v, want transparent", tc.name, p, got)
+			}
+		}
+	}
+}
+
+// TestSVGFormat checks that the image package only takes documents starting
+// with an <svg> element for SVG, leaving other XML unclaimed, and that
+// source files are read as SVG by their extension, prolog or not, at the
+// size Options.SVGSize gives.
+func TestSVGFormat(t *testing.T) {
+	RegisterSVGFormat()
+	for _, data := range []string{`<?xml version="1.0"?><feed/>`, `<!-- generated --><plist/>`} {
+		if _, _, err := image.DecodeConfig(strings.NewReader(data)); !errors.Is(err, image.ErrFormat) {
+			t.Errorf("%q: got error %v, want %v", data, err, image.ErrFormat)
+		}
+	}
+	config, format, err := image.DecodeConfig(strings.NewReader(halfRed))
+	if err != nil || format != "svg" || config.Width != 10 || config.Height != 10 {
+		t.Errorf("registered decoder read %q as %s %dx%d, error %v", halfRed, format, config.Width, config.Height, err)
+	}
+
+	fsys := fstest.MapFS{
+		"icon.svg": {Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<!-- exported -->\n" + halfRed)},
+	}
+	opts := Options{MaxWidth: 64, MaxHeight: 64, SVGSize: Size{W: 32, H: 32}, Alpha: AlphaStraight}
+	rectangles, err := LoadFS(context.Background(), fsys, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rectangles) != 1 || rectangles[0].Width != 32 || rectangles[0].Height != 32 {
+		t.Fatalf("loaded %+v, want icon.svg at 32x32", rectangles)
+	}
+	opts.FS = fsys
+	sizes, err := loadImageSizes(context.Background(), []string{"icon.svg"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes[0].Width != 32 || sizes[0].Height != 32 {
+		t.Errorf("planned icon.svg at %dx%d, want 32x32", sizes[0].Width, sizes[0].Height)
+	}
+}
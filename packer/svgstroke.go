@@ -0,0 +1,155 @@
+package packer
+
+import (
+	"math"
+	"slices"
+)
+
+// svgStroke is how a shape's outline is stroked, in pixels: HalfWidth on
+// each side of the path, with the stroke-linejoin, stroke-linecap and
+// stroke-miterlimit the SVG attributes give.
+type svgStroke struct {
+	HalfWidth  float64
+	Join, Cap  string
+	MiterLimit float64
+}
+
+// outline returns the polygons covering the stroke of the subpaths: a quad
+// along each segment, with the joins between segments and the caps at the
+// ends of open subpaths as polygons of their own. Every polygon winds the
+// same way, so filling them under the nonzero rule paints their union.
+func (s svgStroke) outline(subpaths []svgSubpath) [][]svgPoint {
+	var polygons [][]svgPoint
+	add := func(polygon []svgPoint) {
+		if svgArea(polygon) < 0 {
+			slices.Reverse(polygon)
+		}
+		polygons = append(polygons, polygon)
+	}
+	hw := s.HalfWidth
+	for _, sub := range subpaths {
+		points := svgDistinct(sub.Points, sub.Closed)
+		n := len(points)
+		if n == 1 {
+			// A zero-length subpath only shows its caps, squared along the
+			// x axis as it has no direction.
+			switch p := points[0]; s.Cap {
+			case "round":
+				add(svgCircle(p, hw))
+			case "square":
+				add([]svgPoint{{p.X - hw, p.Y - hw}, {p.X + hw, p.Y - hw}, {p.X + hw, p.Y + hw}, {p.X - hw, p.Y + hw}})
+			}
+			continue
+		}
+		segments := n - 1
+		if sub.Closed {
+			segments = n
+		}
+		for i := 0; i < segments; i++ {
+			a, b := points[i], points[(i+1)%n]
+			d := svgDirection(a, b)
+			if !sub.Closed && s.Cap == "square" {
+				if i == 0 {
+					a = svgPoint{a.X - d.X*hw, a.Y - d.Y*hw}
+				}
+				if i == segments-1 {
+					b = svgPoint{b.X + d.X*hw, b.Y + d.Y*hw}
+				}
+			}
+			nx, ny := -d.Y*hw, d.X*hw
+			add([]svgPoint{{a.X + nx, a.Y + ny}, {b.X + nx, b.Y + ny}, {b.X - nx, b.Y - ny}, {a.X - nx, a.Y - ny}})
+		}
+		for i := 0; i < n; i++ {
+			if !sub.Closed && (i == 0 || i == n-1) {
+				continue
+			}
+			if join := s.join(points[(i+n-1)%n], points[i], points[(i+1)%n]); join != nil {
+				add(join)
+			}
+		}
+		if !sub.Closed && s.Cap == "round" {
+			add(svgCircle(points[0], hw))
+			add(svgCircle(points[n-1], hw))
+		}
+	}
+	return polygons
+}
+
+// join returns the polygon filling the gap on the outer side of the turn at
+// v between the segments from prev and to next, or nil where the path goes
+// straight on. Miters longer than MiterLimit times the stroke width fall
+// back to bevels, as in SVG.
+func (s svgStroke) join(prev, v, next svgPoint) []svgPoint {
+	d0, d1 := svgDirection(prev, v), svgDirection(v, next)
+	cross := d0.X*d1.Y - d0.Y*d1.X
+	dot := d0.X*d1.X + d0.Y*d1.Y
+	if math.Abs(cross) < 1e-9 && dot > 0 {
+		return nil
+	}
+	if s.Join == "round" {
+		return svgCircle(v, s.HalfWidth)
+	}
+	// The outer side is the one the path turns away from.
+	side := s.HalfWidth
+	if cross > 0 {
+		side = -side
+	}
+	p0 := svgPoint{v.X - d0.Y*side, v.Y + d0.X*side}
+	p1 := svgPoint{v.X - d1.Y*side, v.Y + d1.X*side}
+	if s.Join == "miter" {
+		// The miter's length over the stroke width is 1 / sin(theta/2) for
+		// the angle theta between the segments, or 1 / cos of half the turn.
+		cosHalf := math.Sqrt((1 + dot) / 2)
+		if cosHalf > 0 && 1/cosHalf <= s.MiterLimit {
+			bx, by := -(d0.Y+d1.Y)/2, (d0.X+d1.X)/2
+			scale := side / (cosHalf * math.Hypot(bx, by))
+			return []svgPoint{v, p0, {v.X + bx*scale, v.Y + by*scale}, p1}
+		}
+	}
+	return []svgPoint{v, p0, p1}
+}
+
+// svgDistinct returns the points without those repeating the one before,
+// or in a closed subpath the first for the last, as they give segments no
+// direction.
+func svgDistinct(points []svgPoint, closed bool) []svgPoint {
+	distinct := []svgPoint{points[0]}
+	for _, p := range points[1:] {
+		if p != distinct[len(distinct)-1] {
+			distinct = append(distinct, p)
+		}
+	}
+	if closed && len(distinct) > 1 && distinct[len(distinct)-1] == distinct[0] {
+		distinct = distinct[:len(distinct)-1]
+	}
+	return distinct
+}
+
+// svgDirection returns the unit vector from a to b.
+func svgDirection(a, b svgPoint) svgPoint {
+	l := math.Hypot(b.X-a.X, b.Y-a.Y)
+	return svgPoint{(b.X - a.X) / l, (b.Y - a.Y) / l}
+}
+
+// svgArea returns the signed area of a polygon, positive when it winds
+// clockwise on screen.
+func svgArea(polygon []svgPoint) float64 {
+	area := 0.0
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return area / 2
+}
+
+// svgCircle returns a polygon approximating the circle centered at c with
+// radius r, with vertices about a pixel apart.
+func svgCircle(c svgPoint, r float64) []svgPoint {
+	n := min(64, max(8, int(math.Ceil(2*math.Pi*r))))
+	polygon := make([]svgPoint, n)
+	for i := range polygon {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		polygon[i] = svgPoint{c.X + r*math.Cos(a), c.Y + r*math.Sin(a)}
+	}
+	return polygon
+}
@@ -0,0 +1,194 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// layerSuffix precedes the layer index in the default filename of each
+// layer of a texture array.
+const layerSuffix = "_layer"
+
+// layerFilename returns the filename of one layer of a texture array with
+// the given base name and extension. With an empty template it is
+// atlas_layer<N>.png, or atlas_<group>_layer<N>.png when grouping; otherwise
+// the template is applied with the layer index as its {page} token.
+func layerFilename(base, ext, template, group string, layer int, kind string) string {
+	if template != "" {
+		return AtlasFilename(base, ext, template, group, layer, kind)
+	}
+	name := base
+	if group != "" {
+		name += "_" + group
+	}
+	return name + layerSuffix + strconv.Itoa(layer) + ext
+}
+
+// arrayManifestFilename returns the filename of the single manifest written
+// for a texture array: the atlas manifest name for base, or the template
+// with its {page} token removed, with the extension of the manifest format.
+func arrayManifestFilename(base, ext, template, group, kind, format string) string {
+	if template != "" {
+		template = strings.ReplaceAll(template, "{page}", "")
+	}
+	return manifestFilename(AtlasFilename(base, ext, template, group, 0, kind), format)
+}
+
+// nextPowerOfTwo returns the smallest power of two that is at least n,
+// and 1 for n below 1.
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// isPowerOfTwo reports whether n is a positive power of two.
+func isPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+// buildTextureArray packs each page of a group and saves them as the layers
+// of a texture array: every layer has the same power-of-two dimensions,
+// large enough for the biggest page. A single manifest lists the layer
+// images and records the layer of each sprite. It prints information about
+// each layer and returns statistics for each. With opts.Verify the saved
+// files are read back and checked. Nothing is saved once ctx is done.
+func buildTextureArray(ctx context.Context, group string, pages [][]Rectangle, opts Options) ([]AtlasStats, error) {
+	start := time.Now()
+	layouts := make([]Layout, len(pages))
+	width, height := 0, 0
+	endPack := profilePhase(ctx, "pack")
+	for i, page := range pages {
+		layout, err := planLayout(page, opts)
+		if err != nil {
+			endPack()
+			return nil, fmt.Errorf("generating texture array: %w", err)
+		}
+		layouts[i] = layout
+		width, height = max(width, layout.Width), max(height, layout.Height)
+	}
+	endPack()
+	width, height = nextPowerOfTwo(width), nextPowerOfTwo(height)
+	planTime := time.Since(start)
+
+	manifestFile := arrayManifestFilename(opts.AtlasName, opts.AtlasExtension, opts.NameTemplate, group, AtlasKindDiffuse, opts.Format)
+	if opts.ManifestTemplate != "" {
+		// As for the layers' template, the one manifest has no {page}.
+		arrayOpts := opts
+		arrayOpts.ManifestTemplate = strings.ReplaceAll(opts.ManifestTemplate, "{page}", "")
+		manifestFile = manifestPath(manifestFile, group, 0, arrayOpts)
+	}
+	manifest := Manifest{
+		Width:   width,
+		Height:  height,
+		Sprites: make(map[string]SpriteEntry),
+	}
+	var stats []AtlasStats
+	var set outputSet
+	defer set.Abort()
+	for i, page := range pages {
+		layerStart := time.Now()
+		layout := layouts[i]
+		layout.Width, layout.Height = width, height
+		reportPlacements(page, layout, opts.Progress)
+		atlas, err := drawAtlas(ctx, page, layout, opts)
+		if err != nil {
+			return nil, fmt.Errorf("generating texture array: %w", err)
+		}
+		packTime := planTime/time.Duration(len(pages)) + time.Since(layerStart)
+
+		layerFile := layerFilename(opts.AtlasName, opts.AtlasExtension, opts.NameTemplate, group, i, AtlasKindDiffuse)
+		otherFiles := []string{manifestFile}
+		if opts.PlacementDump {
+			otherFiles = append(otherFiles, placementsFilename(manifestFile))
+		}
+		if opts.Debug {
+			otherFiles = append(otherFiles, debugFilename(layerFile))
+		}
+		outputs, err := writeAtlasImages(ctx, &set, layerFile, atlas, opts, otherFiles...)
+		if err != nil {
+			return nil, err
+		}
+
+		layer := i
+		pageManifest := buildManifest(layerFile, page, layout)
+		for name, entry := range pageManifest.Sprites {
+			entry.Layer = &layer
+			manifest.Sprites[name] = entry
+		}
+		for _, row := range pageManifest.Rows {
+			row.Layer = &layer
+			manifest.Rows = append(manifest.Rows, row)
+		}
+		manifest.Reserved = pageManifest.Reserved
+		manifest.Layers = append(manifest.Layers, layerFile)
+		if opts.Alpha == AlphaBoth {
+			manifest.PremultipliedLayers = append(manifest.PremultipliedLayers, outputs[1].File)
+		}
+
+		printAtlasInfo(layerFile, width, height, layout.Placements)
+		if opts.ReportLargest > 0 {
+			printLargest(page, opts.ReportLargest, opts.Padding)
+		}
+		if opts.DumpFree {
+			printFreeRegions(layerFile, layout, opts.Padding)
+		}
+		if opts.Verbose {
+			printPackingSummary(layerFile, page, layout, opts)
+		}
+		if opts.Debug {
+			if err := saveDebugOverlay(debugFilename(layerFile), atlas, page, layout, opts); err != nil {
+				return nil, fmt.Errorf("saving debug overlay: %w", err)
+			}
+		}
+		layerStats := newAtlasStats(layerFile, page, layout, packTime)
+		layerStats.Manifest = manifestFile
+		stats = append(stats, layerStats)
+	}
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("saving manifest: %w", context.Cause(ctx))
+	}
+	manifest.Image = manifest.Layers[0]
+	manifest.PremultipliedAlpha = opts.Alpha == AlphaPremultiplied
+	if opts.SDF {
+		manifest.SDFSpread = opts.SDFSpread
+	}
+	if opts.Scale > 1 {
+		manifest.Scale = opts.Scale
+	}
+	if opts.Recorded != nil {
+		manifest.Meta = manifestMeta(opts)
+	}
+	if opts.EmitQuads {
+		addQuads(&manifest)
+	}
+	written := withOrigin(manifest, opts.Origin, false)
+	if err := saveManifest(&set, manifestFile, written, opts); err != nil {
+		return nil, fmt.Errorf("saving manifest: %w", err)
+	}
+	// The layers and their manifest are moved into place together.
+	if err := set.Commit(); err != nil {
+		return nil, fmt.Errorf("saving texture array: %w", err)
+	}
+	if opts.PlacementDump {
+		if err := savePlacementDump(placementsFilename(manifestFile), written, opts.Overwrite); err != nil {
+			return nil, fmt.Errorf("saving placement dump: %w", err)
+		}
+	}
+	if opts.Verify {
+		sprites := 0
+		for _, page := range pages {
+			sprites += spriteCount(page)
+		}
+		if err := verifyOutputs(manifestFile, sprites, opts.Padding); err != nil {
+			return nil, fmt.Errorf("verifying %s: %w", manifestFile, err)
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,65 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"strings"
+)
+
+// TileEntry is one tile of an atlas sliced with -tilesize: its column and
+// row in the grid of tiles, the atlas pixels it holds, and the image file
+// holding them. Tiles in the last column and row are cut short by the
+// atlas's edges.
+type TileEntry struct {
+	Column int    `json:"column"`
+	Row    int    `json:"row"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	W      int    `json:"w"`
+	H      int    `json:"h"`
+	Image  string `json:"image"`
+}
+
+// tileFilename returns the filename of the tile at the given column and row
+// of an atlas, written beside it with -tilesize.
+func tileFilename(atlasFile string, column, row int) string {
+	ext := filepath.Ext(atlasFile)
+	return fmt.Sprintf("%s_tile_%d_%d%s", strings.TrimSuffix(atlasFile, ext), column, row, ext)
+}
+
+// atlasTiles slices an atlas of w x h pixels into tiles of size x size
+// pixels, returned row by row.
+func atlasTiles(atlasFile string, w, h, size int) []TileEntry {
+	var tiles []TileEntry
+	for row, y := 0, 0; y < h; row, y = row+1, y+size {
+		for column, x := 0, 0; x < w; column, x = column+1, x+size {
+			tiles = append(tiles, TileEntry{
+				Column: column,
+				Row:    row,
+				X:      x,
+				Y:      y,
+				W:      min(size, w-x),
+				H:      min(size, h-y),
+				Image:  tileFilename(atlasFile, column, row),
+			})
+		}
+	}
+	return tiles
+}
+
+// saveTiles writes each tile of the atlas image as its own file of set,
+// honoring opts.Overwrite.
+func saveTiles(set *outputSet, img image.Image, tiles []TileEntry, opts Options) error {
+	sub, ok := img.(subImager)
+	if !ok {
+		return fmt.Errorf("%T images cannot be sliced into tiles", img)
+	}
+	for _, tile := range tiles {
+		r := image.Rect(tile.X, tile.Y, tile.X+tile.W, tile.Y+tile.H).Add(img.Bounds().Min)
+		if err := encodeAtlas(set, tile.Image, sub.SubImage(r), opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
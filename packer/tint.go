@@ -0,0 +1,171 @@
+package packer
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Variant is a tinted copy of a sprite, listed under the sprite's sidecar
+// entry and packed as a sprite of its own named Name. Hue rotates every
+// pixel's hue by that many degrees, and Multiply, a "#rrggbb" color, then
+// multiplies each channel by the color's, as a tint layer would. Alpha is
+// kept as it is.
+type Variant struct {
+	Name     string  `json:"name"`
+	Hue      float64 `json:"hue,omitempty"`
+	Multiply string  `json:"multiply,omitempty"`
+}
+
+// parseHexColor parses an opaque color written as "#rrggbb", with or
+// without the "#".
+func parseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: want #rrggbb", s)
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// addVariants appends a tinted copy of every rectangle for each variant its
+// sidecar entry lists. Copies get the next free IDs, the base sprite's
+// metadata and outlines, and an image of the 16-bit NRGBA64 type when deep
+// is set, of the 8-bit NRGBA type otherwise. A variant without a name, with
+// a malformed color, or named like another sprite is an error.
+func addVariants(rectangles []Rectangle, deep bool) ([]Rectangle, error) {
+	names := make(map[string]bool, len(rectangles))
+	nextID := 1
+	for _, rect := range rectangles {
+		names[rect.Name] = true
+		nextID = max(nextID, rect.ID+1)
+	}
+
+	n := len(rectangles)
+	for i := 0; i < n; i++ {
+		base := rectangles[i]
+		for _, variant := range base.Meta.Variants {
+			if variant.Name == "" {
+				return nil, fmt.Errorf("sprite %s: variant without a name", base.Name)
+			}
+			if names[variant.Name] {
+				return nil, fmt.Errorf("sprite %s: variant %s is named like another sprite", base.Name, variant.Name)
+			}
+			multiply := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+			if variant.Multiply != "" {
+				c, err := parseHexColor(variant.Multiply)
+				if err != nil {
+					return nil, fmt.Errorf("sprite %s: variant %s: %w", base.Name, variant.Name, err)
+				}
+				multiply = c
+			}
+			names[variant.Name] = true
+
+			rect := base
+			rect.ID = nextID
+			rect.Name = variant.Name
+			rect.PixelHash = [sha256.Size]byte{}
+			if base.Image != nil {
+				rect.Image = tintImage(base.Image, variant.Hue, multiply, deep)
+				if base.PixelHash != ([sha256.Size]byte{}) {
+					rect.PixelHash = pixelHash(rect.Image)
+				}
+			} else {
+				hue := variant.Hue
+				rect.Reload = func() (image.Image, error) {
+					img, err := base.pixels()
+					if err != nil {
+						return nil, err
+					}
+					return tintImage(img, hue, multiply, deep), nil
+				}
+			}
+			rect.Meta.Variants = nil
+			nextID++
+			rectangles = append(rectangles, rect)
+		}
+	}
+	return rectangles, nil
+}
+
+// tintImage returns a copy of img with the hue of every pixel rotated by
+// hue degrees and its channels then multiplied by those of multiply. Colors
+// are processed straight, at 16 bits per channel.
+func tintImage(img image.Image, hue float64, multiply color.NRGBA, deep bool) image.Image {
+	b := img.Bounds()
+	var out image.Image
+	var set func(x, y int, c color.NRGBA64)
+	if deep {
+		m := image.NewNRGBA64(b)
+		out, set = m, m.SetNRGBA64
+	} else {
+		m := image.NewNRGBA(b)
+		out = m
+		set = func(x, y int, c color.NRGBA64) {
+			m.SetNRGBA(x, y, color.NRGBA{R: uint8(c.R >> 8), G: uint8(c.G >> 8), B: uint8(c.B >> 8), A: uint8(c.A >> 8)})
+		}
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			if c.A != 0 {
+				r, g, bl := float64(c.R)/0xffff, float64(c.G)/0xffff, float64(c.B)/0xffff
+				if hue != 0 {
+					r, g, bl = rotateHue(r, g, bl, hue)
+				}
+				c.R = uint16(math.Round(r * float64(multiply.R) / 0xff * 0xffff))
+				c.G = uint16(math.Round(g * float64(multiply.G) / 0xff * 0xffff))
+				c.B = uint16(math.Round(bl * float64(multiply.B) / 0xff * 0xffff))
+			}
+			set(x, y, c)
+		}
+	}
+	return out
+}
+
+// rotateHue rotates the hue of a color with channels between 0 and 1 by the
+// given number of degrees, keeping its saturation and value.
+func rotateHue(r, g, b, degrees float64) (float64, float64, float64) {
+	v := math.Max(r, math.Max(g, b))
+	chroma := v - math.Min(r, math.Min(g, b))
+	if chroma == 0 {
+		return r, g, b
+	}
+	var h float64
+	switch v {
+	case r:
+		h = math.Mod((g-b)/chroma, 6)
+	case g:
+		h = (b-r)/chroma + 2
+	default:
+		h = (r-g)/chroma + 4
+	}
+	h = math.Mod(h*60+degrees, 360)
+	if h < 0 {
+		h += 360
+	}
+
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var r1, g1, b1 float64
+	switch int(h / 60) {
+	case 0:
+		r1, g1 = chroma, x
+	case 1:
+		r1, g1 = x, chroma
+	case 2:
+		g1, b1 = chroma, x
+	case 3:
+		g1, b1 = x, chroma
+	case 4:
+		r1, b1 = x, chroma
+	default:
+		r1, b1 = chroma, x
+	}
+	m := v - chroma
+	return r1 + m, g1 + m, b1 + m
+}
@@ -0,0 +1,264 @@
+package packer
+
+import (
+	"image"
+	"image/color"
+)
+
+// subImager is implemented by the standard library image types, which can
+// return a view of part of themselves without copying pixels.
+type subImager interface {
+	SubImage(r image.Rectangle) image.Image
+}
+
+// trimRectangle replaces the rectangle's image with the part inside its
+// border, as found by trimBounds, and records the original size and the
+// offset of the kept pixels. Images without a border are left unchanged, and
+// so are those consisting of nothing but border, unless opts.IncludeEmpty
+// asks for them to be trimmed to their top-left pixel; it reports whether
+// the image was left unchanged for that reason, so it can be skipped. Solid
+// trimming never removes every pixel: an image of nothing but its border
+// color only loses its transparent borders, so an opaque one is kept whole.
+func trimRectangle(rect *Rectangle, opts Options) bool {
+	bounds := rect.Image.Bounds()
+	content := trimBounds(rect.Image, opts.TrimSolid, opts.TrimTolerance, opts.TrimAlpha)
+	if content.Empty() && opts.TrimSolid {
+		content = trimBounds(rect.Image, false, 0, opts.TrimAlpha)
+	}
+	if content.Empty() && opts.IncludeEmpty {
+		content = image.Rectangle{Min: bounds.Min, Max: bounds.Min.Add(image.Pt(1, 1))}
+	}
+	if content.Empty() {
+		return true
+	}
+	if content == bounds {
+		return false
+	}
+
+	sub, ok := rect.Image.(subImager)
+	if !ok {
+		return false
+	}
+
+	rect.Trimmed = true
+	rect.SourceWidth = bounds.Dx()
+	rect.SourceHeight = bounds.Dy()
+	rect.TrimOffset = content.Min.Sub(bounds.Min)
+	rect.Image = sub.SubImage(content)
+	rect.Width = content.Dx()
+	rect.Height = content.Dy()
+	return false
+}
+
+// trimBounds returns the smallest rectangle containing every non-border pixel
+// of img, or an empty rectangle if there are none. A border pixel has an
+// alpha of at most alpha, so 0 trims only fully transparent pixels; when
+// solid is set, a pixel whose color is within tolerance of the top-left
+// pixel's color on every channel also counts as border. A row or column is
+// trimmed only if all of its pixels are border, so a single pixel more
+// opaque than alpha, such as the faint edge of a drop shadow, keeps it.
+// Edges are scanned inward one row or column at a time and each scan stops
+// at the first non-border pixel, so only the border and the edges of the
+// content are read. Pixels are read with nrgbaReader, which avoids the cost
+// of At for the image types decoders commonly return.
+func trimBounds(img image.Image, solid bool, tolerance, alpha int) image.Rectangle {
+	b := img.Bounds()
+	if b.Empty() {
+		return image.Rectangle{}
+	}
+
+	isBorder := borderTest(img, solid, tolerance, alpha)
+	rowIsBorder := func(y, x0, x1 int) bool {
+		for x := x0; x < x1; x++ {
+			if !isBorder(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+	colIsBorder := func(x, y0, y1 int) bool {
+		for y := y0; y < y1; y++ {
+			if !isBorder(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+	if pix, stride, ok := alphaBytes(img); ok && !solid {
+		// Only transparency matters, so test the alpha bytes in place.
+		rowIsBorder = func(y, x0, x1 int) bool {
+			row := (y-b.Min.Y)*stride + (x0-b.Min.X)*4
+			for i := row; i < row+(x1-x0)*4; i += 4 {
+				if int(pix[i]) > alpha {
+					return false
+				}
+			}
+			return true
+		}
+		colIsBorder = func(x, y0, y1 int) bool {
+			col := (y0-b.Min.Y)*stride + (x-b.Min.X)*4
+			for i := col; i < col+(y1-y0)*stride; i += stride {
+				if int(pix[i]) > alpha {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	top := b.Min.Y
+	for top < b.Max.Y && rowIsBorder(top, b.Min.X, b.Max.X) {
+		top++
+	}
+	if top == b.Max.Y {
+		return image.Rectangle{}
+	}
+	bottom := b.Max.Y
+	for rowIsBorder(bottom-1, b.Min.X, b.Max.X) {
+		bottom--
+	}
+	left := b.Min.X
+	for colIsBorder(left, top, bottom) {
+		left++
+	}
+	right := b.Max.X
+	for colIsBorder(right-1, top, bottom) {
+		right--
+	}
+	return image.Rect(left, top, right, bottom)
+}
+
+// borderTest returns a function reporting whether the pixel of img at (x, y)
+// is border, as trimBounds defines it. The image must not be empty.
+func borderTest(img image.Image, solid bool, tolerance, alpha int) func(x, y int) bool {
+	at := nrgbaReader(img)
+	corner := at(img.Bounds().Min.X, img.Bounds().Min.Y)
+	return func(x, y int) bool {
+		c := at(x, y)
+		if int(c.A) <= alpha {
+			return true
+		}
+		return solid &&
+			within(c.R, corner.R, tolerance) &&
+			within(c.G, corner.G, tolerance) &&
+			within(c.B, corner.B, tolerance) &&
+			within(c.A, corner.A, tolerance)
+	}
+}
+
+// alphaBytes returns the pixel buffer of an *image.NRGBA or *image.RGBA,
+// starting at the alpha byte of its top-left pixel, and the buffer's
+// stride. Both types store alpha in the fourth byte of each pixel, and it
+// holds the same value in both, unaffected by premultiplication.
+func alphaBytes(img image.Image) ([]byte, int, bool) {
+	switch m := img.(type) {
+	case *image.NRGBA:
+		return m.Pix[m.PixOffset(m.Rect.Min.X, m.Rect.Min.Y)+3:], m.Stride, true
+	case *image.RGBA:
+		return m.Pix[m.PixOffset(m.Rect.Min.X, m.Rect.Min.Y)+3:], m.Stride, true
+	default:
+		return nil, 0, false
+	}
+}
+
+// hasAlpha reports whether any pixel of img is less than fully opaque,
+// stopping at the first one found. *image.NRGBA and *image.RGBA are scanned
+// through their alpha bytes and *image.Paletted through the palette entries
+// that are translucent, while *image.Gray, *image.Gray16, *image.CMYK and
+// the *image.YCbCr that JPEGs decode to have no alpha and are not scanned at
+// all. Other types are read through At.
+func hasAlpha(img image.Image) bool {
+	b := img.Bounds()
+	if pix, stride, ok := alphaBytes(img); ok {
+		for y := 0; y < b.Dy(); y++ {
+			row := pix[y*stride:]
+			for x := 0; x < b.Dx(); x++ {
+				if row[4*x] != 0xff {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch m := img.(type) {
+	case *image.Gray, *image.Gray16, *image.CMYK, *image.YCbCr:
+		return false
+	case *image.Paletted:
+		var translucent [256]bool
+		found := false
+		for i, c := range m.Palette {
+			if _, _, _, a := c.RGBA(); a != 0xffff && i < len(translucent) {
+				translucent[i], found = true, true
+			}
+		}
+		if !found {
+			return false
+		}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for _, i := range m.Pix[m.PixOffset(b.Min.X, y):][:b.Dx()] {
+				if translucent[i] {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nrgbaReader returns a function reading the straight-alpha color of a pixel
+// of img. For *image.NRGBA, *image.RGBA, *image.Paletted and *image.Gray it
+// reads the pixel buffer directly, only converting translucent RGBA pixels,
+// instead of going through At and a color model for every pixel.
+func nrgbaReader(img image.Image) func(x, y int) color.NRGBA {
+	switch m := img.(type) {
+	case *image.NRGBA:
+		return func(x, y int) color.NRGBA {
+			s := m.Pix[m.PixOffset(x, y):]
+			return color.NRGBA{R: s[0], G: s[1], B: s[2], A: s[3]}
+		}
+	case *image.RGBA:
+		return func(x, y int) color.NRGBA {
+			s := m.Pix[m.PixOffset(x, y):]
+			switch s[3] {
+			case 0:
+				return color.NRGBA{}
+			case 0xff:
+				return color.NRGBA{R: s[0], G: s[1], B: s[2], A: 0xff}
+			}
+			return color.NRGBAModel.Convert(color.RGBA{R: s[0], G: s[1], B: s[2], A: s[3]}).(color.NRGBA)
+		}
+	case *image.Paletted:
+		var palette [256]color.NRGBA
+		for i, c := range m.Palette {
+			palette[i] = color.NRGBAModel.Convert(c).(color.NRGBA)
+		}
+		return func(x, y int) color.NRGBA {
+			return palette[m.Pix[m.PixOffset(x, y)]]
+		}
+	case *image.Gray:
+		return func(x, y int) color.NRGBA {
+			g := m.Pix[m.PixOffset(x, y)]
+			return color.NRGBA{R: g, G: g, B: g, A: 0xff}
+		}
+	default:
+		return func(x, y int) color.NRGBA {
+			return color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		}
+	}
+}
+
+// within reports whether a and b differ by at most tolerance.
+func within(a, b uint8, tolerance int) bool {
+	d := int(a) - int(b)
+	return d <= tolerance && -d <= tolerance
+}
@@ -0,0 +1,118 @@
+package packer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// TestTrimInvariant checks that for every trimmed sprite the offset, the
+// kept size and the right and bottom margins add up to its source size,
+// including sources whose bounds do not start at the origin and content
+// touching their edges, and that verifyTrim fails each rectangle whose trim
+// is off by one.
+func TestTrimInvariant(t *testing.T) {
+	sheet := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for _, content := range []image.Rectangle{
+		image.Rect(5, 4, 12, 9),
+		image.Rect(0, 0, 3, 3),
+		image.Rect(30, 20, 40, 30),
+		image.Rect(0, 12, 40, 13),
+		image.Rect(20, 0, 21, 30),
+	} {
+		for _, bounds := range []image.Rectangle{sheet.Bounds(), image.Rect(0, 0, 40, 30).Intersect(content.Inset(-2))} {
+			img := image.NewNRGBA(sheet.Bounds())
+			draw.Draw(img, content, patterned(content.Dx(), content.Dy(), 2), image.Point{}, draw.Src)
+			source := img.SubImage(bounds)
+			rect := Rectangle{Name: "s", Image: source, Width: bounds.Dx(), Height: bounds.Dy()}
+			trimRectangle(&rect, Options{Trim: true})
+			if err := verifyTrim(rect, source, Options{Trim: true}); err != nil {
+				t.Errorf("content %v of %v: %v", content, bounds, err)
+				continue
+			}
+			if rect.Trimmed {
+				right := bounds.Max.X - rect.Image.Bounds().Max.X
+				bottom := bounds.Max.Y - rect.Image.Bounds().Max.Y
+				if rect.TrimOffset.X+rect.Width+right != rect.SourceWidth || rect.TrimOffset.Y+rect.Height+bottom != rect.SourceHeight {
+					t.Errorf("content %v of %v: trim %+v does not add up", content, bounds, rect)
+				}
+			}
+
+			for name, broken := range map[string]func(r *Rectangle){
+				"offset":      func(r *Rectangle) { r.TrimOffset.X++ },
+				"width":       func(r *Rectangle) { r.Width-- },
+				"source size": func(r *Rectangle) { r.SourceHeight++ },
+				"kept area":   func(r *Rectangle) { r.Image = img.SubImage(r.Image.Bounds().Inset(1)) },
+			} {
+				wrong := rect
+				wrong.Trimmed, wrong.SourceWidth, wrong.SourceHeight = true, bounds.Dx(), bounds.Dy()
+				wrong.TrimOffset = rect.Image.Bounds().Min.Sub(bounds.Min)
+				broken(&wrong)
+				if err := verifyTrim(wrong, source, Options{Trim: true}); err == nil {
+					t.Errorf("content %v of %v: trim with a wrong %s passed", content, bounds, name)
+				}
+			}
+		}
+	}
+}
+
+// TestTrimSolidUniform checks that solid trimming never trims an image of
+// nothing but its border color away: an opaque one is kept whole and one
+// with transparent borders only loses those.
+func TestTrimSolidUniform(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	corner := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(corner, image.Rect(0, 0, 5, 5), image.NewUniform(red), image.Point{}, draw.Src)
+	for _, tc := range []struct {
+		name string
+		img  image.Image
+		want image.Rectangle
+	}{
+		{"opaque", solid(6, 4, red), image.Rect(0, 0, 6, 4)},
+		{"transparent borders", corner, image.Rect(0, 0, 5, 5)},
+	} {
+		rect := Rectangle{Name: tc.name, Image: tc.img, Width: tc.img.Bounds().Dx(), Height: tc.img.Bounds().Dy()}
+		opts := Options{Trim: true, TrimSolid: true}
+		if trimRectangle(&rect, opts) {
+			t.Errorf("%s: trimmed away", tc.name)
+			continue
+		}
+		if got := rect.Image.Bounds(); got != tc.want {
+			t.Errorf("%s: kept %v, want %v", tc.name, got, tc.want)
+		}
+		if rect.Trimmed {
+			if err := verifyTrim(rect, tc.img, opts); err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			}
+		}
+	}
+}
+
+// opaqueImage hides the concrete type of an image, so trimBounds reads its
+// pixels through At.
+type opaqueImage struct{ image.Image }
+
+// BenchmarkTrimBounds measures finding the content of a 4096x4096 sprite that
+// is mostly transparent, reading its pixel buffer directly and, for
+// comparison, through At.
+func BenchmarkTrimBounds(b *testing.B) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4096, 4096))
+	content := image.Rect(1800, 2000, 2100, 2300)
+	draw.Draw(img, content, patterned(content.Dx(), content.Dy(), 1), image.Point{}, draw.Src)
+	for _, bench := range []struct {
+		name string
+		img  image.Image
+	}{
+		{"nrgba", img},
+		{"at", opaqueImage{img}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			for b.Loop() {
+				if got := trimBounds(bench.img, false, 0, 0); got != content {
+					b.Fatalf("trimmed to %v, want %v", got, content)
+				}
+			}
+		})
+	}
+}
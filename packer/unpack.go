@@ -0,0 +1,37 @@
+package packer
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Unpack cuts every sprite of the manifest out of its atlas image, keyed by
+// sprite name, as the inverse of packing. Each sprite is a view of the
+// atlas's pixels covering its rectangle, so its bounds are where it sits in
+// the atlas rather than starting at the origin; atlas types that cannot
+// share their pixels are copied. Sprites are returned as packed: trimmed,
+// halved by -mirrorhalves and scaled as they were, except that sprites
+// packed rotated are copied and turned back upright, with their bounds at
+// the origin. For a texture array, atlas must be the layer holding the
+// sprites wanted, and only sprites on that layer make sense. Rectangles
+// reaching outside the atlas are clipped to it.
+func Unpack(atlas image.Image, manifest Manifest) map[string]image.Image {
+	sprites := make(map[string]image.Image, len(manifest.Sprites))
+	bounds := atlas.Bounds()
+	for name, entry := range manifest.Sprites {
+		r := image.Rect(entry.X, entry.Y, entry.X+entry.W, entry.Y+entry.H).Add(bounds.Min).Intersect(bounds)
+		var img image.Image
+		if sub, ok := atlas.(subImager); ok {
+			img = sub.SubImage(r)
+		} else {
+			copied := image.NewNRGBA(r)
+			draw.Draw(copied, r, atlas, r.Min, draw.Src)
+			img = copied
+		}
+		if entry.Rotated {
+			img = turnBack(img)
+		}
+		sprites[name] = img
+	}
+	return sprites
+}
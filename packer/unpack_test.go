@@ -0,0 +1,73 @@
+package packer
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// patterned returns a w by h image whose every pixel differs, so a sprite
+// cut out at the wrong offset or turned the wrong way does not match.
+func patterned(w, h int, seed uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 8), G: uint8(y * 8), B: seed, A: 255})
+		}
+	}
+	return img
+}
+
+// sameImage reports the first pixel at which got, read from its own bounds,
+// differs from want, which starts at the origin.
+func sameImage(t *testing.T, name string, got, want image.Image) {
+	t.Helper()
+	if got.Bounds().Size() != want.Bounds().Size() {
+		t.Errorf("%s is %v, want %v", name, got.Bounds().Size(), want.Bounds().Size())
+		return
+	}
+	origin := got.Bounds().Min
+	for y := 0; y < want.Bounds().Dy(); y++ {
+		for x := 0; x < want.Bounds().Dx(); x++ {
+			g := color.NRGBAModel.Convert(got.At(origin.X+x, origin.Y+y))
+			w := color.NRGBAModel.Convert(want.At(x, y))
+			if g != w {
+				t.Errorf("%s: pixel (%d, %d) is %v, want %v", name, x, y, g, w)
+				return
+			}
+		}
+	}
+}
+
+// TestUnpack checks that Unpack, given the manifest of an atlas, gives back
+// the pixels of every sprite drawn into it, including one packed turned.
+func TestUnpack(t *testing.T) {
+	sources := map[string]*image.NRGBA{
+		"a.png":    patterned(6, 8, 1),
+		"b.png":    patterned(5, 3, 2),
+		"wide.png": patterned(12, 4, 3),
+	}
+	rectangles := []Rectangle{
+		{ID: 0, Name: "a.png", Width: 6, Height: 8, Image: sources["a.png"]},
+		{ID: 1, Name: "wide.png", Width: 12, Height: 4, Image: sources["wide.png"]},
+		{ID: 2, Name: "b.png", Width: 5, Height: 3, Image: sources["b.png"]},
+	}
+	opts := Options{MaxWidth: 10, MaxHeight: 64, Padding: Padding{X: 1, Y: 1}, AllowRotation: true}
+	atlas, layout, err := generateAtlas(context.Background(), rectangles, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := buildManifest("atlas.png", rectangles, layout)
+	if !manifest.Sprites["wide.png"].Rotated {
+		t.Fatalf("wide.png was not packed turned: %+v", manifest.Sprites["wide.png"])
+	}
+
+	sprites := Unpack(atlas, manifest)
+	if len(sprites) != len(sources) {
+		t.Fatalf("Unpack returned %d sprites, want %d", len(sprites), len(sources))
+	}
+	for name, want := range sources {
+		sameImage(t, name, sprites[name], want)
+	}
+}
@@ -0,0 +1,47 @@
+package packer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// printPackingSummary prints how well an atlas is packed, for -verbose: the
+// area of its sprites against that of the atlas, the shelves the shelf
+// packer opened, counted by the distinct top edges of the sprites, and the
+// largest of its free regions as freeRegions finds them.
+func printPackingSummary(filename string, rectangles []Rectangle, layout Layout, opts Options) {
+	used := 0
+	for _, rect := range rectangles {
+		used += rect.Width * rect.Height
+	}
+	fmt.Printf("Packing summary of %s: %d of %d px used (%.1f%% utilization)\n", filename, used, layout.Width*layout.Height, occupancy(rectangles, layout)*100)
+	if strings.HasPrefix(algorithmName(opts), PackerShelf) {
+		tops := make(map[int]bool)
+		for _, r := range layout.Placements {
+			tops[r.Min.Y] = true
+		}
+		fmt.Printf("  shelves: %d\n", len(tops))
+	}
+	if regions := freeRegions(layout, opts.Padding); len(regions) > 0 {
+		r := regions[0]
+		fmt.Printf("  largest gap: %v, %d x %d, %d px\n", r, r.Dx(), r.Dy(), r.Dx()*r.Dy())
+	} else {
+		fmt.Println("  largest gap: none")
+	}
+}
+
+// totalUtilization returns the fraction of the area of all the atlases
+// covered by their sprites, in the range [0, 1], so that a nearly empty last
+// page counts for only as much area as it has.
+func totalUtilization(atlases []AtlasStats) float64 {
+	used, total := 0.0, 0
+	for _, atlas := range atlases {
+		area := atlas.Width * atlas.Height
+		used += atlas.Occupancy * float64(area)
+		total += area
+	}
+	if total == 0 {
+		return 0
+	}
+	return used / float64(total)
+}
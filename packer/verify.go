@@ -0,0 +1,178 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"sort"
+)
+
+// verifyOutputs re-reads a manifest that was just written, along with every
+// atlas image it names, and checks that they agree: each image decodes in
+// full and has the manifest's dimensions, the manifest lists the expected
+// number of sprites, every sprite rectangle is non-empty and lies within
+// the atlas, and no two sprites are closer than the padding, as checked by
+// checkSpacing. It catches truncated writes and encoder and packer bugs
+// before a broken atlas is shipped.
+func verifyOutputs(manifestFile string, sprites int, padding Padding) error {
+	manifest, err := ReadManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+
+	images := []string{manifest.Image}
+	if manifest.PremultipliedImage != "" {
+		images = append(images, manifest.PremultipliedImage)
+	}
+	images = append(images, manifest.Layers...)
+	images = append(images, manifest.PremultipliedLayers...)
+	for _, file := range images {
+		if err := verifyImage(file, manifest.Width, manifest.Height); err != nil {
+			return err
+		}
+	}
+
+	if len(manifest.Sprites) != sprites {
+		return fmt.Errorf("manifest lists %d sprites, expected %d", len(manifest.Sprites), sprites)
+	}
+	bounds := image.Rect(0, 0, manifest.Width, manifest.Height)
+	for name, sprite := range manifest.Sprites {
+		r := image.Rect(sprite.X, sprite.Y, sprite.X+sprite.W, sprite.Y+sprite.H)
+		if sprite.W <= 0 || sprite.H <= 0 {
+			return fmt.Errorf("sprite %s is empty: %dx%d", name, sprite.W, sprite.H)
+		}
+		if !r.In(bounds) {
+			return fmt.Errorf("sprite %s at %v lies outside the %dx%d atlas", name, r, manifest.Width, manifest.Height)
+		}
+	}
+	return checkSpacing(manifest, padding)
+}
+
+// checkSpacing fails if two sprites on the same page or layer are closer
+// than the padding: every pair must be at least padding.X pixels apart
+// horizontally or padding.Y pixels apart vertically. Since sprites are
+// axis-aligned rectangles, this also keeps the corners of sprites on
+// adjacent shelves at least that far apart diagonally. Sprites sharing a
+// region, as -dedup duplicates do, are not compared.
+func checkSpacing(manifest Manifest, padding Padding) error {
+	type placed struct {
+		name  string
+		layer int
+		rect  image.Rectangle
+	}
+	sprites := make([]placed, 0, len(manifest.Sprites))
+	for name, sprite := range manifest.Sprites {
+		layer := 0
+		if sprite.Layer != nil {
+			layer = *sprite.Layer
+		}
+		sprites = append(sprites, placed{name, layer, image.Rect(sprite.X, sprite.Y, sprite.X+sprite.W, sprite.Y+sprite.H)})
+	}
+	sort.Slice(sprites, func(i, j int) bool {
+		if sprites[i].rect.Min.X != sprites[j].rect.Min.X {
+			return sprites[i].rect.Min.X < sprites[j].rect.Min.X
+		}
+		return sprites[i].name < sprites[j].name
+	})
+	grow := image.Pt(padding.X, padding.Y)
+	for i, a := range sprites {
+		reach := image.Rectangle{Min: a.rect.Min, Max: a.rect.Max.Add(grow)}
+		// Sorted by left edge, so later sprites starting beyond a's padded
+		// right edge cannot be too close to it, nor can any after them.
+		for _, b := range sprites[i+1:] {
+			if b.rect.Min.X >= reach.Max.X {
+				break
+			}
+			if b.layer == a.layer && b.rect != a.rect && reach.Overlaps(image.Rectangle{Min: b.rect.Min, Max: b.rect.Max.Add(grow)}) {
+				return fmt.Errorf("sprites %s at %v and %s at %v are closer than the padding of %s", a.name, a.rect, b.name, b.rect, padding.String())
+			}
+		}
+	}
+	return nil
+}
+
+// verifyImage decodes every pixel of an atlas image and checks its size.
+func verifyImage(file string, width, height int) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	if b := img.Bounds(); b.Dx() != width || b.Dy() != height {
+		return fmt.Errorf("%s is %dx%d, but the manifest says %dx%d", file, b.Dx(), b.Dy(), width, height)
+	}
+	return nil
+}
+
+// verifyTrim checks a freshly trimmed rectangle against the source image it
+// was trimmed from: the recorded offset and size must match the kept pixels,
+// the offset, size and right and bottom margins must add up to the source
+// size, every pixel outside the kept area must be border, and every edge of
+// the kept area must touch a visible pixel. An off-by-one in the trim math
+// would otherwise silently misalign the sprite in the consumer.
+func verifyTrim(rect Rectangle, source image.Image, opts Options) error {
+	b, kept := source.Bounds(), rect.Image.Bounds()
+	if !kept.In(b) {
+		return fmt.Errorf("kept area %v lies outside the source %v", kept, b)
+	}
+	if offset := kept.Min.Sub(b.Min); rect.TrimOffset != offset {
+		return fmt.Errorf("recorded offset %v, but the kept pixels start at %v", rect.TrimOffset, offset)
+	}
+	if rect.Width != kept.Dx() || rect.Height != kept.Dy() {
+		return fmt.Errorf("recorded size %dx%d, but %dx%d pixels were kept", rect.Width, rect.Height, kept.Dx(), kept.Dy())
+	}
+	right, bottom := b.Max.X-kept.Max.X, b.Max.Y-kept.Max.Y
+	w := rect.TrimOffset.X + rect.Width + right
+	h := rect.TrimOffset.Y + rect.Height + bottom
+	if w != b.Dx() || h != b.Dy() || rect.SourceWidth != b.Dx() || rect.SourceHeight != b.Dy() {
+		return fmt.Errorf("reconstructed size %dx%d and recorded source size %dx%d differ from the %dx%d source",
+			w, h, rect.SourceWidth, rect.SourceHeight, b.Dx(), b.Dy())
+	}
+
+	isBorder := borderTest(source, opts.TrimSolid, opts.TrimTolerance, opts.TrimAlpha)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !image.Pt(x, y).In(kept) && !isBorder(x, y) {
+				return fmt.Errorf("visible pixel at %v was trimmed away", image.Pt(x, y).Sub(b.Min))
+			}
+		}
+	}
+	if opts.IncludeEmpty && kept.Size() == image.Pt(1, 1) && isBorder(kept.Min.X, kept.Min.Y) {
+		// The placeholder pixel of an image that is all border.
+		return nil
+	}
+	if opts.TrimSolid && allBorder(kept, isBorder) {
+		// An image of nothing but its border color only loses its
+		// transparent borders.
+		isBorder = borderTest(source, false, 0, opts.TrimAlpha)
+	}
+	edges := []image.Rectangle{
+		image.Rect(kept.Min.X, kept.Min.Y, kept.Max.X, kept.Min.Y+1),
+		image.Rect(kept.Min.X, kept.Max.Y-1, kept.Max.X, kept.Max.Y),
+		image.Rect(kept.Min.X, kept.Min.Y, kept.Min.X+1, kept.Max.Y),
+		image.Rect(kept.Max.X-1, kept.Min.Y, kept.Max.X, kept.Max.Y),
+	}
+	for _, edge := range edges {
+		if allBorder(edge, isBorder) {
+			return fmt.Errorf("kept edge %v is border that should have been trimmed", edge.Sub(b.Min))
+		}
+	}
+	return nil
+}
+
+// allBorder reports whether every pixel of r is border under isBorder.
+func allBorder(r image.Rectangle, isBorder func(x, y int) bool) bool {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if !isBorder(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
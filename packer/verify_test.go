@@ -0,0 +1,63 @@
+package packer
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// gap returns the distance between the closest points of two rectangles.
+func gap(a, b image.Rectangle) float64 {
+	dx := max(0, b.Min.X-a.Max.X, a.Min.X-b.Max.X)
+	dy := max(0, b.Min.Y-a.Max.Y, a.Min.Y-b.Max.Y)
+	return math.Hypot(float64(dx), float64(dy))
+}
+
+// TestDiagonalGap checks that sprites on adjacent shelves are at least the
+// padding apart even measured diagonally between their corners, and that
+// checkSpacing rejects a layout whose corners come closer.
+func TestDiagonalGap(t *testing.T) {
+	// Two shelves: the first ends with a short sprite, so the second
+	// shelf's sprites sit diagonally below the corners of the first's.
+	rectangles := []Rectangle{
+		{ID: 0, Name: "a.png", Width: 10, Height: 12},
+		{ID: 1, Name: "b.png", Width: 7, Height: 9},
+		{ID: 2, Name: "c.png", Width: 5, Height: 4},
+		{ID: 3, Name: "d.png", Width: 9, Height: 6},
+		{ID: 4, Name: "e.png", Width: 14, Height: 5},
+	}
+	padding := Padding{X: 3, Y: 2}
+	layout, err := planLayout(rectangles, Options{MaxWidth: 30, MaxHeight: 64, Padding: padding})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shelves := map[int]bool{}
+	for _, r := range layout.Placements {
+		shelves[r.Min.Y] = true
+	}
+	if len(shelves) < 2 {
+		t.Fatalf("all sprites on one shelf: %v", layout.Placements)
+	}
+
+	least := math.Min(float64(padding.X), float64(padding.Y))
+	for i, a := range rectangles {
+		for _, b := range rectangles[i+1:] {
+			ra, rb := layout.Placements[a.ID], layout.Placements[b.ID]
+			if d := gap(ra, rb); d < least {
+				t.Errorf("%s at %v and %s at %v are %.2f pixels apart, less than the padding", a.Name, ra, b.Name, rb, d)
+			}
+		}
+	}
+	if err := checkSpacing(buildManifest("atlas.png", rectangles, layout), padding); err != nil {
+		t.Errorf("checkSpacing rejected the packed layout: %v", err)
+	}
+
+	// Corners one pixel apart on each axis are too close diagonally.
+	corners := Manifest{Sprites: map[string]SpriteEntry{
+		"a.png": {X: 0, Y: 0, W: 4, H: 4},
+		"b.png": {X: 5, Y: 5, W: 4, H: 4},
+	}}
+	if err := checkSpacing(corners, padding); err == nil {
+		t.Error("checkSpacing accepted corners a pixel apart diagonally")
+	}
+}
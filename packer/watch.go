@@ -0,0 +1,131 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchInterval is how often -watch polls the images for changes. A rebuild
+// waits until a poll finds nothing new, so a burst of exports, such as an
+// editor saving many files, is packed once.
+const watchInterval = 500 * time.Millisecond
+
+// fileStamp is what -watch compares to tell that a file changed.
+type fileStamp struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// imagePath returns the path on disk of the image named name in opts.FS:
+// name within the -filedir directory or, when several are listed, within
+// the one whose base name its first element is.
+func imagePath(opts Options, name string) string {
+	dirs := SplitList(opts.FileDir)
+	if len(dirs) == 1 {
+		return filepath.Join(dirs[0], filepath.FromSlash(name))
+	}
+	first, rest, _ := strings.Cut(name, "/")
+	for _, dir := range dirs {
+		if filepath.Base(filepath.Clean(dir)) == first {
+			return filepath.Join(dir, filepath.FromSlash(rest))
+		}
+	}
+	return filepath.FromSlash(name)
+}
+
+// snapshotInputs returns the size and modification time of every image file
+// in opts.FS that walkImageFiles selects and of each of the input files
+// that exists, keyed by absolute path.
+func snapshotInputs(opts Options, inputs []string) (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+	err := walkImageFiles(opts.FS, opts, func(name string, d fs.DirEntry) error {
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		path, err := filepath.Abs(imagePath(opts, name))
+		if err != nil {
+			return err
+		}
+		stamps[path] = fileStamp{Size: info.Size(), ModTime: info.ModTime()}
+		return nil
+	})
+	for _, input := range inputs {
+		path, err := filepath.Abs(input)
+		if err != nil {
+			return nil, err
+		}
+		// A missing input is recorded as absent, so creating it again is a
+		// change.
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{Size: info.Size(), ModTime: info.ModTime()}
+		}
+	}
+	return stamps, err
+}
+
+// countChanges returns how many files were added, changed or removed between
+// two snapshots, leaving out the outputs the run itself has written.
+func countChanges(before, after map[string]fileStamp) int {
+	changes := 0
+	for path, stamp := range after {
+		if old, ok := before[path]; (!ok || old != stamp) && !isCommittedOutput(path) {
+			changes++
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok && !isCommittedOutput(path) {
+			changes++
+		}
+	}
+	return changes
+}
+
+// Watch runs build, then polls opts.FS and the input files and runs it again
+// each time images or inputs are added, changed or removed, once the changes
+// have settled, until ctx is done, as it is when the program is interrupted.
+// Each snapshot is taken before its build, so a file saved while the build
+// runs is picked up straight after it; the outputs the run writes are told
+// apart by their paths, so atlases written inside -filedir do not trigger
+// another build. Outputs are written atomically, so a consumer reloading
+// them never sees a partial atlas.
+func Watch(ctx context.Context, opts Options, inputs []string, build func(context.Context)) {
+	for {
+		stamps, err := snapshotInputs(opts, inputs)
+		if err != nil {
+			Warnf("watching %s: %v", opts.FileDir, err)
+		}
+		build(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("Watching %s for changes; press Ctrl-C to stop.\n", opts.FileDir)
+
+		changes := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchInterval):
+			}
+			next, err := snapshotInputs(opts, inputs)
+			if err != nil {
+				// The directory may be in the middle of being replaced;
+				// try again on the next poll.
+				continue
+			}
+			n := countChanges(stamps, next)
+			stamps = next
+			if n == 0 && changes > 0 {
+				break
+			}
+			changes += n
+		}
+		fmt.Printf("%d input changes detected; rebuilding.\n", changes)
+	}
+}
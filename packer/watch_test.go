@@ -0,0 +1,38 @@
+package packer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWatchSavedDuringBuild checks that an image saved while a build is
+// running triggers another build, while the atlas each build writes into
+// the watched directory does not.
+func TestWatchSavedDuringBuild(t *testing.T) {
+	dir := t.TempDir()
+	sprite := filepath.Join(dir, "a.png")
+	if err := os.WriteFile(sprite, pngFile(t, patterned(4, 4, 1)).Data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{FileDir: dir, FS: os.DirFS(dir), Recursive: true}
+	ctx, cancel := context.WithTimeout(context.Background(), 6*watchInterval)
+	defer cancel()
+
+	builds := 0
+	Watch(ctx, opts, nil, func(ctx context.Context) {
+		builds++
+		if err := writeOutput(filepath.Join(dir, "atlas.png"), pngFile(t, patterned(8, 8, uint8(builds))).Data, true); err != nil {
+			t.Error(err)
+		}
+		if builds == 1 {
+			if err := os.WriteFile(sprite, pngFile(t, patterned(5, 4, 2)).Data, 0o644); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	if builds != 2 {
+		t.Errorf("%d builds, want one more after the image saved during the first", builds)
+	}
+}
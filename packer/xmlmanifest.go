@@ -0,0 +1,116 @@
+package packer
+
+import (
+	"encoding/xml"
+	"sort"
+)
+
+// xmlAtlas is the root element of an XML manifest: the atlas image, its
+// size, page and -origin, a reserved element for a -reserve region, and one sprite
+// element per sprite.
+type xmlAtlas struct {
+	XMLName  xml.Name     `xml:"atlas"`
+	Image    string       `xml:"image,attr"`
+	Width    int          `xml:"width,attr"`
+	Height   int          `xml:"height,attr"`
+	Page     int          `xml:"page,attr"`
+	Origin   string       `xml:"origin,attr,omitempty"`
+	Reserved *xmlReserved `xml:"reserved"`
+	Sprites  []xmlSprite  `xml:"sprite"`
+}
+
+// xmlReserved is the region kept blank with -reserve, as in RegionEntry.
+type xmlReserved struct {
+	X int `xml:"x,attr"`
+	Y int `xml:"y,attr"`
+	W int `xml:"w,attr"`
+	H int `xml:"h,attr"`
+}
+
+// xmlSprite is the placement of one sprite in an XML manifest. Layer is
+// only set for texture arrays, rotated only for sprites packed turned, and
+// the trim child only for trimmed sprites.
+type xmlSprite struct {
+	Name    string   `xml:"name,attr"`
+	X       int      `xml:"x,attr"`
+	Y       int      `xml:"y,attr"`
+	W       int      `xml:"w,attr"`
+	H       int      `xml:"h,attr"`
+	Layer   *int     `xml:"layer,attr,omitempty"`
+	Rotated bool     `xml:"rotated,attr,omitempty"`
+	Trim    *xmlTrim `xml:"trim"`
+}
+
+// xmlTrim is where a trimmed sprite's pixels sat within its source image,
+// and the size of that image, as in TrimEntry.
+type xmlTrim struct {
+	X       int `xml:"x,attr"`
+	Y       int `xml:"y,attr"`
+	SourceW int `xml:"sourceW,attr"`
+	SourceH int `xml:"sourceH,attr"`
+}
+
+// xmlManifest renders the placements of a manifest as generic XML, for tools
+// that cannot easily parse JSON: an atlas element holding a sprite element
+// with the name, position and size of each sprite, in name order. Fields
+// beyond placement and trimming, such as pivots and polygons, are left out.
+func xmlManifest(manifest Manifest) ([]byte, error) {
+	names := make([]string, 0, len(manifest.Sprites))
+	for name := range manifest.Sprites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	atlas := xmlAtlas{
+		Image:   manifest.Image,
+		Width:   manifest.Width,
+		Height:  manifest.Height,
+		Page:    manifest.Page,
+		Origin:  manifest.Origin,
+		Sprites: make([]xmlSprite, 0, len(names)),
+	}
+	if r := manifest.Reserved; r != nil {
+		atlas.Reserved = &xmlReserved{X: r.X, Y: r.Y, W: r.W, H: r.H}
+	}
+	for _, name := range names {
+		entry := manifest.Sprites[name]
+		sprite := xmlSprite{Name: name, X: entry.X, Y: entry.Y, W: entry.W, H: entry.H, Layer: entry.Layer, Rotated: entry.Rotated}
+		if entry.Trim != nil {
+			sprite.Trim = &xmlTrim{X: entry.Trim.X, Y: entry.Trim.Y, SourceW: entry.Trim.SourceW, SourceH: entry.Trim.SourceH}
+		}
+		atlas.Sprites = append(atlas.Sprites, sprite)
+	}
+	data, err := xml.MarshalIndent(atlas, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(xml.Header), data...), '\n'), nil
+}
+
+// parseXMLManifest reads a manifest written by xmlManifest back into a
+// Manifest holding the fields it records.
+func parseXMLManifest(data []byte) (Manifest, error) {
+	var atlas xmlAtlas
+	if err := xml.Unmarshal(data, &atlas); err != nil {
+		return Manifest{}, err
+	}
+	manifest := Manifest{
+		Image:   atlas.Image,
+		Width:   atlas.Width,
+		Height:  atlas.Height,
+		Page:    atlas.Page,
+		Origin:  atlas.Origin,
+		Sprites: make(map[string]SpriteEntry, len(atlas.Sprites)),
+	}
+	if r := atlas.Reserved; r != nil {
+		manifest.Reserved = &RegionEntry{X: r.X, Y: r.Y, W: r.W, H: r.H}
+	}
+	for _, sprite := range atlas.Sprites {
+		entry := SpriteEntry{X: sprite.X, Y: sprite.Y, W: sprite.W, H: sprite.H, Layer: sprite.Layer, Rotated: sprite.Rotated}
+		if sprite.Trim != nil {
+			entry.Trim = &TrimEntry{X: sprite.Trim.X, Y: sprite.Trim.Y, SourceW: sprite.Trim.SourceW, SourceH: sprite.Trim.SourceH}
+		}
+		manifest.Sprites[sprite.Name] = entry
+	}
+	return manifest, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiles starts writing a CPU profile of the whole run to cpuFile
+// and returns a function that stops it and then writes a heap profile to
+// memFile, covering every allocation made since the program started. An
+// empty filename disables that profile. Samples taken while loading
+// sprites, packing them, drawing the atlas and saving its images carry a
+// "phase" label of "load", "pack", "draw" or "save", which pprof's -tags
+// option breaks the profile down by and -tagfocus filters on.
+func startProfiles(cpuFile, memFile string) (func() error, error) {
+	var cpu *os.File
+	if cpuFile != "" {
+		f, err := os.Create(cpuFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		cpu = f
+	}
+	return func() error {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				return fmt.Errorf("writing CPU profile: %w", err)
+			}
+		}
+		if memFile == "" {
+			return nil
+		}
+		f, err := os.Create(memFile)
+		if err != nil {
+			return fmt.Errorf("writing memory profile: %w", err)
+		}
+		defer f.Close()
+		// Collect garbage first so the in-use figures are up to date.
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			return fmt.Errorf("writing memory profile: %w", err)
+		}
+		return f.Close()
+	}, nil
+}
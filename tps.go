@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"texturepacker/packer"
+)
+
+// tpsValue is a value in a TexturePacker .tps settings file. Its kind is the
+// name of the XML element holding it, such as "int", "enum", "true" or
+// "struct"; scalars carry their text, and structs and sizes their fields,
+// keyed by the <key> element before each.
+type tpsValue struct {
+	Kind   string
+	Text   string
+	Fields map[string]tpsValue
+}
+
+// field returns the value of a field of a struct, and an empty value if
+// there is no such field.
+func (v tpsValue) field(name string) tpsValue {
+	return v.Fields[name]
+}
+
+// applyTPSFile reads the subset of a TexturePacker .tps settings file that
+// this tool has options for and sets the corresponding flags that were not
+// already given, on the command line or by -config:
+//
+//   - shapePadding sets -padding and borderPadding -borderpadding,
+//   - the width and height of maxTextureSize set -maxwidth and -maxheight,
+//   - the trimMode of globalSpriteSettings sets -trim, and also -polygon for
+//     polygon trimming,
+//   - allowRotation sets -allowrotation,
+//   - the extrude of globalSpriteSettings sets -extrude, and widens
+//     -padding by twice as much, since TexturePacker keeps shapePadding
+//     between the extruded borders rather than extruding into it.
+//
+// The names of all other settings are listed in a single warning, which is
+// not an error.
+func applyTPSFile(flags *flag.FlagSet, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	settings, err := parseTPS(xml.NewDecoder(f))
+	if err != nil {
+		return fmt.Errorf("failed to parse TexturePacker settings %s: %w", filename, err)
+	}
+
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	keys := make([]string, 0, len(settings.Fields))
+	for key := range settings.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make(map[string]string)
+	var ignored []string
+	for _, key := range keys {
+		value := settings.Fields[key]
+		switch key {
+		case "shapePadding":
+			values["padding"] = value.Text
+		case "borderPadding":
+			values["borderpadding"] = value.Text
+		case "maxTextureSize":
+			values["maxwidth"] = value.field("width").Text
+			values["maxheight"] = value.field("height").Text
+		case "allowRotation":
+			values["allowrotation"] = strconv.FormatBool(value.Kind == "true")
+		case "globalSpriteSettings":
+			values["extrude"] = value.field("extrude").Text
+			switch mode := value.field("trimMode").Text; mode {
+			case "":
+			case "None":
+				values["trim"] = "false"
+			case "Polygon":
+				values["trim"], values["polygon"] = "true", "true"
+			default:
+				values["trim"] = "true"
+			}
+		default:
+			ignored = append(ignored, key)
+		}
+	}
+	if extrude, err := strconv.Atoi(values["extrude"]); err == nil && extrude > 0 && !explicit["extrude"] {
+		// An unparsable shapePadding is left for flags.Set to report.
+		var shapePadding int
+		if values["padding"] != "" {
+			shapePadding, err = strconv.Atoi(values["padding"])
+		}
+		if err == nil {
+			values["padding"] = strconv.Itoa(shapePadding + 2*extrude)
+		}
+	}
+	if len(ignored) > 0 {
+		packer.Warnf("ignoring unsupported TexturePacker settings: %s", strings.Join(ignored, ", "))
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if explicit[name] || values[name] == "" {
+			continue
+		}
+		if err := flags.Set(name, values[name]); err != nil {
+			return fmt.Errorf("TexturePacker settings %s: option %q: %w", filename, name, err)
+		}
+	}
+	return nil
+}
+
+// parseTPS decodes the Settings struct at the root of a .tps file.
+func parseTPS(d *xml.Decoder) (tpsValue, error) {
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return tpsValue{}, errNoTPSSettings
+		}
+		if err != nil {
+			return tpsValue{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "struct" {
+			return parseTPSValue(d, start)
+		}
+	}
+}
+
+// parseTPSValue decodes the value begun by start, up to its end element.
+// Every <key> element inside it names the value that follows.
+func parseTPSValue(d *xml.Decoder, start xml.StartElement) (tpsValue, error) {
+	value := tpsValue{Kind: start.Name.Local}
+	var text strings.Builder
+	key, keyed := "", false
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return tpsValue{}, err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			text.Write(tok)
+		case xml.StartElement:
+			if tok.Name.Local == "key" {
+				var name string
+				if err := d.DecodeElement(&name, &tok); err != nil {
+					return tpsValue{}, err
+				}
+				key, keyed = name, true
+				continue
+			}
+			child, err := parseTPSValue(d, tok)
+			if err != nil {
+				return tpsValue{}, err
+			}
+			if keyed {
+				if value.Fields == nil {
+					value.Fields = make(map[string]tpsValue)
+				}
+				value.Fields[key] = child
+				keyed = false
+			}
+		case xml.EndElement:
+			if value.Fields == nil {
+				value.Text = strings.TrimSpace(text.String())
+			}
+			return value, nil
+		}
+	}
+}
+
+// errNoTPSSettings is returned for files without a settings struct.
+var errNoTPSSettings = errors.New("no settings found")